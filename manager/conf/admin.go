@@ -0,0 +1,21 @@
+package conf
+
+// Machine-wide policies, such as which configurations may be activated and
+// which hook commands may run, must not be stored under RootDirectory, which
+// belongs to the user they restrict. They are read from a location that only
+// administrators may write to instead:
+//
+// On Windows, each one is a REG_SZ value of the same name, such as
+// policy.json, under HKEY_LOCAL_MACHINE\SOFTWARE\Policies\WireGuard, where
+// group policy can deploy it.
+//
+// Elsewhere, each one is a file in /etc/wireguard, which, like the file
+// itself, must be owned by root and writable by nobody else.
+
+// ReadAdminFile returns the contents of the machine-wide policy called name.
+// An error satisfying os.IsNotExist means that the administrator has not
+// configured it. Any other error means that it cannot be trusted or read,
+// and callers should refuse whatever it would have allowed.
+func ReadAdminFile(name string) ([]byte, error) {
+	return readAdminFile(name)
+}
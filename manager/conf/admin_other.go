@@ -0,0 +1,48 @@
+// +build !windows
+
+package conf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const adminDirectory = "/etc/wireguard"
+
+// checkAdminOwned refuses files and directories that anyone but root could have written.
+func checkAdminOwned(path string, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Uid != 0 || info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%s must be owned by root and writable only by root", path)
+	}
+	return nil
+}
+
+func readAdminFile(name string) ([]byte, error) {
+	// Whoever can write to the directory can remove the file, so it is checked even when the file is missing.
+	info, err := os.Stat(adminDirectory)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkAdminOwned(adminDirectory, info); err != nil {
+		return nil, err
+	}
+
+	filename := filepath.Join(adminDirectory, name)
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err = file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if err = checkAdminOwned(filename, info); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(file)
+}
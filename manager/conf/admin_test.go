@@ -0,0 +1,47 @@
+// +build !windows
+
+package conf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckAdminOwned(test *testing.T) {
+	dir, err := ioutil.TempDir("", "wireguard-admin")
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "policy.json")
+	if err = ioutil.WriteFile(filename, []byte("{}"), 0644); err != nil {
+		test.Fatal(err)
+	}
+
+	if err = os.Chmod(filename, 0666); err != nil {
+		test.Fatal(err)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if checkAdminOwned(filename, info) == nil {
+		test.Error("Expected a file writable by everyone to be refused")
+	}
+
+	if err = os.Chmod(filename, 0644); err != nil {
+		test.Fatal(err)
+	}
+	info, err = os.Stat(filename)
+	if err != nil {
+		test.Fatal(err)
+	}
+	err = checkAdminOwned(filename, info)
+	if os.Getuid() == 0 && err != nil {
+		test.Errorf("Expected a file owned and written only by root to be accepted, got %v", err)
+	} else if os.Getuid() != 0 && err == nil {
+		test.Error("Expected a file owned by another user to be refused")
+	}
+}
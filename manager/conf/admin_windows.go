@@ -0,0 +1,40 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const adminKey = `SOFTWARE\Policies\WireGuard`
+
+func readAdminFile(name string) ([]byte, error) {
+	var key syscall.Handle
+	err := syscall.RegOpenKeyEx(syscall.HKEY_LOCAL_MACHINE, syscall.StringToUTF16Ptr(adminKey), 0, syscall.KEY_READ, &key)
+	if err == syscall.ERROR_FILE_NOT_FOUND {
+		return nil, os.ErrNotExist
+	} else if err != nil {
+		return nil, fmt.Errorf("Unable to open HKEY_LOCAL_MACHINE\\%s: %v", adminKey, err)
+	}
+	defer syscall.RegCloseKey(key)
+
+	valueName := syscall.StringToUTF16Ptr(name)
+	var valueType, size uint32
+	err = syscall.RegQueryValueEx(key, valueName, nil, &valueType, nil, &size)
+	if err == syscall.ERROR_FILE_NOT_FOUND {
+		return nil, os.ErrNotExist
+	} else if err != nil {
+		return nil, fmt.Errorf("Unable to read %s from HKEY_LOCAL_MACHINE\\%s: %v", name, adminKey, err)
+	}
+	if valueType != syscall.REG_SZ && valueType != syscall.REG_EXPAND_SZ {
+		return nil, fmt.Errorf("Value %s in HKEY_LOCAL_MACHINE\\%s must be a string", name, adminKey)
+	}
+	buffer := make([]uint16, size/2+1)
+	size = uint32(len(buffer) * 2)
+	err = syscall.RegQueryValueEx(key, valueName, nil, &valueType, (*byte)(unsafe.Pointer(&buffer[0])), &size)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read %s from HKEY_LOCAL_MACHINE\\%s: %v", name, adminKey, err)
+	}
+	return []byte(syscall.UTF16ToString(buffer)), nil
+}
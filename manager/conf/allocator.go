@@ -0,0 +1,101 @@
+package conf
+
+import (
+	"errors"
+)
+
+// Allocator picks tunnel addresses for new peers of a server, one from each
+// of the subnets in the server's Interface.Addresses, typically yielding an
+// IPv4 and IPv6 pair.
+type Allocator struct {
+	pools []*AddressPool
+}
+
+func NewAllocator(server *Config) *Allocator {
+	a := &Allocator{}
+	for _, address := range server.Interface.Addresses {
+		pool := NewAddressPool(address)
+		pool.ReserveAddress(address.IP)
+		for _, peer := range server.Peers {
+			for _, allowedIP := range peer.AllowedIPs {
+				pool.ReserveRange(allowedIP)
+			}
+		}
+		a.pools = append(a.pools, pool)
+	}
+	return a
+}
+
+// Next returns one host address, as a /32 or /128, from each subnet. If any
+// subnet is exhausted, a *PoolExhaustedError is returned.
+func (a *Allocator) Next() ([]IPCidr, error) {
+	if len(a.pools) == 0 {
+		return nil, errors.New("Server has no interface addresses to allocate from")
+	}
+	var addresses []IPCidr
+	for _, pool := range a.pools {
+		address, err := pool.Next()
+		if err != nil {
+			return nil, err
+		}
+		address.Cidr = address.Bits()
+		addresses = append(addresses, *address)
+	}
+	return addresses, nil
+}
+
+// AddClient creates a configuration for a new client of server, with fresh
+// keys and the next free addresses, and appends the matching peer to server.
+// The client connects to serverEndpoint, whose port defaults to the server's
+// listen port, and routes the server's subnets through the tunnel.
+func (server *Config) AddClient(name string, serverEndpoint Endpoint) (Peer, *Config, error) {
+	tunnelName, err := NewTunnelName(name)
+	if err != nil {
+		return Peer{}, nil, err
+	}
+	if serverEndpoint.Port == 0 {
+		serverEndpoint.Port = server.Interface.ListenPort
+	}
+	if serverEndpoint.IsEmpty() || serverEndpoint.Port == 0 {
+		return Peer{}, nil, errors.New("Server endpoint must have a host and a port")
+	}
+	addresses, err := NewAllocator(server).Next()
+	if err != nil {
+		return Peer{}, nil, err
+	}
+	privateKey, err := NewPrivateKey()
+	if err != nil {
+		return Peer{}, nil, err
+	}
+	presharedKey, err := NewPresharedKey()
+	if err != nil {
+		return Peer{}, nil, err
+	}
+
+	client := &Config{
+		Name: tunnelName,
+		Interface: Interface{
+			PrivateKey: *privateKey,
+		},
+	}
+	serverPeer := Peer{
+		PublicKey:    *privateKey.Public(),
+		PresharedKey: *presharedKey,
+		AllowedIPs:   addresses,
+	}
+	clientPeer := Peer{
+		PublicKey:    *server.Interface.PrivateKey.Public(),
+		PresharedKey: *presharedKey,
+		Endpoint:     serverEndpoint,
+	}
+	for i, address := range addresses {
+		subnet := server.Interface.Addresses[i]
+		address.Cidr = subnet.Cidr
+		client.Interface.Addresses = append(client.Interface.Addresses, address)
+		subnet.MaskSelf()
+		clientPeer.AllowedIPs = append(clientPeer.AllowedIPs, subnet)
+	}
+	client.Peers = []Peer{clientPeer}
+	server.Peers = append(server.Peers, serverPeer)
+	return serverPeer, client, nil
+}
@@ -0,0 +1,80 @@
+package conf
+
+import (
+	"testing"
+)
+
+const testServer = `[Interface]
+PrivateKey = cJM9wXUVXc0fa/t5b/Lm0BHNx6jh5UiTLsO+oJhyQUU=
+Address = 10.0.0.1/29, fd00::1/125
+ListenPort = 51820
+
+[Peer]
+PublicKey = JRI8Xc0zKP9kXk8qP84NdUQA04h6DLfFbwJn4g+/PFs=
+AllowedIPs = 10.0.0.2/32, fd00::2/128
+
+[Peer]
+PublicKey = QCssGR6joqOIEQW6j2AR7nMcXJIVI9E9PCcbwrVXhU8=
+AllowedIPs = 10.0.0.4/31, fd00::3/128, 192.168.1.0/24
+`
+
+func TestAllocator(test *testing.T) {
+	server, err := FromWgQuick(testServer, "hub")
+	if err != nil {
+		test.Fatal(err)
+	}
+	allocator := NewAllocator(server)
+	expected := [][]string{
+		{"10.0.0.3/32", "fd00::4/128"},
+		{"10.0.0.6/32", "fd00::5/128"},
+	}
+	for _, e := range expected {
+		addresses, err := allocator.Next()
+		if err != nil {
+			test.Fatal(err)
+		}
+		if len(addresses) != 2 || addresses[0].String() != e[0] || addresses[1].String() != e[1] {
+			test.Errorf("Expected %v, got %v", e, addresses)
+		}
+	}
+	// 10.0.0.7 is the broadcast address of the /29.
+	_, err = allocator.Next()
+	if _, ok := err.(*PoolExhaustedError); !ok {
+		test.Errorf("Expected PoolExhaustedError, got %v", err)
+	}
+}
+
+func TestAddClient(test *testing.T) {
+	server, err := FromWgQuick(testServer, "hub")
+	if err != nil {
+		test.Fatal(err)
+	}
+	peer, client, err := server.AddClient("laptop", Endpoint{Host: "vpn.example.com"})
+	if err != nil {
+		test.Fatal(err)
+	}
+	if len(server.Peers) != 3 || server.Peers[2].PublicKey != peer.PublicKey {
+		test.Fatal("Expected the new peer to be appended to the server")
+	}
+	if peer.PublicKey != *client.Interface.PrivateKey.Public() {
+		test.Error("Server peer does not match client key")
+	}
+	if client.Peers[0].PublicKey != *server.Interface.PrivateKey.Public() {
+		test.Error("Client peer does not match server key")
+	}
+	if peer.PresharedKey.IsZero() || peer.PresharedKey != client.Peers[0].PresharedKey {
+		test.Error("Preshared keys do not match")
+	}
+	if e := client.Peers[0].Endpoint.String(); e != "vpn.example.com:51820" {
+		test.Errorf("Unexpected endpoint %s", e)
+	}
+	if a := client.Interface.Addresses; len(a) != 2 || a[0].String() != "10.0.0.3/29" || a[1].String() != "fd00::4/125" {
+		test.Errorf("Unexpected client addresses %v", a)
+	}
+	if a := client.Peers[0].AllowedIPs; len(a) != 2 || a[0].String() != "10.0.0.0/29" || a[1].String() != "fd00::/125" {
+		test.Errorf("Unexpected client allowed IPs %v", a)
+	}
+	if _, err = FromWgQuick(client.ToWgQuick(), "laptop"); err != nil {
+		test.Errorf("Client config does not round trip: %v", err)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"net"
 	"strings"
 	"time"
+
+	"git.zx2c4.com/wireguard-windows/manager/humanize"
 )
 
 const KeyLength = 32
@@ -26,7 +28,7 @@ type Key [KeyLength]byte
 type HandshakeTime time.Duration
 
 type Config struct {
-	Name      string
+	Name      TunnelName
 	Interface Interface
 	Peers     []Peer
 }
@@ -37,6 +39,13 @@ type Interface struct {
 	ListenPort uint16
 	Mtu        uint16
 	Dns        []net.IP
+
+	// Commands to run around bringing the tunnel up and down, in order.
+	// They only run when the service's hook policy allows them.
+	PreUp    []string
+	PostUp   []string
+	PreDown  []string
+	PostDown []string
 }
 
 type Peer struct {
@@ -79,51 +88,11 @@ func (k *Key) IsZero() bool {
 	return subtle.ConstantTimeCompare(zeros[:], k[:]) == 1
 }
 
-func formatInterval(i int64, n string, l int) string {
-	r := ""
-	if l > 0 {
-		r += ", "
-	}
-	r += fmt.Sprintf("%d %s", i, n)
-	if i != 1 {
-		r += "s"
-	}
-	return r
+// Time returns the handshake time as an absolute time.
+func (t HandshakeTime) Time() time.Time {
+	return time.Unix(0, 0).Add(time.Duration(t))
 }
 
 func (t HandshakeTime) String() string {
-	u := time.Unix(0, 0).Add(time.Duration(t)).Unix()
-	n := time.Now().Unix()
-	if u == n {
-		return "Now"
-	} else if u > n {
-		return "System clock wound backward!"
-	}
-	left := n - u
-	years := left / (365 * 24 * 60 * 60)
-	left = left % (365 * 24 * 60 * 60)
-	days := left / (24 * 60 * 60)
-	left = left % (24 * 60 * 60)
-	hours := left / (60 * 60)
-	left = left % (60 * 60)
-	minutes := left / 60
-	seconds := left % 60
-	s := ""
-	if years > 0 {
-		s += formatInterval(years, "year", len(s))
-	}
-	if days > 0 {
-		s += formatInterval(days, "day", len(s))
-	}
-	if hours > 0 {
-		s += formatInterval(hours, "hour", len(s))
-	}
-	if minutes > 0 {
-		s += formatInterval(minutes, "minute", len(s))
-	}
-	if seconds > 0 {
-		s += formatInterval(seconds, "second", len(s))
-	}
-	s += " ago"
-	return s
+	return humanize.Ago(t.Time())
 }
@@ -0,0 +1,388 @@
+package conf
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ipInt is an address as a 128-bit integer; IPv4 addresses only use the low 32 bits.
+type ipInt struct {
+	hi, lo uint64
+}
+
+func ipIntFromIP(ip net.IP) ipInt {
+	if v4 := ip.To4(); v4 != nil {
+		return ipInt{0, uint64(binary.BigEndian.Uint32(v4))}
+	}
+	v6 := ip.To16()
+	return ipInt{binary.BigEndian.Uint64(v6[:8]), binary.BigEndian.Uint64(v6[8:])}
+}
+
+func (i ipInt) toIP(bits uint8) net.IP {
+	if bits == 32 {
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, uint32(i.lo))
+		return ip
+	}
+	ip := make(net.IP, net.IPv6len)
+	binary.BigEndian.PutUint64(ip[:8], i.hi)
+	binary.BigEndian.PutUint64(ip[8:], i.lo)
+	return ip
+}
+
+func (i ipInt) less(j ipInt) bool {
+	return i.hi < j.hi || (i.hi == j.hi && i.lo < j.lo)
+}
+
+func (i ipInt) addOne() ipInt {
+	if i.lo == ^uint64(0) {
+		return ipInt{i.hi + 1, 0}
+	}
+	return ipInt{i.hi, i.lo + 1}
+}
+
+func (i ipInt) subOne() ipInt {
+	if i.lo == 0 {
+		return ipInt{i.hi - 1, ^uint64(0)}
+	}
+	return ipInt{i.hi, i.lo - 1}
+}
+
+// hostMask returns the integer with the low n bits set.
+func hostMask(n uint) ipInt {
+	switch {
+	case n == 0:
+		return ipInt{}
+	case n < 64:
+		return ipInt{0, 1<<n - 1}
+	case n == 64:
+		return ipInt{0, ^uint64(0)}
+	case n < 128:
+		return ipInt{1<<(n-64) - 1, ^uint64(0)}
+	}
+	return ipInt{^uint64(0), ^uint64(0)}
+}
+
+func (i ipInt) trailingZeros() uint {
+	if i.lo != 0 {
+		return uint(bits.TrailingZeros64(i.lo))
+	}
+	return 64 + uint(bits.TrailingZeros64(i.hi))
+}
+
+type ipRange struct {
+	first, last ipInt
+}
+
+// IPSet is a set of IPv4 and IPv6 addresses, kept as sorted, non-overlapping,
+// non-adjacent ranges of each family. The zero value is the empty set.
+type IPSet struct {
+	v4, v6 []ipRange
+}
+
+func NewIPSet(prefixes ...IPCidr) *IPSet {
+	s := &IPSet{}
+	for _, prefix := range prefixes {
+		s.Add(prefix)
+	}
+	return s
+}
+
+func prefixRange(prefix IPCidr) ipRange {
+	first := ipIntFromIP(prefix.IPNet().IP)
+	mask := hostMask(uint(prefix.Bits() - prefix.Cidr))
+	return ipRange{first, ipInt{first.hi | mask.hi, first.lo | mask.lo}}
+}
+
+func (s *IPSet) family(bits uint8) *[]ipRange {
+	if bits == 32 {
+		return &s.v4
+	}
+	return &s.v6
+}
+
+func (s *IPSet) Add(prefix IPCidr) {
+	ranges := s.family(prefix.Bits())
+	*ranges = normalizeRanges(append(*ranges, prefixRange(prefix)))
+}
+
+func normalizeRanges(ranges []ipRange) []ipRange {
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].first.less(ranges[j].first)
+	})
+	var result []ipRange
+	for _, r := range ranges {
+		if len(result) > 0 {
+			last := &result[len(result)-1]
+			// Merge overlapping and adjacent ranges, taking care not to overflow past the all-ones address.
+			if !last.last.less(r.first) || last.last.addOne() == r.first {
+				if last.last.less(r.last) {
+					last.last = r.last
+				}
+				continue
+			}
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+func (s *IPSet) Contains(ip net.IP) bool {
+	i := ipIntFromIP(ip)
+	ranges := s.v6
+	if ip.To4() != nil {
+		ranges = s.v4
+	}
+	for _, r := range ranges {
+		if !i.less(r.first) && !r.last.less(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *IPSet) IsEmpty() bool {
+	return len(s.v4) == 0 && len(s.v6) == 0
+}
+
+func (s *IPSet) Union(other *IPSet) *IPSet {
+	return &IPSet{
+		v4: normalizeRanges(append(append([]ipRange{}, s.v4...), other.v4...)),
+		v6: normalizeRanges(append(append([]ipRange{}, s.v6...), other.v6...)),
+	}
+}
+
+func intersectRanges(a, b []ipRange) []ipRange {
+	var result []ipRange
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		first, last := a[i].first, a[i].last
+		if first.less(b[j].first) {
+			first = b[j].first
+		}
+		if b[j].last.less(last) {
+			last = b[j].last
+		}
+		if !last.less(first) {
+			result = append(result, ipRange{first, last})
+		}
+		if a[i].last.less(b[j].last) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return result
+}
+
+func (s *IPSet) Intersect(other *IPSet) *IPSet {
+	return &IPSet{
+		v4: intersectRanges(s.v4, other.v4),
+		v6: intersectRanges(s.v6, other.v6),
+	}
+}
+
+func subtractRanges(a, b []ipRange) []ipRange {
+	var result []ipRange
+	j := 0
+	for _, r := range a {
+		for j < len(b) && b[j].last.less(r.first) {
+			j++
+		}
+		current := r
+		empty := false
+		for k := j; k < len(b) && !current.last.less(b[k].first); k++ {
+			if current.first.less(b[k].first) {
+				result = append(result, ipRange{current.first, b[k].first.subOne()})
+			}
+			if !b[k].last.less(current.last) {
+				empty = true
+				break
+			}
+			current.first = b[k].last.addOne()
+		}
+		if !empty {
+			result = append(result, current)
+		}
+	}
+	return result
+}
+
+func (s *IPSet) Subtract(other *IPSet) *IPSet {
+	return &IPSet{
+		v4: subtractRanges(s.v4, other.v4),
+		v6: subtractRanges(s.v6, other.v6),
+	}
+}
+
+func rangePrefixes(r ipRange, bits uint8) []IPCidr {
+	var result []IPCidr
+	for {
+		// Take the largest block that is aligned at r.first and does not extend past r.last.
+		size := r.first.trailingZeros()
+		if size > uint(bits) {
+			size = uint(bits)
+		}
+		for size > 0 {
+			mask := hostMask(size)
+			end := ipInt{r.first.hi | mask.hi, r.first.lo | mask.lo}
+			if !r.last.less(end) {
+				break
+			}
+			size--
+		}
+		result = append(result, IPCidr{r.first.toIP(bits), bits - uint8(size)})
+		mask := hostMask(size)
+		end := ipInt{r.first.hi | mask.hi, r.first.lo | mask.lo}
+		if end == r.last {
+			return result
+		}
+		r.first = end.addOne()
+	}
+}
+
+// Prefixes returns the smallest list of prefixes covering exactly the addresses in the set, IPv4 first.
+func (s *IPSet) Prefixes() []IPCidr {
+	var result []IPCidr
+	for _, r := range s.v4 {
+		result = append(result, rangePrefixes(r, 32)...)
+	}
+	for _, r := range s.v6 {
+		result = append(result, rangePrefixes(r, 128)...)
+	}
+	return result
+}
+
+func mustParseIPCidrs(prefixes ...string) []IPCidr {
+	var result []IPCidr
+	for _, s := range prefixes {
+		p, err := parseIPCidr(s)
+		if err != nil {
+			panic(err)
+		}
+		result = append(result, *p)
+	}
+	return result
+}
+
+// PrivateIPv4Ranges are the networks reserved for private use by RFC 1918.
+var PrivateIPv4Ranges = mustParseIPCidrs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16")
+
+// ExcludePrivateIPs removes the private ranges from the AllowedIPs of a peer
+// that is the IPv4 default route, so that a full tunnel leaves the local
+// network reachable, and reports whether it did so. AllowedIPs is rewritten
+// as a minimal list of prefixes.
+func (peer *Peer) ExcludePrivateIPs() bool {
+	allowed := NewIPSet(peer.AllowedIPs...)
+	defaultRoute := NewIPSet(mustParseIPCidrs("0.0.0.0/0")...)
+	if !defaultRoute.Subtract(allowed).IsEmpty() {
+		return false
+	}
+	peer.AllowedIPs = allowed.Subtract(NewIPSet(PrivateIPv4Ranges...)).Prefixes()
+	return true
+}
+
+// nonPublicIPv4Ranges are the networks that are not routed on the internet:
+// private, shared, loopback, link-local, multicast and reserved addresses.
+var nonPublicIPv4Ranges = mustParseIPCidrs("0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12", "192.168.0.0/16", "224.0.0.0/4", "240.0.0.0/4")
+
+var (
+	publicIPv4 = NewIPSet(mustParseIPCidrs("0.0.0.0/0")...).Subtract(NewIPSet(nonPublicIPv4Ranges...))
+	publicIPv6 = NewIPSet(mustParseIPCidrs("2000::/3")...)
+)
+
+// IsFullTunnel reports whether prefixes cover every public IPv4 or every
+// public IPv6 address, so that all internet traffic of that family goes
+// through the tunnel, however the prefixes split it up and whichever private
+// ranges they leave out.
+func IsFullTunnel(prefixes []IPCidr) bool {
+	allowed := NewIPSet(prefixes...)
+	return publicIPv4.Subtract(allowed).IsEmpty() || publicIPv6.Subtract(allowed).IsEmpty()
+}
+
+// ExcludePrivateIPsInWgQuick applies ExcludePrivateIPs to every peer of the
+// wg-quick text s. Only the AllowedIPs lines of the peers it changes are
+// rewritten, so comments and formatting elsewhere are kept. A peer's list
+// is written on its first AllowedIPs line and any further ones are removed.
+func ExcludePrivateIPsInWgQuick(s string) (string, error) {
+	type peerLines struct {
+		lines      []int
+		allowedIPs []IPCidr
+	}
+	var peers []*peerLines
+	var peer *peerLines
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		code := line
+		if pound := strings.IndexByte(code, '#'); pound >= 0 {
+			code = code[:pound]
+		}
+		code = strings.TrimSpace(code)
+		switch strings.ToLower(code) {
+		case "[interface]":
+			peer = nil
+			continue
+		case "[peer]":
+			peer = &peerLines{}
+			peers = append(peers, peer)
+			continue
+		}
+		equals := strings.IndexByte(code, '=')
+		if peer == nil || equals < 0 || strings.ToLower(strings.TrimSpace(code[:equals])) != "allowedips" {
+			continue
+		}
+		addresses, err := splitList(code[equals+1:])
+		if err != nil {
+			return "", err
+		}
+		for _, address := range addresses {
+			a, err := parseIPCidr(address)
+			if err != nil {
+				return "", err
+			}
+			peer.allowedIPs = append(peer.allowedIPs, *a)
+		}
+		peer.lines = append(peer.lines, i)
+	}
+
+	removed := make(map[int]bool)
+	for _, p := range peers {
+		rewritten := Peer{AllowedIPs: p.allowedIPs}
+		if !rewritten.ExcludePrivateIPs() {
+			continue
+		}
+		addrStrings := make([]string, len(rewritten.AllowedIPs))
+		for i, address := range rewritten.AllowedIPs {
+			addrStrings[i] = address.String()
+		}
+		first := p.lines[0]
+		lines[first] = replaceValue(lines[first], strings.Join(addrStrings, ", "))
+		for _, i := range p.lines[1:] {
+			removed[i] = true
+		}
+	}
+	output := lines[:0]
+	for i, line := range lines {
+		if !removed[i] {
+			output = append(output, line)
+		}
+	}
+	return strings.Join(output, "\n"), nil
+}
+
+// replaceValue replaces the value of the key = value line, keeping the spacing around it and any comment.
+func replaceValue(line string, value string) string {
+	code := line
+	if pound := strings.IndexByte(code, '#'); pound >= 0 {
+		code = code[:pound]
+	}
+	equals := strings.IndexByte(code, '=')
+	start := len(code) - len(strings.TrimLeft(code[equals+1:], " \t"))
+	end := len(strings.TrimRight(code, " \t\r"))
+	if end < start {
+		end = start
+	}
+	return line[:start] + value + line[end:]
+}
@@ -0,0 +1,135 @@
+package conf
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func prefixesString(prefixes []IPCidr) string {
+	var s []string
+	for _, p := range prefixes {
+		s = append(s, p.String())
+	}
+	return fmt.Sprint(s)
+}
+
+func TestIPSetArithmetic(test *testing.T) {
+	all := NewIPSet(mustParseIPCidrs("0.0.0.0/0", "::/0")...)
+	lan := NewIPSet(mustParseIPCidrs("192.168.0.0/16")...)
+
+	everythingButLan := all.Subtract(lan)
+	expected := "[0.0.0.0/1 128.0.0.0/2 192.0.0.0/9 192.128.0.0/11 192.160.0.0/13 192.169.0.0/16 192.170.0.0/15 192.172.0.0/14 192.176.0.0/12 192.192.0.0/10 193.0.0.0/8 194.0.0.0/7 196.0.0.0/6 200.0.0.0/5 208.0.0.0/4 224.0.0.0/3 ::/0]"
+	if s := prefixesString(everythingButLan.Prefixes()); s != expected {
+		test.Errorf("Unexpected subtraction result %s", s)
+	}
+	if everythingButLan.Contains(net.ParseIP("192.168.1.1")) || !everythingButLan.Contains(net.ParseIP("192.169.0.1")) || !everythingButLan.Contains(net.ParseIP("2001:db8::1")) {
+		test.Error("Unexpected membership after subtraction")
+	}
+	if s := prefixesString(everythingButLan.Union(lan).Prefixes()); s != "[0.0.0.0/0 ::/0]" {
+		test.Errorf("Unexpected union result %s", s)
+	}
+	if s := prefixesString(everythingButLan.Intersect(lan).Prefixes()); s != "[]" {
+		test.Errorf("Unexpected intersection result %s", s)
+	}
+
+	a := NewIPSet(mustParseIPCidrs("10.0.0.0/24", "fd00::/64", "10.0.2.0/24")...)
+	b := NewIPSet(mustParseIPCidrs("10.0.0.128/25", "10.0.1.0/24", "fd00::8000:0:0:0/65")...)
+	if s := prefixesString(a.Intersect(b).Prefixes()); s != "[10.0.0.128/25 fd00::8000:0:0:0/65]" {
+		test.Errorf("Unexpected intersection result %s", s)
+	}
+	if s := prefixesString(a.Union(b).Prefixes()); s != "[10.0.0.0/23 10.0.2.0/24 fd00::/64]" {
+		test.Errorf("Unexpected union result %s", s)
+	}
+	if s := prefixesString(a.Subtract(b).Prefixes()); s != "[10.0.0.0/25 10.0.2.0/24 fd00::/65]" {
+		test.Errorf("Unexpected subtraction result %s", s)
+	}
+	if s := prefixesString(NewIPSet(mustParseIPCidrs("10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/30", "10.0.0.8/32")...).Prefixes()); s != "[10.0.0.1/32 10.0.0.2/31 10.0.0.4/30 10.0.0.8/32]" {
+		test.Errorf("Unexpected cover %s", s)
+	}
+	if !(&IPSet{}).IsEmpty() || a.IsEmpty() {
+		test.Error("Unexpected emptiness")
+	}
+}
+
+func TestExcludePrivateIPs(test *testing.T) {
+	peer := Peer{AllowedIPs: mustParseIPCidrs("192.168.22.0/24")}
+	if peer.ExcludePrivateIPs() || len(peer.AllowedIPs) != 1 {
+		test.Error("Expected peer without a default route to be left alone")
+	}
+	peer = Peer{AllowedIPs: mustParseIPCidrs("0.0.0.0/1", "128.0.0.0/1", "::/0")}
+	if !peer.ExcludePrivateIPs() {
+		test.Error("Expected peer with a default route to be rewritten")
+	}
+	set := NewIPSet(peer.AllowedIPs...)
+	for _, ip := range []string{"10.1.2.3", "172.16.0.1", "172.31.255.255", "192.168.0.1"} {
+		if set.Contains(net.ParseIP(ip)) {
+			test.Errorf("Expected %s to be excluded", ip)
+		}
+	}
+	for _, ip := range []string{"1.1.1.1", "172.32.0.1", "192.169.0.1", "::1"} {
+		if !set.Contains(net.ParseIP(ip)) {
+			test.Errorf("Expected %s to be included", ip)
+		}
+	}
+}
+
+func TestIsFullTunnel(test *testing.T) {
+	excluded := Peer{AllowedIPs: mustParseIPCidrs("0.0.0.0/0")}
+	excluded.ExcludePrivateIPs()
+	for _, prefixes := range [][]IPCidr{
+		mustParseIPCidrs("0.0.0.0/0"),
+		mustParseIPCidrs("::/0"),
+		mustParseIPCidrs("0.0.0.0/1", "128.0.0.0/1"),
+		mustParseIPCidrs("::/1", "8000::/1"),
+		mustParseIPCidrs("2000::/3"),
+		mustParseIPCidrs("0.0.0.0/1", "128.0.0.0/2", "192.0.0.0/3"),
+		excluded.AllowedIPs,
+	} {
+		if !IsFullTunnel(prefixes) {
+			test.Errorf("Expected %s to be a full tunnel", prefixesString(prefixes))
+		}
+	}
+	for _, prefixes := range [][]IPCidr{
+		nil,
+		mustParseIPCidrs("0.0.0.0/1"),
+		mustParseIPCidrs("0.0.0.0/1", "128.0.0.0/2"),
+		mustParseIPCidrs("10.0.0.0/8", "fc00::/7"),
+		mustParseIPCidrs("2000::/4", "3000::/5"),
+	} {
+		if IsFullTunnel(prefixes) {
+			test.Errorf("Expected %s not to be a full tunnel", prefixesString(prefixes))
+		}
+	}
+}
+
+func TestExcludePrivateIPsInWgQuick(test *testing.T) {
+	input := "[Interface]\r\n" +
+		"# Laptop\r\n" +
+		"PrivateKey = cJM9wXUVXc0fa/t5b/Lm0BHNx6jh5UiTLsO+oJhyQUU=\r\n" +
+		"\r\n" +
+		"[Peer]\r\n" +
+		"PublicKey = JRI8Xc0zKP9kXk8qP84NdUQA04h6DLfFbwJn4g+/PFs=\r\n" +
+		"AllowedIPs = 192.168.22.0/24\r\n" +
+		"\r\n" +
+		"[Peer] # Full tunnel\r\n" +
+		"PublicKey = QCssGR6joqOIEQW6j2AR7nMcXJIVI9E9PCcbwrVXhU8=\r\n" +
+		"allowedips=0.0.0.0/1, 128.0.0.0/1   # everything\r\n" +
+		"AllowedIPs = ::/0\r\n" +
+		"Endpoint = vpn.example.com:51820\r\n"
+	expected := strings.Replace(input,
+		"allowedips=0.0.0.0/1, 128.0.0.0/1   # everything\r\nAllowedIPs = ::/0\r\n",
+		"allowedips=0.0.0.0/5, 8.0.0.0/7, 11.0.0.0/8, 12.0.0.0/6, 16.0.0.0/4, 32.0.0.0/3, 64.0.0.0/2, 128.0.0.0/3, 160.0.0.0/5, 168.0.0.0/6, 172.0.0.0/12, 172.32.0.0/11, 172.64.0.0/10, 172.128.0.0/9, 173.0.0.0/8, 174.0.0.0/7, 176.0.0.0/4, 192.0.0.0/9, 192.128.0.0/11, 192.160.0.0/13, 192.169.0.0/16, 192.170.0.0/15, 192.172.0.0/14, 192.176.0.0/12, 192.192.0.0/10, 193.0.0.0/8, 194.0.0.0/7, 196.0.0.0/6, 200.0.0.0/5, 208.0.0.0/4, 224.0.0.0/3, ::/0   # everything\r\n", 1)
+
+	output, err := ExcludePrivateIPsInWgQuick(input)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if output != expected {
+		test.Errorf("Unexpected output:\n%s", output)
+	}
+	if _, err = ExcludePrivateIPsInWgQuick("[Peer]\nAllowedIPs = 10.0.0.300/8\n"); err == nil {
+		test.Error("Expected an invalid address to be reported")
+	}
+}
@@ -0,0 +1,32 @@
+package conf
+
+import (
+	"crypto/rand"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+func NewPresharedKey() (*Key, error) {
+	var k Key
+	_, err := rand.Read(k[:])
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func NewPrivateKey() (*Key, error) {
+	k, err := NewPresharedKey()
+	if err != nil {
+		return nil, err
+	}
+	k[0] &= 248
+	k[31] = (k[31] & 127) | 64
+	return k, nil
+}
+
+func (k *Key) Public() *Key {
+	var p [KeyLength]byte
+	curve25519.ScalarBaseMult(&p, (*[KeyLength]byte)(k))
+	return (*Key)(&p)
+}
@@ -0,0 +1,101 @@
+package conf
+
+import (
+	"regexp"
+	"strings"
+)
+
+// TunnelName is the name of a tunnel, which must pass TunnelNameIsValid. It is
+// used verbatim for the configuration file name, the network adapter name and
+// the UAPI socket name, so the rules below are the strictest of all three.
+type TunnelName string
+
+const maxTunnelNameLength = 32
+
+var allowedNameFormat = regexp.MustCompile("^[a-zA-Z0-9_=+.-]{1,32}$")
+
+// Windows refuses to create files with these base names, with or without an extension.
+var reservedNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
+func isReserved(name string) bool {
+	if dot := strings.IndexByte(name, '.'); dot >= 0 {
+		name = name[:dot]
+	}
+	for _, reserved := range reservedNames {
+		if strings.EqualFold(name, reserved) {
+			return true
+		}
+	}
+	return false
+}
+
+func TunnelNameIsValid(name string) bool {
+	if !allowedNameFormat.MatchString(name) {
+		return false
+	}
+	// A trailing dot is silently dropped by Windows file APIs, which would make two names share one file.
+	if name[len(name)-1] == '.' {
+		return false
+	}
+	return !isReserved(name)
+}
+
+func NewTunnelName(name string) (TunnelName, error) {
+	if len(name) > maxTunnelNameLength {
+		return "", &ParseError{"Tunnel name must not be longer than 32 characters", name}
+	}
+	if !TunnelNameIsValid(name) {
+		if isReserved(name) {
+			return "", &ParseError{"Tunnel name is reserved by Windows", name}
+		}
+		return "", &ParseError{"Tunnel name may only contain letters, numbers and _=+.-, and must not end in a dot", name}
+	}
+	return TunnelName(name), nil
+}
+
+func (name TunnelName) String() string {
+	return string(name)
+}
+
+// Equal compares names the way Windows compares file and adapter names, that is, ignoring case.
+func (name TunnelName) Equal(other TunnelName) bool {
+	return strings.EqualFold(string(name), string(other))
+}
+
+func (name TunnelName) FileName() string {
+	return string(name) + configFileSuffix
+}
+
+// AdapterName is the name given to the tunnel's network adapter and UAPI socket.
+// It is the tunnel name itself, since every valid tunnel name is a valid adapter
+// name, and keeping them identical is what lets a running tunnel be mapped back
+// to its configuration.
+func (name TunnelName) AdapterName() string {
+	return string(name)
+}
+
+// ControlName is the name under which the service of a tunnel accepts
+// requests to stop, next to its UAPI. Like stats.ListenerName, it cannot clash
+// with another tunnel's UAPI, because tunnel names may not contain an @.
+func (name TunnelName) ControlName() string {
+	return name.AdapterName() + "@control"
+}
+
+// FindTunnelName returns the entry of names that is equal to name, if any.
+func FindTunnelName(names []TunnelName, name TunnelName) (TunnelName, bool) {
+	for _, n := range names {
+		if n.Equal(name) {
+			return n, true
+		}
+	}
+	return "", false
+}
+
+func TunnelNameIsUnique(names []TunnelName, name TunnelName) bool {
+	_, found := FindTunnelName(names, name)
+	return !found
+}
@@ -0,0 +1,43 @@
+package conf
+
+import (
+	"testing"
+)
+
+func TestTunnelNameIsValid(test *testing.T) {
+	valid := []string{"wg0", "office", "Home-VPN_2", "a.b", "x=y+z", "COM10", "console", "ab345678901234567890123456789012"}
+	invalid := []string{"", "has space", "tab\tname", "slash/name", "back\\slash", "dot.", "CON", "con", "Nul.conf", "lpt1", "COM9.txt", "ab3456789012345678901234567890123", "ünïcode"}
+	for _, name := range valid {
+		if !TunnelNameIsValid(name) {
+			test.Errorf("Expected %q to be valid", name)
+		}
+		if _, err := NewTunnelName(name); err != nil {
+			test.Errorf("Unexpected error for %q: %v", name, err)
+		}
+	}
+	for _, name := range invalid {
+		if TunnelNameIsValid(name) {
+			test.Errorf("Expected %q to be invalid", name)
+		}
+		if _, err := NewTunnelName(name); err == nil {
+			test.Errorf("Expected error for %q", name)
+		}
+	}
+}
+
+func TestTunnelNameUniqueness(test *testing.T) {
+	names := []TunnelName{"office", "Home"}
+	if TunnelNameIsUnique(names, "OFFICE") || TunnelNameIsUnique(names, "home") {
+		test.Error("Names differing only in case must collide")
+	}
+	if !TunnelNameIsUnique(names, "office2") {
+		test.Error("Distinct names must not collide")
+	}
+	if found, _ := FindTunnelName(names, "HOME"); found != "Home" {
+		test.Errorf("Expected to find existing spelling, got %q", found)
+	}
+	name := TunnelName("office")
+	if name.FileName() != "office.conf" || name.AdapterName() != "office" {
+		test.Errorf("Unexpected mapping: %q %q", name.FileName(), name.AdapterName())
+	}
+}
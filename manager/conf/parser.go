@@ -165,6 +165,19 @@ func splitList(s string) ([]string, error) {
 	return out, nil
 }
 
+// ParseKey parses a base64 key, as used in configuration and key files.
+func ParseKey(s string) (*Key, error) {
+	return parseKeyBase64(strings.TrimSpace(s))
+}
+
+func ParseIPCidr(s string) (*IPCidr, error) {
+	return parseIPCidr(s)
+}
+
+func ParseEndpoint(s string) (*Endpoint, error) {
+	return parseEndpoint(s)
+}
+
 type ParserState int
 
 const (
@@ -180,9 +193,13 @@ func (c *Config) maybeAddPeer(p *Peer) {
 }
 
 func FromWgQuick(s string, name string) (*Config, error) {
+	tunnelName, err := NewTunnelName(name)
+	if err != nil {
+		return nil, err
+	}
 	lines := strings.Split(s, "\n")
 	parserState := notInASection
-	conf := Config{Name: name}
+	conf := Config{Name: tunnelName}
 	sawPrivateKey := false
 	var peer *Peer
 	for _, line := range lines {
@@ -257,11 +274,19 @@ func FromWgQuick(s string, name string) (*Config, error) {
 				}
 				for _, address := range addresses {
 					a := net.ParseIP(address)
-					if a != nil {
+					if a == nil {
 						return nil, &ParseError{"Invalid IP address", address}
 					}
 					conf.Interface.Dns = append(conf.Interface.Dns, a)
 				}
+			case "preup":
+				conf.Interface.PreUp = append(conf.Interface.PreUp, val)
+			case "postup":
+				conf.Interface.PostUp = append(conf.Interface.PostUp, val)
+			case "predown":
+				conf.Interface.PreDown = append(conf.Interface.PreDown, val)
+			case "postdown":
+				conf.Interface.PostDown = append(conf.Interface.PostDown, val)
 			default:
 				return nil, &ParseError{"Invalid key for [Interface] section", key}
 			}
@@ -322,7 +347,13 @@ func FromWgQuick(s string, name string) (*Config, error) {
 	return &conf, nil
 }
 
+// FromUAPI parses the response to a UAPI get operation. The UAPI knows nothing
+// of the name, addresses, DNS servers, MTU and hooks, so those are taken from
+// existingConfig, which may be nil.
 func FromUAPI(s string, existingConfig *Config) (*Config, error) {
+	if existingConfig == nil {
+		existingConfig = &Config{}
+	}
 	lines := strings.Split(s, "\n")
 	parserState := inInterfaceSection
 	conf := Config{
@@ -331,6 +362,10 @@ func FromUAPI(s string, existingConfig *Config) (*Config, error) {
 			Addresses: existingConfig.Interface.Addresses,
 			Dns:       existingConfig.Interface.Dns,
 			Mtu:       existingConfig.Interface.Mtu,
+			PreUp:     existingConfig.Interface.PreUp,
+			PostUp:    existingConfig.Interface.PostUp,
+			PreDown:   existingConfig.Interface.PreDown,
+			PostDown:  existingConfig.Interface.PostDown,
 		},
 	}
 	var peer *Peer
@@ -361,7 +396,7 @@ func FromUAPI(s string, existingConfig *Config) (*Config, error) {
 		if parserState == inInterfaceSection {
 			switch key {
 			case "private_key":
-				k, err := parseKeyBase64(val)
+				k, err := parseKeyHex(val)
 				if err != nil {
 					return nil, err
 				}
@@ -381,13 +416,13 @@ func FromUAPI(s string, existingConfig *Config) (*Config, error) {
 		} else if parserState == inPeerSection {
 			switch key {
 			case "public_key":
-				k, err := parseKeyBase64(val)
+				k, err := parseKeyHex(val)
 				if err != nil {
 					return nil, err
 				}
 				peer.PublicKey = *k
 			case "preshared_key":
-				k, err := parseKeyBase64(val)
+				k, err := parseKeyHex(val)
 				if err != nil {
 					return nil, err
 				}
@@ -0,0 +1,127 @@
+package conf
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFromUAPI(test *testing.T) {
+	config, err := FromWgQuick(testInput, "demo")
+	if err != nil {
+		test.Fatal(err)
+	}
+	psk, err := NewPresharedKey()
+	if err != nil {
+		test.Fatal(err)
+	}
+	peer := &config.Peers[0]
+	peer.PresharedKey = *psk
+	peer.Endpoint = Endpoint{"192.0.2.1", 12912}
+	get := fmt.Sprintf("private_key=%s\nlisten_port=51820\npublic_key=%s\npreshared_key=%s\nprotocol_version=1\nendpoint=192.0.2.1:12912\nlast_handshake_time_sec=1500000000\nlast_handshake_time_nsec=5\ntx_bytes=123\nrx_bytes=456\npersistent_keepalive_interval=0\nallowed_ip=0.0.0.0/0\nerrno=0\n",
+		config.Interface.PrivateKey.HexString(), peer.PublicKey.HexString(), peer.PresharedKey.HexString())
+	if _, err = FromUAPI(get, nil); err != nil {
+		test.Errorf("Unable to parse without an existing config: %v", err)
+	}
+	running, err := FromUAPI(get, config)
+	if err != nil {
+		test.Fatal(err)
+	}
+	config.Interface.ListenPort = 51820
+	if running.ToWgQuick() != config.ToWgQuick() {
+		test.Errorf("Running config differs:\n%s\nexpected\n%s", running.ToWgQuick(), config.ToWgQuick())
+	}
+	p := running.Peers[0]
+	if p.TxBytes != 123 || p.RxBytes != 456 || time.Duration(p.LastHandshakeTime) != 1500000000*time.Second+5 {
+		test.Errorf("Unexpected statistics: %+v", p)
+	}
+}
+
+func TestLearnEndpoints(test *testing.T) {
+	stored, err := FromWgQuick(testInput, "demo")
+	if err != nil {
+		test.Fatal(err)
+	}
+	k1, _ := NewPrivateKey()
+	k2, _ := NewPrivateKey()
+	stored.Peers = append(stored.Peers,
+		Peer{PublicKey: *k1.Public(), Endpoint: Endpoint{"192.0.2.1", 51820}},
+		Peer{PublicKey: *k2.Public()})
+	running := &Config{Peers: []Peer{
+		{PublicKey: stored.Peers[0].PublicKey, Endpoint: Endpoint{"163.172.161.0", 12912}},
+		{PublicKey: *k1.Public(), Endpoint: Endpoint{"198.51.100.4", 40000}},
+		{PublicKey: *k2.Public(), Endpoint: Endpoint{"2001:db8::1", 1234}},
+	}}
+	changed := stored.LearnEndpoints(running)
+	if len(changed) != 2 || changed[0] != *k1.Public() || changed[1] != *k2.Public() {
+		test.Errorf("Unexpected changed peers: %v", changed)
+	}
+	if stored.Peers[0].Endpoint.Host != "demo.wireguard.com" {
+		test.Error("Host name endpoint was replaced")
+	}
+	if e := stored.Peers[1].Endpoint; e.String() != "198.51.100.4:40000" {
+		test.Errorf("Roamed endpoint not learned: %s", e.String())
+	}
+	if e := stored.Peers[2].Endpoint; e.String() != "[2001:db8::1]:1234" {
+		test.Errorf("New endpoint not learned: %s", e.String())
+	}
+	if changed = stored.LearnEndpoints(running); len(changed) != 0 {
+		test.Errorf("Expected nothing to change the second time, got %v", changed)
+	}
+}
+
+func TestApplyUAPI(test *testing.T) {
+	config, err := FromWgQuick(testInput, "demo")
+	if err != nil {
+		test.Fatal(err)
+	}
+	k1, _ := NewPrivateKey()
+	k2, _ := NewPrivateKey()
+	config.Peers = append(config.Peers, Peer{PublicKey: *k1.Public(), AllowedIPs: mustParseIPCidrs("10.0.0.2/32")})
+	first := config.Peers[0].PublicKey
+	set := fmt.Sprintf("listen_port=1234\npublic_key=%s\nreplace_allowed_ips=true\nallowed_ip=0.0.0.0/0\nendpoint=192.0.2.1:51820\npublic_key=%s\nremove=true\npublic_key=%s\npersistent_keepalive_interval=25\nallowed_ip=10.0.0.3/32\n",
+		first.HexString(), k1.Public().HexString(), k2.Public().HexString())
+	if err = config.ApplyUAPI(set); err != nil {
+		test.Fatal(err)
+	}
+	if config.Interface.ListenPort != 1234 || len(config.Peers) != 2 {
+		test.Fatalf("Unexpected config:\n%s", config.ToWgQuick())
+	}
+	if p := config.Peers[0]; p.PublicKey != first || prefixesString(p.AllowedIPs) != "[0.0.0.0/0]" || p.Endpoint.String() != "192.0.2.1:51820" {
+		test.Errorf("Unexpected changed peer: %+v", p)
+	}
+	if p := config.Peers[1]; p.PublicKey != *k2.Public() || p.PersistentKeepalive != 25 || prefixesString(p.AllowedIPs) != "[10.0.0.3/32]" {
+		test.Errorf("Unexpected added peer: %+v", p)
+	}
+
+	if err = config.ApplyUAPI(fmt.Sprintf("replace_peers=true\npublic_key=%s\n", first.HexString())); err != nil {
+		test.Fatal(err)
+	}
+	if len(config.Peers) != 1 || config.Peers[0].PublicKey != first || len(config.Peers[0].AllowedIPs) != 0 {
+		test.Errorf("Expected replaced peers to start over, got %+v", config.Peers)
+	}
+
+	for _, set := range []string{"allowed_ip=10.0.0.0/8\n", "bogus=1\n", "listen_port=65536\n"} {
+		if err = config.ApplyUAPI(set); err == nil {
+			test.Errorf("Expected error applying %q", set)
+		}
+	}
+}
+
+func TestHookCommands(test *testing.T) {
+	input := testInput[:len("[Interface]\n")] + "PreUp = echo one\nPostDown = echo two # comment\npreup = C:\\Tools\\three.exe --flag\n" + testInput[len("[Interface]\n"):]
+	config, err := FromWgQuick(input, "demo")
+	if err != nil {
+		test.Fatal(err)
+	}
+	if len(config.Interface.PreUp) != 2 || config.Interface.PreUp[1] != `C:\Tools\three.exe --flag` || len(config.Interface.PostDown) != 1 || config.Interface.PostDown[0] != "echo two" {
+		test.Errorf("Unexpected hooks: %+v", config.Interface)
+	}
+	again, err := FromWgQuick(config.ToWgQuick(), "demo")
+	if err != nil {
+		test.Fatal(err)
+	}
+	if again.ToWgQuick() != config.ToWgQuick() {
+		test.Errorf("Hooks do not round trip:\n%s", config.ToWgQuick())
+	}
+}
@@ -0,0 +1,48 @@
+package conf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+var cachedConfigFileDir string
+
+// rootDirectory is overridden by tests.
+var rootDirectory = func() (string, error) {
+	var root string
+	if runtime.GOOS == "windows" {
+		root = os.Getenv("LOCALAPPDATA")
+	} else if xdg := os.Getenv("XDG_CONFIG_HOME"); len(xdg) > 0 {
+		root = xdg
+	} else if home := os.Getenv("HOME"); len(home) > 0 {
+		root = filepath.Join(home, ".config")
+	}
+	if len(root) == 0 {
+		return "", errors.New("Unable to determine configuration root directory")
+	}
+	return filepath.Join(root, "WireGuard"), nil
+}
+
+// RootDirectory is where all of WireGuard's per-user state lives.
+func RootDirectory() (string, error) {
+	return rootDirectory()
+}
+
+func tunnelConfigurationsDirectory() (string, error) {
+	if len(cachedConfigFileDir) > 0 {
+		return cachedConfigFileDir, nil
+	}
+	root, err := rootDirectory()
+	if err != nil {
+		return "", err
+	}
+	c := filepath.Join(root, "Configurations")
+	err = os.MkdirAll(c, os.ModeDir|0700)
+	if err != nil {
+		return "", err
+	}
+	cachedConfigFileDir = c
+	return c, nil
+}
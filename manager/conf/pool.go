@@ -0,0 +1,119 @@
+package conf
+
+import (
+	"math/big"
+	"net"
+)
+
+func (r *IPCidr) Bits() uint8 {
+	if r.IP.To4() != nil {
+		return 32
+	}
+	return 128
+}
+
+func (r *IPCidr) IPNet() net.IPNet {
+	ip := r.IP.To4()
+	if ip == nil {
+		ip = r.IP.To16()
+	}
+	mask := net.CIDRMask(int(r.Cidr), int(r.Bits()))
+	return net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
+
+// MaskSelf clears the host bits, turning an interface address such as 10.0.0.1/24 into its network 10.0.0.0/24.
+func (r *IPCidr) MaskSelf() {
+	r.IP = r.IPNet().IP
+}
+
+func (r *IPCidr) Contains(ip net.IP) bool {
+	network := r.IPNet()
+	return network.Contains(ip)
+}
+
+func ipToInt(ip net.IP) *big.Int {
+	if v4 := ip.To4(); v4 != nil {
+		return new(big.Int).SetBytes(v4)
+	}
+	return new(big.Int).SetBytes(ip.To16())
+}
+
+func intToIP(i *big.Int, bits uint8) net.IP {
+	b := i.Bytes()
+	ip := make(net.IP, bits/8)
+	copy(ip[len(ip)-len(b):], b)
+	return ip
+}
+
+// lastAddress returns the highest address covered by r as an integer.
+func (r *IPCidr) lastAddress() *big.Int {
+	hostBits := uint(r.Bits() - r.Cidr)
+	last := new(big.Int).Lsh(big.NewInt(1), hostBits)
+	last.Sub(last, big.NewInt(1))
+	return last.Or(last, ipToInt(r.IPNet().IP))
+}
+
+// AddressPool hands out host addresses from a subnet, in ascending order,
+// skipping anything that has been reserved.
+type AddressPool struct {
+	Prefix   IPCidr
+	reserved []IPCidr
+}
+
+type PoolExhaustedError struct {
+	Prefix IPCidr
+}
+
+func (e *PoolExhaustedError) Error() string {
+	return "No free addresses left in " + e.Prefix.String()
+}
+
+func NewAddressPool(prefix IPCidr) *AddressPool {
+	prefix.MaskSelf()
+	return &AddressPool{Prefix: prefix}
+}
+
+// ReserveAddress marks a single address, such as one of the server's own interface addresses, as taken.
+func (pool *AddressPool) ReserveAddress(ip net.IP) {
+	host := IPCidr{IP: ip}
+	host.Cidr = host.Bits()
+	pool.reserved = append(pool.reserved, host)
+}
+
+// ReserveRange marks every address in r as taken, as needed for a peer's AllowedIPs.
+func (pool *AddressPool) ReserveRange(r IPCidr) {
+	r.MaskSelf()
+	pool.reserved = append(pool.reserved, r)
+}
+
+// Next returns the lowest free address, with the prefix length of the pool, and reserves it.
+func (pool *AddressPool) Next() (*IPCidr, error) {
+	bits := pool.Prefix.Bits()
+	current := ipToInt(pool.Prefix.IP)
+	last := pool.Prefix.lastAddress()
+	one := big.NewInt(1)
+
+	// The network address is never handed out, and neither is the broadcast address for IPv4 subnets that have one.
+	if pool.Prefix.Cidr+1 < bits {
+		current.Add(current, one)
+		if bits == 32 {
+			last.Sub(last, one)
+		}
+	}
+
+outer:
+	for current.Cmp(last) <= 0 {
+		candidate := intToIP(current, bits)
+		for i := range pool.reserved {
+			r := &pool.reserved[i]
+			if r.Bits() == bits && r.Contains(candidate) {
+				current = r.lastAddress()
+				current.Add(current, one)
+				continue outer
+			}
+		}
+		pool.ReserveAddress(candidate)
+		return &IPCidr{candidate, pool.Prefix.Cidr}, nil
+	}
+	return nil, &PoolExhaustedError{pool.Prefix}
+}
@@ -0,0 +1,132 @@
+package conf
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// RouteCandidate is an AllowedIPs entry that contains the looked up address.
+type RouteCandidate struct {
+	PeerIndex int
+	Prefix    IPCidr
+	// Shadowed is set when a later peer claims the same prefix, which in
+	// WireGuard moves the prefix to that peer.
+	Shadowed bool
+}
+
+// RouteDecision is the outcome of cryptokey routing for one destination.
+type RouteDecision struct {
+	IP         net.IP
+	PeerIndex  int // -1 when no peer matches and the packet is dropped.
+	Prefix     IPCidr
+	Candidates []RouteCandidate // Longest prefix first.
+}
+
+// Route returns the peer that WireGuard sends packets for ip to: the one with
+// the longest AllowedIPs prefix containing ip. When several peers list the
+// same prefix, the last one in the configuration owns it, since each peer's
+// AllowedIPs replace any earlier assignment.
+func (config *Config) Route(ip net.IP) *RouteDecision {
+	decision := &RouteDecision{IP: ip, PeerIndex: -1}
+	owners := make(map[string]int)
+	for i, peer := range config.Peers {
+		for _, allowedIP := range peer.AllowedIPs {
+			allowedIP.MaskSelf()
+			if allowedIP.Contains(ip) {
+				decision.Candidates = append(decision.Candidates, RouteCandidate{PeerIndex: i, Prefix: allowedIP})
+				owners[allowedIP.String()] = i
+			}
+		}
+	}
+	sort.SliceStable(decision.Candidates, func(i, j int) bool {
+		return decision.Candidates[i].Prefix.Cidr > decision.Candidates[j].Prefix.Cidr
+	})
+	for i := range decision.Candidates {
+		c := &decision.Candidates[i]
+		c.Shadowed = owners[c.Prefix.String()] != c.PeerIndex
+		if !c.Shadowed && decision.PeerIndex < 0 {
+			decision.PeerIndex = c.PeerIndex
+			decision.Prefix = c.Prefix
+		}
+	}
+	return decision
+}
+
+// Peer returns the chosen peer of config, or nil if the packet is dropped.
+func (decision *RouteDecision) Peer(config *Config) *Peer {
+	if decision.PeerIndex < 0 {
+		return nil
+	}
+	return &config.Peers[decision.PeerIndex]
+}
+
+func describePeer(config *Config, index int) string {
+	return fmt.Sprintf("peer %d (%s)", index+1, config.Peers[index].PublicKey.String())
+}
+
+// Explain describes in English why the decision was made.
+func (decision *RouteDecision) Explain(config *Config) string {
+	var output strings.Builder
+	if decision.PeerIndex < 0 {
+		output.WriteString(fmt.Sprintf("%s is not in the AllowedIPs of any peer, so packets to it are dropped.\n", decision.IP))
+		return output.String()
+	}
+	output.WriteString(fmt.Sprintf("%s is sent to %s, ", decision.IP, describePeer(config, decision.PeerIndex)))
+	output.WriteString(fmt.Sprintf("because %s is the longest matching prefix.\n", decision.Prefix.String()))
+	for _, c := range decision.Candidates {
+		if c.PeerIndex == decision.PeerIndex && c.Prefix.Cidr == decision.Prefix.Cidr {
+			continue
+		}
+		if c.Shadowed {
+			output.WriteString(fmt.Sprintf("%s also lists %s, but a later peer takes it over.\n", describePeer(config, c.PeerIndex), c.Prefix.String()))
+		} else {
+			output.WriteString(fmt.Sprintf("%s matches %s, which is less specific.\n", describePeer(config, c.PeerIndex), c.Prefix.String()))
+		}
+	}
+	return output.String()
+}
+
+// RouteConflict is a prefix listed in the AllowedIPs of more than one peer.
+// Only the last of PeerIndices actually receives the traffic.
+type RouteConflict struct {
+	Prefix      IPCidr
+	PeerIndices []int
+}
+
+func (conflict *RouteConflict) Explain(config *Config) string {
+	var peers []string
+	for _, i := range conflict.PeerIndices {
+		peers = append(peers, describePeer(config, i))
+	}
+	return fmt.Sprintf("%s is listed by %s; only %s receives it.", conflict.Prefix.String(), strings.Join(peers, ", "), describePeer(config, conflict.PeerIndices[len(conflict.PeerIndices)-1]))
+}
+
+// RouteConflicts reports every prefix that several peers claim, in order of first appearance.
+func (config *Config) RouteConflicts() []RouteConflict {
+	var order []string
+	claims := make(map[string]*RouteConflict)
+	for i, peer := range config.Peers {
+		for _, allowedIP := range peer.AllowedIPs {
+			allowedIP.MaskSelf()
+			key := allowedIP.String()
+			conflict, ok := claims[key]
+			if !ok {
+				conflict = &RouteConflict{Prefix: allowedIP}
+				claims[key] = conflict
+				order = append(order, key)
+			}
+			if n := len(conflict.PeerIndices); n == 0 || conflict.PeerIndices[n-1] != i {
+				conflict.PeerIndices = append(conflict.PeerIndices, i)
+			}
+		}
+	}
+	var conflicts []RouteConflict
+	for _, key := range order {
+		if len(claims[key].PeerIndices) > 1 {
+			conflicts = append(conflicts, *claims[key])
+		}
+	}
+	return conflicts
+}
@@ -0,0 +1,58 @@
+package conf
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+const testRouting = `[Interface]
+PrivateKey = cJM9wXUVXc0fa/t5b/Lm0BHNx6jh5UiTLsO+oJhyQUU=
+
+[Peer]
+PublicKey = JRI8Xc0zKP9kXk8qP84NdUQA04h6DLfFbwJn4g+/PFs=
+AllowedIPs = 0.0.0.0/0, 10.0.0.0/8
+
+[Peer]
+PublicKey = QCssGR6joqOIEQW6j2AR7nMcXJIVI9E9PCcbwrVXhU8=
+AllowedIPs = 10.1.0.0/16, 10.0.0.0/8, fd00::/64
+`
+
+func TestRoute(test *testing.T) {
+	config, err := FromWgQuick(testRouting, "routes")
+	if err != nil {
+		test.Fatal(err)
+	}
+	expected := map[string]int{
+		"10.1.2.3":  1,
+		"10.2.0.1":  1,
+		"8.8.8.8":   0,
+		"fd00::1":   1,
+		"2001::1":   -1,
+		"192.0.2.1": 0,
+	}
+	for ip, peer := range expected {
+		decision := config.Route(net.ParseIP(ip))
+		if decision.PeerIndex != peer {
+			test.Errorf("Expected %s to go to peer %d, got %d:\n%s", ip, peer, decision.PeerIndex, decision.Explain(config))
+		}
+		if (decision.Peer(config) == nil) != (peer < 0) {
+			test.Errorf("Unexpected peer for %s", ip)
+		}
+	}
+	explanation := config.Route(net.ParseIP("10.2.0.1")).Explain(config)
+	if !strings.Contains(explanation, "10.0.0.0/8 is the longest") || !strings.Contains(explanation, "later peer takes it over") || !strings.Contains(explanation, "0.0.0.0/0, which is less specific") {
+		test.Errorf("Unexpected explanation:\n%s", explanation)
+	}
+	if explanation := config.Route(net.ParseIP("2001::1")).Explain(config); !strings.Contains(explanation, "dropped") {
+		test.Errorf("Unexpected explanation:\n%s", explanation)
+	}
+
+	conflicts := config.RouteConflicts()
+	if len(conflicts) != 1 || conflicts[0].Prefix.String() != "10.0.0.0/8" || len(conflicts[0].PeerIndices) != 2 {
+		test.Fatalf("Unexpected conflicts %+v", conflicts)
+	}
+	if explanation := conflicts[0].Explain(config); !strings.Contains(explanation, "only peer 2") {
+		test.Errorf("Unexpected explanation: %s", explanation)
+	}
+}
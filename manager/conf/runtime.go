@@ -0,0 +1,148 @@
+package conf
+
+import (
+	"net"
+	"strings"
+)
+
+// LearnEndpoints copies the endpoints that peers have roamed to in running,
+// a configuration returned by FromUAPI, into config, and returns the public
+// keys of the peers it changed. Endpoints given as host names are left alone,
+// since the running tunnel only knows what they resolved to; so are peers
+// missing from either side.
+func (config *Config) LearnEndpoints(running *Config) []Key {
+	learned := make(map[Key]Endpoint)
+	for _, peer := range running.Peers {
+		if !peer.Endpoint.IsEmpty() {
+			learned[peer.PublicKey] = peer.Endpoint
+		}
+	}
+	var changed []Key
+	for i := range config.Peers {
+		peer := &config.Peers[i]
+		endpoint, ok := learned[peer.PublicKey]
+		if !ok {
+			continue
+		}
+		if !peer.Endpoint.IsEmpty() {
+			ip := net.ParseIP(peer.Endpoint.Host)
+			if ip == nil || (ip.Equal(net.ParseIP(endpoint.Host)) && peer.Endpoint.Port == endpoint.Port) {
+				continue
+			}
+		}
+		peer.Endpoint = endpoint
+		changed = append(changed, peer.PublicKey)
+	}
+	return changed
+}
+
+// ApplyUAPI changes config the way a UAPI set operation, given without its
+// set=1 line, changes a running tunnel, so that the outcome of the operation
+// can be vetted before it is applied.
+func (config *Config) ApplyUAPI(s string) error {
+	peer := -1
+	removed := make(map[int]bool)
+	for _, line := range strings.Split(s, "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		equals := strings.IndexByte(line, '=')
+		if equals < 0 {
+			return &ParseError{"Invalid config key is missing an equals separator", line}
+		}
+		key, val := line[:equals], line[equals+1:]
+		if len(val) == 0 {
+			return &ParseError{"Key must have a value", line}
+		}
+		switch key {
+		case "private_key", "listen_port", "fwmark", "replace_peers", "public_key":
+		default:
+			if peer < 0 {
+				return &ParseError{"Invalid key for interface section", key}
+			}
+		}
+		switch key {
+		case "private_key":
+			k, err := parseKeyHex(val)
+			if err != nil {
+				return err
+			}
+			config.Interface.PrivateKey = *k
+		case "listen_port":
+			p, err := parsePort(val)
+			if err != nil {
+				return err
+			}
+			config.Interface.ListenPort = p
+		case "fwmark":
+			// Ignored for now.
+		case "replace_peers":
+			if val == "true" {
+				for i := range config.Peers {
+					removed[i] = true
+				}
+			}
+		case "public_key":
+			k, err := parseKeyHex(val)
+			if err != nil {
+				return err
+			}
+			peer = -1
+			for i := range config.Peers {
+				if config.Peers[i].PublicKey == *k && !removed[i] {
+					peer = i
+				}
+			}
+			if peer < 0 {
+				config.Peers = append(config.Peers, Peer{PublicKey: *k})
+				peer = len(config.Peers) - 1
+			}
+		case "remove":
+			if val == "true" {
+				removed[peer] = true
+			}
+		case "preshared_key":
+			k, err := parseKeyHex(val)
+			if err != nil {
+				return err
+			}
+			config.Peers[peer].PresharedKey = *k
+		case "endpoint":
+			e, err := parseEndpoint(val)
+			if err != nil {
+				return err
+			}
+			config.Peers[peer].Endpoint = *e
+		case "persistent_keepalive_interval":
+			p, err := parsePersistentKeepalive(val)
+			if err != nil {
+				return err
+			}
+			config.Peers[peer].PersistentKeepalive = p
+		case "replace_allowed_ips":
+			if val == "true" {
+				config.Peers[peer].AllowedIPs = nil
+			}
+		case "allowed_ip":
+			a, err := parseIPCidr(val)
+			if err != nil {
+				return err
+			}
+			config.Peers[peer].AllowedIPs = append(config.Peers[peer].AllowedIPs, *a)
+		case "protocol_version":
+			if val != "1" {
+				return &ParseError{"Protocol version must be 1", val}
+			}
+		default:
+			return &ParseError{"Invalid key for peer section", key}
+		}
+	}
+	peers := config.Peers[:0]
+	for i, p := range config.Peers {
+		if !removed[i] {
+			peers = append(peers, p)
+		}
+	}
+	config.Peers = peers
+	return nil
+}
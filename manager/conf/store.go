@@ -0,0 +1,112 @@
+package conf
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const configFileSuffix = ".conf"
+
+func ListConfigNames() ([]TunnelName, error) {
+	configFileDir, err := tunnelConfigurationsDirectory()
+	if err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(configFileDir)
+	if err != nil {
+		return nil, err
+	}
+	configs := make([]TunnelName, 0, len(files))
+	for _, file := range files {
+		name := file.Name()
+		if !file.Mode().IsRegular() || !strings.HasSuffix(name, configFileSuffix) {
+			continue
+		}
+		name = strings.TrimSuffix(name, configFileSuffix)
+		if !TunnelNameIsValid(name) {
+			continue
+		}
+		configs = append(configs, TunnelName(name))
+	}
+	return configs, nil
+}
+
+func LoadFromName(name TunnelName) (*Config, error) {
+	configFileDir, err := tunnelConfigurationsDirectory()
+	if err != nil {
+		return nil, err
+	}
+	return LoadFromPath(filepath.Join(configFileDir, name.FileName()))
+}
+
+func LoadFromPath(path string) (*Config, error) {
+	name, err := NameFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromWgQuick(string(bytes), string(name))
+}
+
+func NameFromPath(path string) (TunnelName, error) {
+	name := filepath.Base(path)
+	if !strings.HasSuffix(name, configFileSuffix) {
+		return "", errors.New("Path must end in " + configFileSuffix)
+	}
+	return NewTunnelName(strings.TrimSuffix(name, configFileSuffix))
+}
+
+func (config *Config) Save() error {
+	filename, err := config.Path()
+	if err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(filename), ".tmp-"+string(config.Name))
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.WriteString(config.ToWgQuick())
+	if err == nil {
+		err = tmpFile.Chmod(0600)
+	}
+	closeErr := tmpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	return nil
+}
+
+func (config *Config) Path() (string, error) {
+	if !TunnelNameIsValid(string(config.Name)) {
+		return "", errors.New("Tunnel name is not valid")
+	}
+	configFileDir, err := tunnelConfigurationsDirectory()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configFileDir, config.Name.FileName()), nil
+}
+
+func DeleteName(name TunnelName) error {
+	if !TunnelNameIsValid(string(name)) {
+		return errors.New("Tunnel name is not valid")
+	}
+	configFileDir, err := tunnelConfigurationsDirectory()
+	if err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(configFileDir, name.FileName()))
+}
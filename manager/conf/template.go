@@ -0,0 +1,411 @@
+package conf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A template is a wg-quick file with ${placeholders}, preceded by a
+// [Variables] section declaring each placeholder and its type:
+//
+//	[Variables]
+//	PrivateKey = key genkey()
+//	Subnet = cidr 10.0.0.0/24
+//	Address = cidr nextip(Subnet)
+//	Server = endpoint
+//
+//	[Interface]
+//	PrivateKey = ${PrivateKey}
+//	Address = ${Address}
+//	...
+//
+// Anything after the type is the default, which is either a literal or one of
+// the functions genkey(), genpsk(), pubkey(var) or nextip(var). Functions may
+// also be used directly in placeholders, and $$ produces a literal dollar sign.
+// Comments are copied as they are, placeholders and all.
+
+type TemplateVariableType int
+
+const (
+	TemplateKey TemplateVariableType = iota
+	TemplateCidr
+	TemplateEndpoint
+	TemplateInt
+)
+
+var templateVariableTypes = map[string]TemplateVariableType{
+	"key":      TemplateKey,
+	"cidr":     TemplateCidr,
+	"endpoint": TemplateEndpoint,
+	"int":      TemplateInt,
+}
+
+func (t TemplateVariableType) String() string {
+	for name, typ := range templateVariableTypes {
+		if typ == t {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+type TemplateVariable struct {
+	Name    string
+	Type    TemplateVariableType
+	Default string
+}
+
+type Template struct {
+	Variables []TemplateVariable
+	body      string
+	reserved  []IPCidr
+	pools     map[string]*AddressPool
+}
+
+var templateIdentifier = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+func ParseTemplate(s string) (*Template, error) {
+	t := &Template{pools: make(map[string]*AddressPool)}
+	var body strings.Builder
+	inVariables := false
+	for _, line := range strings.Split(s, "\n") {
+		trimmed := line
+		if pound := strings.IndexByte(trimmed, '#'); pound >= 0 {
+			trimmed = trimmed[:pound]
+		}
+		trimmed = strings.TrimSpace(trimmed)
+		if strings.HasPrefix(trimmed, "[") {
+			inVariables = strings.ToLower(trimmed) == "[variables]"
+			if inVariables {
+				continue
+			}
+		}
+		if !inVariables {
+			body.WriteString(line)
+			body.WriteByte('\n')
+			continue
+		}
+		if len(trimmed) == 0 {
+			continue
+		}
+		equals := strings.IndexByte(trimmed, '=')
+		if equals < 0 {
+			return nil, &ParseError{"Invalid variable declaration is missing an equals separator", trimmed}
+		}
+		name := strings.TrimSpace(trimmed[:equals])
+		if !templateIdentifier.MatchString(name) {
+			return nil, &ParseError{"Invalid variable name", name}
+		}
+		if t.variable(name) != nil {
+			return nil, &ParseError{"Variable declared twice", name}
+		}
+		fields := strings.Fields(trimmed[equals+1:])
+		if len(fields) == 0 {
+			return nil, &ParseError{"Variable must have a type", trimmed}
+		}
+		typ, ok := templateVariableTypes[strings.ToLower(fields[0])]
+		if !ok {
+			return nil, &ParseError{"Invalid variable type", fields[0]}
+		}
+		t.Variables = append(t.Variables, TemplateVariable{name, typ, strings.Join(fields[1:], " ")})
+	}
+	t.body = body.String()
+
+	for _, v := range t.Variables {
+		if isTemplateCall(v.Default) {
+			if err := t.checkExpression(v.Default); err != nil {
+				return nil, err
+			}
+		}
+	}
+	_, err := t.substitute(func(expression string) (string, error) {
+		return "", t.checkExpression(expression)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *Template) variable(name string) *TemplateVariable {
+	for i := range t.Variables {
+		if t.Variables[i].Name == name {
+			return &t.Variables[i]
+		}
+	}
+	return nil
+}
+
+// ReserveAddresses prevents nextip() from handing out the given addresses,
+// typically the server's own interface addresses. Only the address itself is
+// reserved, not the rest of its subnet.
+func (t *Template) ReserveAddresses(addresses ...IPCidr) {
+	for _, a := range addresses {
+		a.Cidr = a.Bits()
+		t.reserve(a)
+	}
+}
+
+// ReserveRanges prevents nextip() from handing out any address in the given
+// ranges, typically the AllowedIPs of the server's other peers.
+func (t *Template) ReserveRanges(ranges ...IPCidr) {
+	for _, r := range ranges {
+		t.reserve(r)
+	}
+}
+
+// ReserveConfig reserves the interface addresses of server and the
+// AllowedIPs of its peers, as NewAllocator does.
+func (t *Template) ReserveConfig(server *Config) {
+	t.ReserveAddresses(server.Interface.Addresses...)
+	for _, peer := range server.Peers {
+		t.ReserveRanges(peer.AllowedIPs...)
+	}
+}
+
+func (t *Template) reserve(r IPCidr) {
+	t.reserved = append(t.reserved, r)
+	for _, pool := range t.pools {
+		pool.ReserveRange(r)
+	}
+}
+
+// Render substitutes values, falling back to defaults for variables that are
+// missing, and parses the result. Addresses allocated by nextip() remain taken
+// for subsequent calls to Render on the same template.
+func (t *Template) Render(name string, values map[string]string) (*Config, error) {
+	for key := range values {
+		if t.variable(key) == nil {
+			return nil, &ParseError{"Value given for undeclared variable", key}
+		}
+	}
+	resolved := make(map[string]string, len(t.Variables))
+	for _, v := range t.Variables {
+		value, ok := values[v.Name]
+		if !ok {
+			if len(v.Default) == 0 {
+				return nil, &ParseError{"Missing value for variable", v.Name}
+			}
+			value = v.Default
+			if isTemplateCall(value) {
+				var err error
+				value, err = t.evaluate(value, resolved)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		value, err := validateTemplateValue(v.Type, value)
+		if err != nil {
+			return nil, err
+		}
+		resolved[v.Name] = value
+	}
+	output, err := t.substitute(func(expression string) (string, error) {
+		return t.evaluate(expression, resolved)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return FromWgQuick(output, name)
+}
+
+// substitute replaces the placeholders of the body, leaving comments as they are.
+func (t *Template) substitute(evaluate func(expression string) (string, error)) (string, error) {
+	var output strings.Builder
+	for _, line := range strings.SplitAfter(t.body, "\n") {
+		comment := ""
+		if pound := strings.IndexByte(line, '#'); pound >= 0 {
+			line, comment = line[:pound], line[pound:]
+		}
+		if err := substituteLine(&output, line, evaluate); err != nil {
+			return "", err
+		}
+		output.WriteString(comment)
+	}
+	return output.String(), nil
+}
+
+func substituteLine(output *strings.Builder, s string, evaluate func(expression string) (string, error)) error {
+	for {
+		dollar := strings.IndexByte(s, '$')
+		if dollar < 0 {
+			output.WriteString(s)
+			return nil
+		}
+		output.WriteString(s[:dollar])
+		s = s[dollar+1:]
+		if strings.HasPrefix(s, "$") {
+			output.WriteByte('$')
+			s = s[1:]
+			continue
+		}
+		if !strings.HasPrefix(s, "{") {
+			output.WriteByte('$')
+			continue
+		}
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return &ParseError{"Unterminated placeholder", "$" + strings.TrimSpace(s)}
+		}
+		value, err := evaluate(s[1:end])
+		if err != nil {
+			return err
+		}
+		output.WriteString(value)
+		s = s[end+1:]
+	}
+}
+
+func validateTemplateValue(typ TemplateVariableType, value string) (string, error) {
+	switch typ {
+	case TemplateKey:
+		k, err := parseKeyBase64(value)
+		if err != nil {
+			return "", err
+		}
+		return k.String(), nil
+	case TemplateCidr:
+		c, err := parseIPCidr(value)
+		if err != nil {
+			return "", err
+		}
+		return c.String(), nil
+	case TemplateEndpoint:
+		e, err := parseEndpoint(value)
+		if err != nil {
+			return "", err
+		}
+		return e.String(), nil
+	case TemplateInt:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", &ParseError{"Invalid integer", value}
+		}
+		return strconv.FormatInt(i, 10), nil
+	}
+	return "", fmt.Errorf("Invalid variable type %d", int(typ))
+}
+
+func isTemplateCall(expression string) bool {
+	return strings.HasSuffix(expression, ")") && strings.IndexByte(expression, '(') > 0
+}
+
+func splitTemplateCall(expression string) (function string, args []string) {
+	paren := strings.IndexByte(expression, '(')
+	function = strings.TrimSpace(expression[:paren])
+	inner := strings.TrimSpace(expression[paren+1 : len(expression)-1])
+	if len(inner) > 0 {
+		for _, arg := range strings.Split(inner, ",") {
+			args = append(args, strings.TrimSpace(arg))
+		}
+	}
+	return
+}
+
+var templateFunctionArguments = map[string]TemplateVariableType{
+	"pubkey": TemplateKey,
+	"nextip": TemplateCidr,
+}
+
+func (t *Template) checkExpression(expression string) error {
+	expression = strings.TrimSpace(expression)
+	if !isTemplateCall(expression) {
+		if t.variable(expression) == nil {
+			return &ParseError{"Undeclared variable", expression}
+		}
+		return nil
+	}
+	function, args := splitTemplateCall(expression)
+	switch function {
+	case "genkey", "genpsk":
+		if len(args) != 0 {
+			return &ParseError{"Function takes no arguments", expression}
+		}
+	case "pubkey", "nextip":
+		if len(args) != 1 {
+			return &ParseError{"Function takes exactly one variable as argument", expression}
+		}
+		v := t.variable(args[0])
+		if v == nil {
+			return &ParseError{"Undeclared variable", args[0]}
+		}
+		if v.Type != templateFunctionArguments[function] {
+			return &ParseError{fmt.Sprintf("Function requires a variable of type %s", templateFunctionArguments[function]), expression}
+		}
+	default:
+		return &ParseError{"Unknown template function", function}
+	}
+	return nil
+}
+
+func (t *Template) evaluate(expression string, resolved map[string]string) (string, error) {
+	if err := t.checkExpression(expression); err != nil {
+		return "", err
+	}
+	expression = strings.TrimSpace(expression)
+	if !isTemplateCall(expression) {
+		value, ok := resolved[expression]
+		if !ok {
+			return "", &ParseError{"Variable used before it is declared", expression}
+		}
+		return value, nil
+	}
+	function, args := splitTemplateCall(expression)
+	var arg string
+	if len(args) == 1 {
+		var ok bool
+		arg, ok = resolved[args[0]]
+		if !ok {
+			return "", &ParseError{"Variable used before it is declared", args[0]}
+		}
+	}
+	switch function {
+	case "genkey":
+		k, err := NewPrivateKey()
+		if err != nil {
+			return "", err
+		}
+		return k.String(), nil
+	case "genpsk":
+		k, err := NewPresharedKey()
+		if err != nil {
+			return "", err
+		}
+		return k.String(), nil
+	case "pubkey":
+		k, err := parseKeyBase64(arg)
+		if err != nil {
+			return "", err
+		}
+		return k.Public().String(), nil
+	case "nextip":
+		prefix, err := parseIPCidr(arg)
+		if err != nil {
+			return "", err
+		}
+		pool := t.pool(*prefix)
+		address, err := pool.Next()
+		if err != nil {
+			return "", err
+		}
+		return address.String(), nil
+	}
+	return "", &ParseError{"Unknown template function", function}
+}
+
+func (t *Template) pool(prefix IPCidr) *AddressPool {
+	prefix.MaskSelf()
+	key := prefix.String()
+	pool, ok := t.pools[key]
+	if !ok {
+		pool = NewAddressPool(prefix)
+		for _, a := range t.reserved {
+			pool.ReserveRange(a)
+		}
+		t.pools[key] = pool
+	}
+	return pool
+}
@@ -0,0 +1,158 @@
+package conf
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+const testTemplate = `[Variables]
+PrivateKey = key genkey()
+Subnet = cidr 10.9.0.0/24
+Address = cidr nextip(Subnet)
+Server = endpoint vpn.example.com:51820
+Keepalive = int 25
+
+[Interface]
+PrivateKey = ${PrivateKey}
+Address = ${Address}
+
+[Peer]
+# Costs $$0, reachable at ${Server}
+PublicKey = JRI8Xc0zKP9kXk8qP84NdUQA04h6DLfFbwJn4g+/PFs=
+PresharedKey = ${genpsk()}
+Endpoint = ${Server}
+AllowedIPs = ${Subnet}
+PersistentKeepalive = ${Keepalive}
+`
+
+func TestTemplateRender(test *testing.T) {
+	t, err := ParseTemplate(testTemplate)
+	if err != nil {
+		test.Fatal(err)
+	}
+	t.ReserveAddresses(IPCidr{IP: net.ParseIP("10.9.0.1"), Cidr: 32})
+
+	alice, err := t.Render("alice", nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	bob, err := t.Render("bob", map[string]string{"Server": "[2001:db8::1]:1234", "Keepalive": "0"})
+	if err != nil {
+		test.Fatal(err)
+	}
+	if a := alice.Interface.Addresses[0].String(); a != "10.9.0.2/24" {
+		test.Errorf("Expected first allocation to be 10.9.0.2/24, got %s", a)
+	}
+	if a := bob.Interface.Addresses[0].String(); a != "10.9.0.3/24" {
+		test.Errorf("Expected second allocation to be 10.9.0.3/24, got %s", a)
+	}
+	if alice.Interface.PrivateKey == bob.Interface.PrivateKey || alice.Interface.PrivateKey.IsZero() {
+		test.Error("Expected distinct generated private keys")
+	}
+	if alice.Peers[0].PresharedKey.IsZero() {
+		test.Error("Expected generated preshared key")
+	}
+	if alice.Peers[0].PersistentKeepalive != 25 || bob.Peers[0].PersistentKeepalive != 0 {
+		test.Error("Unexpected keepalive values")
+	}
+	if e := bob.Peers[0].Endpoint.String(); e != "[2001:db8::1]:1234" {
+		test.Errorf("Unexpected endpoint %s", e)
+	}
+}
+
+const testServerTemplate = `[Variables]
+Subnet = cidr 10.8.0.0/24
+
+[Interface]
+# Clients are given ${Address} = ${nextip(Subnet)} by their own template
+PrivateKey = cJM9wXUVXc0fa/t5b/Lm0BHNx6jh5UiTLsO+oJhyQUU=
+Address = 10.8.0.1/24, fd08::1/64
+
+[Peer]
+PublicKey = JRI8Xc0zKP9kXk8qP84NdUQA04h6DLfFbwJn4g+/PFs=
+AllowedIPs = 10.8.0.2/31, 192.168.1.0/24
+`
+
+func TestTemplateRenderClientOfServer(test *testing.T) {
+	serverTemplate, err := ParseTemplate(testServerTemplate)
+	if err != nil {
+		test.Fatal(err)
+	}
+	server, err := serverTemplate.Render("server", nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	clientTemplate, err := ParseTemplate(strings.Replace(testTemplate, "10.9.0.0/24", "10.8.0.0/24", 1))
+	if err != nil {
+		test.Fatal(err)
+	}
+	clientTemplate.ReserveConfig(server)
+	client, err := clientTemplate.Render("client", nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if a := client.Interface.Addresses[0].String(); a != "10.8.0.4/24" {
+		test.Errorf("Expected the first address after the server and its peer, got %s", a)
+	}
+}
+
+func TestTemplateErrors(test *testing.T) {
+	invalid := []string{
+		"[Variables]\nA = float\n[Interface]\nPrivateKey = ${A}\n",
+		"[Variables]\nA = key\nA = key\n",
+		"[Interface]\nPrivateKey = ${Missing}\n",
+		"[Variables]\nA = int\nB = cidr nextip(A)\n",
+		"[Variables]\nA = key frobnicate()\n",
+		"[Interface]\nPrivateKey = ${genkey()\n",
+		"[Interface]\nPrivateKey = ${genkey()  # }\n",
+	}
+	for _, s := range invalid {
+		if _, err := ParseTemplate(s); err == nil {
+			test.Errorf("Expected error parsing template:\n%s", s)
+		}
+	}
+
+	t, err := ParseTemplate(testTemplate)
+	if err != nil {
+		test.Fatal(err)
+	}
+	renders := []map[string]string{
+		{"Keepalive": "twenty"},
+		{"Server": "no-port"},
+		{"Address": "10.9.0.300/24"},
+		{"Unknown": "1"},
+	}
+	for _, values := range renders {
+		if _, err := t.Render("test", values); err == nil {
+			test.Errorf("Expected error rendering with %v", values)
+		}
+	}
+
+	t, err = ParseTemplate(strings.Replace(testTemplate, "10.9.0.0/24", "10.9.0.0/30", 1))
+	if err != nil {
+		test.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err = t.Render("test", nil); err != nil {
+			test.Fatal(err)
+		}
+	}
+	if _, err = t.Render("test", nil); err == nil {
+		test.Error("Expected pool exhaustion")
+	} else if _, ok := err.(*PoolExhaustedError); !ok {
+		test.Errorf("Expected PoolExhaustedError, got %v", err)
+	}
+}
+
+func TestKeys(test *testing.T) {
+	// Alice's key pair from section 6.1 of RFC 7748.
+	private, err := parseKeyHex("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a")
+	if err != nil {
+		test.Fatal(err)
+	}
+	if public := private.Public().HexString(); public != "8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a" {
+		test.Errorf("Unexpected public key %s", public)
+	}
+}
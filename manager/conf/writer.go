@@ -18,25 +18,39 @@ func (conf *Config) ToWgQuick() string {
 	}
 
 	if len(conf.Interface.Addresses) > 0 {
-		var addrStrings [len(conf.Interface.Addresses)]string
+		addrStrings := make([]string, len(conf.Interface.Addresses))
 		for i, address := range conf.Interface.Addresses {
 			addrStrings[i] = address.String()
 		}
-		output.WriteString(fmt.Sprintf("Address = %s\n", strings.Join(addrStrings[:], ", ")))
+		output.WriteString(fmt.Sprintf("Address = %s\n", strings.Join(addrStrings, ", ")))
 	}
 
 	if len(conf.Interface.Dns) > 0 {
-		var addrStrings [len(conf.Interface.Dns)]string
+		addrStrings := make([]string, len(conf.Interface.Dns))
 		for i, address := range conf.Interface.Dns {
 			addrStrings[i] = address.String()
 		}
-		output.WriteString(fmt.Sprintf("DNS = %s\n", strings.Join(addrStrings[:], ", ")))
+		output.WriteString(fmt.Sprintf("DNS = %s\n", strings.Join(addrStrings, ", ")))
 	}
 
 	if conf.Interface.Mtu > 0 {
 		output.WriteString(fmt.Sprintf("MTU = %d\n", conf.Interface.Mtu))
 	}
 
+	for _, hook := range []struct {
+		key      string
+		commands []string
+	}{
+		{"PreUp", conf.Interface.PreUp},
+		{"PostUp", conf.Interface.PostUp},
+		{"PreDown", conf.Interface.PreDown},
+		{"PostDown", conf.Interface.PostDown},
+	} {
+		for _, command := range hook.commands {
+			output.WriteString(fmt.Sprintf("%s = %s\n", hook.key, command))
+		}
+	}
+
 	for _, peer := range conf.Peers {
 		output.WriteString("\n[Peer]\n")
 
@@ -47,11 +61,11 @@ func (conf *Config) ToWgQuick() string {
 		}
 
 		if len(peer.AllowedIPs) > 0 {
-			var addrStrings [len(peer.AllowedIPs)]string
+			addrStrings := make([]string, len(peer.AllowedIPs))
 			for i, address := range peer.AllowedIPs {
 				addrStrings[i] = address.String()
 			}
-			output.WriteString(fmt.Sprintf("AllowedIPs = %s\n", strings.Join(addrStrings[:], ", ")))
+			output.WriteString(fmt.Sprintf("AllowedIPs = %s\n", strings.Join(addrStrings, ", ")))
 		}
 
 		if !peer.Endpoint.IsEmpty() {
@@ -65,6 +79,30 @@ func (conf *Config) ToWgQuick() string {
 	return output.String()
 }
 
+// Resolve looks up the host of the endpoint, preferring an IPv4 address, since
+// the UAPI only accepts literal addresses.
+func (e *Endpoint) Resolve() (*Endpoint, error) {
+	ips, err := net.LookupIP(e.Host)
+	if err != nil {
+		return nil, err
+	}
+	var ip net.IP
+	for _, iterip := range ips {
+		iterip = iterip.To4()
+		if iterip != nil {
+			ip = iterip
+			break
+		}
+		if ip == nil {
+			ip = iterip
+		}
+	}
+	if ip == nil {
+		return nil, errors.New("Unable to resolve IP address of endpoint")
+	}
+	return &Endpoint{ip.String(), e.Port}, nil
+}
+
 func (conf *Config) ToUAPI() (string, error) {
 	var output strings.Builder
 	output.WriteString(fmt.Sprintf("private_key=%s\n", conf.Interface.PrivateKey.HexString()))
@@ -81,29 +119,14 @@ func (conf *Config) ToUAPI() (string, error) {
 		output.WriteString(fmt.Sprintf("public_key=%s\n", peer.PublicKey.HexString()))
 
 		if !peer.PresharedKey.IsZero() {
-			output.WriteString(fmt.Sprintf("preshared_key = %s\n", peer.PresharedKey.String()))
+			output.WriteString(fmt.Sprintf("preshared_key=%s\n", peer.PresharedKey.HexString()))
 		}
 
 		if !peer.Endpoint.IsEmpty() {
-			ips, err := net.LookupIP(peer.Endpoint.Host)
+			resolvedEndpoint, err := peer.Endpoint.Resolve()
 			if err != nil {
 				return "", err
 			}
-			var ip net.IP
-			for _, iterip := range ips {
-				iterip = iterip.To4()
-				if iterip != nil {
-					ip = iterip
-					break
-				}
-				if ip == nil {
-					ip = iterip
-				}
-			}
-			if ip == nil {
-				return "", errors.New("Unable to resolve IP address of endpoint")
-			}
-			resolvedEndpoint := Endpoint{ip.String(), peer.Endpoint.Port}
 			output.WriteString(fmt.Sprintf("endpoint=%s\n", resolvedEndpoint.String()))
 		}
 
@@ -0,0 +1,214 @@
+package conf
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+type CollisionPolicy int
+
+const (
+	CollisionSkip CollisionPolicy = iota
+	CollisionRename
+	CollisionOverwrite
+)
+
+// Configuration files are tiny; anything larger is refused rather than inflated.
+const maxZipEntrySize = 1024 * 1024
+
+// ImportFilter vets every configuration before it is stored. A configuration
+// it returns an error for is not imported, and the error is reported in its
+// ImportResult. A nil filter imports everything.
+type ImportFilter func(config *Config) error
+
+type ImportResult struct {
+	FileName string     // Path of the file, or of the entry inside the archive.
+	Name     TunnelName // Name of the imported tunnel, or empty if nothing was imported.
+	Skipped  bool
+	Err      error
+}
+
+func ImportZipFile(filename string, policy CollisionPolicy, filter ImportFilter) ([]ImportResult, error) {
+	reader, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return importZip(&reader.Reader, policy, filter)
+}
+
+// ImportZip stores every .conf file found in the archive as a tunnel. Problems
+// with individual files are reported in their ImportResult and do not stop the
+// import; only a failure to read the archive itself is returned as an error.
+func ImportZip(r io.ReaderAt, size int64, policy CollisionPolicy, filter ImportFilter) ([]ImportResult, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return importZip(reader, policy, filter)
+}
+
+func importZip(reader *zip.Reader, policy CollisionPolicy, filter ImportFilter) ([]ImportResult, error) {
+	existing, err := ListConfigNames()
+	if err != nil {
+		return nil, err
+	}
+	var results []ImportResult
+	for _, file := range reader.File {
+		base := path.Base(file.Name)
+		if file.FileInfo().IsDir() || strings.HasPrefix(file.Name, "__MACOSX/") || strings.HasPrefix(base, ".") || !strings.HasSuffix(strings.ToLower(base), configFileSuffix) {
+			continue
+		}
+		result := ImportResult{FileName: file.Name}
+		result.Name, result.Skipped, result.Err = importZipEntry(file, existing, policy, filter)
+		if len(result.Name) > 0 {
+			existing = append(existing, result.Name)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func importZipEntry(file *zip.File, existing []TunnelName, policy CollisionPolicy, filter ImportFilter) (name TunnelName, skipped bool, err error) {
+	base := path.Base(file.Name)
+	name, err = NewTunnelName(base[:len(base)-len(configFileSuffix)])
+	if err != nil {
+		return "", false, err
+	}
+	if file.UncompressedSize64 > maxZipEntrySize {
+		return "", false, &ParseError{"Configuration file is too large", file.Name}
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return "", false, err
+	}
+	bytes, err := ioutil.ReadAll(io.LimitReader(rc, maxZipEntrySize+1))
+	rc.Close()
+	if err != nil {
+		return "", false, err
+	}
+	if len(bytes) > maxZipEntrySize {
+		return "", false, &ParseError{"Configuration file is too large", file.Name}
+	}
+	config, err := FromWgQuick(string(bytes), string(name))
+	if err != nil {
+		return "", false, err
+	}
+	return storeImported(config, existing, policy, filter)
+}
+
+// ImportFile stores a single .conf file as a tunnel named after the file.
+func ImportFile(filename string, policy CollisionPolicy, filter ImportFilter) ImportResult {
+	result := ImportResult{FileName: filename}
+	existing, err := ListConfigNames()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	config, err := LoadFromPath(filename)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Name, result.Skipped, result.Err = storeImported(config, existing, policy, filter)
+	return result
+}
+
+func storeImported(config *Config, existing []TunnelName, policy CollisionPolicy, filter ImportFilter) (name TunnelName, skipped bool, err error) {
+	if filter != nil {
+		if err = filter(config); err != nil {
+			return "", false, err
+		}
+	}
+	name = config.Name
+	if collision, found := FindTunnelName(existing, name); found {
+		switch policy {
+		case CollisionSkip:
+			return "", true, nil
+		case CollisionOverwrite:
+			// Keep the existing spelling, so that case-only differences do not leave two files behind.
+			config.Name = collision
+		case CollisionRename:
+			config.Name, err = uniqueName(existing, name)
+			if err != nil {
+				return "", false, err
+			}
+		default:
+			return "", false, errors.New("Invalid collision policy")
+		}
+	}
+	err = config.Save()
+	if err != nil {
+		return "", false, err
+	}
+	return config.Name, false, nil
+}
+
+func uniqueName(existing []TunnelName, name TunnelName) (TunnelName, error) {
+	for i := 2; i < 1000; i++ {
+		suffix := fmt.Sprintf("-%d", i)
+		base := string(name)
+		if len(base)+len(suffix) > maxTunnelNameLength {
+			base = base[:maxTunnelNameLength-len(suffix)]
+		}
+		candidate, err := NewTunnelName(base + suffix)
+		if err != nil {
+			continue
+		}
+		if TunnelNameIsUnique(existing, candidate) {
+			return candidate, nil
+		}
+	}
+	return "", &ParseError{"Unable to find a free tunnel name", string(name)}
+}
+
+func ExportZipFile(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = ExportZip(file)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(filename)
+	}
+	return err
+}
+
+// ExportZip writes every stored tunnel into a zip archive as name.conf.
+func ExportZip(w io.Writer) error {
+	names, err := ListConfigNames()
+	if err != nil {
+		return err
+	}
+	writer := zip.NewWriter(w)
+	for _, name := range names {
+		config, err := LoadFromName(name)
+		if err != nil {
+			return fmt.Errorf("Unable to load %s: %v", name, err)
+		}
+		header := &zip.FileHeader{
+			Name:   name.FileName(),
+			Method: zip.Deflate,
+		}
+		header.SetMode(0600)
+		entry, err := writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(entry, config.ToWgQuick())
+		if err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
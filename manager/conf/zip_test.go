@@ -0,0 +1,194 @@
+package conf
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+const testInput = `[Interface]
+PrivateKey = cJM9wXUVXc0fa/t5b/Lm0BHNx6jh5UiTLsO+oJhyQUU=
+Address = 192.168.4.84/24
+DNS = 8.8.8.8, 1.1.1.1
+
+[Peer]
+PublicKey = JRI8Xc0zKP9kXk8qP84NdUQA04h6DLfFbwJn4g+/PFs=
+Endpoint = demo.wireguard.com:12912
+AllowedIPs = 0.0.0.0/0
+`
+
+func useTemporaryStore(test *testing.T) func() {
+	dir, err := ioutil.TempDir("", "wireguard-conf-test")
+	if err != nil {
+		test.Fatal(err)
+	}
+	oldRoot := rootDirectory
+	rootDirectory = func() (string, error) {
+		return dir, nil
+	}
+	cachedConfigFileDir = ""
+	return func() {
+		rootDirectory = oldRoot
+		cachedConfigFileDir = ""
+		os.RemoveAll(dir)
+	}
+}
+
+func makeZip(test *testing.T, files map[string]string) *bytes.Reader {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, err := writer.Create(name)
+		if err != nil {
+			test.Fatal(err)
+		}
+		w.Write([]byte(files[name]))
+	}
+	if err := writer.Close(); err != nil {
+		test.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestZipImportExport(test *testing.T) {
+	defer useTemporaryStore(test)()
+
+	existing, err := FromWgQuick(testInput, "office")
+	if err != nil {
+		test.Fatal(err)
+	}
+	if err = existing.Save(); err != nil {
+		test.Fatal(err)
+	}
+
+	archive := makeZip(test, map[string]string{
+		"tunnels/home.conf":     testInput,
+		"tunnels/Office.conf":   testInput,
+		"tunnels/broken.conf":   "[Interface]\nPrivateKey = nope\n",
+		"tunnels/bad name.conf": testInput,
+		"tunnels/README.txt":    "ignored",
+	})
+	results, err := ImportZip(archive, archive.Size(), CollisionRename, nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if len(results) != 4 {
+		test.Fatalf("Expected 4 results, got %d", len(results))
+	}
+	byFile := make(map[string]ImportResult)
+	for _, result := range results {
+		byFile[result.FileName] = result
+	}
+	if r := byFile["tunnels/home.conf"]; r.Err != nil || r.Name != "home" {
+		test.Errorf("Unexpected result for home.conf: %+v", r)
+	}
+	if r := byFile["tunnels/Office.conf"]; r.Err != nil || r.Name != "Office-2" {
+		test.Errorf("Unexpected result for Office.conf: %+v", r)
+	}
+	if r := byFile["tunnels/broken.conf"]; r.Err == nil || len(r.Name) > 0 {
+		test.Errorf("Expected parse error for broken.conf: %+v", r)
+	}
+	if r := byFile["tunnels/bad name.conf"]; r.Err == nil {
+		test.Errorf("Expected name error for bad name.conf: %+v", r)
+	}
+
+	results, err = ImportZip(archive, archive.Size(), CollisionSkip, nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	for _, result := range results {
+		if result.FileName == "tunnels/home.conf" && !result.Skipped {
+			test.Errorf("Expected home.conf to be skipped: %+v", result)
+		}
+	}
+
+	results, err = ImportZip(archive, archive.Size(), CollisionOverwrite, nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	for _, result := range results {
+		if result.FileName == "tunnels/Office.conf" && result.Name != "office" {
+			test.Errorf("Expected Office.conf to overwrite office: %+v", result)
+		}
+	}
+
+	tunnelNames, err := ListConfigNames()
+	if err != nil {
+		test.Fatal(err)
+	}
+	var names []string
+	for _, name := range tunnelNames {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	if len(names) != 3 || names[0] != "Office-2" || names[1] != "home" || names[2] != "office" {
+		test.Errorf("Unexpected tunnels after import: %v", names)
+	}
+
+	var buf bytes.Buffer
+	if err = ExportZip(&buf); err != nil {
+		test.Fatal(err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		test.Fatal(err)
+	}
+	if len(reader.File) != 3 {
+		test.Errorf("Expected 3 files in export, got %d", len(reader.File))
+	}
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			test.Fatal(err)
+		}
+		contents, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		if string(contents) != existing.ToWgQuick() {
+			test.Errorf("Unexpected contents of %s:\n%s", file.Name, contents)
+		}
+	}
+}
+
+func TestImportFile(test *testing.T) {
+	defer useTemporaryStore(test)()
+
+	dir, err := ioutil.TempDir("", "wireguard-import-test")
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "home.conf")
+	if err = ioutil.WriteFile(filename, []byte(testInput), 0600); err != nil {
+		test.Fatal(err)
+	}
+
+	if r := ImportFile(filename, CollisionSkip, nil); r.Err != nil || r.Name != "home" {
+		test.Errorf("Unexpected result for first import: %+v", r)
+	}
+	if r := ImportFile(filename, CollisionSkip, nil); r.Err != nil || !r.Skipped {
+		test.Errorf("Expected second import to be skipped: %+v", r)
+	}
+	if r := ImportFile(filename, CollisionRename, nil); r.Err != nil || r.Name != "home-2" {
+		test.Errorf("Unexpected result for renamed import: %+v", r)
+	}
+	if r := ImportFile(filepath.Join(dir, "missing.conf"), CollisionSkip, nil); r.Err == nil {
+		test.Error("Expected error importing a missing file")
+	}
+
+	refuse := func(config *Config) error {
+		return errors.New("Refused")
+	}
+	if r := ImportFile(filename, CollisionRename, refuse); r.Err == nil || len(r.Name) > 0 {
+		test.Errorf("Expected filtered import to fail: %+v", r)
+	}
+}
@@ -0,0 +1,242 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+type Event int
+
+const (
+	PreUp Event = iota
+	PostUp
+	PreDown
+	PostDown
+)
+
+func (e Event) String() string {
+	switch e {
+	case PreUp:
+		return "PreUp"
+	case PostUp:
+		return "PostUp"
+	case PreDown:
+		return "PreDown"
+	case PostDown:
+		return "PostDown"
+	}
+	return "Event(" + strconv.Itoa(int(e)) + ")"
+}
+
+// Commands returns the commands of config to run for the event.
+func (e Event) Commands(config *conf.Config) []string {
+	switch e {
+	case PreUp:
+		return config.Interface.PreUp
+	case PostUp:
+		return config.Interface.PostUp
+	case PreDown:
+		return config.Interface.PreDown
+	case PostDown:
+		return config.Interface.PostDown
+	}
+	return nil
+}
+
+// Executor runs a single command, writing its combined output to output, and
+// gives up when ctx is done.
+type Executor interface {
+	Execute(ctx context.Context, argv []string, env []string, output io.Writer) error
+}
+
+// ExecExecutor runs commands as child processes, without a shell.
+type ExecExecutor struct{}
+
+func (ExecExecutor) Execute(ctx context.Context, argv []string, env []string, output io.Writer) error {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Env = env
+	cmd.Stdout = output
+	cmd.Stderr = output
+	return cmd.Run()
+}
+
+// SplitCommand splits a command line into arguments at unquoted white space.
+// Double quotes group arguments and are removed; backslashes have no special
+// meaning, so that Windows paths can be written as they are.
+func SplitCommand(command string) ([]string, error) {
+	var argv []string
+	var current strings.Builder
+	inQuotes, inArgument := false, false
+	for _, c := range command {
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+			inArgument = true
+		case (c == ' ' || c == '\t') && !inQuotes:
+			if inArgument {
+				argv = append(argv, current.String())
+				current.Reset()
+				inArgument = false
+			}
+		default:
+			current.WriteRune(c)
+			inArgument = true
+		}
+	}
+	if inQuotes {
+		return nil, errors.New("Unterminated quote in command")
+	}
+	if inArgument {
+		argv = append(argv, current.String())
+	}
+	if len(argv) == 0 {
+		return nil, errors.New("Empty command")
+	}
+	return argv, nil
+}
+
+// Runner runs the hooks of a tunnel according to a policy.
+type Runner struct {
+	Policy   *Policy
+	Executor Executor    // nil means ExecExecutor.
+	Logger   *log.Logger // Receives the output of commands and what the runner does.
+
+	// Interface is the adapter name that replaces %i in commands, as in wg-quick.
+	Interface string
+
+	// Environ is the environment that the tunnel variables are added to; nil
+	// means the environment of the current process.
+	Environ []string
+}
+
+func (r *Runner) environment(event Event, config *conf.Config) []string {
+	env := r.Environ
+	if env == nil {
+		env = os.Environ()
+	}
+	addresses := make([]string, len(config.Interface.Addresses))
+	for i := range config.Interface.Addresses {
+		addresses[i] = config.Interface.Addresses[i].String()
+	}
+	dns := make([]string, len(config.Interface.Dns))
+	for i, ip := range config.Interface.Dns {
+		dns[i] = ip.String()
+	}
+	return append(append([]string{}, env...),
+		"WIREGUARD_EVENT="+event.String(),
+		"WIREGUARD_TUNNEL_NAME="+string(config.Name),
+		"WIREGUARD_INTERFACE="+r.Interface,
+		"WIREGUARD_PUBLIC_KEY="+config.Interface.PrivateKey.Public().String(),
+		"WIREGUARD_LISTEN_PORT="+strconv.Itoa(int(config.Interface.ListenPort)),
+		"WIREGUARD_ADDRESSES="+strings.Join(addresses, ","),
+		"WIREGUARD_DNS="+strings.Join(dns, ","),
+		"WIREGUARD_PEERS="+strconv.Itoa(len(config.Peers)),
+	)
+}
+
+// Run runs the commands of config for event, one after the other. The up
+// events stop at the first failure, which should abort bringing the tunnel
+// up; the down events run every command regardless, so that the tunnel can
+// always go down, and report the first failure. When hooks are disabled,
+// nothing is run and the commands are only logged.
+func (r *Runner) Run(event Event, config *conf.Config) error {
+	commands := event.Commands(config)
+	if len(commands) == 0 {
+		return nil
+	}
+	if r.Policy == nil || !r.Policy.Enabled {
+		r.Logger.Printf("Ignoring %d %s commands, because hooks are disabled", len(commands), event)
+		return nil
+	}
+	env := r.environment(event, config)
+	var firstErr error
+	for _, command := range commands {
+		err := r.runOne(event, strings.Replace(command, "%i", r.Interface, -1), env)
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("%s command ‘%s’ failed: %v", event, command, err)
+		r.Logger.Println(err)
+		if event == PreUp || event == PostUp {
+			return err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (r *Runner) runOne(event Event, command string, env []string) error {
+	argv, err := SplitCommand(command)
+	if err != nil {
+		return err
+	}
+	if !r.Policy.Allows(argv[0]) {
+		return errors.New("Executable is not in the allowed list")
+	}
+	executor := r.Executor
+	if executor == nil {
+		executor = ExecExecutor{}
+	}
+	timeout := r.Policy.timeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	r.Logger.Printf("%s: running %s", event, command)
+	output := &lineLogger{logger: r.Logger, prefix: event.String() + ": "}
+	err = executor.Execute(ctx, argv, env, output)
+	output.Flush()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("Timed out after %v", timeout)
+	}
+	return err
+}
+
+// lineLogger logs every complete line written to it.
+type lineLogger struct {
+	mutex   sync.Mutex
+	logger  *log.Logger
+	prefix  string
+	partial []byte
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.partial = append(l.partial, p...)
+	for {
+		newline := bytes.IndexByte(l.partial, '\n')
+		if newline < 0 {
+			break
+		}
+		l.logger.Print(l.prefix + strings.TrimRight(string(l.partial[:newline]), "\r"))
+		l.partial = l.partial[newline+1:]
+	}
+	return len(p), nil
+}
+
+func (l *lineLogger) Flush() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.partial) > 0 {
+		l.logger.Print(l.prefix + string(l.partial))
+		l.partial = nil
+	}
+}
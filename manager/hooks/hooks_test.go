@@ -0,0 +1,202 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+type call struct {
+	argv []string
+	env  []string
+}
+
+// fakeExecutor records calls and plays back scripted output and results.
+type fakeExecutor struct {
+	calls   []call
+	output  map[string]string
+	fail    map[string]error
+	blocked map[string]bool
+}
+
+func (f *fakeExecutor) Execute(ctx context.Context, argv []string, env []string, output io.Writer) error {
+	f.calls = append(f.calls, call{argv, env})
+	io.WriteString(output, f.output[argv[0]])
+	if f.blocked[argv[0]] {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return f.fail[argv[0]]
+}
+
+const testConfig = `[Interface]
+PrivateKey = cJM9wXUVXc0fa/t5b/Lm0BHNx6jh5UiTLsO+oJhyQUU=
+Address = 192.168.4.84/24, fd00::1/64
+PreUp = /usr/bin/echo "up %i"
+PreUp = /usr/bin/true
+PostDown = /usr/bin/false
+PostDown = /usr/bin/echo down
+PreDown = echo relative
+
+[Peer]
+PublicKey = JRI8Xc0zKP9kXk8qP84NdUQA04h6DLfFbwJn4g+/PFs=
+AllowedIPs = 0.0.0.0/0
+`
+
+func setup(test *testing.T, policy *Policy) (*Runner, *fakeExecutor, *bytes.Buffer, *conf.Config) {
+	config, err := conf.FromWgQuick(testConfig, "office")
+	if err != nil {
+		test.Fatal(err)
+	}
+	executor := &fakeExecutor{output: map[string]string{}, fail: map[string]error{}, blocked: map[string]bool{}}
+	var buf bytes.Buffer
+	runner := &Runner{
+		Policy:    policy,
+		Executor:  executor,
+		Logger:    log.New(&buf, "", 0),
+		Interface: "office",
+		Environ:   []string{"PATH=/usr/bin"},
+	}
+	return runner, executor, &buf, config
+}
+
+var allowAll = &Policy{Enabled: true, Allowed: []string{"/usr/bin/echo", "/usr/bin/true", "/usr/bin/false"}}
+
+func TestDisabledByDefault(test *testing.T) {
+	runner, executor, buf, config := setup(test, &Policy{})
+	if err := runner.Run(PreUp, config); err != nil {
+		test.Fatal(err)
+	}
+	if len(executor.calls) != 0 {
+		test.Errorf("Expected no commands to run, got %v", executor.calls)
+	}
+	if !strings.Contains(buf.String(), "Ignoring 2 PreUp commands") {
+		test.Errorf("Expected a log message, got %q", buf.String())
+	}
+	runner.Policy = nil
+	if err := runner.Run(PreUp, config); err != nil || len(executor.calls) != 0 {
+		test.Errorf("Expected nil policy to run nothing: %v", err)
+	}
+}
+
+func TestRun(test *testing.T) {
+	runner, executor, buf, config := setup(test, allowAll)
+	executor.output["/usr/bin/echo"] = "first line\r\nsecond line\nunterminated"
+	if err := runner.Run(PreUp, config); err != nil {
+		test.Fatal(err)
+	}
+	if len(executor.calls) != 2 {
+		test.Fatalf("Expected 2 calls, got %d", len(executor.calls))
+	}
+	if argv := executor.calls[0].argv; fmt.Sprintf("%q", argv) != `["/usr/bin/echo" "up office"]` {
+		test.Errorf("Unexpected arguments %q", argv)
+	}
+	env := strings.Join(executor.calls[0].env, "\n")
+	for _, v := range []string{"PATH=/usr/bin", "WIREGUARD_EVENT=PreUp", "WIREGUARD_TUNNEL_NAME=office", "WIREGUARD_INTERFACE=office", "WIREGUARD_ADDRESSES=192.168.4.84/24,fd00::1/64", "WIREGUARD_PEERS=1"} {
+		if !strings.Contains(env+"\n", v+"\n") {
+			test.Errorf("Missing %s in environment:\n%s", v, env)
+		}
+	}
+	for _, line := range []string{"PreUp: first line\n", "PreUp: second line\n", "PreUp: unterminated\n"} {
+		if !strings.Contains(buf.String(), line) {
+			test.Errorf("Missing %q in log:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestFailures(test *testing.T) {
+	runner, executor, buf, config := setup(test, allowAll)
+	executor.fail["/usr/bin/echo"] = errors.New("exit status 1")
+	if err := runner.Run(PreUp, config); err == nil || len(executor.calls) != 1 {
+		test.Errorf("Expected PreUp to stop at the first failure: %v, %d calls", err, len(executor.calls))
+	}
+
+	executor.calls = nil
+	executor.fail = map[string]error{"/usr/bin/false": errors.New("exit status 1")}
+	err := runner.Run(PostDown, config)
+	if err == nil || !strings.Contains(err.Error(), "/usr/bin/false") || len(executor.calls) != 2 {
+		test.Errorf("Expected PostDown to run everything and report the failure: %v, %d calls", err, len(executor.calls))
+	}
+
+	executor.calls = nil
+	if err = runner.Run(PreDown, config); err == nil || len(executor.calls) != 0 {
+		test.Errorf("Expected relative executable to be refused: %v", err)
+	}
+	if !strings.Contains(buf.String(), "not in the allowed list") {
+		test.Errorf("Expected refusal to be logged:\n%s", buf.String())
+	}
+}
+
+func TestTimeout(test *testing.T) {
+	runner, executor, _, config := setup(test, &Policy{Enabled: true, Allowed: allowAll.Allowed, TimeoutSeconds: 1})
+	executor.blocked["/usr/bin/echo"] = true
+	start := time.Now()
+	err := runner.Run(PreUp, config)
+	if err == nil || !strings.Contains(err.Error(), "Timed out") {
+		test.Errorf("Expected timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		test.Errorf("Timeout took %v", elapsed)
+	}
+}
+
+func TestSplitCommand(test *testing.T) {
+	for command, expected := range map[string]string{
+		`C:\Tools\x.exe /a "b c"`:  `["C:\\Tools\\x.exe" "/a" "b c"]`,
+		`"C:\Program Files\y.exe"`: `["C:\\Program Files\\y.exe"]`,
+		"  a\t b  ":                `["a" "b"]`,
+		`a ""`:                     `["a" ""]`,
+		`a"b c"d`:                  `["ab cd"]`,
+	} {
+		argv, err := SplitCommand(command)
+		if err != nil || fmt.Sprintf("%q", argv) != expected {
+			test.Errorf("SplitCommand(%s) = %q, %v, expected %s", command, argv, err, expected)
+		}
+	}
+	for _, command := range []string{"", "   ", `a "b`} {
+		if _, err := SplitCommand(command); err == nil {
+			test.Errorf("Expected error splitting %q", command)
+		}
+	}
+}
+
+func TestLoadPolicy(test *testing.T) {
+	dir, err := ioutil.TempDir("", "wireguard-hooks-test")
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "hooks.json")
+	policy, err := LoadPolicy(filename)
+	if err != nil || policy.Enabled {
+		test.Errorf("Expected missing policy to be disabled: %+v, %v", policy, err)
+	}
+	ioutil.WriteFile(filename, []byte(`{"enabled": true, "allowed": ["/usr/bin/echo", "relative"], "timeout_seconds": 5}`), 0600)
+	policy, err = LoadPolicy(filename)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if policy.timeout() != 5*time.Second || !policy.Allows("/usr/bin/../bin/echo") || policy.Allows("relative") || policy.Allows("/usr/bin/true") {
+		test.Errorf("Unexpected policy behaviour: %+v", policy)
+	}
+	ioutil.WriteFile(filename, []byte(`{"enabled": tru`), 0600)
+	if _, err = LoadPolicy(filename); err == nil {
+		test.Error("Expected error for malformed policy")
+	}
+}
@@ -0,0 +1,91 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package hooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+// DefaultPolicyFile is the name of the administrator's hook policy, as read
+// by conf.ReadAdminFile. Hooks are disabled unless an administrator creates it.
+const DefaultPolicyFile = "hooks.json"
+
+// DefaultTimeout bounds each command when the policy does not say otherwise.
+const DefaultTimeout = 30 * time.Second
+
+// Policy decides which hook commands may run. The zero value runs nothing.
+type Policy struct {
+	Enabled bool `json:"enabled"`
+
+	// Allowed lists the absolute paths of the executables that commands may
+	// start. Executables found through the search path are never allowed,
+	// since whoever can write to a directory on it could substitute their own.
+	Allowed []string `json:"allowed"`
+
+	// TimeoutSeconds bounds each command; zero means DefaultTimeout.
+	TimeoutSeconds int `json:"timeout_seconds"`
+}
+
+// LoadPolicy reads a policy from a JSON file. A missing file is not an
+// error and yields the disabled zero policy.
+func LoadPolicy(filename string) (*Policy, error) {
+	return parsePolicy(ioutil.ReadFile(filename))
+}
+
+// LoadDefaultPolicy reads the administrator's hook policy.
+func LoadDefaultPolicy() (*Policy, error) {
+	return parsePolicy(conf.ReadAdminFile(DefaultPolicyFile))
+}
+
+func parsePolicy(contents []byte, err error) (*Policy, error) {
+	if os.IsNotExist(err) {
+		return &Policy{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	policy := &Policy{}
+	err = json.Unmarshal(contents, policy)
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func (policy *Policy) timeout() time.Duration {
+	if policy.TimeoutSeconds <= 0 {
+		return DefaultTimeout
+	}
+	return time.Duration(policy.TimeoutSeconds) * time.Second
+}
+
+func samePath(a, b string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// Allows reports whether executable may be run.
+func (policy *Policy) Allows(executable string) bool {
+	if !policy.Enabled || !filepath.IsAbs(executable) {
+		return false
+	}
+	executable = filepath.Clean(executable)
+	for _, allowed := range policy.Allowed {
+		if filepath.IsAbs(allowed) && samePath(filepath.Clean(allowed), executable) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package humanize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TranslationFunction has the signature of walk.TranslationFunction, so the
+// two can be converted into each other and share one set of translations.
+type TranslationFunction func(source string, context ...string) string
+
+// Formatter turns numbers and times into short, translated text.
+type Formatter struct {
+	// Translate is applied to every piece of text; nil leaves it in English.
+	Translate TranslationFunction
+
+	// Now returns the current time for relative times; nil means time.Now.
+	Now func() time.Time
+
+	// Location is the time zone of absolute times and of calendar
+	// arithmetic; nil means time.Local.
+	Location *time.Location
+}
+
+// Default is used by the package level functions.
+var Default = &Formatter{}
+
+func SetTranslationFunc(f TranslationFunction) {
+	Default.Translate = f
+}
+
+func (f *Formatter) tr(source string, context ...string) string {
+	if f.Translate == nil {
+		return source
+	}
+	return f.Translate(source, context...)
+}
+
+func (f *Formatter) now() time.Time {
+	if f.Now == nil {
+		return time.Now()
+	}
+	return f.Now()
+}
+
+func (f *Formatter) location() *time.Location {
+	if f.Location == nil {
+		return time.Local
+	}
+	return f.Location
+}
+
+// plural picks between the singular and plural source strings, which each
+// contain one %d, and translates the chosen one.
+func (f *Formatter) plural(n int64, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf(f.tr(singular), n)
+	}
+	return fmt.Sprintf(f.tr(plural), n)
+}
+
+func (f *Formatter) decimal(value float64) string {
+	s := strconv.FormatFloat(value, 'f', 2, 64)
+	return strings.Replace(s, ".", f.tr(".", "decimal separator"), 1)
+}
+
+var byteUnits = []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// Bytes formats a byte count in binary units with two decimals, like
+// "1.50 MiB", or exactly when below 1 KiB, like "512 B".
+func (f *Formatter) Bytes(b uint64) string {
+	if b < 1024 {
+		return fmt.Sprintf(f.tr("%d B"), b)
+	}
+	value, unit := float64(b)/1024, 0
+	for value >= 1024 && unit < len(byteUnits)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf(f.tr("%s "+byteUnits[unit]), f.decimal(value))
+}
+
+// Rate formats a transfer rate given in bytes per second.
+func (f *Formatter) Rate(bytesPerSecond float64) string {
+	if bytesPerSecond < 0 {
+		bytesPerSecond = 0
+	}
+	return fmt.Sprintf(f.tr("%s/s", "rate"), f.Bytes(uint64(bytesPerSecond+0.5)))
+}
+
+type component struct {
+	n                int64
+	singular, plural string
+}
+
+// join formats the most significant non-zero component, followed by the next
+// smaller one if that is non-zero too; "3 days, 2 hours" is precise enough
+// and more readable than going down to the second.
+func (f *Formatter) join(components []component) string {
+	for i, c := range components {
+		if c.n == 0 {
+			continue
+		}
+		s := f.plural(c.n, c.singular, c.plural)
+		if i+1 < len(components) && components[i+1].n > 0 {
+			next := components[i+1]
+			s += f.tr(", ", "list separator") + f.plural(next.n, next.singular, next.plural)
+		}
+		return s
+	}
+	last := components[len(components)-1]
+	return f.plural(0, last.singular, last.plural)
+}
+
+func clockComponents(d time.Duration) []component {
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+	d -= minutes * time.Minute
+	return []component{
+		{int64(days), "%d day", "%d days"},
+		{int64(hours), "%d hour", "%d hours"},
+		{int64(minutes), "%d minute", "%d minutes"},
+		{int64(d / time.Second), "%d second", "%d seconds"},
+	}
+}
+
+// Duration formats a duration in days, hours, minutes and seconds, like
+// "2 hours, 5 minutes".
+func (f *Formatter) Duration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	return f.join(clockComponents(d))
+}
+
+// calendarComponents splits the time from a to b, a not after b, into
+// calendar years, months and days, so that leap years and month lengths are
+// honoured, followed by the remaining hours, minutes and seconds.
+func (f *Formatter) calendarComponents(a, b time.Time) []component {
+	a, b = a.In(f.location()), b.In(f.location())
+	years := b.Year() - a.Year()
+	if a.AddDate(years, 0, 0).After(b) {
+		years--
+	}
+	a = a.AddDate(years, 0, 0)
+	months := 0
+	for !a.AddDate(0, months+1, 0).After(b) {
+		months++
+	}
+	a = a.AddDate(0, months, 0)
+	rest := clockComponents(b.Sub(a))
+	return append([]component{
+		{int64(years), "%d year", "%d years"},
+		{int64(months), "%d month", "%d months"},
+	}, rest...)
+}
+
+// Ago formats t relative to now, like "5 minutes ago". Years and months are
+// calendar ones rather than fixed lengths. Times slightly in the future, as
+// happen with unsynchronised clocks, are shown as "Now".
+func (f *Formatter) Ago(t time.Time) string {
+	now := f.now()
+	d := now.Sub(t)
+	if d < time.Second && d > -time.Minute {
+		return f.tr("Now")
+	}
+	if d < 0 {
+		return fmt.Sprintf(f.tr("in %s", "future time"), f.join(f.calendarComponents(now, t)))
+	}
+	return fmt.Sprintf(f.tr("%s ago"), f.join(f.calendarComponents(t, now)))
+}
+
+// Time formats an absolute time in the local convention.
+func (f *Formatter) Time(t time.Time) string {
+	return t.In(f.location()).Format(f.tr("2006-01-02 15:04:05", "time layout"))
+}
+
+func Bytes(b uint64) string {
+	return Default.Bytes(b)
+}
+
+func Rate(bytesPerSecond float64) string {
+	return Default.Rate(bytesPerSecond)
+}
+
+func Duration(d time.Duration) string {
+	return Default.Duration(d)
+}
+
+func Ago(t time.Time) string {
+	return Default.Ago(t)
+}
+
+func Time(t time.Time) string {
+	return Default.Time(t)
+}
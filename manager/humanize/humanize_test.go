@@ -0,0 +1,118 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package humanize
+
+import (
+	"testing"
+	"time"
+)
+
+var now = time.Date(2019, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+func formatter(language string) *Formatter {
+	return &Formatter{
+		Translate: Translations(language, nil),
+		Now:       func() time.Time { return now },
+		Location:  time.UTC,
+	}
+}
+
+func TestBytes(test *testing.T) {
+	f := formatter("en")
+	for _, c := range []struct {
+		b        uint64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.00 KiB"},
+		{1536, "1.50 KiB"},
+		{5 * 1024 * 1024, "5.00 MiB"},
+		{^uint64(0), "16.00 EiB"},
+	} {
+		if s := f.Bytes(c.b); s != c.expected {
+			test.Errorf("Bytes(%d) = %q, expected %q", c.b, s, c.expected)
+		}
+	}
+	if s := f.Rate(1536.4); s != "1.50 KiB/s" {
+		test.Errorf("Unexpected rate %q", s)
+	}
+	if s := formatter("fr").Rate(1536); s != "1,50 Kio/s" {
+		test.Errorf("Unexpected French rate %q", s)
+	}
+	if s := formatter("de").Bytes(3 * 1024 * 1024 * 1024); s != "3,00 GiB" {
+		test.Errorf("Unexpected German size %q", s)
+	}
+}
+
+func TestDuration(test *testing.T) {
+	f := formatter("en")
+	for _, c := range []struct {
+		d        time.Duration
+		expected string
+	}{
+		{0, "0 seconds"},
+		{time.Second, "1 second"},
+		{90 * time.Second, "1 minute, 30 seconds"},
+		{2*time.Hour + 5*time.Second, "2 hours"},
+		{49*time.Hour + 59*time.Minute, "2 days, 1 hour"},
+		{-time.Minute, "1 minute"},
+	} {
+		if s := f.Duration(c.d); s != c.expected {
+			test.Errorf("Duration(%v) = %q, expected %q", c.d, s, c.expected)
+		}
+	}
+}
+
+func TestAgo(test *testing.T) {
+	for _, c := range []struct {
+		language string
+		t        time.Time
+		expected string
+	}{
+		{"en", now, "Now"},
+		{"en", now.Add(10 * time.Second), "Now"},
+		{"en", now.Add(-5 * time.Minute), "5 minutes ago"},
+		// February 2019 has 28 days, so this is exactly one calendar month.
+		{"en", time.Date(2019, time.February, 1, 12, 0, 0, 0, time.UTC), "1 month ago"},
+		// With fixed 365-day years, a span covering 29 February 2016 would come out a day short.
+		{"en", time.Date(2015, time.March, 1, 12, 0, 0, 0, time.UTC), "4 years ago"},
+		{"en", time.Date(2017, time.January, 15, 0, 0, 0, 0, time.UTC), "2 years, 1 month ago"},
+		{"en", now.Add(2 * time.Hour), "in 2 hours"},
+		{"de", now.Add(-26 * time.Hour), "vor 1 Tag, 2 Stunden"},
+		{"de", now, "Jetzt"},
+		{"fr", now.Add(-3 * time.Minute), "il y a 3 minutes"},
+		{"fr", time.Date(2018, time.March, 1, 11, 0, 0, 0, time.UTC), "il y a 1 an"},
+	} {
+		if s := formatter(c.language).Ago(c.t); s != c.expected {
+			test.Errorf("Ago(%v) in %s = %q, expected %q", c.t, c.language, s, c.expected)
+		}
+	}
+}
+
+func TestTimeAndFallback(test *testing.T) {
+	t := time.Date(2019, time.March, 4, 5, 6, 7, 0, time.UTC)
+	if s := formatter("de").Time(t); s != "04.03.2019 05:06:07" {
+		test.Errorf("Unexpected German time %q", s)
+	}
+	if s := formatter("fr").Time(t); s != "04/03/2019 05:06:07" {
+		test.Errorf("Unexpected French time %q", s)
+	}
+	if s := formatter("xx").Time(t); s != "2019-03-04 05:06:07" {
+		test.Errorf("Unexpected default time %q", s)
+	}
+
+	fallback := func(source string, context ...string) string {
+		if source == "Now" {
+			return "Nu"
+		}
+		return source
+	}
+	f := &Formatter{Translate: Translations("nl", fallback), Now: func() time.Time { return now }}
+	if s := f.Ago(now); s != "Nu" {
+		test.Errorf("Expected fallback translation, got %q", s)
+	}
+}
@@ -0,0 +1,88 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package humanize
+
+// Catalogs maps source strings to their translations, per language. Strings
+// translated with a context are keyed as context + "\x04" + source, as in
+// gettext.
+var Catalogs = map[string]map[string]string{
+	"de": {
+		"decimal separator\x04.":             ",",
+		"list separator\x04, ":               ", ",
+		"%d B":                               "%d B",
+		"%s KiB":                             "%s KiB",
+		"%s MiB":                             "%s MiB",
+		"%s GiB":                             "%s GiB",
+		"%s TiB":                             "%s TiB",
+		"%s PiB":                             "%s PiB",
+		"%s EiB":                             "%s EiB",
+		"rate\x04%s/s":                       "%s/s",
+		"%d year":                            "%d Jahr",
+		"%d years":                           "%d Jahre",
+		"%d month":                           "%d Monat",
+		"%d months":                          "%d Monate",
+		"%d day":                             "%d Tag",
+		"%d days":                            "%d Tage",
+		"%d hour":                            "%d Stunde",
+		"%d hours":                           "%d Stunden",
+		"%d minute":                          "%d Minute",
+		"%d minutes":                         "%d Minuten",
+		"%d second":                          "%d Sekunde",
+		"%d seconds":                         "%d Sekunden",
+		"Now":                                "Jetzt",
+		"%s ago":                             "vor %s",
+		"future time\x04in %s":               "in %s",
+		"time layout\x042006-01-02 15:04:05": "02.01.2006 15:04:05",
+	},
+	"fr": {
+		"decimal separator\x04.":             ",",
+		"list separator\x04, ":               ", ",
+		"%d B":                               "%d o",
+		"%s KiB":                             "%s Kio",
+		"%s MiB":                             "%s Mio",
+		"%s GiB":                             "%s Gio",
+		"%s TiB":                             "%s Tio",
+		"%s PiB":                             "%s Pio",
+		"%s EiB":                             "%s Eio",
+		"rate\x04%s/s":                       "%s/s",
+		"%d year":                            "%d an",
+		"%d years":                           "%d ans",
+		"%d month":                           "%d mois",
+		"%d months":                          "%d mois",
+		"%d day":                             "%d jour",
+		"%d days":                            "%d jours",
+		"%d hour":                            "%d heure",
+		"%d hours":                           "%d heures",
+		"%d minute":                          "%d minute",
+		"%d minutes":                         "%d minutes",
+		"%d second":                          "%d seconde",
+		"%d seconds":                         "%d secondes",
+		"Now":                                "Maintenant",
+		"%s ago":                             "il y a %s",
+		"future time\x04in %s":               "dans %s",
+		"time layout\x042006-01-02 15:04:05": "02/01/2006 15:04:05",
+	},
+}
+
+// Translations returns a TranslationFunction for language, an ISO 639-1 code
+// such as "de", that looks strings up in Catalogs and passes those it does
+// not know to fallback, which may be nil.
+func Translations(language string, fallback TranslationFunction) TranslationFunction {
+	catalog := Catalogs[language]
+	return func(source string, context ...string) string {
+		key := source
+		if len(context) > 0 {
+			key = context[0] + "\x04" + source
+		}
+		if translation, ok := catalog[key]; ok {
+			return translation
+		}
+		if fallback != nil {
+			return fallback(source, context...)
+		}
+		return source
+	}
+}
@@ -7,14 +7,19 @@ package main
 
 import (
 	"encoding/base64"
+	"git.zx2c4.com/wireguard-windows/manager/conf"
 	"git.zx2c4.com/wireguard-windows/manager/walk"
 	. "git.zx2c4.com/wireguard-windows/manager/walk/declarative"
 	"golang.org/x/crypto/curve25519"
 )
 
 func main() {
+	setupTranslation()
+
+	var mw *walk.MainWindow
 	var se *SyntaxEdit
 	var tl *walk.TextLabel
+	var routeDestination *walk.LineEdit
 	lastPrivate := ""
 
 	demo_config := `[Interface]
@@ -36,9 +41,10 @@ AllowedIPs = 192.168.22.0/24, fd00:3001::/64
 `
 
 	MainWindow{
-		Title:   "WireGuard for Windows",
-		MinSize: Size{900, 800},
-		Layout:  VBox{},
+		AssignTo: &mw,
+		Title:    "WireGuard for Windows",
+		MinSize:  Size{900, 800},
+		Layout:   VBox{},
 		Children: []Widget{
 			TextLabel{
 				AssignTo: &tl,
@@ -76,6 +82,61 @@ AllowedIPs = 192.168.22.0/24, fd00:3001::/64
 					}
 				},
 			},
+			Composite{
+				Layout: HBox{MarginsZero: true},
+				Children: []Widget{
+					LineEdit{
+						AssignTo:  &routeDestination,
+						CueBanner: "Destination IP address",
+					},
+					PushButton{
+						Text: "Which peer?",
+						OnClicked: func() {
+							config, err := conf.FromWgQuick(se.Text(), "demo")
+							if err != nil {
+								walk.MsgBox(mw, "Route lookup", err.Error(), walk.MsgBoxIconError)
+								return
+							}
+							showRoute(mw, config, routeDestination.Text())
+						},
+					},
+				},
+			},
+			PushButton{
+				Text: "Exclude private IPs",
+				OnClicked: func() {
+					text, err := conf.ExcludePrivateIPsInWgQuick(se.Text())
+					if err != nil {
+						walk.MsgBox(mw, "Unable to exclude private IPs", err.Error(), walk.MsgBoxIconError)
+						return
+					}
+					se.SetText(text)
+				},
+			},
+			PushButton{
+				Text: "Show QR code",
+				OnClicked: func() {
+					config, err := conf.FromWgQuick(se.Text(), "demo")
+					if err == nil {
+						err = showQRCode(mw, config)
+					}
+					if err != nil {
+						walk.MsgBox(mw, "Unable to show QR code", err.Error(), walk.MsgBoxIconError)
+					}
+				},
+			},
+			PushButton{
+				Text: "Show traffic",
+				OnClicked: func() {
+					config, err := conf.FromWgQuick(se.Text(), "demo")
+					if err == nil {
+						err = showTrafficGraph(mw, config)
+					}
+					if err != nil {
+						walk.MsgBox(mw, "Unable to show traffic", err.Error(), walk.MsgBoxIconError)
+					}
+				},
+			},
 		},
 	}.Run()
 }
@@ -0,0 +1,128 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+// Source returns the current state of a tunnel, as parsed by conf.FromUAPI.
+type Source func() (*conf.Config, error)
+
+// ListenLocal listens on address, which must be a loopback address, since
+// the metrics include public keys and endpoints and are not authenticated.
+func ListenLocal(address string) (net.Listener, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(host)
+	if host == "localhost" {
+		ip = net.IPv4(127, 0, 0, 1)
+		address = net.JoinHostPort(ip.String(), port)
+	}
+	if ip == nil || !ip.IsLoopback() {
+		return nil, errors.New("Metrics may only be served on a loopback address")
+	}
+	return net.Listen("tcp", address)
+}
+
+// Handler serves the metrics of the tunnels returned by sources in the
+// Prometheus text exposition format. now is used for handshake ages; nil
+// means time.Now.
+func Handler(now func() time.Time, sources ...Source) http.Handler {
+	if now == nil {
+		now = time.Now
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var configs []*conf.Config
+		for _, source := range sources {
+			config, err := source()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			configs = append(configs, config)
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(Exposition(configs, now()))
+	})
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type labels []string
+
+func (l labels) String() string {
+	var parts []string
+	for i := 0; i+1 < len(l); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, l[i], labelEscaper.Replace(l[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+type sample struct {
+	labels labels
+	value  float64
+}
+
+type family struct {
+	name, help, typ string
+	samples         []sample
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Exposition renders the metrics of configs as of now.
+func Exposition(configs []*conf.Config, now time.Time) []byte {
+	peers := &family{name: "wireguard_peers", help: "Number of peers of the tunnel.", typ: "gauge"}
+	received := &family{name: "wireguard_peer_received_bytes_total", help: "Bytes received from the peer.", typ: "counter"}
+	sent := &family{name: "wireguard_peer_sent_bytes_total", help: "Bytes sent to the peer.", typ: "counter"}
+	handshake := &family{name: "wireguard_peer_last_handshake_age_seconds", help: "Seconds since the latest handshake with the peer, absent if there was none.", typ: "gauge"}
+	endpoint := &family{name: "wireguard_peer_endpoint_info", help: "Current endpoint of the peer.", typ: "gauge"}
+	allowedIPs := &family{name: "wireguard_peer_allowed_ips", help: "Number of allowed IP prefixes of the peer.", typ: "gauge"}
+
+	for _, config := range configs {
+		tunnel := string(config.Name)
+		peers.samples = append(peers.samples, sample{labels{"tunnel", tunnel}, float64(len(config.Peers))})
+		for _, peer := range config.Peers {
+			l := labels{"tunnel", tunnel, "public_key", peer.PublicKey.String()}
+			received.samples = append(received.samples, sample{l, float64(peer.RxBytes)})
+			sent.samples = append(sent.samples, sample{l, float64(peer.TxBytes)})
+			if peer.LastHandshakeTime != 0 {
+				age := now.Sub(peer.LastHandshakeTime.Time()).Seconds()
+				if age < 0 {
+					age = 0
+				}
+				handshake.samples = append(handshake.samples, sample{l, age})
+			}
+			if !peer.Endpoint.IsEmpty() {
+				endpoint.samples = append(endpoint.samples, sample{labels{"tunnel", tunnel, "public_key", l[3], "endpoint", peer.Endpoint.String()}, 1})
+			}
+			allowedIPs.samples = append(allowedIPs.samples, sample{l, float64(len(peer.AllowedIPs))})
+		}
+	}
+
+	var output bytes.Buffer
+	for _, f := range []*family{peers, received, sent, handshake, endpoint, allowedIPs} {
+		fmt.Fprintf(&output, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ)
+		for _, s := range f.samples {
+			fmt.Fprintf(&output, "%s%s %s\n", f.name, s.labels.String(), formatValue(s.value))
+		}
+	}
+	return output.Bytes()
+}
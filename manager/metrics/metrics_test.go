@@ -0,0 +1,117 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+var now = time.Unix(1500000100, 0)
+
+func uapiSource(test *testing.T, name string) (Source, *conf.Key) {
+	privateKey, _ := conf.NewPrivateKey()
+	peerKey, _ := conf.NewPrivateKey()
+	other, _ := conf.NewPrivateKey()
+	response := fmt.Sprintf(`private_key=%s
+listen_port=51820
+public_key=%s
+endpoint=192.0.2.1:51820
+last_handshake_time_sec=1500000000
+last_handshake_time_nsec=500000000
+tx_bytes=1234567890
+rx_bytes=42
+persistent_keepalive_interval=0
+allowed_ip=10.0.0.0/24
+allowed_ip=fd00::/64
+public_key=%s
+last_handshake_time_sec=0
+last_handshake_time_nsec=0
+tx_bytes=0
+rx_bytes=0
+persistent_keepalive_interval=0
+errno=0
+`, privateKey.HexString(), peerKey.Public().HexString(), other.Public().HexString())
+	return func() (*conf.Config, error) {
+		return conf.FromUAPI(response, &conf.Config{Name: conf.TunnelName(name)})
+	}, peerKey.Public()
+}
+
+func scrape(test *testing.T, handler http.Handler) (int, string) {
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	response, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		test.Fatal(err)
+	}
+	return response.StatusCode, string(body)
+}
+
+func TestHandler(test *testing.T) {
+	source, peerKey := uapiSource(test, "office")
+	status, body := scrape(test, Handler(func() time.Time { return now }, source))
+	if status != http.StatusOK {
+		test.Fatalf("Unexpected status %d: %s", status, body)
+	}
+	labels := fmt.Sprintf(`{tunnel="office",public_key="%s"}`, peerKey.String())
+	for _, line := range []string{
+		"# TYPE wireguard_peer_received_bytes_total counter",
+		`wireguard_peers{tunnel="office"} 2`,
+		"wireguard_peer_received_bytes_total" + labels + " 42",
+		"wireguard_peer_sent_bytes_total" + labels + " 1234567890",
+		"wireguard_peer_last_handshake_age_seconds" + labels + " 99.5",
+		fmt.Sprintf(`wireguard_peer_endpoint_info{tunnel="office",public_key="%s",endpoint="192.0.2.1:51820"} 1`, peerKey.String()),
+		"wireguard_peer_allowed_ips" + labels + " 2",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			test.Errorf("Missing %q in:\n%s", line, body)
+		}
+	}
+	if strings.Count(body, "wireguard_peer_last_handshake_age_seconds{") != 1 || strings.Count(body, "wireguard_peer_endpoint_info{") != 1 {
+		test.Errorf("Expected the peer without handshake and endpoint to be left out:\n%s", body)
+	}
+
+	failing := func() (*conf.Config, error) {
+		return nil, errors.New("Device is gone")
+	}
+	if status, _ = scrape(test, Handler(nil, source, failing)); status != http.StatusInternalServerError {
+		test.Errorf("Expected failure when a source fails, got %d", status)
+	}
+}
+
+func TestLabelEscaping(test *testing.T) {
+	l := labels{"tunnel", "a\"b\\c\nd"}
+	if s := l.String(); s != `{tunnel="a\"b\\c\nd"}` {
+		test.Errorf("Unexpected escaping %s", s)
+	}
+}
+
+func TestListenLocal(test *testing.T) {
+	for _, address := range []string{"0.0.0.0:0", "192.0.2.1:0", ":0", "example.com:0", "nope"} {
+		if listener, err := ListenLocal(address); err == nil {
+			listener.Close()
+			test.Errorf("Expected %s to be refused", address)
+		}
+	}
+	listener, err := ListenLocal("localhost:0")
+	if err != nil {
+		test.Fatal(err)
+	}
+	listener.Close()
+}
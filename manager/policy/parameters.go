@@ -0,0 +1,127 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package policy
+
+import (
+	"errors"
+	"strings"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+	"git.zx2c4.com/wireguard-windows/manager/walk/govaluate"
+)
+
+// parameters exposes a configuration to rules as nested maps, which accessors
+// such as peer.Endpoint.Host walk into. Dotted names escaped in brackets,
+// such as [peer.Endpoint.Host], are looked up one level at a time too.
+// Numbers are float64 and lists are []interface{}, which is what govaluate's
+// operators expect.
+type parameters map[string]interface{}
+
+func (p parameters) Get(name string) (interface{}, error) {
+	var value interface{} = map[string]interface{}(p)
+	for _, part := range strings.Split(name, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("No parameter '" + name + "' found.")
+		}
+		value, ok = m[part]
+		if !ok {
+			return nil, errors.New("No parameter '" + name + "' found.")
+		}
+	}
+	return value, nil
+}
+
+func cidrList(cidrs []conf.IPCidr) []interface{} {
+	list := make([]interface{}, len(cidrs))
+	for i := range cidrs {
+		list[i] = cidrs[i].String()
+	}
+	return list
+}
+
+// configSchema types the parameters of configParameters, so that rules are
+// checked when they are compiled rather than when they are first evaluated.
+var configSchema = govaluate.TypeSchema{
+	"name":                 govaluate.TypeString,
+	"interface.Addresses":  govaluate.TypeArray,
+	"interface.ListenPort": govaluate.TypeNumber,
+	"interface.Mtu":        govaluate.TypeNumber,
+	"interface.Dns":        govaluate.TypeArray,
+	"interface.HasDns":     govaluate.TypeBool,
+	"interface.HasHooks":   govaluate.TypeBool,
+	"interface.FullTunnel": govaluate.TypeBool,
+	"peers":                govaluate.TypeNumber,
+	"peerList":             govaluate.TypeArray,
+}
+
+// peerSchema types the parameters that peerParameters adds.
+var peerSchema = govaluate.TypeSchema{
+	"peer.PublicKey":           govaluate.TypeString,
+	"peer.HasPresharedKey":     govaluate.TypeBool,
+	"peer.AllowedIPs":          govaluate.TypeArray,
+	"peer.FullTunnel":          govaluate.TypeBool,
+	"peer.PersistentKeepalive": govaluate.TypeNumber,
+	"peer.Endpoint.Host":       govaluate.TypeString,
+	"peer.Endpoint.Port":       govaluate.TypeNumber,
+}
+
+func configParameters(config *conf.Config) parameters {
+	dns := make([]interface{}, len(config.Interface.Dns))
+	for i, ip := range config.Interface.Dns {
+		dns[i] = ip.String()
+	}
+	// Peers can share the routes of a full tunnel between them.
+	var allowedIPs []conf.IPCidr
+	for i := range config.Peers {
+		allowedIPs = append(allowedIPs, config.Peers[i].AllowedIPs...)
+	}
+	hooks := len(config.Interface.PreUp) + len(config.Interface.PostUp) + len(config.Interface.PreDown) + len(config.Interface.PostDown)
+	peers := make([]interface{}, len(config.Peers))
+	for i := range config.Peers {
+		peers[i] = peerMap(&config.Peers[i])
+	}
+	return parameters{
+		"name": string(config.Name),
+		"interface": map[string]interface{}{
+			"Addresses":  cidrList(config.Interface.Addresses),
+			"ListenPort": float64(config.Interface.ListenPort),
+			"Mtu":        float64(config.Interface.Mtu),
+			"Dns":        dns,
+			"HasDns":     len(dns) > 0,
+			"HasHooks":   hooks > 0,
+			"FullTunnel": conf.IsFullTunnel(allowedIPs),
+		},
+		"peers":    float64(len(config.Peers)),
+		"peerList": peers,
+	}
+}
+
+// peerMap is the shape of peer, which is also the shape of each item of
+// peerList, so that any(peerList, p => ...) sees what a peer rule sees.
+func peerMap(peer *conf.Peer) map[string]interface{} {
+	return map[string]interface{}{
+		"PublicKey":           peer.PublicKey.String(),
+		"HasPresharedKey":     !peer.PresharedKey.IsZero(),
+		"AllowedIPs":          cidrList(peer.AllowedIPs),
+		"FullTunnel":          conf.IsFullTunnel(peer.AllowedIPs),
+		"PersistentKeepalive": float64(peer.PersistentKeepalive),
+		"Endpoint": map[string]interface{}{
+			"Host": peer.Endpoint.Host,
+			"Port": float64(peer.Endpoint.Port),
+		},
+	}
+}
+
+// peerParameters adds peer to the parameters of its configuration.
+func peerParameters(base parameters, peer *conf.Peer) parameters {
+	p := make(parameters, len(base)+1)
+	for k, v := range base {
+		p[k] = v
+	}
+	p["peer"] = peerMap(peer)
+	return p
+}
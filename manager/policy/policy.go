@@ -0,0 +1,208 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+	"git.zx2c4.com/wireguard-windows/manager/walk/govaluate"
+)
+
+// DefaultFile is the name of the administrator's policy, as read by
+// conf.ReadAdminFile. Without it, every configuration is allowed.
+const DefaultFile = "policy.json"
+
+type Scope string
+
+const (
+	ScopeInterface Scope = "interface" // The rule is evaluated once per configuration.
+	ScopePeer      Scope = "peer"      // The rule is evaluated once for every peer.
+)
+
+// Rule is a requirement on configurations, written by an administrator.
+type Rule struct {
+	Name  string `json:"name"`
+	Scope Scope  `json:"scope"`
+
+	// Require is a govaluate expression that must evaluate to true for the
+	// configuration to be allowed, such as peer.Endpoint.Host =~ '\.corp\.example$'.
+	Require string `json:"require"`
+
+	// Message explains the rejection to the user; the rule name is used when empty.
+	Message string `json:"message"`
+}
+
+type compiledRule struct {
+	Rule
+	expression *govaluate.EvaluableExpression
+}
+
+// Policy is a compiled set of rules. The zero value allows everything.
+type Policy struct {
+	rules []compiledRule
+}
+
+type file struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Compile checks the rules and prepares them for evaluation.
+func Compile(rules []Rule) (*Policy, error) {
+	p := &Policy{}
+	for i, rule := range rules {
+		if len(rule.Name) == 0 {
+			rule.Name = fmt.Sprintf("#%d", i+1)
+		}
+		switch rule.Scope {
+		case "":
+			rule.Scope = ScopeInterface
+		case ScopeInterface, ScopePeer:
+		default:
+			return nil, fmt.Errorf("Rule %s has an invalid scope ‘%s’", rule.Name, rule.Scope)
+		}
+		if len(strings.TrimSpace(rule.Require)) == 0 {
+			return nil, fmt.Errorf("Rule %s has no requirement", rule.Name)
+		}
+		schemas := []govaluate.TypeSchema{configSchema}
+		if rule.Scope == ScopePeer {
+			schemas = append(schemas, peerSchema)
+		}
+		expression, err := govaluate.NewEvaluableExpression(rule.Require, schemas...)
+		if err != nil {
+			return nil, fmt.Errorf("Rule %s is invalid: %v", rule.Name, err)
+		}
+		if t := expression.ResultType(); t != govaluate.TypeBool && t != govaluate.TypeAny {
+			return nil, fmt.Errorf("Rule %s is invalid: it gives a %s rather than true or false", rule.Name, t)
+		}
+		p.rules = append(p.rules, compiledRule{rule, expression})
+	}
+	return p, nil
+}
+
+// Load reads a policy file. A missing file is not an error and gives the
+// policy that allows everything.
+func Load(filename string) (*Policy, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	return parseFile(filename, bytes, err)
+}
+
+// LoadDefault reads the administrator's policy. A policy that has been
+// configured but cannot be read or trusted is an error, so that callers
+// refuse everything rather than lift the restrictions.
+func LoadDefault() (*Policy, error) {
+	bytes, err := conf.ReadAdminFile(DefaultFile)
+	return parseFile(DefaultFile, bytes, err)
+}
+
+func parseFile(filename string, bytes []byte, err error) (*Policy, error) {
+	if os.IsNotExist(err) {
+		return &Policy{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read policy file %s: %v", filename, err)
+	}
+	var f file
+	if err = json.Unmarshal(bytes, &f); err != nil {
+		return nil, fmt.Errorf("Invalid policy file %s: %v", filename, err)
+	}
+	return Compile(f.Rules)
+}
+
+func (p *Policy) Rules() []Rule {
+	rules := make([]Rule, len(p.rules))
+	for i := range p.rules {
+		rules[i] = p.rules[i].Rule
+	}
+	return rules
+}
+
+// Violation is a rule that a configuration does not satisfy.
+type Violation struct {
+	Rule string
+	Peer int // Index of the offending peer, or -1 for interface rules.
+
+	// Reason is the message of the rule, or why the rule could not be
+	// evaluated, which counts as a violation so that broken rules fail closed.
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	if v.Peer >= 0 {
+		return fmt.Sprintf("Peer %d: %s", v.Peer+1, v.Reason)
+	}
+	return v.Reason
+}
+
+// Violations is the error returned when a configuration is rejected.
+type Violations struct {
+	Name       conf.TunnelName
+	Violations []Violation
+}
+
+func (v *Violations) Error() string {
+	reasons := make([]string, len(v.Violations))
+	for i := range v.Violations {
+		reasons[i] = v.Violations[i].Error()
+	}
+	return fmt.Sprintf("Tunnel %s is not allowed by policy: %s", v.Name, strings.Join(reasons, "; "))
+}
+
+func (r *compiledRule) evaluate(parameters govaluate.Parameters, peer int) *Violation {
+	result, err := r.expression.Eval(parameters)
+	if err == nil {
+		allowed, ok := result.(bool)
+		if ok && allowed {
+			return nil
+		}
+		if !ok {
+			err = errors.New("Result is not a boolean")
+		}
+	}
+	v := &Violation{Rule: r.Name, Peer: peer}
+	switch {
+	case err != nil:
+		v.Reason = fmt.Sprintf("Rule %s could not be evaluated: %v", r.Name, err)
+	case len(r.Message) > 0:
+		v.Reason = r.Message
+	default:
+		v.Reason = fmt.Sprintf("Rule %s is not satisfied", r.Name)
+	}
+	return v
+}
+
+// Check evaluates every rule against config and returns a *Violations error
+// listing all of the rules it does not satisfy.
+func (p *Policy) Check(config *conf.Config) error {
+	if p == nil || len(p.rules) == 0 {
+		return nil
+	}
+	var violations []Violation
+	base := configParameters(config)
+	for i := range p.rules {
+		rule := &p.rules[i]
+		if rule.Scope == ScopeInterface {
+			if v := rule.evaluate(base, -1); v != nil {
+				violations = append(violations, *v)
+			}
+			continue
+		}
+		for j := range config.Peers {
+			if v := rule.evaluate(peerParameters(base, &config.Peers[j]), j); v != nil {
+				violations = append(violations, *v)
+			}
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return &Violations{Name: config.Name, Violations: violations}
+}
@@ -0,0 +1,182 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+const testConfig = `[Interface]
+PrivateKey = cJM9wXUVXc0fa/t5b/Lm0BHNx6jh5UiTLsO+oJhyQUU=
+Address = 192.168.4.84/24
+DNS = 8.8.8.8
+
+[Peer]
+PublicKey = JRI8Xc0zKP9kXk8qP84NdUQA04h6DLfFbwJn4g+/PFs=
+Endpoint = vpn.corp.example:51820
+AllowedIPs = 10.0.0.0/8
+
+[Peer]
+PublicKey = QCssGR6joqOIEQW6j2AR7nMcXJIVI9E9PCcbwrVXhU8=
+Endpoint = demo.wireguard.com:12912
+AllowedIPs = 0.0.0.0/0
+`
+
+func parse(test *testing.T) *conf.Config {
+	config, err := conf.FromWgQuick(testConfig, "office")
+	if err != nil {
+		test.Fatal(err)
+	}
+	return config
+}
+
+func TestCheck(test *testing.T) {
+	config := parse(test)
+	p, err := Compile([]Rule{
+		{Name: "corp-endpoints", Scope: ScopePeer, Require: `peer.Endpoint.Host =~ '\.corp\.example$'`, Message: "Endpoints must be in corp.example"},
+		{Name: "no-full-tunnel", Scope: ScopePeer, Require: `peer.FullTunnel == false`},
+		{Name: "no-dns", Require: `[interface.HasDns] == false`, Message: "Custom DNS servers are not allowed"},
+		{Name: "allowed-dns", Require: `'8.8.8.8' IN interface.Dns`},
+		{Name: "ports", Scope: ScopePeer, Require: `[peer.Endpoint.Port] > 1024 && peers == 2`},
+		{Name: "peer-list", Require: `count(peerList, p => p.Endpoint.Port > 1024) == peers`},
+		{Name: "no-default-route", Require: `!any(peerList, p => any(p.AllowedIPs, ip => ip == '0.0.0.0/0'))`},
+	})
+	if err != nil {
+		test.Fatal(err)
+	}
+	err = p.Check(config)
+	violations, ok := err.(*Violations)
+	if !ok {
+		test.Fatalf("Expected violations, got %v", err)
+	}
+	expected := []Violation{
+		{"corp-endpoints", 1, "Endpoints must be in corp.example"},
+		{"no-full-tunnel", 1, "Rule no-full-tunnel is not satisfied"},
+		{"no-dns", -1, "Custom DNS servers are not allowed"},
+		{"no-default-route", -1, "Rule no-default-route is not satisfied"},
+	}
+	if len(violations.Violations) != len(expected) {
+		test.Fatalf("Expected %d violations, got %v", len(expected), violations.Violations)
+	}
+	for i, v := range violations.Violations {
+		if v != expected[i] {
+			test.Errorf("Violation %d is %+v, expected %+v", i, v, expected[i])
+		}
+	}
+	if !strings.HasPrefix(err.Error(), "Tunnel office is not allowed by policy: Peer 2: Endpoints must be in corp.example; ") {
+		test.Errorf("Unexpected message: %v", err)
+	}
+
+	config.Peers = config.Peers[:1]
+	config.Interface.Dns = nil
+	if err = p.Check(config); err == nil || !strings.Contains(err.Error(), "Rule allowed-dns is not satisfied") {
+		test.Errorf("Expected only allowed-dns and ports to fail, got %v", err)
+	}
+}
+
+func TestSplitFullTunnel(test *testing.T) {
+	p, err := Compile([]Rule{
+		{Name: "no-full-tunnel", Scope: ScopePeer, Require: `!peer.FullTunnel`},
+		{Name: "no-shared-full-tunnel", Require: `!interface.FullTunnel`},
+	})
+	if err != nil {
+		test.Fatal(err)
+	}
+	excluded, err := conf.ExcludePrivateIPsInWgQuick(testConfig)
+	if err != nil {
+		test.Fatal(err)
+	}
+	for _, c := range []struct {
+		config     string
+		violations int
+	}{
+		{strings.Replace(testConfig, "AllowedIPs = 0.0.0.0/0", "AllowedIPs = 0.0.0.0/1, 128.0.0.0/1", 1), 2},
+		{excluded, 2},
+		{strings.Replace(testConfig, "AllowedIPs = 0.0.0.0/0", "AllowedIPs = ::/1, 8000::/1", 1), 2},
+		{strings.Replace(testConfig, "AllowedIPs = 0.0.0.0/0", "AllowedIPs = 0.0.0.0/1", 1), 0},
+		{strings.Replace(strings.Replace(testConfig, "AllowedIPs = 0.0.0.0/0", "AllowedIPs = 0.0.0.0/1", 1), "AllowedIPs = 10.0.0.0/8", "AllowedIPs = 128.0.0.0/1", 1), 1},
+	} {
+		config, err := conf.FromWgQuick(c.config, "office")
+		if err != nil {
+			test.Fatal(err)
+		}
+		err = p.Check(config)
+		violations, _ := err.(*Violations)
+		if (c.violations == 0 && err != nil) || (c.violations > 0 && (violations == nil || len(violations.Violations) != c.violations)) {
+			test.Errorf("Expected %d violations for\n%s\ngot %v", c.violations, c.config, err)
+		}
+	}
+}
+
+func TestBrokenRulesFailClosed(test *testing.T) {
+	p, err := Compile([]Rule{
+		{Name: "no-result", Require: `peers > 100 ? true`},
+		{Name: "bad-pattern", Require: `name =~ ('[' + name)`},
+	})
+	if err != nil {
+		test.Fatal(err)
+	}
+	violations, ok := p.Check(parse(test)).(*Violations)
+	if !ok || len(violations.Violations) != 2 {
+		test.Fatalf("Expected both rules to be violated, got %v", violations)
+	}
+	if !strings.Contains(violations.Violations[0].Reason, "could not be evaluated") {
+		test.Errorf("Unexpected reason %q", violations.Violations[0].Reason)
+	}
+}
+
+func TestCompileErrors(test *testing.T) {
+	for _, rule := range []Rule{
+		{Name: "scope", Scope: "machine", Require: "true"},
+		{Name: "empty", Require: " "},
+		{Name: "syntax", Require: "1 +"},
+		{Name: "missing", Require: "interface.Nonexistent == 1"},
+		{Name: "peer-outside-scope", Require: "peer.FullTunnel == false"},
+		{Name: "mistyped", Require: "interface.Mtu > '1280'"},
+		{Name: "not-boolean", Require: "[interface.Mtu] + 1"},
+	} {
+		if _, err := Compile([]Rule{rule}); err == nil || !strings.Contains(err.Error(), rule.Name) {
+			test.Errorf("Expected error naming %s, got %v", rule.Name, err)
+		}
+	}
+}
+
+func TestLoad(test *testing.T) {
+	dir, err := ioutil.TempDir("", "wireguard-policy-test")
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, DefaultFile)
+	p, err := Load(filename)
+	if err != nil || p.Check(parse(test)) != nil {
+		test.Errorf("Expected missing policy to allow everything: %v", err)
+	}
+	ioutil.WriteFile(filename, []byte(`{"rules": [{"name": "keepalive", "scope": "peer", "require": "[peer.PersistentKeepalive] > 0"}]}`), 0600)
+	p, err = Load(filename)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if rules := p.Rules(); len(rules) != 1 || rules[0].Scope != ScopePeer {
+		test.Errorf("Unexpected rules %+v", rules)
+	}
+	if p.Check(parse(test)) == nil {
+		test.Error("Expected keepalive rule to be violated")
+	}
+	ioutil.WriteFile(filename, []byte(`{"rules": [`), 0600)
+	if _, err = Load(filename); err == nil {
+		test.Error("Expected error for malformed policy")
+	}
+	if _, err = parseFile(filename, nil, os.ErrPermission); err == nil {
+		test.Error("Expected error for unreadable policy")
+	}
+}
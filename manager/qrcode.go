@@ -0,0 +1,43 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+	"git.zx2c4.com/wireguard-windows/manager/qrcode"
+	"git.zx2c4.com/wireguard-windows/manager/walk"
+	. "git.zx2c4.com/wireguard-windows/manager/walk/declarative"
+)
+
+const qrCodeScale = 4
+
+func showQRCode(owner walk.Form, config *conf.Config) error {
+	code, err := qrcode.Encode([]byte(config.ToWgQuick()), qrcode.Low)
+	if err != nil {
+		return err
+	}
+	bitmap, err := walk.NewBitmapFromImage(code.Image(qrCodeScale))
+	if err != nil {
+		return err
+	}
+	defer bitmap.Dispose()
+
+	title := "QR code"
+	if len(config.Name) > 0 {
+		title = string(config.Name) + " – " + title
+	}
+	_, err = Dialog{
+		Title:  title,
+		Layout: VBox{},
+		Children: []Widget{
+			ImageView{
+				Image: bitmap,
+				Mode:  ImageViewModeIdeal,
+			},
+		},
+	}.Run(owner)
+	return err
+}
@@ -0,0 +1,45 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// QuietZone is the border, in modules, that readers require around a symbol.
+const QuietZone = 4
+
+// Image renders the code with each module drawn as a scale×scale square, surrounded by the quiet zone.
+func (c *Code) Image(scale int) *image.Paletted {
+	if scale < 1 {
+		scale = 1
+	}
+	side := (c.Size + 2*QuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < side; y++ {
+		my := y/scale - QuietZone
+		for x := 0; x < side; x++ {
+			mx := x/scale - QuietZone
+			if mx >= 0 && mx < c.Size && my >= 0 && my < c.Size && c.Dark(mx, my) {
+				img.Pix[y*img.Stride+x] = 1
+			}
+		}
+	}
+	return img
+}
+
+// PNG returns the output of Image encoded as a PNG file.
+func (c *Code) PNG(scale int) ([]byte, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, c.Image(scale))
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,510 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+type ErrorCorrectionLevel int
+
+const (
+	Low ErrorCorrectionLevel = iota
+	Medium
+	Quartile
+	High
+)
+
+const (
+	MinVersion = 1
+	MaxVersion = 40
+)
+
+var ErrDataTooLong = errors.New("Data too long to fit in a QR code")
+
+// Code is an encoded QR symbol. Modules are addressed by column x and row y,
+// with (0, 0) at the top left; the quiet zone is not included.
+type Code struct {
+	Version int
+	Level   ErrorCorrectionLevel
+	Mask    int
+	Size    int
+
+	modules    []bool
+	isFunction []bool
+}
+
+func (l ErrorCorrectionLevel) String() string {
+	switch l {
+	case Low:
+		return "L"
+	case Medium:
+		return "M"
+	case Quartile:
+		return "Q"
+	case High:
+		return "H"
+	}
+	return fmt.Sprintf("ErrorCorrectionLevel(%d)", int(l))
+}
+
+// formatBits are the two bits identifying the level in the format information, which are not in level order.
+func (l ErrorCorrectionLevel) formatBits() int {
+	return [...]int{1, 0, 3, 2}[l]
+}
+
+var eccCodewordsPerBlock = [4][MaxVersion + 1]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var errorCorrectionBlocks = [4][MaxVersion + 1]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// rawDataModules is the number of modules left for codewords and remainder bits
+// once all function patterns and format/version information have been placed.
+func rawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func dataCodewords(version int, level ErrorCorrectionLevel) int {
+	return rawDataModules(version)/8 - eccCodewordsPerBlock[level][version]*errorCorrectionBlocks[level][version]
+}
+
+func characterCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// Capacity returns the number of bytes that fit into a symbol of the given version and level.
+func Capacity(version int, level ErrorCorrectionLevel) int {
+	return (dataCodewords(version, level)*8 - 4 - characterCountBits(version)) / 8
+}
+
+func alignmentPatternPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	numAlign := version/7 + 2
+	var step int
+	if version == 32 {
+		step = 26
+	} else {
+		step = (version*4 + numAlign*2 + 1) / (numAlign*2 - 2) * 2
+	}
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, version*4+10; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+// Encode encodes data in byte mode using the smallest version that holds it at the requested error correction level.
+func Encode(data []byte, level ErrorCorrectionLevel) (*Code, error) {
+	return EncodeVersion(data, level, MinVersion, MaxVersion)
+}
+
+// EncodeVersion is like Encode, but restricts the search to versions in [minVersion, maxVersion].
+func EncodeVersion(data []byte, level ErrorCorrectionLevel, minVersion, maxVersion int) (*Code, error) {
+	if level < Low || level > High {
+		return nil, fmt.Errorf("Invalid error correction level: %d", int(level))
+	}
+	if minVersion < MinVersion || maxVersion > MaxVersion || minVersion > maxVersion {
+		return nil, fmt.Errorf("Invalid version range: %d-%d", minVersion, maxVersion)
+	}
+	version := minVersion
+	for ; version <= maxVersion; version++ {
+		if len(data) <= Capacity(version, level) {
+			break
+		}
+	}
+	if version > maxVersion {
+		return nil, ErrDataTooLong
+	}
+
+	var bits bitBuffer
+	bits.append(0x4, 4)
+	bits.append(len(data), characterCountBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacityBits := dataCodewords(version, level) * 8
+	terminator := capacityBits - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xec; len(bits) < capacityBits; pad ^= 0xec ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	code := newCode(version, level)
+	code.drawFunctionPatterns()
+	code.drawCodewords(code.addErrorCorrection(bits.bytes()))
+	code.chooseMask()
+	return code, nil
+}
+
+func newCode(version int, level ErrorCorrectionLevel) *Code {
+	size := version*4 + 17
+	return &Code{
+		Version:    version,
+		Level:      level,
+		Size:       size,
+		modules:    make([]bool, size*size),
+		isFunction: make([]bool, size*size),
+	}
+}
+
+// Dark reports whether the module at column x and row y is dark.
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y*c.Size+x]
+}
+
+// Matrix returns the modules row by row, with true meaning dark.
+func (c *Code) Matrix() [][]bool {
+	matrix := make([][]bool, c.Size)
+	for y := range matrix {
+		matrix[y] = make([]bool, c.Size)
+		copy(matrix[y], c.modules[y*c.Size:(y+1)*c.Size])
+	}
+	return matrix
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y*c.Size+x] = dark
+	c.isFunction[y*c.Size+x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	c.drawFinderPattern(3, 3)
+	c.drawFinderPattern(c.Size-4, 3)
+	c.drawFinderPattern(3, c.Size-4)
+
+	positions := alignmentPatternPositions(c.Version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignmentPattern(x, y)
+		}
+	}
+
+	// Reserve the format areas now so that codeword placement skips them.
+	c.drawFormatBits(0)
+	c.drawVersionBits()
+}
+
+func (c *Code) drawFinderPattern(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= c.Size || y < 0 || y >= c.Size {
+				continue
+			}
+			dist := abs(dx)
+			if abs(dy) > dist {
+				dist = abs(dy)
+			}
+			c.setFunction(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (c *Code) drawAlignmentPattern(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			dist := abs(dx)
+			if abs(dy) > dist {
+				dist = abs(dy)
+			}
+			c.setFunction(cx+dx, cy+dy, dist != 1)
+		}
+	}
+}
+
+func formatInformation(level ErrorCorrectionLevel, mask int) int {
+	data := level.formatBits()<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatInformation(c.Level, mask)
+	bit := func(i int) bool {
+		return (bits>>uint(i))&1 != 0
+	}
+
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(i))
+	}
+	c.setFunction(8, 7, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(i))
+	}
+	c.setFunction(8, c.Size-8, true)
+}
+
+func (c *Code) drawVersionBits() {
+	if c.Version < 7 {
+		return
+	}
+	rem := c.Version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+	}
+	bits := c.Version<<12 | rem
+	for i := 0; i < 18; i++ {
+		dark := (bits>>uint(i))&1 != 0
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, dark)
+		c.setFunction(b, a, dark)
+	}
+}
+
+// addErrorCorrection splits the data into blocks, appends the Reed-Solomon
+// codewords of each and interleaves the result.
+func (c *Code) addErrorCorrection(data []byte) []byte {
+	numBlocks := errorCorrectionBlocks[c.Level][c.Version]
+	eccLen := eccCodewordsPerBlock[c.Level][c.Version]
+	rawCodewords := rawDataModules(c.Version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	generator := rsGenerator(eccLen)
+	blocks := make([][]byte, numBlocks)
+	eccs := make([][]byte, numBlocks)
+	offset := 0
+	for i := range blocks {
+		length := shortBlockLen - eccLen
+		if i >= numShortBlocks {
+			length++
+		}
+		blocks[i] = data[offset : offset+length]
+		eccs[i] = rsRemainder(blocks[i], generator)
+		offset += length
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := 0; i <= shortBlockLen-eccLen; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for _, ecc := range eccs {
+			result = append(result, ecc[i])
+		}
+	}
+	return result
+}
+
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.isFunction[y*c.Size+x] || i >= len(codewords)*8 {
+					continue
+				}
+				c.modules[y*c.Size+x] = (codewords[i>>3]>>uint(7-i&7))&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+func maskApplies(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	case 7:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+	panic("invalid mask")
+}
+
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.isFunction[y*c.Size+x] && maskApplies(mask, x, y) {
+				c.modules[y*c.Size+x] = !c.modules[y*c.Size+x]
+			}
+		}
+	}
+}
+
+func (c *Code) chooseMask() {
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		penalty := c.penalty()
+		if bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		c.applyMask(mask) // Masking is an involution, so this undoes it.
+	}
+	c.Mask = best
+	c.applyMask(best)
+	c.drawFormatBits(best)
+}
+
+var finderLikePatterns = [2][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+func (c *Code) penalty() int {
+	result := 0
+	line := make([]bool, c.Size)
+	for _, horizontal := range []bool{true, false} {
+		for i := 0; i < c.Size; i++ {
+			for j := 0; j < c.Size; j++ {
+				if horizontal {
+					line[j] = c.Dark(j, i)
+				} else {
+					line[j] = c.Dark(i, j)
+				}
+			}
+			result += linePenalty(line)
+		}
+	}
+
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Dark(x, y) {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size {
+				d := c.Dark(x, y)
+				if d == c.Dark(x+1, y) && d == c.Dark(x, y+1) && d == c.Dark(x+1, y+1) {
+					result += 3
+				}
+			}
+		}
+	}
+	total := c.Size * c.Size
+	result += abs(dark*100/total-50) / 5 * 10
+	return result
+}
+
+func linePenalty(line []bool) int {
+	result := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			result += run - 2
+		}
+		run = 1
+	}
+	for i := 0; i+11 <= len(line); i++ {
+		for _, pattern := range finderLikePatterns {
+			matches := true
+			for j, dark := range pattern {
+				if line[i+j] != dark {
+					matches = false
+					break
+				}
+			}
+			if matches {
+				result += 40
+			}
+		}
+	}
+	return result
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value int, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, (value>>uint(i))&1 != 0)
+	}
+}
+
+func (b bitBuffer) bytes() []byte {
+	result := make([]byte, (len(b)+7)/8)
+	for i, bit := range b {
+		if bit {
+			result[i>>3] |= 0x80 >> uint(i&7)
+		}
+	}
+	return result
+}
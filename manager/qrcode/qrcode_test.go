@@ -0,0 +1,339 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"math/bits"
+	"strings"
+	"testing"
+)
+
+const testConfig = `[Interface]
+PrivateKey = cJM9wXUVXc0fa/t5b/Lm0BHNx6jh5UiTLsO+oJhyQUU=
+Address = 192.168.4.84/24, 2001:abcd:33::/120
+DNS = 8.8.8.8, 8.8.4.4, 1.1.1.1, 1.0.0.1
+
+[Peer]
+PublicKey = JRI8Xc0zKP9kXk8qP84NdUQA04h6DLfFbwJn4g+/PFs=
+Endpoint = demo.wireguard.com:12912
+AllowedIPs = 0.0.0.0/0
+`
+
+func TestCapacity(test *testing.T) {
+	// Byte mode capacities from table 7 of ISO/IEC 18004:2015.
+	known := []struct {
+		version  int
+		level    ErrorCorrectionLevel
+		capacity int
+	}{
+		{1, Low, 17}, {1, Medium, 14}, {1, Quartile, 11}, {1, High, 7},
+		{7, Medium, 122}, {10, Medium, 213}, {10, High, 119},
+		{25, Quartile, 715}, {40, Low, 2953}, {40, Medium, 2331},
+		{40, Quartile, 1663}, {40, High, 1273},
+	}
+	for _, k := range known {
+		c := Capacity(k.version, k.level)
+		if c != k.capacity {
+			test.Errorf("Version %d-%s: expected capacity %d, got %d", k.version, k.level, k.capacity, c)
+		}
+	}
+}
+
+func TestAlignmentPatternPositions(test *testing.T) {
+	// From annex E of ISO/IEC 18004:2015.
+	known := map[int][]int{
+		1:  nil,
+		2:  {6, 18},
+		6:  {6, 34},
+		7:  {6, 22, 38},
+		14: {6, 26, 46, 66},
+		22: {6, 26, 50, 74, 98},
+		32: {6, 34, 60, 86, 112, 138},
+		40: {6, 30, 58, 86, 114, 142, 170},
+	}
+	for version, expected := range known {
+		actual := alignmentPatternPositions(version)
+		if fmt.Sprint(actual) != fmt.Sprint(expected) {
+			test.Errorf("Version %d: expected %v, got %v", version, expected, actual)
+		}
+	}
+}
+
+func TestVersionSelection(test *testing.T) {
+	code, err := Encode([]byte(strings.Repeat("a", 17)), Low)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if code.Version != 1 || code.Size != 21 {
+		test.Errorf("Expected version 1 of size 21, got version %d of size %d", code.Version, code.Size)
+	}
+	code, err = Encode([]byte(strings.Repeat("a", 18)), Low)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if code.Version != 2 {
+		test.Errorf("Expected version 2, got %d", code.Version)
+	}
+	_, err = Encode(make([]byte, 2954), Low)
+	if err != ErrDataTooLong {
+		test.Errorf("Expected ErrDataTooLong, got %v", err)
+	}
+	_, err = EncodeVersion([]byte(testConfig), Medium, 1, 5)
+	if err != ErrDataTooLong {
+		test.Errorf("Expected ErrDataTooLong, got %v", err)
+	}
+}
+
+func TestRoundTrip(test *testing.T) {
+	payloads := [][]byte{
+		[]byte(""),
+		[]byte("WireGuard"),
+		[]byte(strings.Repeat("wg", 70)),
+		[]byte(testConfig),
+		[]byte(strings.Repeat(testConfig, 4)),
+		bytes.Repeat([]byte{0x00, 0xff, 0x11, 0xec}, 300),
+	}
+	for _, payload := range payloads {
+		for level := Low; level <= High; level++ {
+			code, err := Encode(payload, level)
+			if err != nil {
+				test.Errorf("Unable to encode %d bytes at level %s: %v", len(payload), level, err)
+				continue
+			}
+			pngBytes, err := code.PNG(3)
+			if err != nil {
+				test.Fatal(err)
+			}
+			img, err := png.Decode(bytes.NewReader(pngBytes))
+			if err != nil {
+				test.Fatal(err)
+			}
+			decoded, err := decodeImage(img, 3)
+			if err != nil {
+				test.Errorf("Unable to decode version %d-%s mask %d: %v", code.Version, level, code.Mask, err)
+				continue
+			}
+			if !bytes.Equal(decoded, payload) {
+				test.Errorf("Version %d-%s: payload mismatch", code.Version, level)
+			}
+		}
+	}
+}
+
+// The decoder below works from the rendered pixels only, and deliberately
+// does not share the encoder's masking, placement or field arithmetic.
+
+func gfMulSlow(a, b byte) byte {
+	var p byte
+	for b != 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1d
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func bchRemainder(value, generator uint, generatorDegree uint) uint {
+	for i := uint(bits.Len(value)); i > generatorDegree; i-- {
+		if value&(1<<(i-1)) != 0 {
+			value ^= generator << (i - 1 - generatorDegree)
+		}
+	}
+	return value
+}
+
+func decodeImage(img image.Image, scale int) ([]byte, error) {
+	side := img.Bounds().Dx()/scale - 2*QuietZone
+	if (side-17)%4 != 0 {
+		return nil, fmt.Errorf("Bad symbol size %d", side)
+	}
+	version := (side - 17) / 4
+	dark := func(x, y int) bool {
+		r, _, _, _ := img.At((x+QuietZone)*scale+scale/2, (y+QuietZone)*scale+scale/2).RGBA()
+		return r < 0x8000
+	}
+
+	var formatRead uint
+	for i := 0; i <= 5; i++ {
+		if dark(8, i) {
+			formatRead |= 1 << uint(i)
+		}
+	}
+	if dark(8, 7) {
+		formatRead |= 1 << 6
+	}
+	if dark(8, 8) {
+		formatRead |= 1 << 7
+	}
+	if dark(7, 8) {
+		formatRead |= 1 << 8
+	}
+	for i := 9; i < 15; i++ {
+		if dark(14-i, 8) {
+			formatRead |= 1 << uint(i)
+		}
+	}
+	formatRead ^= 0x5412
+	if bchRemainder(formatRead, 0x537, 10) != 0 {
+		return nil, fmt.Errorf("Corrupt format information %015b", formatRead)
+	}
+	level := map[uint]ErrorCorrectionLevel{1: Low, 0: Medium, 3: Quartile, 2: High}[formatRead>>13]
+	mask := int(formatRead>>10) & 7
+
+	if version >= 7 {
+		var versionRead uint
+		for i := 0; i < 18; i++ {
+			if dark(i/3, side-11+i%3) {
+				versionRead |= 1 << uint(i)
+			}
+		}
+		if bchRemainder(versionRead, 0x1f25, 12) != 0 || int(versionRead>>12) != version {
+			return nil, fmt.Errorf("Corrupt version information %018b", versionRead)
+		}
+	}
+
+	reserved := make([][]bool, side)
+	for y := range reserved {
+		reserved[y] = make([]bool, side)
+	}
+	reserve := func(x0, y0, w, h int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				reserved[y][x] = true
+			}
+		}
+	}
+	reserve(0, 0, 9, 9)
+	reserve(side-8, 0, 8, 9)
+	reserve(0, side-8, 9, 8)
+	reserve(6, 0, 1, side)
+	reserve(0, 6, side, 1)
+	positions := alignmentPatternPositions(version)
+	for _, x := range positions {
+		for _, y := range positions {
+			if (x < 9 && y < 9) || (x < 9 && y > side-9) || (x > side-9 && y < 9) {
+				continue
+			}
+			reserve(x-2, y-2, 5, 5)
+		}
+	}
+	if version >= 7 {
+		reserve(side-11, 0, 3, 6)
+		reserve(0, side-11, 6, 3)
+	}
+
+	masks := []func(i, j int) bool{
+		func(i, j int) bool { return (i+j)%2 == 0 },
+		func(i, j int) bool { return i%2 == 0 },
+		func(i, j int) bool { return j%3 == 0 },
+		func(i, j int) bool { return (i+j)%3 == 0 },
+		func(i, j int) bool { return (i/2+j/3)%2 == 0 },
+		func(i, j int) bool { return (i*j)%2+(i*j)%3 == 0 },
+		func(i, j int) bool { return ((i*j)%2+(i*j)%3)%2 == 0 },
+		func(i, j int) bool { return ((i*j)%3+(i+j)%2)%2 == 0 },
+	}
+
+	var stream []byte
+	var current byte
+	count := 0
+	x, y, dy := side-1, side-1, -1
+	for x > 0 {
+		if x == 6 {
+			x--
+		}
+		for ; y >= 0 && y < side; y += dy {
+			for _, cx := range []int{x, x - 1} {
+				if reserved[y][cx] {
+					continue
+				}
+				bit := dark(cx, y) != masks[mask](y, cx)
+				current <<= 1
+				if bit {
+					current |= 1
+				}
+				count++
+				if count == 8 {
+					stream = append(stream, current)
+					current, count = 0, 0
+				}
+			}
+		}
+		dy = -dy
+		y += dy
+		x -= 2
+	}
+
+	numBlocks := errorCorrectionBlocks[level][version]
+	eccLen := eccCodewordsPerBlock[level][version]
+	total := len(stream)
+	shortLen := total / numBlocks
+	numShort := numBlocks - total%numBlocks
+	blocks := make([][]byte, numBlocks)
+	i := 0
+	for k := 0; k < shortLen-eccLen+1; k++ {
+		for b := range blocks {
+			if k == shortLen-eccLen && b < numShort {
+				continue
+			}
+			blocks[b] = append(blocks[b], stream[i])
+			i++
+		}
+	}
+	for k := 0; k < eccLen; k++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], stream[i])
+			i++
+		}
+	}
+
+	var data []byte
+	for b, block := range blocks {
+		root := byte(1)
+		for k := 0; k < eccLen; k++ {
+			var syndrome byte
+			for _, c := range block {
+				syndrome = gfMulSlow(syndrome, root) ^ c
+			}
+			if syndrome != 0 {
+				return nil, fmt.Errorf("Block %d has non-zero syndrome %d", b, k)
+			}
+			root = gfMulSlow(root, 2)
+		}
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+
+	bitPos := 0
+	read := func(n int) int {
+		v := 0
+		for k := 0; k < n; k++ {
+			v = v<<1 | int(data[bitPos/8]>>uint(7-bitPos%8))&1
+			bitPos++
+		}
+		return v
+	}
+	if mode := read(4); mode != 4 {
+		return nil, fmt.Errorf("Unexpected mode %d", mode)
+	}
+	length := read(8)
+	if version >= 10 {
+		length = length<<8 | read(8)
+	}
+	out := make([]byte, length)
+	for k := range out {
+		out[k] = byte(read(8))
+	}
+	return out, nil
+}
@@ -0,0 +1,65 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package qrcode
+
+// Arithmetic in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1, as mandated by ISO/IEC 18004.
+const gfPolynomial = 0x11d
+
+var gfExp [512]byte
+var gfLog [256]byte
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= gfPolynomial
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// rsGenerator returns the coefficients of (x - α^0)(x - α^1)…(x - α^(degree-1)),
+// highest power first, omitting the leading 1.
+func rsGenerator(degree int) []byte {
+	g := make([]byte, degree)
+	g[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := 0; j < degree; j++ {
+			g[j] = gfMul(g[j], root)
+			if j+1 < degree {
+				g[j] ^= g[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return g
+}
+
+func rsRemainder(data []byte, generator []byte) []byte {
+	rem := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ rem[0]
+		copy(rem, rem[1:])
+		rem[len(rem)-1] = 0
+		for i, coef := range generator {
+			rem[i] ^= gfMul(coef, factor)
+		}
+	}
+	return rem
+}
@@ -0,0 +1,30 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"net"
+	"strings"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+	"git.zx2c4.com/wireguard-windows/manager/walk"
+)
+
+func showRoute(owner walk.Form, config *conf.Config, destination string) {
+	ip := net.ParseIP(strings.TrimSpace(destination))
+	if ip == nil {
+		walk.MsgBox(owner, "Route lookup", "Please enter a valid IP address.", walk.MsgBoxIconError)
+		return
+	}
+	message := config.Route(ip).Explain(config)
+	if conflicts := config.RouteConflicts(); len(conflicts) > 0 {
+		message += "\nConflicting AllowedIPs:\n"
+		for _, conflict := range conflicts {
+			message += conflict.Explain(config) + "\n"
+		}
+	}
+	walk.MsgBox(owner, "Route lookup", message, walk.MsgBoxIconInformation)
+}
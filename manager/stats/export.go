@@ -0,0 +1,113 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+type jsonPoint struct {
+	Time     time.Time `json:"time"`
+	Duration float64   `json:"duration"` // Seconds.
+	RxBytes  uint64    `json:"rx_bytes"`
+	TxBytes  uint64    `json:"tx_bytes"`
+	RxRate   float64   `json:"rx_rate"`
+	TxRate   float64   `json:"tx_rate"`
+	Reset    bool      `json:"reset,omitempty"`
+}
+
+type jsonSeries struct {
+	PublicKey string      `json:"public_key"`
+	Points    []jsonPoint `json:"points"`
+}
+
+func (s Series) MarshalJSON() ([]byte, error) {
+	j := jsonSeries{PublicKey: s.PublicKey.String(), Points: make([]jsonPoint, len(s.Points))}
+	for i := range s.Points {
+		p := &s.Points[i]
+		j.Points[i] = jsonPoint{
+			Time:     p.Time.UTC(),
+			Duration: p.Duration.Seconds(),
+			RxBytes:  p.RxBytes,
+			TxBytes:  p.TxBytes,
+			RxRate:   p.RxRate(),
+			TxRate:   p.TxRate(),
+			Reset:    p.Reset,
+		}
+	}
+	return json.Marshal(j)
+}
+
+func (s *Series) UnmarshalJSON(data []byte) error {
+	var j jsonSeries
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+	key, err := conf.ParseKey(j.PublicKey)
+	if err != nil {
+		return err
+	}
+	s.PublicKey = *key
+	s.Points = make([]Point, len(j.Points))
+	for i, p := range j.Points {
+		s.Points[i] = Point{
+			Time:     p.Time,
+			Duration: time.Duration(p.Duration * float64(time.Second)),
+			RxBytes:  p.RxBytes,
+			TxBytes:  p.TxBytes,
+			Reset:    p.Reset,
+		}
+	}
+	return nil
+}
+
+func WriteJSON(w io.Writer, series []Series) error {
+	if series == nil {
+		series = []Series{}
+	}
+	return json.NewEncoder(w).Encode(series)
+}
+
+func ReadJSON(r io.Reader) ([]Series, error) {
+	var series []Series
+	err := json.NewDecoder(r).Decode(&series)
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// WriteCSV writes one row per point, with times in RFC 3339 and rates in
+// bytes per second.
+func WriteCSV(w io.Writer, series []Series) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"public_key", "time", "duration", "rx_bytes", "tx_bytes", "rx_rate", "tx_rate", "reset"})
+	for _, s := range series {
+		key := s.PublicKey.String()
+		for i := range s.Points {
+			p := &s.Points[i]
+			writer.Write([]string{
+				key,
+				p.Time.UTC().Format(time.RFC3339),
+				strconv.FormatFloat(p.Duration.Seconds(), 'f', -1, 64),
+				strconv.FormatUint(p.RxBytes, 10),
+				strconv.FormatUint(p.TxBytes, 10),
+				strconv.FormatFloat(p.RxRate(), 'f', 2, 64),
+				strconv.FormatFloat(p.TxRate(), 'f', 2, 64),
+				strconv.FormatBool(p.Reset),
+			})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,75 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package stats
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+var (
+	graphBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	graphRx         = color.RGBA{0x8c, 0xd1, 0x8c, 0xff}
+	graphTx         = color.RGBA{0x1f, 0x4e, 0x9c, 0xff}
+)
+
+// rates is the traffic of all peers during one interval, in bytes per second.
+type rates struct {
+	rx, tx float64
+}
+
+// Graph draws the traffic of all peers in series as a width by height image,
+// oldest interval on the left. The receive rate is drawn as a filled area and
+// the transmit rate as a line, both scaled to the highest rate of either.
+func Graph(series []Series, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{graphBackground}, image.ZP, draw.Src)
+
+	totals := make(map[int64]*rates)
+	for _, s := range series {
+		for i := range s.Points {
+			p := &s.Points[i]
+			key := p.Time.UnixNano()
+			if totals[key] == nil {
+				totals[key] = &rates{}
+			}
+			totals[key].rx += p.RxRate()
+			totals[key].tx += p.TxRate()
+		}
+	}
+	if len(totals) == 0 || width <= 0 || height <= 0 {
+		return img
+	}
+	times := make([]int64, 0, len(totals))
+	var highest float64
+	for key, r := range totals {
+		times = append(times, key)
+		if r.rx > highest {
+			highest = r.rx
+		}
+		if r.tx > highest {
+			highest = r.tx
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	if highest <= 0 {
+		return img
+	}
+
+	scale := func(rate float64) int {
+		return int(rate / highest * float64(height-1))
+	}
+	for x := 0; x < width; x++ {
+		r := totals[times[x*len(times)/width]]
+		for y := 0; y <= scale(r.rx); y++ {
+			img.SetRGBA(x, height-1-y, graphRx)
+		}
+		img.SetRGBA(x, height-1-scale(r.tx), graphTx)
+	}
+	return img
+}
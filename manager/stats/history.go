@@ -0,0 +1,241 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package stats
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+// Point is the traffic of one peer during one interval of the history.
+type Point struct {
+	Time     time.Time     // Start of the interval, a multiple of the resolution.
+	Duration time.Duration // Time actually covered by the counters, which can exceed the resolution after a gap.
+	RxBytes  uint64
+	TxBytes  uint64
+
+	// Reset is set when the counters went backwards during the interval,
+	// because the device restarted or the peer was removed and added again.
+	// Traffic from before the reset that was not yet recorded is lost.
+	Reset bool
+}
+
+// RxRate returns the average receive rate in bytes per second.
+func (p *Point) RxRate() float64 {
+	if p.Duration <= 0 {
+		return 0
+	}
+	return float64(p.RxBytes) / p.Duration.Seconds()
+}
+
+// TxRate returns the average transmit rate in bytes per second.
+func (p *Point) TxRate() float64 {
+	if p.Duration <= 0 {
+		return 0
+	}
+	return float64(p.TxBytes) / p.Duration.Seconds()
+}
+
+// Series is the history of one peer, oldest point first.
+type Series struct {
+	PublicKey conf.Key
+	Points    []Point
+}
+
+// ring holds the points of one peer, overwriting the oldest once it holds
+// capacity points. Its storage grows as points arrive, so that peers with
+// little history take little memory.
+type ring struct {
+	points   []Point
+	start    int
+	count    int
+	capacity int
+
+	lastTime time.Time
+	lastRx   uint64
+	lastTx   uint64
+}
+
+func (r *ring) latest() *Point {
+	if r.count == 0 {
+		return nil
+	}
+	return &r.points[(r.start+r.count-1)%len(r.points)]
+}
+
+// minRingSize is the number of points a ring first makes room for.
+const minRingSize = 16
+
+func (r *ring) grow() {
+	size := 2 * len(r.points)
+	if size < minRingSize {
+		size = minRingSize
+	}
+	if size > r.capacity {
+		size = r.capacity
+	}
+	points := make([]Point, size)
+	for i := 0; i < r.count; i++ {
+		points[i] = r.points[(r.start+i)%len(r.points)]
+	}
+	r.points, r.start = points, 0
+}
+
+func (r *ring) push(p Point) {
+	if r.count == len(r.points) && len(r.points) < r.capacity {
+		r.grow()
+	}
+	if r.count < len(r.points) {
+		r.points[(r.start+r.count)%len(r.points)] = p
+		r.count++
+		return
+	}
+	r.points[r.start] = p
+	r.start = (r.start + 1) % len(r.points)
+}
+
+// expire drops points at or before cutoff, which remain when recording has gaps.
+func (r *ring) expire(cutoff time.Time) {
+	for r.count > 0 && !r.points[r.start].Time.After(cutoff) {
+		r.start = (r.start + 1) % len(r.points)
+		r.count--
+	}
+}
+
+func (r *ring) since(t time.Time) []Point {
+	var points []Point
+	for i := 0; i < r.count; i++ {
+		p := r.points[(r.start+i)%len(r.points)]
+		if !p.Time.Before(t) {
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+// counterDelta returns how far a counter moved from last to current. A
+// counter that went backwards either wrapped around, which a 64-bit byte
+// counter only does from very close to the top, or started over from zero.
+func counterDelta(last, current uint64) (delta uint64, reset bool) {
+	if current >= last {
+		return current - last, false
+	}
+	if last >= 1<<63 {
+		return current - last, false
+	}
+	return current, true
+}
+
+// History keeps the recent transfer statistics of every peer of a tunnel, at
+// a fixed resolution, for a fixed retention period. It is safe for
+// concurrent use.
+type History struct {
+	mutex      sync.Mutex
+	resolution time.Duration
+	retention  time.Duration
+	peers      map[conf.Key]*ring
+}
+
+func NewHistory(resolution, retention time.Duration) *History {
+	if resolution <= 0 {
+		resolution = time.Second
+	}
+	if retention < resolution {
+		retention = resolution
+	}
+	return &History{
+		resolution: resolution,
+		retention:  retention,
+		peers:      make(map[conf.Key]*ring),
+	}
+}
+
+func (h *History) Resolution() time.Duration {
+	return h.resolution
+}
+
+func (h *History) Retention() time.Duration {
+	return h.retention
+}
+
+// Record adds the cumulative counters of peers, as read from the device at
+// now. It may be called more often than the resolution, in which case the
+// traffic is added to the current interval. The first record of a peer only
+// establishes its baseline.
+func (h *History) Record(now time.Time, peers []conf.Peer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	bucket := now.Truncate(h.resolution)
+	for _, peer := range peers {
+		r, ok := h.peers[peer.PublicKey]
+		if !ok {
+			r = &ring{
+				capacity: int(h.retention / h.resolution),
+				lastTime: now,
+				lastRx:   peer.RxBytes,
+				lastTx:   peer.TxBytes,
+			}
+			h.peers[peer.PublicKey] = r
+			continue
+		}
+		if !now.After(r.lastTime) {
+			continue
+		}
+		rx, rxReset := counterDelta(r.lastRx, peer.RxBytes)
+		tx, txReset := counterDelta(r.lastTx, peer.TxBytes)
+		elapsed := now.Sub(r.lastTime)
+		r.lastTime, r.lastRx, r.lastTx = now, peer.RxBytes, peer.TxBytes
+
+		if latest := r.latest(); latest != nil && latest.Time.Equal(bucket) {
+			latest.Duration += elapsed
+			latest.RxBytes += rx
+			latest.TxBytes += tx
+			latest.Reset = latest.Reset || rxReset || txReset
+			continue
+		}
+		r.push(Point{
+			Time:     bucket,
+			Duration: elapsed,
+			RxBytes:  rx,
+			TxBytes:  tx,
+			Reset:    rxReset || txReset,
+		})
+	}
+
+	// Forget peers that have not been seen for the whole retention period.
+	cutoff := bucket.Add(-h.retention)
+	for key, r := range h.peers {
+		if now.Sub(r.lastTime) > h.retention {
+			delete(h.peers, key)
+			continue
+		}
+		r.expire(cutoff)
+	}
+}
+
+// Query returns the points at or after since of every peer that has any,
+// ordered by public key.
+func (h *History) Query(since time.Time) []Series {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var series []Series
+	for key, r := range h.peers {
+		points := r.since(since)
+		if len(points) > 0 {
+			series = append(series, Series{PublicKey: key, Points: points})
+		}
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return bytes.Compare(series[i].PublicKey[:], series[j].PublicKey[:]) < 0
+	})
+	return series
+}
@@ -0,0 +1,190 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package stats
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+var epoch = time.Unix(1500000000, 0)
+
+func peer(key byte, rx, tx uint64) conf.Peer {
+	p := conf.Peer{RxBytes: rx, TxBytes: tx}
+	p.PublicKey[0] = key
+	return p
+}
+
+func TestRecord(test *testing.T) {
+	h := NewHistory(time.Second, 10*time.Second)
+	h.Record(epoch, []conf.Peer{peer(1, 1000, 500)})
+	h.Record(epoch.Add(500*time.Millisecond), []conf.Peer{peer(1, 1500, 600)})
+	h.Record(epoch.Add(900*time.Millisecond), []conf.Peer{peer(1, 2000, 700)})
+	h.Record(epoch.Add(2*time.Second), []conf.Peer{peer(1, 4200, 700)})
+	// The device restarted, so the counters start over.
+	h.Record(epoch.Add(3*time.Second), []conf.Peer{peer(1, 100, 50), peer(2, 7, 7)})
+
+	series := h.Query(time.Time{})
+	if len(series) != 1 {
+		test.Fatalf("Expected one series, got %d", len(series))
+	}
+	points := series[0].Points
+	if len(points) != 3 {
+		test.Fatalf("Expected 3 points, got %+v", points)
+	}
+	if p := points[0]; !p.Time.Equal(epoch) || p.RxBytes != 1000 || p.TxBytes != 200 || p.RxRate() != 1000/0.9 || p.Reset {
+		test.Errorf("Unexpected first point: %+v", p)
+	}
+	if p := points[1]; p.RxBytes != 2200 || p.Duration != 1100*time.Millisecond || p.RxRate() < 1999.99 || p.RxRate() > 2000.01 {
+		test.Errorf("Unexpected second point: %+v", p)
+	}
+	if p := points[2]; !p.Reset || p.RxBytes != 100 || p.TxBytes != 50 {
+		test.Errorf("Expected a reset in the third point: %+v", p)
+	}
+
+	if series = h.Query(epoch.Add(2 * time.Second)); len(series[0].Points) != 2 {
+		test.Errorf("Expected 2 points since 2s, got %d", len(series[0].Points))
+	}
+}
+
+func TestCounterWrap(test *testing.T) {
+	if delta, reset := counterDelta(^uint64(0)-9, 10); delta != 20 || reset {
+		test.Errorf("Expected wrap to give 20, got %d, %v", delta, reset)
+	}
+	if delta, reset := counterDelta(1000, 10); delta != 10 || !reset {
+		test.Errorf("Expected reset to give 10, got %d, %v", delta, reset)
+	}
+}
+
+func TestRetention(test *testing.T) {
+	h := NewHistory(time.Second, 5*time.Second)
+	for i := 0; i <= 20; i++ {
+		h.Record(epoch.Add(time.Duration(i)*time.Second), []conf.Peer{peer(1, uint64(i)*100, 0)})
+	}
+	points := h.Query(time.Time{})[0].Points
+	if len(points) != 5 || !points[0].Time.Equal(epoch.Add(16*time.Second)) {
+		test.Errorf("Expected the last 5 seconds, got %d points from %v", len(points), points[0].Time)
+	}
+
+	// After a gap, old points expire even though the ring is not full.
+	h.Record(epoch.Add(23*time.Second), []conf.Peer{peer(1, 2500, 0)})
+	points = h.Query(time.Time{})[0].Points
+	if len(points) != 3 || points[2].Duration != 3*time.Second || points[2].RxRate() != 500.0/3 {
+		test.Errorf("Unexpected points after gap: %+v", points)
+	}
+
+	// Peers disappear once they have been gone for the whole retention.
+	h.Record(epoch.Add(30*time.Second), nil)
+	if series := h.Query(time.Time{}); len(series) != 0 {
+		test.Errorf("Expected departed peer to be forgotten, got %+v", series)
+	}
+}
+
+func TestRingGrowth(test *testing.T) {
+	h := NewHistory(time.Second, time.Hour)
+	h.Record(epoch, []conf.Peer{peer(1, 0, 0)})
+	r := h.peers[peer(1, 0, 0).PublicKey]
+	if len(r.points) != 0 {
+		test.Errorf("Expected no storage before the first point, got %d", len(r.points))
+	}
+	for i := 1; i <= 100; i++ {
+		h.Record(epoch.Add(time.Duration(i)*time.Second), []conf.Peer{peer(1, uint64(i), 0)})
+		if i == 10 {
+			// Expire the first points, so that the ring wraps before it grows.
+			r.expire(epoch.Add(5 * time.Second))
+		}
+	}
+	if len(r.points) != 128 {
+		test.Errorf("Expected storage for 128 points, got %d", len(r.points))
+	}
+	points := h.Query(time.Time{})[0].Points
+	if len(points) != 95 || !points[0].Time.Equal(epoch.Add(6*time.Second)) {
+		test.Fatalf("Expected 95 points from 6s, got %d from %v", len(points), points[0].Time)
+	}
+	for i, p := range points {
+		if !p.Time.Equal(epoch.Add(time.Duration(i+6)*time.Second)) || p.RxBytes != 1 {
+			test.Fatalf("Unexpected point %d: %+v", i, p)
+		}
+	}
+
+	h = NewHistory(time.Second, 20*time.Second)
+	for i := 0; i <= 100; i++ {
+		h.Record(epoch.Add(time.Duration(i)*time.Second), []conf.Peer{peer(1, uint64(i), 0)})
+	}
+	if r = h.peers[peer(1, 0, 0).PublicKey]; len(r.points) != 20 {
+		test.Errorf("Expected storage to stop at the capacity of 20 points, got %d", len(r.points))
+	}
+}
+
+func TestExport(test *testing.T) {
+	h := NewHistory(time.Second, time.Minute)
+	h.Record(epoch, []conf.Peer{peer(2, 0, 0), peer(1, 0, 0)})
+	h.Record(epoch.Add(2*time.Second), []conf.Peer{peer(2, 2048, 1024), peer(1, 10, 0)})
+	series := h.Query(time.Time{})
+	if series[0].PublicKey[0] != 1 {
+		test.Error("Expected series ordered by public key")
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, series); err != nil {
+		test.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || lines[0] != "public_key,time,duration,rx_bytes,tx_bytes,rx_rate,tx_rate,reset" {
+		test.Fatalf("Unexpected CSV:\n%s", buf.String())
+	}
+	key := series[1].PublicKey
+	if expected := key.String() + ",2017-07-14T02:40:02Z,2,2048,1024,1024.00,512.00,false"; lines[2] != expected {
+		test.Errorf("Unexpected CSV row %s, expected %s", lines[2], expected)
+	}
+
+	a, b := net.Pipe()
+	go func() {
+		h.ServeQuery(b)
+		b.Close()
+	}()
+	fetched, err := Fetch(a, epoch.Add(time.Second))
+	a.Close()
+	if err != nil {
+		test.Fatal(err)
+	}
+	if len(fetched) != 2 || fetched[1].PublicKey != key || len(fetched[1].Points) != 1 {
+		test.Fatalf("Unexpected fetched series: %+v", fetched)
+	}
+	if p := fetched[1].Points[0]; !p.Time.Equal(epoch.Add(2*time.Second)) || p.Duration != 2*time.Second || p.RxRate() != 1024 {
+		test.Errorf("Unexpected fetched point: %+v", p)
+	}
+}
+
+func TestGraph(test *testing.T) {
+	h := NewHistory(time.Second, time.Minute)
+	h.Record(epoch, []conf.Peer{peer(1, 0, 0), peer(2, 0, 0)})
+	h.Record(epoch.Add(time.Second), []conf.Peer{peer(1, 1000, 500), peer(2, 1000, 0)})
+	h.Record(epoch.Add(2*time.Second), []conf.Peer{peer(1, 2000, 500), peer(2, 1000, 0)})
+	h.Record(epoch.Add(3*time.Second), []conf.Peer{peer(1, 2000, 500), peer(2, 1000, 0)})
+
+	img := Graph(h.Query(time.Time{}), 30, 11)
+	// the first interval receives 2000 bytes per second across both peers,
+	// the highest rate, and sends 500, a quarter of it.
+	if img.RGBAAt(0, 0) != graphRx || img.RGBAAt(0, 10) != graphRx {
+		test.Errorf("Expected the highest receive rate to fill the first column")
+	}
+	if img.RGBAAt(0, 10-2) != graphTx {
+		test.Errorf("Expected the transmit rate to be drawn at a quarter of the height")
+	}
+	if img.RGBAAt(29, 0) != graphBackground {
+		test.Errorf("Expected the last interval to be drawn lower than the highest rate")
+	}
+
+	if img = Graph(nil, 30, 11); img.RGBAAt(0, 10) != graphBackground {
+		test.Errorf("Expected an empty graph without history")
+	}
+}
@@ -0,0 +1,96 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+// ListenerName is the name under which the service of a tunnel answers
+// history queries, next to its UAPI. It cannot clash with another tunnel's
+// UAPI, because tunnel names may not contain an @.
+func ListenerName(tunnel conf.TunnelName) string {
+	return tunnel.AdapterName() + "@stats"
+}
+
+// The protocol follows the UAPI: a request is a block of key=value lines
+// ended by a blank line, currently always "get=stats" and "since=" followed
+// by Unix nanoseconds, and the response starts with an errno line, which on
+// success is followed by the JSON export.
+
+// ServeQuery answers a single query read from conn.
+func (h *History) ServeQuery(conn io.ReadWriter) error {
+	reader := bufio.NewReader(conn)
+	var since time.Time
+	valid := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if len(line) == 0 {
+			break
+		}
+		equals := strings.IndexByte(line, '=')
+		if equals < 0 {
+			valid = false
+			continue
+		}
+		key, value := line[:equals], line[equals+1:]
+		switch key {
+		case "get":
+			valid = value == "stats"
+		case "since":
+			nanoseconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				valid = false
+				continue
+			}
+			if nanoseconds > 0 {
+				since = time.Unix(0, nanoseconds)
+			}
+		}
+	}
+	if !valid {
+		_, err := io.WriteString(conn, "errno=22\n\n")
+		return err
+	}
+	_, err := io.WriteString(conn, "errno=0\n")
+	if err != nil {
+		return err
+	}
+	return WriteJSON(conn, h.Query(since))
+}
+
+// Fetch queries the history that a tunnel service serves on conn, returning
+// the points at or after since, or all of them if since is zero.
+func Fetch(conn io.ReadWriter, since time.Time) ([]Series, error) {
+	request := "get=stats\n"
+	if !since.IsZero() {
+		request += fmt.Sprintf("since=%d\n", since.UnixNano())
+	}
+	_, err := io.WriteString(conn, request+"\n")
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	status, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if status != "errno=0\n" {
+		return nil, fmt.Errorf("Unable to query transfer history: %s", strings.TrimSpace(status))
+	}
+	return ReadJSON(reader)
+}
@@ -0,0 +1,272 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package topology
+
+import (
+	"errors"
+	"fmt"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+type Layout int
+
+const (
+	// Star connects every spoke to the single hub, which relays between them.
+	Star Layout = iota
+	// FullMesh connects every node directly to every other node, regardless of role.
+	FullMesh
+	// Hybrid connects the hubs in a full mesh, and each spoke to its own hub.
+	Hybrid
+)
+
+type Role int
+
+const (
+	Spoke Role = iota
+	Hub
+)
+
+type Node struct {
+	Name string
+	Role Role
+
+	// Hub names the hub a spoke attaches to in a hybrid layout. It may be
+	// left empty when there is only one hub.
+	Hub string
+
+	// Endpoint is where other nodes can reach this node; it is empty for
+	// nodes that only ever initiate connections, such as those behind NAT.
+	Endpoint conf.Endpoint
+
+	// Addresses are the node's own tunnel addresses, and Subnets are the
+	// networks behind it for which it routes.
+	Addresses []conf.IPCidr
+	Subnets   []conf.IPCidr
+
+	// PrivateKey is generated when left zero.
+	PrivateKey conf.Key
+}
+
+type Topology struct {
+	Layout Layout
+	Nodes  []Node
+
+	// PresharedKeys adds a distinct preshared key to every pair of connected nodes.
+	PresharedKeys bool
+
+	// PersistentKeepalive is used by nodes without an endpoint towards peers that have one.
+	PersistentKeepalive uint16
+}
+
+type node struct {
+	*Node
+	name       conf.TunnelName
+	privateKey conf.Key
+	publicKey  conf.Key
+	hub        *node
+	spokes     []*node
+}
+
+func (n *node) prefixes() []conf.IPCidr {
+	var prefixes []conf.IPCidr
+	for _, address := range n.Addresses {
+		address.Cidr = address.Bits()
+		prefixes = append(prefixes, address)
+	}
+	for _, subnet := range n.Subnets {
+		subnet.MaskSelf()
+		prefixes = append(prefixes, subnet)
+	}
+	return prefixes
+}
+
+// Generate returns one configuration per node, in the order of t.Nodes.
+func (t *Topology) Generate() ([]*conf.Config, error) {
+	nodes, err := t.resolveNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	links := make(map[*node][]*node)
+	link := func(a, b *node) {
+		links[a] = append(links[a], b)
+		links[b] = append(links[b], a)
+	}
+	var hubs []*node
+	for _, n := range nodes {
+		if t.Layout == FullMesh || n.Role == Hub {
+			hubs = append(hubs, n)
+		}
+	}
+	for i, a := range hubs {
+		for _, b := range hubs[i+1:] {
+			link(a, b)
+		}
+	}
+	for _, n := range nodes {
+		if n.hub != nil {
+			link(n, n.hub)
+		}
+	}
+
+	for _, a := range nodes {
+		for _, b := range links[a] {
+			if a.Endpoint.IsEmpty() && b.Endpoint.IsEmpty() {
+				return nil, fmt.Errorf("Neither %s nor %s has an endpoint, so they cannot connect", a.name, b.name)
+			}
+		}
+	}
+
+	presharedKeys := make(map[[2]*node]conf.Key)
+	presharedKey := func(a, b *node) (conf.Key, error) {
+		if !t.PresharedKeys {
+			return conf.Key{}, nil
+		}
+		pair := [2]*node{a, b}
+		if a.name > b.name {
+			pair = [2]*node{b, a}
+		}
+		if k, ok := presharedKeys[pair]; ok {
+			return k, nil
+		}
+		k, err := conf.NewPresharedKey()
+		if err != nil {
+			return conf.Key{}, err
+		}
+		presharedKeys[pair] = *k
+		return *k, nil
+	}
+
+	configs := make([]*conf.Config, len(nodes))
+	for i, n := range nodes {
+		config := &conf.Config{
+			Name: n.name,
+			Interface: conf.Interface{
+				PrivateKey: n.privateKey,
+				Addresses:  n.Addresses,
+				ListenPort: n.Endpoint.Port,
+			},
+		}
+		for _, other := range links[n] {
+			psk, err := presharedKey(n, other)
+			if err != nil {
+				return nil, err
+			}
+			peer := conf.Peer{
+				PublicKey:    other.publicKey,
+				PresharedKey: psk,
+				Endpoint:     other.Endpoint,
+				AllowedIPs:   conf.NewIPSet(t.routedVia(n, other, nodes)...).Prefixes(),
+			}
+			if n.Endpoint.IsEmpty() && !other.Endpoint.IsEmpty() {
+				peer.PersistentKeepalive = t.PersistentKeepalive
+			}
+			config.Peers = append(config.Peers, peer)
+		}
+		configs[i] = config
+	}
+	return configs, nil
+}
+
+func (t *Topology) resolveNodes() ([]*node, error) {
+	if len(t.Nodes) == 0 {
+		return nil, errors.New("Topology has no nodes")
+	}
+	var nodes []*node
+	var names []conf.TunnelName
+	byName := make(map[conf.TunnelName]*node)
+	var hubs []*node
+	for i := range t.Nodes {
+		n := &node{Node: &t.Nodes[i]}
+		name, err := conf.NewTunnelName(n.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !conf.TunnelNameIsUnique(names, name) {
+			return nil, fmt.Errorf("Node name %s is used more than once", name)
+		}
+		if len(n.Addresses) == 0 {
+			return nil, fmt.Errorf("Node %s has no addresses", name)
+		}
+		n.name = name
+		n.privateKey = n.PrivateKey
+		if n.privateKey.IsZero() {
+			k, err := conf.NewPrivateKey()
+			if err != nil {
+				return nil, err
+			}
+			n.privateKey = *k
+		}
+		n.publicKey = *n.privateKey.Public()
+		names = append(names, name)
+		byName[name] = n
+		nodes = append(nodes, n)
+		if n.Role == Hub {
+			hubs = append(hubs, n)
+		}
+	}
+
+	switch t.Layout {
+	case FullMesh:
+		return nodes, nil
+	case Star:
+		if len(hubs) != 1 {
+			return nil, fmt.Errorf("A star layout needs exactly one hub, but there are %d", len(hubs))
+		}
+	case Hybrid:
+		if len(hubs) == 0 {
+			return nil, errors.New("A hybrid layout needs at least one hub")
+		}
+	default:
+		return nil, fmt.Errorf("Invalid layout %d", int(t.Layout))
+	}
+	for _, n := range nodes {
+		if n.Role == Hub {
+			continue
+		}
+		if len(n.Hub) == 0 {
+			if len(hubs) != 1 {
+				return nil, fmt.Errorf("Spoke %s must name its hub", n.name)
+			}
+			n.hub = hubs[0]
+		} else {
+			hubName, _ := conf.FindTunnelName(names, conf.TunnelName(n.Hub))
+			n.hub = byName[hubName]
+			if n.hub == nil || n.hub.Role != Hub {
+				return nil, fmt.Errorf("Spoke %s names %s as its hub, which is not a hub", n.name, n.Hub)
+			}
+		}
+		n.hub.spokes = append(n.hub.spokes, n)
+	}
+	return nodes, nil
+}
+
+// routedVia returns the prefixes that from sends to its directly linked peer via.
+func (t *Topology) routedVia(from, via *node, nodes []*node) []conf.IPCidr {
+	if t.Layout == FullMesh {
+		return via.prefixes()
+	}
+	if from.hub == via {
+		// Spokes send everything except their own prefixes to their hub.
+		var prefixes []conf.IPCidr
+		for _, n := range nodes {
+			if n != from {
+				prefixes = append(prefixes, n.prefixes()...)
+			}
+		}
+		return prefixes
+	}
+	if via.hub == from {
+		return via.prefixes()
+	}
+	// Between hubs, each hub is responsible for itself and its spokes.
+	prefixes := via.prefixes()
+	for _, spoke := range via.spokes {
+		prefixes = append(prefixes, spoke.prefixes()...)
+	}
+	return prefixes
+}
@@ -0,0 +1,129 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package topology
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+func cidr(s string) conf.IPCidr {
+	ip, network, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	ones, _ := network.Mask.Size()
+	return conf.IPCidr{IP: ip, Cidr: uint8(ones)}
+}
+
+func testNodes() []Node {
+	return []Node{
+		{Name: "hub1", Role: Hub, Endpoint: conf.Endpoint{Host: "hub1.example.com", Port: 51820}, Addresses: []conf.IPCidr{cidr("10.0.0.1/24"), cidr("fd00::1/64")}, Subnets: []conf.IPCidr{cidr("192.168.1.0/24")}},
+		{Name: "hub2", Role: Hub, Endpoint: conf.Endpoint{Host: "hub2.example.com", Port: 51820}, Addresses: []conf.IPCidr{cidr("10.0.0.2/24"), cidr("fd00::2/64")}},
+		{Name: "laptop", Hub: "hub1", Addresses: []conf.IPCidr{cidr("10.0.0.3/24"), cidr("fd00::3/64")}},
+		{Name: "phone", Hub: "hub2", Addresses: []conf.IPCidr{cidr("10.0.0.4/24")}},
+		{Name: "desktop", Hub: "hub2", Endpoint: conf.Endpoint{Host: "198.51.100.7", Port: 4000}, Addresses: []conf.IPCidr{cidr("10.0.0.5/24")}},
+	}
+}
+
+func prefixesString(prefixes []conf.IPCidr) string {
+	var s []string
+	for _, a := range prefixes {
+		s = append(s, a.String())
+	}
+	return fmt.Sprint(s)
+}
+
+func allowedIPs(peer conf.Peer) string {
+	return prefixesString(peer.AllowedIPs)
+}
+
+func TestHybrid(test *testing.T) {
+	t := Topology{Layout: Hybrid, Nodes: testNodes(), PresharedKeys: true, PersistentKeepalive: 25}
+	configs, err := t.Generate()
+	if err != nil {
+		test.Fatal(err)
+	}
+	if err = Verify(configs); err != nil {
+		test.Fatal(err)
+	}
+	hub1, hub2, laptop, phone := configs[0], configs[1], configs[2], configs[3]
+	if len(hub1.Peers) != 2 || len(hub2.Peers) != 3 || len(laptop.Peers) != 1 || len(phone.Peers) != 1 {
+		test.Fatalf("Unexpected peer counts %d %d %d %d", len(hub1.Peers), len(hub2.Peers), len(laptop.Peers), len(phone.Peers))
+	}
+	if a := allowedIPs(hub1.Peers[0]); a != "[10.0.0.2/32 10.0.0.4/31 fd00::2/128]" {
+		test.Errorf("Unexpected aggregated routes from hub1 to hub2: %s", a)
+	}
+	if a := allowedIPs(laptop.Peers[0]); a != "[10.0.0.1/32 10.0.0.2/32 10.0.0.4/31 192.168.1.0/24 fd00::1/128 fd00::2/128]" {
+		test.Errorf("Unexpected routes from laptop to hub1: %s", a)
+	}
+	if laptop.Peers[0].PersistentKeepalive != 25 || hub1.Peers[1].PersistentKeepalive != 0 {
+		test.Error("Expected keepalive only from nodes without an endpoint")
+	}
+	if hub1.Peers[0].PresharedKey == hub1.Peers[1].PresharedKey || hub1.Peers[0].PresharedKey.IsZero() {
+		test.Error("Expected distinct preshared keys per pair")
+	}
+	for _, config := range configs {
+		if _, err := conf.FromWgQuick(config.ToWgQuick(), string(config.Name)); err != nil {
+			test.Errorf("Config for %s does not round trip: %v", config.Name, err)
+		}
+	}
+}
+
+func TestStarAndMesh(test *testing.T) {
+	nodes := testNodes()
+	nodes[1].Role = Spoke
+	for i := range nodes {
+		nodes[i].Hub = ""
+	}
+	star := Topology{Layout: Star, Nodes: nodes}
+	configs, err := star.Generate()
+	if err != nil {
+		test.Fatal(err)
+	}
+	if err = Verify(configs); err != nil {
+		test.Fatal(err)
+	}
+	if len(configs[0].Peers) != 4 {
+		test.Errorf("Expected the hub to have 4 peers, got %d", len(configs[0].Peers))
+	}
+
+	mesh := Topology{Layout: FullMesh, Nodes: testNodes()}
+	_, err = mesh.Generate()
+	if err == nil {
+		test.Error("Expected error connecting laptop and phone, which both lack endpoints")
+	}
+	meshNodes := testNodes()[:3]
+	mesh = Topology{Layout: FullMesh, Nodes: meshNodes}
+	configs, err = mesh.Generate()
+	if err != nil {
+		test.Fatal(err)
+	}
+	if err = Verify(configs); err != nil {
+		test.Fatal(err)
+	}
+}
+
+func TestVerifyAsymmetric(test *testing.T) {
+	t := Topology{Layout: Hybrid, Nodes: testNodes()}
+	configs, err := t.Generate()
+	if err != nil {
+		test.Fatal(err)
+	}
+	// Make hub2 forget that phone's address is behind phone.
+	phone := *configs[3].Interface.PrivateKey.Public()
+	for i := range configs[1].Peers {
+		if configs[1].Peers[i].PublicKey == phone {
+			configs[1].Peers[i].AllowedIPs = nil
+		}
+	}
+	if err = Verify(configs); err == nil {
+		test.Error("Expected asymmetric routing to be detected")
+	}
+}
@@ -0,0 +1,108 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package topology
+
+import (
+	"fmt"
+	"net"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+func lookup(config *conf.Config, ip net.IP) *conf.Peer {
+	return config.Route(ip).Peer(config)
+}
+
+func owns(config *conf.Config, ip net.IP) bool {
+	for _, address := range config.Interface.Addresses {
+		if address.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks that a set of configurations describing a whole network is
+// consistent: every peer relationship is mutual with matching preshared keys,
+// and traffic between any two node addresses is routed there and back along
+// the same path, so that no hop drops it for arriving from the wrong peer.
+func Verify(configs []*conf.Config) error {
+	byKey := make(map[conf.Key]*conf.Config)
+	for _, config := range configs {
+		publicKey := *config.Interface.PrivateKey.Public()
+		if other, ok := byKey[publicKey]; ok {
+			return fmt.Errorf("%s and %s have the same private key", other.Name, config.Name)
+		}
+		byKey[publicKey] = config
+	}
+
+	for _, a := range configs {
+		publicKey := *a.Interface.PrivateKey.Public()
+		for _, peer := range a.Peers {
+			b, ok := byKey[peer.PublicKey]
+			if !ok {
+				return fmt.Errorf("%s has a peer with public key %s that is not part of the network", a.Name, peer.PublicKey.String())
+			}
+			var reverse *conf.Peer
+			for i := range b.Peers {
+				if b.Peers[i].PublicKey == publicKey {
+					reverse = &b.Peers[i]
+				}
+			}
+			if reverse == nil {
+				return fmt.Errorf("%s has %s as a peer, but not the other way around", a.Name, b.Name)
+			}
+			if reverse.PresharedKey != peer.PresharedKey {
+				return fmt.Errorf("%s and %s disagree on their preshared key", a.Name, b.Name)
+			}
+		}
+	}
+
+	for _, source := range configs {
+		for _, destination := range configs {
+			if source == destination {
+				continue
+			}
+			for _, dst := range destination.Interface.Addresses {
+				for _, src := range source.Interface.Addresses {
+					if src.Bits() != dst.Bits() {
+						continue
+					}
+					err := verifyPath(byKey, source, src.IP, dst.IP)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func verifyPath(byKey map[conf.Key]*conf.Config, source *conf.Config, src, dst net.IP) error {
+	current, previous := source, (*conf.Config)(nil)
+	visited := make(map[*conf.Config]bool)
+	for {
+		if previous != nil {
+			back := lookup(current, src)
+			if back == nil || byKey[back.PublicKey] != previous {
+				return fmt.Errorf("%s drops traffic from %s arriving via %s, because it routes %s elsewhere", current.Name, src, previous.Name, src)
+			}
+		}
+		if owns(current, dst) {
+			return nil
+		}
+		if visited[current] {
+			return fmt.Errorf("Traffic from %s to %s loops at %s", src, dst, current.Name)
+		}
+		visited[current] = true
+		next := lookup(current, dst)
+		if next == nil {
+			return fmt.Errorf("%s has no route to %s", current.Name, dst)
+		}
+		previous, current = current, byKey[next.PublicKey]
+	}
+}
@@ -0,0 +1,64 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+	"git.zx2c4.com/wireguard-windows/manager/humanize"
+	"git.zx2c4.com/wireguard-windows/manager/stats"
+	"git.zx2c4.com/wireguard-windows/manager/walk"
+	. "git.zx2c4.com/wireguard-windows/manager/walk/declarative"
+)
+
+const (
+	trafficGraphWidth  = 600
+	trafficGraphHeight = 200
+	trafficGraphPeriod = time.Hour
+)
+
+func showTrafficGraph(owner walk.Form, config *conf.Config) error {
+	connection, err := os.OpenFile(`\\.\pipe\WireGuard\`+stats.ListenerName(config.Name), os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("Unable to access tunnel %s: %v", config.Name, err)
+	}
+	defer connection.Close()
+	series, err := stats.Fetch(connection, time.Now().Add(-trafficGraphPeriod))
+	if err != nil {
+		return err
+	}
+
+	bitmap, err := walk.NewBitmapFromImage(stats.Graph(series, trafficGraphWidth, trafficGraphHeight))
+	if err != nil {
+		return err
+	}
+	defer bitmap.Dispose()
+
+	var rx, tx float64
+	for _, s := range series {
+		latest := s.Points[len(s.Points)-1]
+		rx += latest.RxRate()
+		tx += latest.TxRate()
+	}
+
+	_, err = Dialog{
+		Title:  string(config.Name) + " – Traffic",
+		Layout: VBox{},
+		Children: []Widget{
+			ImageView{
+				Image: bitmap,
+				Mode:  ImageViewModeIdeal,
+			},
+			TextLabel{
+				Text: fmt.Sprintf("Receiving %s (area), sending %s (line). The graph shows the last %s.", humanize.Rate(rx), humanize.Rate(tx), humanize.Duration(trafficGraphPeriod)),
+			},
+		},
+	}.Run(owner)
+	return err
+}
@@ -0,0 +1,33 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"git.zx2c4.com/wireguard-windows/manager/humanize"
+	"git.zx2c4.com/wireguard-windows/manager/walk"
+	"git.zx2c4.com/wireguard-windows/manager/walk/win"
+)
+
+// Primary language identifiers, the low ten bits of a LANGID.
+const (
+	langGerman = 0x07
+	langFrench = 0x0c
+)
+
+// setupTranslation installs the built-in translations of the user interface
+// language for both walk and the formatting of sizes and times.
+func setupTranslation() {
+	var language string
+	switch win.GetThreadUILanguage() & 0x3ff {
+	case langGerman:
+		language = "de"
+	case langFrench:
+		language = "fr"
+	}
+	tr := humanize.Translations(language, humanize.TranslationFunction(walk.TranslationFunc()))
+	humanize.SetTranslationFunc(tr)
+	walk.SetTranslationFunc(walk.TranslationFunction(tr))
+}
@@ -628,6 +628,9 @@ func (b *Builder) conditionOrProperty(data Property) interface{} {
 		}
 
 		expr, err := govaluate.NewEvaluableExpressionWithFunctions(text, b.functions)
+		if perr, ok := err.(*govaluate.ParseError); ok {
+			panic(fmt.Errorf("invalid expression \"%s\": %s\n%s", e.text, err.Error(), perr.Snippet()))
+		}
 		if err != nil {
 			panic(fmt.Errorf(`invalid expression "%s": %s`, e.text, err.Error()))
 		}
@@ -646,7 +649,12 @@ func (b *Builder) conditionOrProperty(data Property) interface{} {
 
 		e.expr = expr
 
-		if _, err := e.expr.Eval(e.subExprsByPath); err != nil {
+		// Expressions are evaluated on every change of what they depend on.
+		if e.compiled, err = expr.Compile(); err != nil {
+			panic(fmt.Errorf(`invalid expression "%s": %s`, e.text, err.Error()))
+		}
+
+		if _, err := e.compiled.Eval(e.subExprsByPath); err != nil {
 			// We hope for the best and leave it to a DataBinder...
 			return nil
 		}
@@ -666,6 +674,7 @@ func (b *Builder) conditionOrProperty(data Property) interface{} {
 
 type expression struct {
 	expr                   *govaluate.EvaluableExpression
+	compiled               *govaluate.CompiledExpression
 	text                   string
 	subExprsByPath         subExpressions
 	subExprsChangedHandles []int
@@ -684,7 +693,7 @@ func (se subExpressions) Get(name string) (interface{}, error) {
 }
 
 func (e *expression) Value() interface{} {
-	val, err := e.expr.Eval(e.subExprsByPath)
+	val, err := e.compiled.Eval(e.subExprsByPath)
 	if err != nil {
 		log.Printf(`walk - failed to evaluate expression "%s": %s`, e.text, err.Error())
 	}
@@ -0,0 +1,591 @@
+package govaluate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+/*
+	CompiledExpression is an EvaluableExpression whose planned stages have been flattened into a list of instructions
+	for a small stack machine, instead of being walked recursively.
+	Numbers and bools stay unboxed on the stack while the common operators work on them,
+	which avoids most of the interface{} allocations of the tree evaluator.
+	Results, including errors, are identical to those of the EvaluableExpression it was compiled from.
+*/
+type CompiledExpression struct {
+
+	/*
+		Same as EvaluableExpression.ChecksTypes, copied from it at compile time.
+	*/
+	ChecksTypes bool
+
+	instructions []vmInstruction
+	constants    []interface{}
+
+	// the names of the functions called, for EvaluationOptions.AllowedFunctions, and whether they could be found.
+	functionNames      []string
+	functionNamesError error
+
+	maxStack     int
+	stacks       *sync.Pool
+
+	// same as EvaluableExpression.typed.
+	typed bool
+}
+
+type vmOpcode uint8
+
+const (
+	vmConstant vmOpcode = iota // pushes constants[arg]
+	vmLoad                     // pushes the result of a stage without operands, such as a parameter
+	vmApply                    // pops the operands of a stage, type checks them and runs its operator
+
+	// short circuits. The "jump" ones leave the left value as the result and continue at arg,
+	// the "hold" ones push the short circuit placeholder as the right value and continue at arg, which applies the stage.
+	vmJumpIfFalse
+	vmJumpIfTrue
+	vmJumpIfNotNil
+	vmHoldIfFalse
+	vmHoldIfNotNil
+
+	vmQuantify // pops a list, and replaces it with the result of a quantifier over it
+
+	// typed fast paths, which fall back to vmApply when the operands aren't of the expected types.
+	vmAdd
+	vmSubtract
+	vmMultiply
+	vmDivide
+	vmModulus
+	vmExponent
+	vmGreater
+	vmLess
+	vmGreaterEqual
+	vmLessEqual
+	vmEqual
+	vmNotEqual
+	vmAnd
+	vmOr
+	vmNegate
+	vmInvert
+)
+
+type vmInstruction struct {
+	opcode vmOpcode
+	arg    int
+
+	// the stage this instruction was compiled from, for vmLoad, vmApply and the fallback of fast paths.
+	stage              *evaluationStage
+	hasLeft, hasRight bool
+}
+
+const vmSmallStack = 8
+
+type vmKind uint8
+
+const (
+	vmInterface vmKind = iota
+	vmFloat
+	vmBool
+)
+
+/*
+	A value on the stack. Numbers and bools are kept unboxed; [value] holds everything else,
+	and for numbers and bools that came from outside the machine, their already boxed form, so it needn't be boxed again.
+*/
+type vmValue struct {
+	kind    vmKind
+	number  float64
+	boolean bool
+	value   interface{}
+}
+
+func vmFromInterface(value interface{}) vmValue {
+
+	switch value.(type) {
+	case float64:
+		return vmValue{kind: vmFloat, number: value.(float64), value: value}
+	case bool:
+		return vmValue{kind: vmBool, boolean: value.(bool), value: value}
+	}
+	return vmValue{value: value}
+}
+
+func (this vmValue) box() interface{} {
+
+	if this.value != nil {
+		return this.value
+	}
+
+	switch this.kind {
+	case vmFloat:
+		return this.number
+	case vmBool:
+		return boolIface(this.boolean)
+	}
+	return nil
+}
+
+func (this vmValue) isFalse() bool {
+	return this.kind == vmBool && !this.boolean
+}
+
+func (this vmValue) isTrue() bool {
+	return this.kind == vmBool && this.boolean
+}
+
+func (this vmValue) isNil() bool {
+	return this.kind == vmInterface && this.value == nil
+}
+
+var vmFastOpcodes = map[OperatorSymbol]vmOpcode{
+	PLUS:     vmAdd,
+	MINUS:    vmSubtract,
+	MULTIPLY: vmMultiply,
+	DIVIDE:   vmDivide,
+	MODULUS:  vmModulus,
+	EXPONENT: vmExponent,
+	GT:       vmGreater,
+	LT:       vmLess,
+	GTE:      vmGreaterEqual,
+	LTE:      vmLessEqual,
+	EQ:       vmEqual,
+	NEQ:      vmNotEqual,
+	AND:      vmAnd,
+	OR:       vmOr,
+	NEGATE:   vmNegate,
+	INVERT:   vmInvert,
+}
+
+/*
+	Compiles this expression for the stack machine. The result can be evaluated concurrently.
+*/
+func (this EvaluableExpression) Compile() (*CompiledExpression, error) {
+
+	var err error
+
+	ret := &CompiledExpression{ChecksTypes: this.ChecksTypes, typed: this.typed}
+	ret.functionNames, ret.functionNamesError = this.functionNames()
+
+	if this.evaluationStages != nil {
+		_, err = ret.compileStage(this.evaluationStages, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	maxStack := ret.maxStack
+	ret.stacks = &sync.Pool{
+		New: func() interface{} {
+			stack := make([]vmValue, 0, maxStack)
+			return &stack
+		},
+	}
+	return ret, nil
+}
+
+func (this *CompiledExpression) emit(instruction vmInstruction) int {
+	this.instructions = append(this.instructions, instruction)
+	return len(this.instructions) - 1
+}
+
+/*
+	Appends the instructions for [stage], which leave exactly one value on the stack, at [depth] values deep.
+	Returns the resulting depth.
+*/
+func (this *CompiledExpression) compileStage(stage *evaluationStage, depth int) (int, error) {
+
+	hasLeft := stage.leftStage != nil
+	hasRight := stage.rightStage != nil
+	checked := stage.leftTypeCheck != nil || stage.rightTypeCheck != nil || stage.typeCheck != nil
+
+	// leaves.
+	if !hasLeft && !hasRight && !checked {
+
+		if stage.symbol == LITERAL {
+			value, err := stage.operator(nil, nil, nil)
+			if err != nil {
+				return 0, err
+			}
+			this.constants = append(this.constants, value)
+			this.emit(vmInstruction{opcode: vmConstant, arg: len(this.constants) - 1})
+		} else {
+			this.emit(vmInstruction{opcode: vmLoad, stage: stage})
+		}
+		return this.grow(depth + 1), nil
+	}
+
+	// the condition of a quantifier isn't an operand; it's evaluated for every item of the list.
+	if stage.isQuantifier() {
+
+		_, err := this.compileStage(stage.leftStage, depth)
+		if err != nil {
+			return 0, err
+		}
+
+		this.emit(vmInstruction{opcode: vmQuantify, stage: stage, hasLeft: true})
+		return depth + 1, nil
+	}
+
+	// parentheses only pass on their contents.
+	if stage.symbol == NOOP && !hasLeft && hasRight && !checked {
+		return this.compileStage(stage.rightStage, depth)
+	}
+
+	var err error
+	var shortCircuit int
+
+	shortCircuit = -1
+	operandDepth := depth
+
+	if hasLeft {
+		operandDepth, err = this.compileStage(stage.leftStage, operandDepth)
+		if err != nil {
+			return 0, err
+		}
+
+		if stage.isShortCircuitable() {
+			switch stage.symbol {
+			case AND:
+				shortCircuit = this.emit(vmInstruction{opcode: vmJumpIfFalse})
+			case OR:
+				shortCircuit = this.emit(vmInstruction{opcode: vmJumpIfTrue})
+			case COALESCE:
+				shortCircuit = this.emit(vmInstruction{opcode: vmJumpIfNotNil})
+			case TERNARY_TRUE:
+				shortCircuit = this.emit(vmInstruction{opcode: vmHoldIfFalse})
+			case TERNARY_FALSE:
+				shortCircuit = this.emit(vmInstruction{opcode: vmHoldIfNotNil})
+			}
+		}
+	}
+
+	if hasRight || (shortCircuit >= 0 && (stage.symbol == TERNARY_TRUE || stage.symbol == TERNARY_FALSE)) {
+		// the placeholder of a "hold" takes the place of the right value, so it's always there.
+		hasRight = true
+	}
+
+	if stage.rightStage != nil {
+		operandDepth, err = this.compileStage(stage.rightStage, operandDepth)
+		if err != nil {
+			return 0, err
+		}
+	} else if hasRight {
+		// a held placeholder without a right stage; push nil when it isn't held, like the tree evaluator's unset right value.
+		this.constants = append(this.constants, nil)
+		this.emit(vmInstruction{opcode: vmConstant, arg: len(this.constants) - 1})
+		operandDepth = this.grow(operandDepth + 1)
+	}
+
+	instruction := vmInstruction{opcode: vmApply, stage: stage, hasLeft: hasLeft, hasRight: hasRight}
+
+	opcode, fast := vmFastOpcodes[stage.symbol]
+	if fast {
+		instruction.opcode = opcode
+	}
+
+	apply := this.emit(instruction)
+
+	if shortCircuit >= 0 {
+		switch this.instructions[shortCircuit].opcode {
+		case vmHoldIfFalse, vmHoldIfNotNil:
+			this.instructions[shortCircuit].arg = apply
+		default:
+			this.instructions[shortCircuit].arg = apply + 1
+		}
+	}
+
+	return depth + 1, nil
+}
+
+func (this *CompiledExpression) grow(depth int) int {
+
+	if depth > this.maxStack {
+		this.maxStack = depth
+	}
+	return depth
+}
+
+/*
+	Same as `Eval`, but automatically wraps a map of parameters into a `govalute.Parameters` structure.
+*/
+func (this *CompiledExpression) Evaluate(parameters map[string]interface{}) (interface{}, error) {
+
+	if parameters == nil {
+		return this.Eval(nil)
+	}
+	return this.Eval(MapParameters(parameters))
+}
+
+/*
+	Runs the compiled expression using the given [parameters], exactly like EvaluableExpression.Eval.
+*/
+func (this *CompiledExpression) Eval(parameters Parameters) (interface{}, error) {
+
+	return this.eval(parameters, nil)
+}
+
+/*
+	Same as `EvalWithOptions`, but automatically wraps a map of parameters into a `govalute.Parameters` structure.
+*/
+func (this *CompiledExpression) EvaluateWithOptions(parameters map[string]interface{}, options EvaluationOptions) (interface{}, error) {
+
+	if parameters == nil {
+		return this.EvalWithOptions(nil, options)
+	}
+	return this.EvalWithOptions(MapParameters(parameters), options)
+}
+
+/*
+	Same as `Eval`, within the limits of [options]. Steps are counted as they are by EvaluableExpression.EvalWithOptions.
+*/
+func (this *CompiledExpression) EvalWithOptions(parameters Parameters, options EvaluationOptions) (interface{}, error) {
+
+	if options.AllowedFunctions != nil && this.functionNamesError != nil {
+		return nil, &LimitError{Limit: LimitFunction, Message: this.functionNamesError.Error()}
+	}
+
+	sandbox, err := newSandbox(options, this.functionNames)
+	if err != nil {
+		return nil, err
+	}
+	return this.eval(parameters, sandbox)
+}
+
+func (this *CompiledExpression) eval(parameters Parameters, sandbox *sandbox) (interface{}, error) {
+
+	if len(this.instructions) == 0 {
+		return nil, nil
+	}
+
+	if parameters != nil {
+		parameters = &sanitizedParameters{parameters, this.typed}
+	}
+
+	// most expressions are shallow enough for their stack to live on the Go stack.
+	if this.maxStack <= vmSmallStack {
+		var buffer [vmSmallStack]vmValue
+		return this.run(buffer[:0], parameters, sandbox)
+	}
+
+	stack := this.stacks.Get().(*[]vmValue)
+	result, err := this.run(*stack, parameters, sandbox)
+	this.stacks.Put(stack)
+	return result, err
+}
+
+/*
+	Runs the instructions. [sandbox] is nil unless evaluating with EvaluationOptions,
+	in which case every stage goes through it, rather than the fast paths, so that it's counted.
+*/
+func (this *CompiledExpression) run(stack []vmValue, parameters Parameters, sandbox *sandbox) (interface{}, error) {
+
+	var instruction *vmInstruction
+	var left, right vmValue
+	var top int
+
+	for pc := 0; pc < len(this.instructions); pc++ {
+
+		instruction = &this.instructions[pc]
+		top = len(stack) - 1
+
+		switch instruction.opcode {
+
+		case vmConstant:
+			stack = append(stack, vmFromInterface(this.constants[instruction.arg]))
+			continue
+
+		case vmLoad:
+			var value interface{}
+			var err error
+
+			if sandbox != nil {
+				value, err = sandbox.apply(instruction.stage, nil, nil, parameters)
+			} else {
+				value, err = instruction.stage.operator(nil, nil, parameters)
+			}
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, vmFromInterface(value))
+			continue
+
+		case vmJumpIfFalse:
+			if stack[top].isFalse() {
+				pc = instruction.arg - 1
+			}
+			continue
+		case vmJumpIfTrue:
+			if stack[top].isTrue() {
+				pc = instruction.arg - 1
+			}
+			continue
+		case vmJumpIfNotNil:
+			if !stack[top].isNil() {
+				pc = instruction.arg - 1
+			}
+			continue
+		case vmHoldIfFalse:
+			if stack[top].isFalse() {
+				stack = append(stack, vmValue{value: shortCircuitHolder})
+				pc = instruction.arg - 1
+			}
+			continue
+		case vmHoldIfNotNil:
+			if !stack[top].isNil() {
+				stack = append(stack, vmValue{value: shortCircuitHolder})
+				pc = instruction.arg - 1
+			}
+			continue
+
+		case vmQuantify:
+			evaluator := EvaluableExpression{ChecksTypes: this.ChecksTypes}
+
+			value, err := evaluator.evaluateQuantifier(instruction.stage, stack[top].box(), parameters, sandbox)
+			if err != nil {
+				return nil, err
+			}
+			stack[top] = vmFromInterface(value)
+			continue
+		}
+
+		// fast paths on unboxed operands.
+		if sandbox == nil && instruction.hasRight && instruction.hasLeft && top >= 1 {
+
+			left = stack[top-1]
+			right = stack[top]
+
+			if left.kind == vmFloat && right.kind == vmFloat {
+
+				done := true
+				left.value = nil
+
+				switch instruction.opcode {
+				case vmAdd:
+					left.number += right.number
+				case vmSubtract:
+					left.number -= right.number
+				case vmMultiply:
+					left.number *= right.number
+				case vmDivide:
+					left.number /= right.number
+				case vmModulus:
+					left.number = math.Mod(left.number, right.number)
+				case vmExponent:
+					left.number = math.Pow(left.number, right.number)
+				case vmGreater:
+					left = vmValue{kind: vmBool, boolean: left.number > right.number}
+				case vmLess:
+					left = vmValue{kind: vmBool, boolean: left.number < right.number}
+				case vmGreaterEqual:
+					left = vmValue{kind: vmBool, boolean: left.number >= right.number}
+				case vmLessEqual:
+					left = vmValue{kind: vmBool, boolean: left.number <= right.number}
+				case vmEqual:
+					left = vmValue{kind: vmBool, boolean: left.number == right.number}
+				case vmNotEqual:
+					left = vmValue{kind: vmBool, boolean: left.number != right.number}
+				default:
+					done = false
+				}
+
+				if done {
+					stack[top-1] = left
+					stack = stack[:top]
+					continue
+				}
+			}
+
+			if left.kind == vmBool && right.kind == vmBool {
+
+				done := true
+				left.value = nil
+
+				switch instruction.opcode {
+				case vmAnd:
+					left.boolean = left.boolean && right.boolean
+				case vmOr:
+					left.boolean = left.boolean || right.boolean
+				case vmEqual:
+					left.boolean = left.boolean == right.boolean
+				case vmNotEqual:
+					left.boolean = left.boolean != right.boolean
+				default:
+					done = false
+				}
+
+				if done {
+					stack[top-1] = left
+					stack = stack[:top]
+					continue
+				}
+			}
+		}
+
+		if sandbox == nil && instruction.hasRight && !instruction.hasLeft && top >= 0 {
+
+			right = stack[top]
+			if instruction.opcode == vmNegate && right.kind == vmFloat {
+				stack[top] = vmValue{kind: vmFloat, number: -right.number}
+				continue
+			}
+			if instruction.opcode == vmInvert && right.kind == vmBool {
+				stack[top] = vmValue{kind: vmBool, boolean: !right.boolean}
+				continue
+			}
+		}
+
+		// everything else goes through the stage, as in the tree evaluator.
+		var leftValue, rightValue interface{}
+
+		if instruction.hasRight {
+			rightValue = stack[len(stack)-1].box()
+			stack = stack[:len(stack)-1]
+		}
+		if instruction.hasLeft {
+			leftValue = stack[len(stack)-1].box()
+			stack = stack[:len(stack)-1]
+		}
+
+		value, err := this.apply(instruction.stage, leftValue, rightValue, parameters, sandbox)
+		if err != nil {
+			return nil, err
+		}
+		stack = append(stack, vmFromInterface(value))
+	}
+
+	return stack[len(stack)-1].box(), nil
+}
+
+func (this *CompiledExpression) apply(stage *evaluationStage, left interface{}, right interface{}, parameters Parameters, sandbox *sandbox) (interface{}, error) {
+
+	var err error
+
+	left, right = timeOperands(stage, left, right)
+
+	if this.ChecksTypes {
+		if stage.typeCheck == nil {
+
+			err = typeCheck(stage.leftTypeCheck, left, stage.symbol, stage.typeErrorFormat)
+			if err != nil {
+				return nil, err
+			}
+
+			err = typeCheck(stage.rightTypeCheck, right, stage.symbol, stage.typeErrorFormat)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			if !stage.typeCheck(left, right) {
+				errorMsg := fmt.Sprintf(stage.typeErrorFormat, left, stage.symbol.String())
+				return nil, errors.New(errorMsg)
+			}
+		}
+	}
+
+	if sandbox != nil {
+		return sandbox.apply(stage, left, right, parameters)
+	}
+	return stage.operator(left, right, parameters)
+}
@@ -0,0 +1,94 @@
+package govaluate
+
+import (
+	"sync"
+	"testing"
+)
+
+/*
+	Short circuits, ternaries and deep expressions, compared against the tree evaluator.
+	Every case of the evaluation tests is also run compiled, through checkCompiledEvaluation.
+*/
+func TestCompiledEvaluation(test *testing.T) {
+
+	parameters := map[string]interface{}{
+		"t":     true,
+		"f":     false,
+		"n":     nil,
+		"one":   1,
+		"two":   2.0,
+		"s":     "str",
+		"slice": []interface{}{1.0, "a"},
+	}
+
+	expressions := []string{
+		"t ? one : two",
+		"f ? one : two",
+		"f ? one",
+		"t ? one",
+		"n ?? s",
+		"s ?? one",
+		"n ?? n ?? two",
+		"f && s",
+		"t || s",
+		"t && s",
+		"f || (one + 'x')",
+		"-(one - two) * -two",
+		"!(t && f) == !f",
+		"one IN slice",
+		"'a' IN (1, 2, 'a')",
+		"((((((((((one + 1) + 1) + 1) + 1) + 1) + 1) + 1) + 1) + 1) + 1) * (((((((((two - 1) - 1) - 1) - 1) - 1) - 1) - 1) - 1) - 1)",
+		"one + one + one + one + one + one + one + one + one + one > two ? s + one : s + two",
+		"s > 'a' && s < 'z' && s =~ '^st'",
+		"s + one > 3",
+		"-s",
+		"t ? f ? one : two : s",
+	}
+
+	for _, expressionString := range expressions {
+
+		expression, err := NewEvaluableExpression(expressionString)
+		if err != nil {
+			test.Errorf("Unable to parse '%s': %v", expressionString, err)
+			continue
+		}
+
+		result, err := expression.Evaluate(parameters)
+		checkCompiledEvaluation(expression, parameters, result, err, expressionString, test)
+
+		expression.ChecksTypes = false
+		if err == nil {
+			result, err = expression.Evaluate(parameters)
+			checkCompiledEvaluation(expression, parameters, result, err, expressionString+" (unchecked)", test)
+		}
+	}
+}
+
+func TestCompiledConcurrency(test *testing.T) {
+
+	// nested to the right, so that the stack is too deep for the small stack buffer.
+	expression, _ := NewEvaluableExpression("(x * 2 + 1) * (1 + (1 + (1 + (1 + (1 + (1 + (1 + (1 + (1 + x)))))))))")
+	compiled, err := expression.Compile()
+	if err != nil {
+		test.Fatal(err)
+	}
+	if compiled.maxStack <= vmSmallStack {
+		test.Fatalf("Expected a stack deeper than %d, got %d", vmSmallStack, compiled.maxStack)
+	}
+
+	var wait sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wait.Add(1)
+		go func(x float64) {
+			defer wait.Done()
+			for j := 0; j < 100; j++ {
+				result, err := compiled.Evaluate(map[string]interface{}{"x": x})
+				if err != nil || result != (x*2+1)*(x+9) {
+					test.Errorf("Unexpected result %v, %v for %v", result, err, x)
+					return
+				}
+			}
+		}(float64(i))
+	}
+	wait.Wait()
+}
@@ -35,23 +35,30 @@ type EvaluableExpression struct {
 	tokens           []ExpressionToken
 	evaluationStages *evaluationStage
 	inputExpression  string
+	resultType       Type
+
+	// whether the expression was parsed with a schema, and so uses times and durations as numbers of seconds.
+	typed bool
 }
 
 /*
 	Parses a new EvaluableExpression from the given [expression] string.
 	Returns an error if the given expression has invalid syntax.
+
+	If a [schema] is given, every parameter used by the expression must be declared in it,
+	and a *TypeError is returned if any operator can't be used with the types of its operands.
 */
-func NewEvaluableExpression(expression string) (*EvaluableExpression, error) {
+func NewEvaluableExpression(expression string, schema ...TypeSchema) (*EvaluableExpression, error) {
 
 	functions := make(map[string]ExpressionFunction)
-	return NewEvaluableExpressionWithFunctions(expression, functions)
+	return NewEvaluableExpressionWithFunctions(expression, functions, schema...)
 }
 
 /*
 	Similar to [NewEvaluableExpression], except that instead of a string, an already-tokenized expression is given.
 	This is useful in cases where you may be generating an expression automatically, or using some other parser (e.g., to parse from a query language)
 */
-func NewEvaluableExpressionFromTokens(tokens []ExpressionToken) (*EvaluableExpression, error) {
+func NewEvaluableExpressionFromTokens(tokens []ExpressionToken, schema ...TypeSchema) (*EvaluableExpression, error) {
 
 	var ret *EvaluableExpression
 	var err error
@@ -79,6 +86,11 @@ func NewEvaluableExpressionFromTokens(tokens []ExpressionToken) (*EvaluableExpre
 		return nil, err
 	}
 
+	err = ret.checkTypes(schema)
+	if err != nil {
+		return nil, err
+	}
+
 	ret.ChecksTypes = true
 	return ret, nil
 }
@@ -87,7 +99,7 @@ func NewEvaluableExpressionFromTokens(tokens []ExpressionToken) (*EvaluableExpre
 	Similar to [NewEvaluableExpression], except enables the use of user-defined functions.
 	Functions passed into this will be available to the expression.
 */
-func NewEvaluableExpressionWithFunctions(expression string, functions map[string]ExpressionFunction) (*EvaluableExpression, error) {
+func NewEvaluableExpressionWithFunctions(expression string, functions map[string]ExpressionFunction, schema ...TypeSchema) (*EvaluableExpression, error) {
 
 	var ret *EvaluableExpression
 	var err error
@@ -98,33 +110,66 @@ func NewEvaluableExpressionWithFunctions(expression string, functions map[string
 
 	ret.tokens, err = parseTokens(expression, functions)
 	if err != nil {
-		return nil, err
+		return nil, withExpression(err, expression)
 	}
 
 	err = checkBalance(ret.tokens)
 	if err != nil {
-		return nil, err
+		return nil, withExpression(err, expression)
 	}
 
 	err = checkExpressionSyntax(ret.tokens)
 	if err != nil {
-		return nil, err
+		return nil, withExpression(err, expression)
 	}
 
 	ret.tokens, err = optimizeTokens(ret.tokens)
 	if err != nil {
-		return nil, err
+		return nil, withExpression(err, expression)
 	}
 
 	ret.evaluationStages, err = planStages(ret.tokens)
 	if err != nil {
-		return nil, err
+		return nil, withExpression(err, expression)
+	}
+
+	err = ret.checkTypes(schema)
+	if err != nil {
+		return nil, withExpression(err, expression)
 	}
 
 	ret.ChecksTypes = true
 	return ret, nil
 }
 
+func (this *EvaluableExpression) checkTypes(schemas []TypeSchema) error {
+
+	var err error
+
+	if len(schemas) == 0 {
+		return nil
+	}
+	this.typed = true
+
+	schema := make(TypeSchema)
+	for _, partial := range schemas {
+		for name, typ := range partial {
+			schema[name] = typ
+		}
+	}
+
+	this.resultType, err = checkStageTypes(this.evaluationStages, this.tokens, schema)
+	return err
+}
+
+/*
+	Returns the type this expression evaluates to, as inferred from the schema it was parsed with.
+	Expressions parsed without a schema, or whose type depends on their parameters, return TypeAny.
+*/
+func (this EvaluableExpression) ResultType() Type {
+	return this.resultType
+}
+
 /*
 	Same as `Eval`, but automatically wraps a map of parameters into a `govalute.Parameters` structure.
 */
@@ -154,23 +199,30 @@ func (this EvaluableExpression) Eval(parameters Parameters) (interface{}, error)
 	}
 
 	if parameters != nil {
-		parameters = &sanitizedParameters{parameters}
+		parameters = &sanitizedParameters{parameters, this.typed}
 	}
-	return this.evaluateStage(this.evaluationStages, parameters)
+	return this.evaluateStage(this.evaluationStages, parameters, nil)
 }
 
-func (this EvaluableExpression) evaluateStage(stage *evaluationStage, parameters Parameters) (interface{}, error) {
+/*
+	Evaluates [stage] and everything under it. [sandbox] is nil unless evaluating with EvaluationOptions.
+*/
+func (this EvaluableExpression) evaluateStage(stage *evaluationStage, parameters Parameters, sandbox *sandbox) (interface{}, error) {
 
 	var left, right interface{}
 	var err error
 
 	if stage.leftStage != nil {
-		left, err = this.evaluateStage(stage.leftStage, parameters)
+		left, err = this.evaluateStage(stage.leftStage, parameters, sandbox)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	if stage.isQuantifier() {
+		return this.evaluateQuantifier(stage, left, parameters, sandbox)
+	}
+
 	if stage.isShortCircuitable() {
 		switch stage.symbol {
 			case AND:
@@ -198,12 +250,14 @@ func (this EvaluableExpression) evaluateStage(stage *evaluationStage, parameters
 	}
 
 	if right != shortCircuitHolder && stage.rightStage != nil {
-		right, err = this.evaluateStage(stage.rightStage, parameters)
+		right, err = this.evaluateStage(stage.rightStage, parameters, sandbox)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	left, right = timeOperands(stage, left, right)
+
 	if this.ChecksTypes {
 		if stage.typeCheck == nil {
 
@@ -225,6 +279,9 @@ func (this EvaluableExpression) evaluateStage(stage *evaluationStage, parameters
 		}
 	}
 
+	if sandbox != nil && stage.symbol != LITERAL && stage.symbol != NOOP {
+		return sandbox.apply(stage, left, right, parameters)
+	}
 	return stage.operator(left, right, parameters)
 }
 
@@ -260,13 +317,50 @@ func (this EvaluableExpression) String() string {
 
 /*
 	Returns an array representing the variables contained in this EvaluableExpression.
+	For accessors, such as "foo.Bar", the parameter that is accessed ("foo") is returned.
+	The bindings of quantifiers, such as "p" in "any(peers, p => p.Port > 1024)", aren't parameters, and aren't returned.
 */
 func (this EvaluableExpression) Vars() []string {
 	var varlist []string
+	var scopes []quantifierScope
+	var depth int
+
 	for _, val := range this.Tokens() {
-		if val.Kind == VARIABLE {
-			varlist = append(varlist, val.Value.(string))
+		switch val.Kind {
+		case CLAUSE:
+			depth++
+		case CLAUSE_CLOSE:
+			depth--
+			for len(scopes) > 0 && scopes[len(scopes)-1].depth > depth {
+				scopes = scopes[:len(scopes)-1]
+			}
+		case LAMBDA:
+			scopes = append(scopes, quantifierScope{val.Value.(string), depth})
+		case VARIABLE:
+			if !isBound(scopes, val.Value.(string)) {
+				varlist = append(varlist, val.Value.(string))
+			}
+		case ACCESSOR:
+			if !isBound(scopes, val.Value.([]string)[0]) {
+				varlist = append(varlist, val.Value.([]string)[0])
+			}
 		}
 	}
 	return varlist
 }
+
+// a binding, and how many parenthesis deep the quantifier's are, which end it.
+type quantifierScope struct {
+	binding string
+	depth   int
+}
+
+func isBound(scopes []quantifierScope, name string) bool {
+
+	for _, scope := range scopes {
+		if scope.binding == name {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strings"
 	"time"
 )
 
@@ -56,6 +57,10 @@ func (this EvaluableExpression) findNextSQLString(stream *tokenStream, transacti
 		ret = fmt.Sprintf("'%s'", token.Value.(*regexp.Regexp).String())
 	case TIME:
 		ret = fmt.Sprintf("'%s'", token.Value.(time.Time).Format(this.QueryDateFormat))
+	case DURATION:
+		ret = fmt.Sprintf("%g", token.Value.(time.Duration).Seconds())
+	case IP, CIDR:
+		ret = fmt.Sprintf("'%v'", token.Value)
 
 	case LOGICALOP:
 		switch logicalSymbols[token.Value.(string)] {
@@ -76,6 +81,9 @@ func (this EvaluableExpression) findNextSQLString(stream *tokenStream, transacti
 	case VARIABLE:
 		ret = fmt.Sprintf("[%s]", token.Value.(string))
 
+	case ACCESSOR:
+		ret = fmt.Sprintf("[%s]", strings.Join(token.Value.([]string), "."))
+
 	case NUMERIC:
 		ret = fmt.Sprintf("%g", token.Value.(float64))
 
@@ -90,6 +98,17 @@ func (this EvaluableExpression) findNextSQLString(stream *tokenStream, transacti
 			ret = "RLIKE"
 		case NREQ:
 			ret = "NOT RLIKE"
+		case IN:
+			// SQL has no networks, so a CIDR would only be compared as a string.
+			if stream.hasNext() {
+				next := stream.next()
+				stream.rewind()
+
+				if next.Kind == CIDR {
+					return "", errors.New("Containment in a network is unsupported in SQL output")
+				}
+			}
+			ret = fmt.Sprintf("%s", token.Value.(string))
 		default:
 			ret = fmt.Sprintf("%s", token.Value.(string))
 		}
@@ -6,4 +6,9 @@ package govaluate
 type ExpressionToken struct {
 	Kind  TokenKind
 	Value interface{}
+
+	// Where the token was read from in the expression, in bytes.
+	// Tokens given to NewEvaluableExpressionFromTokens need not set these.
+	Offset int
+	Length int
 }
@@ -45,6 +45,13 @@ const (
 
 	FUNCTIONAL
 	SEPARATE
+
+	ARRAY
+	MAP
+	INDEX
+	ANY
+	ALL
+	COUNT
 )
 
 type operatorPrecedence int
@@ -70,6 +77,18 @@ func findOperatorPrecedenceForSymbol(symbol OperatorSymbol) operatorPrecedence {
 
 	switch symbol {
 	case NOOP:
+		fallthrough
+	case ARRAY:
+		fallthrough
+	case MAP:
+		fallthrough
+	case INDEX:
+		fallthrough
+	case ANY:
+		fallthrough
+	case ALL:
+		fallthrough
+	case COUNT:
 		return noopPrecedence
 	case VALUE:
 		return valuePrecedence
@@ -217,6 +236,12 @@ var separatorSymbols = map[string]OperatorSymbol{
 	",": SEPARATE,
 }
 
+var quantifierSymbols = map[string]OperatorSymbol{
+	"any":   ANY,
+	"all":   ALL,
+	"count": COUNT,
+}
+
 /*
 	Returns true if this operator is contained by the given array of candidate symbols.
 	False otherwise.
@@ -301,6 +326,18 @@ func (this OperatorSymbol) String() string {
 		return ":"
 	case COALESCE:
 		return "??"
+	case ARRAY:
+		return "[]"
+	case MAP:
+		return "{}"
+	case INDEX:
+		return "[]"
+	case ANY:
+		return "any"
+	case ALL:
+		return "all"
+	case COUNT:
+		return "count"
 	}
 	return ""
 }
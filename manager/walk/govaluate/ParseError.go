@@ -0,0 +1,100 @@
+package govaluate
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+	Returned when an expression can't be parsed, pointing out where in the expression the problem is.
+*/
+type ParseError struct {
+
+	// The expression being parsed. Empty when it was given as tokens.
+	Expression string
+
+	// Where the offending text is in the expression, in bytes.
+	// A zero length points between two characters, such as at the end of an expression which ended too early.
+	Offset int
+	Length int
+
+	// The kinds of token which would have been valid at this position, if known.
+	Expected []TokenKind
+
+	Message string
+}
+
+func (this *ParseError) Error() string {
+	return fmt.Sprintf("%s (at offset %d)", this.Message, this.Offset)
+}
+
+/*
+	Returns the line of the expression containing the error, with carets underneath the offending text, like:
+
+		foo > > 10
+		      ^
+*/
+func (this *ParseError) Snippet() string {
+	return annotateSnippet(this.Expression, this.Offset, this.Length)
+}
+
+func newParseError(token ExpressionToken, expected []TokenKind, message string) *ParseError {
+
+	return &ParseError{
+		Offset:   token.Offset,
+		Length:   token.Length,
+		Expected: expected,
+		Message:  message,
+	}
+}
+
+/*
+	Returns [err] with the given [expression] recorded in it, if it points out a position in the expression.
+*/
+func withExpression(err error, expression string) error {
+
+	switch err.(type) {
+	case *ParseError:
+		err.(*ParseError).Expression = expression
+	case *TypeError:
+		err.(*TypeError).Expression = expression
+	}
+	return err
+}
+
+func annotateSnippet(expression string, offset int, length int) string {
+
+	if len(expression) == 0 || offset < 0 || offset > len(expression) {
+		return ""
+	}
+
+	start := strings.LastIndexByte(expression[:offset], '\n') + 1
+	end := strings.IndexByte(expression[offset:], '\n')
+	if end < 0 {
+		end = len(expression)
+	} else {
+		end += offset
+	}
+
+	if offset+length > end {
+		length = end - offset
+	}
+
+	// pad with the same whitespace as the line, so that tabs line up too.
+	var padding strings.Builder
+	for _, character := range expression[start:offset] {
+		if character == '\t' {
+			padding.WriteRune('\t')
+		} else {
+			padding.WriteRune(' ')
+		}
+	}
+
+	width := utf8.RuneCountInString(expression[offset : offset+length])
+	if width < 1 {
+		width = 1
+	}
+
+	return expression[start:end] + "\n" + padding.String() + "^" + strings.Repeat("~", width-1)
+}
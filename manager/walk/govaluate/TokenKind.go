@@ -15,6 +15,7 @@ const (
 	PATTERN
 	TIME
 	VARIABLE
+	ACCESSOR
 	FUNCTION
 	SEPARATOR
 
@@ -26,6 +27,19 @@ const (
 	CLAUSE_CLOSE
 
 	TERNARY
+
+	ARRAY_OPEN
+	INDEX_OPEN
+	BRACKET_CLOSE
+	MAP_OPEN
+	MAP_CLOSE
+	QUANTIFIER
+	LAMBDA
+
+	DURATION
+	IP
+	CIDR
+	MEMBER
 )
 
 /*
@@ -50,6 +64,8 @@ func (kind TokenKind) String() string {
 		return "TIME"
 	case VARIABLE:
 		return "VARIABLE"
+	case ACCESSOR:
+		return "ACCESSOR"
 	case FUNCTION:
 		return "FUNCTION"
 	case SEPARATOR:
@@ -66,6 +82,28 @@ func (kind TokenKind) String() string {
 		return "CLAUSE_CLOSE"
 	case TERNARY:
 		return "TERNARY"
+	case ARRAY_OPEN:
+		return "ARRAY_OPEN"
+	case INDEX_OPEN:
+		return "INDEX_OPEN"
+	case BRACKET_CLOSE:
+		return "BRACKET_CLOSE"
+	case MAP_OPEN:
+		return "MAP_OPEN"
+	case MAP_CLOSE:
+		return "MAP_CLOSE"
+	case QUANTIFIER:
+		return "QUANTIFIER"
+	case LAMBDA:
+		return "LAMBDA"
+	case DURATION:
+		return "DURATION"
+	case IP:
+		return "IP"
+	case CIDR:
+		return "CIDR"
+	case MEMBER:
+		return "MEMBER"
 	}
 
 	return "UNKNOWN"
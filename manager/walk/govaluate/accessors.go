@@ -0,0 +1,290 @@
+package govaluate
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+type accessorStepKind int
+
+const (
+	accessorMissing accessorStepKind = iota
+	accessorMapKey
+	accessorField
+	accessorMethod
+)
+
+/*
+	How to take one member from a value of a given type. Finding it requires walking the type by name,
+	so the result is cached, and later evaluations with the same types just follow the indexes.
+*/
+type accessorStep struct {
+	kind  accessorStepKind
+	index []int // for fields, the index path through embedded structs; for methods, the method index.
+
+	// when the method returns (value, error) instead of just a value.
+	returnsError bool
+
+	// when the method has a pointer receiver, so it is called on the address of the value, or of a copy of it.
+	pointerReceiver bool
+}
+
+type accessorCacheKey struct {
+	depth int
+	typ   reflect.Type
+}
+
+/*
+	The reflection path of one accessor in one expression. Parameters may hold values of different types
+	from one evaluation to the next (or concurrently), so every step is cached by the type it was taken from.
+*/
+type accessorCache struct {
+	mutex sync.RWMutex
+	steps map[accessorCacheKey]accessorStep
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func (this *accessorCache) step(depth int, typ reflect.Type, member string) accessorStep {
+
+	key := accessorCacheKey{depth, typ}
+
+	this.mutex.RLock()
+	step, found := this.steps[key]
+	this.mutex.RUnlock()
+
+	if found {
+		return step
+	}
+
+	step = findAccessorStep(typ, member)
+
+	this.mutex.Lock()
+	this.steps[key] = step
+	this.mutex.Unlock()
+	return step
+}
+
+/*
+	Looks up [member] on [typ]: a key if it's a map with string keys, otherwise an exported zero-argument method
+	(of the type, including those with a pointer receiver, or, through pointers, of the struct), otherwise an exported field of the struct.
+*/
+func findAccessorStep(typ reflect.Type, member string) accessorStep {
+
+	if typ.Kind() == reflect.Map {
+		if typ.Key().Kind() == reflect.String {
+			return accessorStep{kind: accessorMapKey}
+		}
+		return accessorStep{kind: accessorMissing}
+	}
+
+	step, found := findMethodStep(typ, member)
+	if !found && typ.Kind() != reflect.Ptr && typ.Kind() != reflect.Interface {
+		step, found = findMethodStep(reflect.PtrTo(typ), member)
+		step.pointerReceiver = true
+	}
+	if found {
+		return step
+	}
+
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() == reflect.Struct {
+		field, found := typ.FieldByName(member)
+		if found && field.PkgPath == "" {
+			return accessorStep{kind: accessorField, index: field.Index}
+		}
+	}
+
+	return accessorStep{kind: accessorMissing}
+}
+
+func findMethodStep(typ reflect.Type, member string) (accessorStep, bool) {
+
+	method, found := typ.MethodByName(member)
+	if !found || method.PkgPath != "" {
+		return accessorStep{}, false
+	}
+
+	// the receiver is the first input.
+	methodType := method.Type
+	if methodType.NumIn() == 1 {
+		if methodType.NumOut() == 1 {
+			return accessorStep{kind: accessorMethod, index: []int{method.Index}}, true
+		}
+		if methodType.NumOut() == 2 && methodType.Out(1) == errorType {
+			return accessorStep{kind: accessorMethod, index: []int{method.Index}, returnsError: true}, true
+		}
+	}
+	return accessorStep{}, false
+}
+
+func makeAccessorStage(pair []string) evaluationOperator {
+
+	cache := &accessorCache{steps: make(map[accessorCacheKey]accessorStep)}
+
+	return func(left interface{}, right interface{}, parameters Parameters) (interface{}, error) {
+
+		parameter, err := parameters.Get(pair[0])
+		if err != nil {
+			return nil, err
+		}
+
+		value := reflect.ValueOf(parameter)
+
+		for depth, member := range pair[1:] {
+
+			path := strings.Join(pair[:depth+1], ".")
+
+			for value.Kind() == reflect.Interface && !value.IsNil() {
+				value = value.Elem()
+			}
+			if !value.IsValid() || isNilValue(value) {
+				errorMsg := fmt.Sprintf("Unable to access '%s' of '%s', it is nil", member, path)
+				return nil, errors.New(errorMsg)
+			}
+
+			value, err = accessMember(value, cache.step(depth, value.Type(), member), member, path, parameters)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return plainValue(value, convertsTimes(parameters)), nil
+	}
+}
+
+/*
+	Takes [member] from [value], which is neither nil nor an interface, following the [step] found for its type.
+	The [path] names the value in errors.
+*/
+func accessMember(value reflect.Value, step accessorStep, member string, path string, parameters Parameters) (reflect.Value, error) {
+
+	switch step.kind {
+
+	case accessorMapKey:
+		key := reflect.ValueOf(member).Convert(value.Type().Key())
+		found := value.MapIndex(key)
+		if !found.IsValid() {
+			errorMsg := fmt.Sprintf("No key '%s' found in '%s'", member, path)
+			return reflect.Value{}, errors.New(errorMsg)
+		}
+		value = found
+
+	case accessorMethod:
+		err := checkMethod(parameters, member)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if step.pointerReceiver {
+			value = addressOf(value)
+		}
+		results := value.Method(step.index[0]).Call(nil)
+		if step.returnsError && !results[1].IsNil() {
+			errorMsg := fmt.Sprintf("Method '%s' of '%s' failed: %v", member, path, results[1].Interface())
+			return reflect.Value{}, errors.New(errorMsg)
+		}
+		value = results[0]
+
+	case accessorField:
+		for _, index := range step.index {
+			for value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					errorMsg := fmt.Sprintf("Unable to access '%s' of '%s', it is nil", member, path)
+					return reflect.Value{}, errors.New(errorMsg)
+				}
+				value = value.Elem()
+			}
+			value = value.Field(index)
+		}
+
+	default:
+		errorMsg := fmt.Sprintf("No member '%s' found in '%s' of type %s", member, path, value.Type())
+		return reflect.Value{}, errors.New(errorMsg)
+	}
+
+	return value, nil
+}
+
+/*
+	Returns the [value] taken from a parameter as operators can use it.
+	Durations are turned into seconds, and times into unix timestamps only if [convertTimes], as sanitizedParameters does.
+*/
+func plainValue(value reflect.Value, convertTimes bool) interface{} {
+
+	// pointers to plain values, such as optional fields, are read through so that operators can use them.
+	for value.Kind() == reflect.Interface && !value.IsNil() {
+		value = value.Elem()
+	}
+	for value.Kind() == reflect.Ptr && !value.IsNil() && isPlainKind(value.Elem().Kind()) {
+		value = value.Elem()
+	}
+
+	if !value.IsValid() || (value.Kind() == reflect.Interface && value.IsNil()) {
+		return nil
+	}
+
+	if d, ok := value.Interface().(time.Duration); ok {
+		return d.Seconds()
+	}
+	if t, ok := value.Interface().(time.Time); ok && convertTimes {
+		return unixSeconds(t)
+	}
+	if address, ok := normalizedAddress(value.Interface()); ok {
+		return address
+	}
+
+	// like sanitizedParameters, but also for named types such as "type Port uint16", which operators can't otherwise use.
+	switch value.Kind() {
+	case reflect.Bool:
+		return boolIface(value.Bool())
+	case reflect.String:
+		return value.String()
+	case reflect.Float32, reflect.Float64:
+		return value.Float()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint())
+	}
+	return value.Interface()
+}
+
+/*
+	Returns a pointer to [value], or to a copy of it if it isn't addressable, as when it was passed by value or read from a map.
+*/
+func addressOf(value reflect.Value) reflect.Value {
+
+	if value.CanAddr() {
+		return value.Addr()
+	}
+	pointer := reflect.New(value.Type())
+	pointer.Elem().Set(value)
+	return pointer
+}
+
+func isNilValue(value reflect.Value) bool {
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map:
+		return value.IsNil()
+	}
+	return false
+}
+
+func isPlainKind(kind reflect.Kind) bool {
+
+	switch kind {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
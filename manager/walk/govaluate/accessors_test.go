@@ -0,0 +1,375 @@
+package govaluate
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+type accessorPort uint16
+
+type accessorEndpoint struct {
+	Host string
+	Port accessorPort
+}
+
+type accessorPeer struct {
+	Endpoint  accessorEndpoint
+	Keepalive *int
+	Tags      map[string]string
+	Extra     interface{}
+
+	hidden string
+}
+
+func (this accessorPeer) Address() string {
+	return this.Endpoint.Host
+}
+
+func (this *accessorPeer) PointerPort() int {
+	return int(this.Endpoint.Port)
+}
+
+func (this accessorPeer) Failing() (string, error) {
+	return "", errors.New("Lookup failed")
+}
+
+func (this accessorPeer) WithArgument(x int) int {
+	return x
+}
+
+type accessorEmbedding struct {
+	*accessorPeer
+	Name string
+}
+
+func TestAccessorParsing(test *testing.T) {
+
+	testCases := []TokenParsingTest{
+
+		TokenParsingTest{
+
+			Name:  "Single accessor",
+			Input: "foo.Bar",
+			Expected: []ExpressionToken{
+				ExpressionToken{
+					Kind:  ACCESSOR,
+					Value: []string{"foo", "Bar"},
+				},
+			},
+		},
+		TokenParsingTest{
+
+			Name:  "Nested accessor in comparison",
+			Input: "peer.Endpoint.Port > 1024",
+			Expected: []ExpressionToken{
+				ExpressionToken{
+					Kind:  ACCESSOR,
+					Value: []string{"peer", "Endpoint", "Port"},
+				},
+				ExpressionToken{
+					Kind:  COMPARATOR,
+					Value: ">",
+				},
+				ExpressionToken{
+					Kind:  NUMERIC,
+					Value: 1024.0,
+				},
+			},
+		},
+		TokenParsingTest{
+
+			Name:  "Escaped period",
+			Input: "foo\\.Bar.Baz",
+			Expected: []ExpressionToken{
+				ExpressionToken{
+					Kind:  ACCESSOR,
+					Value: []string{"foo.Bar", "Baz"},
+				},
+			},
+		},
+		TokenParsingTest{
+
+			Name:  "Escaped parameter with dots",
+			Input: "[foo.Bar]",
+			Expected: []ExpressionToken{
+				ExpressionToken{
+					Kind:  VARIABLE,
+					Value: "foo.Bar",
+				},
+			},
+		},
+	}
+
+	runTokenParsingTest(testCases, test)
+}
+
+func TestAccessorParsingFailure(test *testing.T) {
+
+	parsingTests := []ParsingFailureTest{
+
+		ParsingFailureTest{
+
+			Name:     "Hanging accessor",
+			Input:    "foo. > 1",
+			Expected: "Empty member name",
+		},
+		ParsingFailureTest{
+
+			Name:     "Doubled period",
+			Input:    "foo..Bar",
+			Expected: "Empty member name",
+		},
+		ParsingFailureTest{
+
+			Name:     "Called accessor",
+			Input:    "foo.Bar()",
+			Expected: "cannot be called",
+		},
+	}
+
+	runParsingFailureTests(parsingTests, test)
+}
+
+func TestAccessorEvaluation(test *testing.T) {
+
+	keepalive := 25
+	peer := &accessorPeer{
+		Endpoint:  accessorEndpoint{"vpn.corp.example", 51820},
+		Keepalive: &keepalive,
+		Tags:      map[string]string{"site": "berlin"},
+		Extra:     accessorEndpoint{"extra", 1},
+	}
+
+	evaluationTests := []EvaluationTest{
+
+		EvaluationTest{
+
+			Name:       "Nested field, converted to float64",
+			Input:      "peer.Endpoint.Port > 1024",
+			Parameters: []EvaluationParameter{{"peer", peer}},
+			Expected:   true,
+		},
+		EvaluationTest{
+
+			Name:       "Field through pointer",
+			Input:      "peer.Keepalive + 5",
+			Parameters: []EvaluationParameter{{"peer", peer}},
+			Expected:   30.0,
+		},
+		EvaluationTest{
+
+			Name:       "Value method",
+			Input:      "peer.Address =~ '\\\\.corp\\\\.example$'",
+			Parameters: []EvaluationParameter{{"peer", *peer}},
+			Expected:   true,
+		},
+		EvaluationTest{
+
+			Name:       "Pointer method",
+			Input:      "peer.PointerPort",
+			Parameters: []EvaluationParameter{{"peer", peer}},
+			Expected:   51820.0,
+		},
+		EvaluationTest{
+
+			Name:       "Map key",
+			Input:      "peer.Tags.site + '/' + config.name",
+			Parameters: []EvaluationParameter{{"peer", peer}, {"config", map[string]interface{}{"name": "office"}}},
+			Expected:   "berlin/office",
+		},
+		EvaluationTest{
+
+			Name:       "Field of interface value",
+			Input:      "peer.Extra.Host",
+			Parameters: []EvaluationParameter{{"peer", peer}},
+			Expected:   "extra",
+		},
+		EvaluationTest{
+
+			Name:       "Promoted field and method of embedded pointer",
+			Input:      "item.Endpoint.Host == item.Address && item.Name == 'x'",
+			Parameters: []EvaluationParameter{{"item", accessorEmbedding{peer, "x"}}},
+			Expected:   true,
+		},
+	}
+
+	runEvaluationTests(evaluationTests, test)
+}
+
+/*
+	The methods of the configuration types all have pointer receivers, and must be found on values of them too.
+*/
+func TestAccessorPointerReceivers(test *testing.T) {
+
+	peer := conf.Peer{
+		Endpoint:   conf.Endpoint{Host: "vpn.corp.example", Port: 51820},
+		AllowedIPs: []conf.IPCidr{{IP: net.ParseIP("10.0.0.0"), Cidr: 8}},
+	}
+	peer.PublicKey[0] = 1
+	config := &conf.Config{Peers: []conf.Peer{peer}}
+
+	evaluationTests := []EvaluationTest{
+
+		EvaluationTest{
+
+			Name:       "Pointer method of a field of a pointer",
+			Input:      "!peer.Endpoint.IsEmpty && peer.Endpoint.String == 'vpn.corp.example:51820'",
+			Parameters: []EvaluationParameter{{"peer", &peer}},
+			Expected:   true,
+		},
+		EvaluationTest{
+
+			Name:       "Pointer method of a field of a value",
+			Input:      "peer.Endpoint.IsEmpty",
+			Parameters: []EvaluationParameter{{"peer", peer}},
+			Expected:   false,
+		},
+		EvaluationTest{
+
+			Name:       "Pointer method of a value",
+			Input:      "endpoint.IsEmpty",
+			Parameters: []EvaluationParameter{{"endpoint", conf.Endpoint{}}},
+			Expected:   true,
+		},
+		EvaluationTest{
+
+			Name:       "Pointer methods of an array and its elements",
+			Input:      "peer.PublicKey.String == 'AQAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=' && any(peer.AllowedIPs, a => a.String == '10.0.0.0/8')",
+			Parameters: []EvaluationParameter{{"peer", peer}},
+			Expected:   true,
+		},
+		EvaluationTest{
+
+			Name:       "Pointer methods in quantifiers",
+			Input:      "all(config.Peers, p => !p.PublicKey.IsZero && !p.Endpoint.IsEmpty)",
+			Parameters: []EvaluationParameter{{"config", config}},
+			Expected:   true,
+		},
+	}
+
+	runEvaluationTests(evaluationTests, test)
+}
+
+func TestAccessorEvaluationFailure(test *testing.T) {
+
+	peer := &accessorPeer{Tags: map[string]string{}}
+	parameters := map[string]interface{}{
+		"peer":     peer,
+		"embedded": accessorEmbedding{},
+		"nilPeer":  (*accessorPeer)(nil),
+	}
+
+	evaluationTests := []EvaluationFailureTest{
+
+		EvaluationFailureTest{
+
+			Name:       "Missing field",
+			Input:      "peer.Endpoint.Nope",
+			Parameters: parameters,
+			Expected:   "No member 'Nope' found in 'peer.Endpoint'",
+		},
+		EvaluationFailureTest{
+
+			Name:       "Unexported field",
+			Input:      "peer.hidden",
+			Parameters: parameters,
+			Expected:   "No member 'hidden' found in 'peer'",
+		},
+		EvaluationFailureTest{
+
+			Name:       "Method with arguments",
+			Input:      "peer.WithArgument",
+			Parameters: parameters,
+			Expected:   "No member 'WithArgument'",
+		},
+		EvaluationFailureTest{
+
+			Name:       "Missing map key",
+			Input:      "peer.Tags.site",
+			Parameters: parameters,
+			Expected:   "No key 'site' found in 'peer.Tags'",
+		},
+		EvaluationFailureTest{
+
+			Name:       "Failing method",
+			Input:      "peer.Failing",
+			Parameters: parameters,
+			Expected:   "Method 'Failing' of 'peer' failed: Lookup failed",
+		},
+		EvaluationFailureTest{
+
+			Name:       "Nil pointer",
+			Input:      "nilPeer.Endpoint",
+			Parameters: parameters,
+			Expected:   "Unable to access 'Endpoint' of 'nilPeer', it is nil",
+		},
+		EvaluationFailureTest{
+
+			Name:       "Nil embedded pointer",
+			Input:      "embedded.Endpoint",
+			Parameters: parameters,
+			Expected:   "it is nil",
+		},
+		EvaluationFailureTest{
+
+			Name:       "Missing parameter",
+			Input:      "absent.Field",
+			Parameters: parameters,
+			Expected:   ABSENT_PARAMETER,
+		},
+	}
+
+	runEvaluationFailureTests(evaluationTests, test)
+}
+
+/*
+	The same expression must follow the right path when parameters change type between (and during) evaluations.
+*/
+func TestAccessorCache(test *testing.T) {
+
+	expression, err := NewEvaluableExpression("x.Host")
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	values := []interface{}{
+		accessorEndpoint{Host: "struct"},
+		&accessorEndpoint{Host: "pointer"},
+		map[string]string{"Host": "map"},
+		map[string]interface{}{"Host": "interface map"},
+	}
+
+	var wait sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for _, value := range values {
+				result, err := expression.Evaluate(map[string]interface{}{"x": value})
+				if err != nil {
+					test.Error(err)
+					return
+				}
+				expected := value
+				switch v := value.(type) {
+				case accessorEndpoint:
+					expected = v.Host
+				case *accessorEndpoint:
+					expected = v.Host
+				case map[string]string:
+					expected = v["Host"]
+				case map[string]interface{}:
+					expected = v["Host"]
+				}
+				if result != expected {
+					test.Errorf("Expected %v, got %v", expected, result)
+				}
+			}
+		}()
+	}
+	wait.Wait()
+}
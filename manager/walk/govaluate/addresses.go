@@ -0,0 +1,148 @@
+package govaluate
+
+import (
+	"bytes"
+	"net"
+	"strings"
+)
+
+/*
+	Attempts to parse the [candidate] as an IP, as in "10.0.0.1" or "fe80::1",
+	or as a network, as in "10.0.0.0/8", which is returned as a *net.IPNet.
+*/
+func tryParseAddress(candidate string) (interface{}, bool) {
+
+	if strings.ContainsRune(candidate, '/') {
+
+		_, network, err := net.ParseCIDR(candidate)
+		if err != nil {
+			return nil, false
+		}
+		return normalizeNetwork(network), true
+	}
+
+	ip := net.ParseIP(candidate)
+	if ip == nil {
+		return nil, false
+	}
+	return normalizeIP(ip), true
+}
+
+/*
+	Returns IPs and networks given as parameters in the form their literals have, so that equal ones are deeply equal.
+	Returns false if [value] is neither.
+*/
+func normalizedAddress(value interface{}) (interface{}, bool) {
+
+	switch typed := value.(type) {
+	case net.IP:
+		if typed == nil {
+			return nil, true
+		}
+		return normalizeIP(typed), true
+	case *net.IPNet:
+		if typed == nil {
+			return nil, true
+		}
+		return normalizeNetwork(typed), true
+	case net.IPNet:
+		return normalizeNetwork(&typed), true
+	}
+	return value, false
+}
+
+// IPv4 addresses are kept in four bytes, however they were given.
+func normalizeIP(ip net.IP) net.IP {
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+// networks are kept with the host bits of their IP cleared, and an IP as long as their mask.
+func normalizeNetwork(network *net.IPNet) *net.IPNet {
+
+	ip := network.IP.To16()
+	if len(network.Mask) == net.IPv4len {
+		ip = network.IP.To4()
+	}
+
+	if ip == nil {
+		return network
+	}
+	return &net.IPNet{IP: ip.Mask(network.Mask), Mask: network.Mask}
+}
+
+/*
+	Returns [value] as an IP or a network, parsing it if it's a string, so that functions and 'in' take addresses either way.
+	Returns false if [value] is neither, nor a string of either.
+*/
+func toAddress(value interface{}) (interface{}, bool) {
+
+	switch typed := value.(type) {
+	case string:
+		return tryParseAddress(typed)
+	case net.IP, *net.IPNet:
+		return value, true
+	}
+	return nil, false
+}
+
+func toIP(value interface{}) (net.IP, bool) {
+
+	address, _ := toAddress(value)
+	ip, ok := address.(net.IP)
+	return ip, ok
+}
+
+func toNetwork(value interface{}) (*net.IPNet, bool) {
+
+	address, _ := toAddress(value)
+	network, ok := address.(*net.IPNet)
+	return network, ok
+}
+
+func isIP(value interface{}) bool {
+	switch value.(type) {
+	case net.IP:
+		return true
+	}
+	return false
+}
+
+func isNetwork(value interface{}) bool {
+	switch value.(type) {
+	case *net.IPNet:
+		return true
+	}
+	return false
+}
+
+/*
+	Orders IPs by their bytes, with every IPv4 address before every IPv6 one.
+*/
+func compareIPs(left net.IP, right net.IP) int {
+
+	if len(left) != len(right) {
+		return len(left) - len(right)
+	}
+	return bytes.Compare(left, right)
+}
+
+/*
+	Whether [value], an IP or a network, is within [network]. Addresses of the other family never are.
+*/
+func networkContains(network *net.IPNet, value interface{}) bool {
+
+	switch typed := value.(type) {
+	case net.IP:
+		return len(typed) == len(network.IP) && network.Contains(typed)
+
+	case *net.IPNet:
+		ones, bits := network.Mask.Size()
+		innerOnes, innerBits := typed.Mask.Size()
+		return bits == innerBits && innerOnes >= ones && network.Contains(typed.IP)
+	}
+	return false
+}
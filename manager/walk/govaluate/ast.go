@@ -0,0 +1,809 @@
+package govaluate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+/*
+	A node of the syntax tree of an expression, as returned by [EvaluableExpression.AST].
+
+	String() prints the node as canonical expression text: operators are spaced, strings are single-quoted,
+	and only the parenthesis needed to keep the same meaning are written. Parsing that text again gives the same tree,
+	so trees can be rewritten and turned back into expressions.
+*/
+type Node interface {
+	String() string
+
+	// how loosely the node binds, from grammarPrecedence.
+	precedence() int
+}
+
+/*
+	A constant: a float64, string, bool, time.Time (for date literals), time.Duration (for duration literals),
+	net.IP or *net.IPNet (for IP and network literals), or *regexp.Regexp (for constant patterns).
+*/
+type LiteralNode struct {
+	Value interface{}
+}
+
+/*
+	A parameter. [Path] is its name, followed by the names of the members read from it by an accessor, as in "peer.Endpoint.Port".
+*/
+type VariableNode struct {
+	Path []string
+}
+
+/*
+	A list of values, as in "[1, 2, 3]" or "foo IN (1, 2, 3)", or the arguments of a function taking a single array.
+*/
+type ArrayNode struct {
+	Items []Node
+}
+
+/*
+	"{'a': 1, 'b': 2}", with its entries in the order they are written.
+*/
+type MapNode struct {
+	Entries []MapEntry
+}
+
+type MapEntry struct {
+	Key   string
+	Value Node
+}
+
+/*
+	"collection[index]": an item of an array, by number, or of a map, by key.
+*/
+type IndexNode struct {
+	Collection Node
+	Index      Node
+}
+
+/*
+	"any(list, x => condition)": ANY, ALL or COUNT, of the items of [List] for which [Condition] is true,
+	when the item is given as the parameter [Binding].
+*/
+type QuantifierNode struct {
+	Quantifier OperatorSymbol
+	List       Node
+	Binding    string
+	Condition  Node
+}
+
+/*
+	A prefix: NEGATE, INVERT or BITWISE_NOT.
+*/
+type UnaryNode struct {
+	Operator OperatorSymbol
+	Operand  Node
+}
+
+/*
+	Any operator with two operands, including COALESCE.
+*/
+type BinaryNode struct {
+	Operator OperatorSymbol
+	Left     Node
+	Right    Node
+}
+
+/*
+	"condition ? then : else". [Else] is nil if there is no else part.
+*/
+type TernaryNode struct {
+	Condition Node
+	Then      Node
+	Else      Node
+}
+
+type FunctionNode struct {
+	Name      string
+	Arguments []Node
+}
+
+/*
+	Returns the syntax tree of this expression, as it was written. Unlike evaluation, constants are not folded;
+	use [Fold] or [Simplify] for that.
+*/
+func (this EvaluableExpression) AST() (Node, error) {
+
+	stage, err := planStructure(this.tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	if stage == nil {
+		return nil, errors.New("Cannot build the syntax tree of an empty expression")
+	}
+	return this.buildNode(stage)
+}
+
+func (this EvaluableExpression) buildNode(stage *evaluationStage) (Node, error) {
+
+	var left, right Node
+	var err error
+
+	switch stage.symbol {
+
+	case NOOP:
+		if stage.rightStage == nil {
+			return nil, errors.New("Cannot build the syntax tree of empty parenthesis")
+		}
+		return this.buildNode(stage.rightStage)
+
+	case LITERAL:
+		return &LiteralNode{Value: this.literalValue(stage)}, nil
+
+	case VALUE:
+		token := this.tokens[stage.tokenIndex]
+		if token.Kind == ACCESSOR {
+			return &VariableNode{Path: token.Value.([]string)}, nil
+		}
+		return &VariableNode{Path: []string{token.Value.(string)}}, nil
+
+	case FUNCTIONAL:
+		name, err := this.functionName(stage)
+		if err != nil {
+			return nil, err
+		}
+
+		var arguments []Node
+		if stage.rightStage != nil && stage.rightStage.rightStage != nil {
+
+			arguments, err = this.buildList(stage.rightStage.rightStage)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &FunctionNode{Name: name, Arguments: arguments}, nil
+
+	case SEPARATE:
+		items, err := this.buildList(stage)
+		if err != nil {
+			return nil, err
+		}
+		return &ArrayNode{Items: items}, nil
+
+	case ARRAY:
+		ret := &ArrayNode{Items: []Node{}}
+		if stage.rightStage != nil {
+
+			ret.Items, err = this.buildList(stage.rightStage)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+
+	case MAP:
+		ret := &MapNode{Entries: []MapEntry{}}
+		if stage.rightStage != nil {
+
+			values, err := this.buildList(stage.rightStage)
+			if err != nil {
+				return nil, err
+			}
+
+			for i, key := range stage.keys {
+				ret.Entries = append(ret.Entries, MapEntry{Key: key, Value: values[i]})
+			}
+		}
+		return ret, nil
+
+	case NEGATE, INVERT, BITWISE_NOT:
+		right, err = this.buildNode(stage.rightStage)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryNode{Operator: stage.symbol, Operand: right}, nil
+	}
+
+	left, err = this.buildNode(stage.leftStage)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err = this.buildNode(stage.rightStage)
+	if err != nil {
+		return nil, err
+	}
+
+	switch stage.symbol {
+
+	case INDEX:
+		return &IndexNode{Collection: left, Index: right}, nil
+
+	case ANY, ALL, COUNT:
+		return &QuantifierNode{Quantifier: stage.symbol, List: left, Binding: stage.binding, Condition: right}, nil
+
+	case TERNARY_TRUE:
+		return &TernaryNode{Condition: left, Then: right}, nil
+
+	case TERNARY_FALSE:
+
+		// "a ? b : c" is planned as "(a ? b) : c".
+		if ternary, ok := left.(*TernaryNode); ok && ternary.Else == nil {
+			return &TernaryNode{Condition: ternary.Condition, Then: ternary.Then, Else: right}, nil
+		}
+	}
+	return &BinaryNode{Operator: stage.symbol, Left: left, Right: right}, nil
+}
+
+/*
+	Builds each item of a list of separated values, or the single value given if it isn't a list.
+*/
+func (this EvaluableExpression) buildList(stage *evaluationStage) ([]Node, error) {
+
+	if stage.symbol == SEPARATE {
+
+		// nested lists, as in "(1, (2, 3))", are parenthesized, and so are items of their own.
+		left, err := this.buildList(stage.leftStage)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := this.buildNode(stage.rightStage)
+		if err != nil {
+			return nil, err
+		}
+		return append(left, right), nil
+	}
+
+	item, err := this.buildNode(stage)
+	if err != nil {
+		return nil, err
+	}
+	return []Node{item}, nil
+}
+
+func (this EvaluableExpression) literalValue(stage *evaluationStage) interface{} {
+
+	// date and duration literals are planned as numbers, but most consumers of a tree have times of their own.
+	if stage.tokenIndex >= 0 && stage.tokenIndex < len(this.tokens) {
+		switch this.tokens[stage.tokenIndex].Kind {
+		case TIME, DURATION:
+			return this.tokens[stage.tokenIndex].Value
+		}
+	}
+
+	value, _ := stage.operator(nil, nil, nil)
+	return value
+}
+
+func (this EvaluableExpression) functionName(stage *evaluationStage) (string, error) {
+
+	token := this.tokens[stage.tokenIndex]
+	if len(this.inputExpression) < token.Offset+token.Length || token.Length == 0 {
+		errorMsg := fmt.Sprintf("Cannot find the name of the function at token %d", stage.tokenIndex)
+		return "", errors.New(errorMsg)
+	}
+	return this.inputExpression[token.Offset : token.Offset+token.Length], nil
+}
+
+/*
+	Visits nodes of a syntax tree with [Walk]. If the visitor returned by Visit is non-nil,
+	the children of the node are visited with it, and then Visit is called with nil.
+*/
+type Visitor interface {
+	Visit(node Node) Visitor
+}
+
+/*
+	Traverses the tree from [node] down, depth-first, in the order the nodes are written in the expression.
+*/
+func Walk(visitor Visitor, node Node) {
+
+	if visitor = visitor.Visit(node); visitor == nil {
+		return
+	}
+
+	for _, child := range children(node) {
+		Walk(visitor, child)
+	}
+	visitor.Visit(nil)
+}
+
+/*
+	Returns a copy of the tree from [node] down, where every node has been replaced by the result of [rewrite].
+	Nodes are rewritten from the bottom up, so [rewrite] is given nodes whose children are already rewritten.
+	The given tree is not modified.
+*/
+func Rewrite(node Node, rewrite func(Node) Node) Node {
+
+	switch typed := node.(type) {
+
+	case *LiteralNode:
+		node = &LiteralNode{Value: typed.Value}
+
+	case *VariableNode:
+		node = &VariableNode{Path: append([]string(nil), typed.Path...)}
+
+	case *ArrayNode:
+		node = &ArrayNode{Items: rewriteAll(typed.Items, rewrite)}
+
+	case *MapNode:
+		entries := make([]MapEntry, len(typed.Entries))
+		for i, entry := range typed.Entries {
+			entries[i] = MapEntry{Key: entry.Key, Value: Rewrite(entry.Value, rewrite)}
+		}
+		node = &MapNode{Entries: entries}
+
+	case *IndexNode:
+		node = &IndexNode{Collection: Rewrite(typed.Collection, rewrite), Index: Rewrite(typed.Index, rewrite)}
+
+	case *QuantifierNode:
+		node = &QuantifierNode{
+			Quantifier: typed.Quantifier,
+			List:       Rewrite(typed.List, rewrite),
+			Binding:    typed.Binding,
+			Condition:  Rewrite(typed.Condition, rewrite),
+		}
+
+	case *UnaryNode:
+		node = &UnaryNode{Operator: typed.Operator, Operand: Rewrite(typed.Operand, rewrite)}
+
+	case *BinaryNode:
+		node = &BinaryNode{Operator: typed.Operator, Left: Rewrite(typed.Left, rewrite), Right: Rewrite(typed.Right, rewrite)}
+
+	case *TernaryNode:
+		ternary := &TernaryNode{Condition: Rewrite(typed.Condition, rewrite), Then: Rewrite(typed.Then, rewrite)}
+		if typed.Else != nil {
+			ternary.Else = Rewrite(typed.Else, rewrite)
+		}
+		node = ternary
+
+	case *FunctionNode:
+		node = &FunctionNode{Name: typed.Name, Arguments: rewriteAll(typed.Arguments, rewrite)}
+	}
+
+	return rewrite(node)
+}
+
+func rewriteAll(nodes []Node, rewrite func(Node) Node) []Node {
+
+	if nodes == nil {
+		return nil
+	}
+
+	ret := make([]Node, len(nodes))
+	for i, node := range nodes {
+		ret[i] = Rewrite(node, rewrite)
+	}
+	return ret
+}
+
+func children(node Node) []Node {
+
+	switch typed := node.(type) {
+	case *ArrayNode:
+		return typed.Items
+	case *MapNode:
+		ret := make([]Node, len(typed.Entries))
+		for i, entry := range typed.Entries {
+			ret[i] = entry.Value
+		}
+		return ret
+	case *IndexNode:
+		return []Node{typed.Collection, typed.Index}
+	case *QuantifierNode:
+		return []Node{typed.List, typed.Condition}
+	case *UnaryNode:
+		return []Node{typed.Operand}
+	case *BinaryNode:
+		return []Node{typed.Left, typed.Right}
+	case *TernaryNode:
+		if typed.Else == nil {
+			return []Node{typed.Condition, typed.Then}
+		}
+		return []Node{typed.Condition, typed.Then, typed.Else}
+	case *FunctionNode:
+		return typed.Arguments
+	}
+	return nil
+}
+
+/*
+	Returns a copy of the tree where every operator whose operands are all literals is replaced by its result,
+	as it would be evaluated. Operators which would fail to evaluate, and functions, are left as they are.
+*/
+func Fold(node Node) Node {
+	return Rewrite(node, foldNode)
+}
+
+/*
+	Returns a copy of the tree which is folded, and simplified with rules such as "true && x" to "x", "!!x" to "x",
+	"!(a == b)" to "a != b" and "true ? a : b" to "a".
+	Simplifying assumes that operands are of the types their operators need, as a schema ensures;
+	an expression which fails to evaluate because they aren't may no longer fail once simplified.
+*/
+func Simplify(node Node) Node {
+
+	return Rewrite(node, func(node Node) Node {
+		return simplifyNode(foldNode(node))
+	})
+}
+
+var invertedComparators = map[OperatorSymbol]OperatorSymbol{
+	EQ:   NEQ,
+	NEQ:  EQ,
+	REQ:  NREQ,
+	NREQ: REQ,
+}
+
+func foldNode(node Node) Node {
+
+	var left, right interface{}
+	var symbol OperatorSymbol
+	var ok bool
+
+	switch typed := node.(type) {
+
+	case *UnaryNode:
+		if right, ok = constantValue(typed.Operand); !ok {
+			return node
+		}
+		symbol = typed.Operator
+
+	case *BinaryNode:
+		if left, ok = constantValue(typed.Left); !ok {
+			return node
+		}
+		if right, ok = constantValue(typed.Right); !ok {
+			return node
+		}
+		symbol = typed.Operator
+
+	default:
+		return node
+	}
+
+	checks := findTypeChecks(symbol)
+	if typeCheck(checks.left, left, symbol, "%v%v") != nil || typeCheck(checks.right, right, symbol, "%v%v") != nil {
+		return node
+	}
+	if checks.combined != nil && !checks.combined(left, right) {
+		return node
+	}
+
+	operator, found := stageSymbolMap[symbol]
+	if !found {
+		return node
+	}
+
+	result, err := operator(left, right, nil)
+	if err != nil {
+		return node
+	}
+
+	switch value := result.(type) {
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return node
+		}
+		// the sum of a time and a duration is planned as a number, but would no longer be a time once printed.
+		if isTimeOrDuration(node) {
+			return node
+		}
+	case string, bool:
+	default:
+		return node
+	}
+	return &LiteralNode{Value: result}
+}
+
+/*
+	Whether a literal operand of [node] is a time or a duration.
+*/
+func isTimeOrDuration(node Node) bool {
+
+	for _, child := range children(node) {
+		if literal, ok := child.(*LiteralNode); ok {
+			switch literal.Value.(type) {
+			case time.Time, time.Duration:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+	Returns the value the [node] has when evaluated, if it's a literal, or an array of them.
+*/
+func constantValue(node Node) (interface{}, bool) {
+
+	switch typed := node.(type) {
+
+	case *LiteralNode:
+		if date, ok := typed.Value.(time.Time); ok {
+			return float64(date.Unix()), true
+		}
+		if duration, ok := typed.Value.(time.Duration); ok {
+			return duration.Seconds(), true
+		}
+		return typed.Value, true
+
+	case *ArrayNode:
+		ret := make([]interface{}, len(typed.Items))
+		for i, item := range typed.Items {
+
+			value, ok := constantValue(item)
+			if !ok {
+				return nil, false
+			}
+			ret[i] = value
+		}
+		return ret, true
+	}
+	return nil, false
+}
+
+func simplifyNode(node Node) Node {
+
+	switch typed := node.(type) {
+
+	case *UnaryNode:
+
+		switch operand := typed.Operand.(type) {
+		case *UnaryNode:
+			// "~~x" isn't x, since the bitwise not truncates it to an integer.
+			if operand.Operator == typed.Operator && (typed.Operator == INVERT || typed.Operator == NEGATE) {
+				return operand.Operand
+			}
+		case *BinaryNode:
+			if inverted, found := invertedComparators[operand.Operator]; found && typed.Operator == INVERT {
+				return &BinaryNode{Operator: inverted, Left: operand.Left, Right: operand.Right}
+			}
+		}
+
+	case *BinaryNode:
+
+		left, leftIsBool := boolValue(typed.Left)
+		right, rightIsBool := boolValue(typed.Right)
+
+		switch typed.Operator {
+		case AND:
+			if leftIsBool {
+				if left {
+					return typed.Right
+				}
+				return typed.Left
+			}
+			if rightIsBool && right {
+				return typed.Left
+			}
+		case OR:
+			if leftIsBool {
+				if left {
+					return typed.Left
+				}
+				return typed.Right
+			}
+			if rightIsBool && !right {
+				return typed.Left
+			}
+		case COALESCE:
+			if _, ok := typed.Left.(*LiteralNode); ok {
+				return typed.Left
+			}
+		}
+
+	case *TernaryNode:
+
+		if condition, ok := boolValue(typed.Condition); ok {
+			if condition {
+				return typed.Then
+			}
+			if typed.Else != nil {
+				return typed.Else
+			}
+		}
+	}
+	return node
+}
+
+func boolValue(node Node) (bool, bool) {
+
+	if literal, ok := node.(*LiteralNode); ok {
+		value, ok := literal.Value.(bool)
+		return value, ok
+	}
+	return false, false
+}
+
+func (this *LiteralNode) String() string {
+
+	switch value := this.Value.(type) {
+	case float64:
+		if value < 0 {
+			return "-" + strconv.FormatFloat(-value, 'f', -1, 64)
+		}
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case time.Time:
+		return quoteString(value.Format(isoDateFormat))
+	case time.Duration:
+		return value.String()
+	case net.IP, *net.IPNet:
+		return fmt.Sprintf("%v", value)
+	case *regexp.Regexp:
+		return quoteString(value.String())
+	}
+	return quoteString(fmt.Sprintf("%v", this.Value))
+}
+
+func (this *VariableNode) String() string {
+
+	if len(this.Path) == 1 && !isPlainName(this.Path[0]) {
+		return "[" + escapeParameterName(this.Path[0]) + "]"
+	}
+	return strings.Join(this.Path, ".")
+}
+
+func (this *ArrayNode) String() string {
+
+	items := joinNodes(this.Items)
+
+	// a single item which looks like a name would be read as an escaped parameter instead.
+	if len(this.Items) == 1 && !strings.ContainsRune(arrayLiteralStarts, []rune(items)[0]) {
+		items = "(" + items + ")"
+	}
+	return "[" + items + "]"
+}
+
+func (this *MapNode) String() string {
+
+	entries := make([]string, len(this.Entries))
+	for i, entry := range this.Entries {
+		entries[i] = quoteString(entry.Key) + ": " + entry.Value.String()
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+func (this *IndexNode) String() string {
+
+	collection := this.Collection.String()
+
+	// only parameters and what's bracketed can be indexed without parenthesis; literals can't.
+	if _, isLiteral := this.Collection.(*LiteralNode); isLiteral || this.Collection.precedence() > 0 {
+		collection = "(" + collection + ")"
+	}
+	return collection + "[" + this.Index.String() + "]"
+}
+
+func (this *QuantifierNode) String() string {
+	return this.Quantifier.String() + "(" + this.List.String() + ", " + this.Binding + " => " + this.Condition.String() + ")"
+}
+
+func (this *UnaryNode) String() string {
+
+	operand := this.Operand.String()
+
+	// prefixes can't follow one another without parenthesis, as "!!" or "--" would be read as one operator.
+	if this.Operand.precedence() > 0 {
+		operand = "(" + operand + ")"
+	}
+	return this.Operator.String() + operand
+}
+
+func (this *BinaryNode) String() string {
+
+	precedence := this.precedence()
+	return parenthesize(this.Left, precedence, false) + " " + operatorText(this.Operator) + " " + parenthesize(this.Right, precedence, true)
+}
+
+func (this *TernaryNode) String() string {
+
+	precedence := this.precedence()
+
+	ret := parenthesize(this.Condition, precedence, false) + " ? " + parenthesize(this.Then, precedence, true)
+	if this.Else != nil {
+		ret += " : " + parenthesize(this.Else, precedence, true)
+	}
+	return ret
+}
+
+func (this *FunctionNode) String() string {
+	return this.Name + "(" + joinNodes(this.Arguments) + ")"
+}
+
+func (this *LiteralNode) precedence() int {
+
+	// negative numbers are written with a prefix.
+	if value, ok := this.Value.(float64); ok && value < 0 {
+		return grammarPrecedence(NEGATE)
+	}
+	return 0
+}
+
+func (this *VariableNode) precedence() int   { return 0 }
+func (this *ArrayNode) precedence() int      { return 0 }
+func (this *MapNode) precedence() int        { return 0 }
+func (this *IndexNode) precedence() int      { return 0 }
+func (this *QuantifierNode) precedence() int { return 0 }
+func (this *FunctionNode) precedence() int   { return 0 }
+
+func (this *UnaryNode) precedence() int  { return grammarPrecedence(this.Operator) }
+func (this *BinaryNode) precedence() int { return grammarPrecedence(this.Operator) }
+
+func (this *TernaryNode) precedence() int { return grammarPrecedence(TERNARY_TRUE) }
+
+/*
+	Returns the text of the [node], parenthesized if it binds more loosely than the operator it's an operand of.
+	Operators are left-associative, so right operands of the same precedence are parenthesized too.
+*/
+func parenthesize(node Node, precedence int, isRight bool) string {
+
+	if node.precedence() > precedence || (isRight && node.precedence() == precedence) {
+		return "(" + node.String() + ")"
+	}
+	return node.String()
+}
+
+func joinNodes(nodes []Node) string {
+
+	texts := make([]string, len(nodes))
+	for i, node := range nodes {
+		texts[i] = node.String()
+	}
+	return strings.Join(texts, ", ")
+}
+
+func quoteString(value string) string {
+	return "'" + escapeCharacters(value, `\'"`) + "'"
+}
+
+/*
+	Escapes a parameter name to be written in brackets, including its first character if it would make them an array literal.
+*/
+func escapeParameterName(name string) string {
+
+	ret := escapeCharacters(name, `\],`)
+
+	start := strings.TrimLeftFunc(ret, unicode.IsSpace)
+	if start != "" && strings.ContainsRune(arrayLiteralStarts, []rune(start)[0]) {
+		return ret[:len(ret)-len(start)] + "\\" + start
+	}
+	return ret
+}
+
+func escapeCharacters(value string, characters string) string {
+
+	var ret strings.Builder
+
+	for _, character := range value {
+		if strings.ContainsRune(characters, character) {
+			ret.WriteRune('\\')
+		}
+		ret.WriteRune(character)
+	}
+	return ret.String()
+}
+
+/*
+	Whether [name] can be written as a parameter without brackets.
+*/
+func isPlainName(name string) bool {
+
+	switch name {
+	case "", "true", "false", "in", "IN":
+		return false
+	}
+
+	for i, character := range name {
+		if !isVariableName(character) || (i == 0 && !unicode.IsLetter(character)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,201 @@
+package govaluate
+
+import (
+	"testing"
+)
+
+/*
+	Represents a test of printing the syntax tree of an expression, optionally after folding or simplifying it.
+*/
+type SyntaxTreeTest struct {
+	Name      string
+	Input     string
+	Functions map[string]ExpressionFunction
+	Transform func(Node) Node
+	Expected  string
+}
+
+func TestSyntaxTreePrinting(test *testing.T) {
+
+	functions := map[string]ExpressionFunction{
+		"foo": noop,
+		"bar": noop,
+	}
+
+	syntaxTreeTests := []SyntaxTreeTest{
+
+		SyntaxTreeTest{
+			Name:     "Canonical spacing",
+			Input:    "a>1&&b=='x'|| !c",
+			Expected: "a > 1 && b == 'x' || !c",
+		},
+		SyntaxTreeTest{
+			Name:     "Redundant parenthesis",
+			Input:    "((a + (b * c))) - ((d))",
+			Expected: "a + b * c - d",
+		},
+		SyntaxTreeTest{
+			Name:     "Needed parenthesis",
+			Input:    "(a + b) * c - (d - e) > (f | g)",
+			Expected: "(a + b) * c - (d - e) > f | g",
+		},
+		SyntaxTreeTest{
+			Name:     "Logical precedence",
+			Input:    "(a || b) && c || (d && e)",
+			Expected: "(a || b) && c || d && e",
+		},
+		SyntaxTreeTest{
+			Name:     "Prefixes",
+			Input:    "-(a + b) + -c - (-1) + !(!d) + ~(e)",
+			Expected: "-(a + b) + -c - -1 + !(!d) + ~e",
+		},
+		SyntaxTreeTest{
+			Name:     "Ternaries",
+			Input:    "(a ? b : c) ? (d ?? e) : (f ? g)",
+			Expected: "a ? b : c ? (d ?? e) : (f ? g)",
+		},
+		SyntaxTreeTest{
+			Name:     "Quoting",
+			Input:    "[foo bar] == 'it\\'s' && [true] =~ '\\\\d' && [a\\]b]",
+			Expected: "[foo bar] == 'it\\'s' && [true] =~ '\\\\d' && [a\\]b]",
+		},
+		SyntaxTreeTest{
+			Name:      "Functions and arrays",
+			Input:     "foo() + bar(1, peer.Name) + foo((1,2)) && x IN (1, (2, 3))",
+			Functions: functions,
+			Expected:  "foo() + bar(1, peer.Name) + foo([1, 2]) && x in [1, [2, 3]]",
+		},
+		SyntaxTreeTest{
+			Name:     "Collections",
+			Input:    "[(x)][0] + {'a':[1]}['a'][0] + (a + b)[1] && any(peers,p=>p.Port>1 && count([[\\1x], y], n => !n) > 0)",
+			Expected: "[(x)][0] + {'a': [1]}['a'][0] + (a + b)[1] && any(peers, p => p.Port > 1 && count([[\\1x], y], n => !n) > 0)",
+		},
+		SyntaxTreeTest{
+			Name:      "Folding",
+			Input:     "x > 1 + 2 * 3 && 'a' + 'b' == y && 2 ** 3 ** 2 > -(-1)",
+			Transform: Fold,
+			Expected:  "x > 7 && 'ab' == y && true",
+		},
+		SyntaxTreeTest{
+			Name:      "Folding leaves failures and functions",
+			Input:     "1 / 0 + 'a' * 2 + foo(1 + 1) + (2 in (1, 2))",
+			Functions: functions,
+			Transform: Fold,
+			Expected:  "1 / 0 + 'a' * 2 + foo(2) + true",
+		},
+		SyntaxTreeTest{
+			Name:      "Simplifying logic",
+			Input:     "(true && a) || false || (b && true) || (false && c) || !(!d)",
+			Transform: Simplify,
+			Expected:  "a || b || d",
+		},
+		SyntaxTreeTest{
+			Name:      "Simplifying comparisons",
+			Input:     "!(a == 1) && !(b !~ 'x') && (1 > 2 ? c : d) && (2 > 1 ? e : f) && ('g' ?? h)",
+			Transform: Simplify,
+			Expected:  "a != 1 && b =~ 'x' && d && e && 'g'",
+		},
+		SyntaxTreeTest{
+			Name:      "Simplifying double prefixes",
+			Input:     "-(-a) + ~(~b)",
+			Transform: Simplify,
+			Expected:  "a + ~(~b)",
+		},
+	}
+
+	for _, syntaxTreeTest := range syntaxTreeTests {
+
+		expression, err := NewEvaluableExpressionWithFunctions(syntaxTreeTest.Input, syntaxTreeTest.Functions)
+		if err != nil {
+			test.Errorf("Test '%s' failed to parse: %s", syntaxTreeTest.Name, err)
+			continue
+		}
+
+		node, err := expression.AST()
+		if err != nil {
+			test.Errorf("Test '%s' failed to build a syntax tree: %s", syntaxTreeTest.Name, err)
+			continue
+		}
+
+		if syntaxTreeTest.Transform != nil {
+			node = syntaxTreeTest.Transform(node)
+		}
+
+		if node.String() != syntaxTreeTest.Expected {
+			test.Errorf("Test '%s' printed '%s', expected '%s'", syntaxTreeTest.Name, node, syntaxTreeTest.Expected)
+		}
+	}
+}
+
+type countingVisitor map[string]int
+
+func (this countingVisitor) Visit(node Node) Visitor {
+
+	switch typed := node.(type) {
+	case *VariableNode:
+		this[typed.String()]++
+	case *FunctionNode:
+		// arguments of functions aren't counted.
+		return nil
+	}
+	return this
+}
+
+func TestSyntaxTreeWalkAndRewrite(test *testing.T) {
+
+	expression, _ := NewEvaluableExpressionWithFunctions("a + b * a > c ? len(a) : peer.Port", StandardFunctions())
+	node, _ := expression.AST()
+
+	counts := countingVisitor{}
+	Walk(counts, node)
+
+	if counts["a"] != 2 || counts["b"] != 1 || counts["peer.Port"] != 1 {
+		test.Errorf("Expected to visit a twice and b and peer.Port once, got %v", counts)
+	}
+
+	renamed := Rewrite(node, func(node Node) Node {
+		if variable, ok := node.(*VariableNode); ok && variable.Path[0] == "a" {
+			return &VariableNode{Path: []string{"renamed"}}
+		}
+		return node
+	})
+
+	expected := "renamed + b * renamed > c ? len(renamed) : peer.Port"
+	if renamed.String() != expected {
+		test.Errorf("Rewrote to '%s', expected '%s'", renamed, expected)
+	}
+
+	if node.String() != "a + b * a > c ? len(a) : peer.Port" {
+		test.Errorf("Expected rewriting to leave the original tree as it was, got '%s'", node)
+	}
+}
+
+/*
+	Checks that printing the syntax tree of the [expression] and parsing it again gives the same tree, printed the same way.
+*/
+func checkSyntaxTreeRoundTrip(expression *EvaluableExpression, name string, functions map[string]ExpressionFunction, test *testing.T) {
+
+	node, err := expression.AST()
+	if err != nil {
+		test.Errorf("Test '%s' failed to build a syntax tree: %s", name, err)
+		return
+	}
+
+	printed := node.String()
+
+	reparsed, err := NewEvaluableExpressionWithFunctions(printed, functions)
+	if err != nil {
+		test.Errorf("Test '%s' printed '%s', which failed to parse: %s", name, printed, err)
+		return
+	}
+
+	reparsedNode, err := reparsed.AST()
+	if err != nil {
+		test.Errorf("Test '%s' printed '%s', which failed to build a syntax tree: %s", name, printed, err)
+		return
+	}
+
+	if reparsedNode.String() != printed {
+		test.Errorf("Test '%s' printed '%s', which was printed as '%s' once parsed again", name, printed, reparsedNode)
+	}
+}
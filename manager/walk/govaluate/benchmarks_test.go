@@ -192,3 +192,76 @@ func BenchmarkConstantRegexExpression(bench *testing.B) {
 		expression.Evaluate(parameters)
 	}
 }
+
+/*
+  The compiled counterparts of the evaluation benchmarks above, to compare the stack machine with the tree evaluator.
+*/
+func benchmarkCompiled(bench *testing.B, expressionString string, parameters map[string]interface{}) {
+
+	expression, _ := NewEvaluableExpression(expressionString)
+	compiled, _ := expression.Compile()
+
+	bench.ReportAllocs()
+	bench.ResetTimer()
+	for i := 0; i < bench.N; i++ {
+		compiled.Evaluate(parameters)
+	}
+}
+
+func BenchmarkCompiledEvaluationSingle(bench *testing.B) {
+	benchmarkCompiled(bench, "1", nil)
+}
+
+func BenchmarkCompiledEvaluationLiteralModifiers(bench *testing.B) {
+	benchmarkCompiled(bench, "(2) + (2) == (4)", nil)
+}
+
+func BenchmarkCompiledEvaluationParameters(bench *testing.B) {
+
+	benchmarkCompiled(bench, "requests_made > requests_succeeded", map[string]interface{}{
+		"requests_made":      99.0,
+		"requests_succeeded": 90.0,
+	})
+}
+
+func BenchmarkCompiledEvaluationParametersModifiers(bench *testing.B) {
+
+	benchmarkCompiled(bench, "(requests_made * requests_succeeded / 100) >= 90", map[string]interface{}{
+		"requests_made":      99.0,
+		"requests_succeeded": 90.0,
+	})
+}
+
+func BenchmarkCompiledComplexExpression(bench *testing.B) {
+
+	expressionString := "2 > 1 &&" +
+		"'something' != 'nothing' || " +
+		"'2014-01-20' < 'Wed Jul  8 23:07:35 MDT 2015' && " +
+		"[escapedVariable name with spaces] <= unescaped\\-variableName &&" +
+		"modifierTest + 1000 / 2 > (80 * 100 % 2)"
+
+	benchmarkCompiled(bench, expressionString, map[string]interface{}{
+		"escapedVariable name with spaces": 99.0,
+		"unescaped\\-variableName":         90.0,
+		"modifierTest":                     5.0,
+	})
+}
+
+/*
+  An arithmetic-heavy expression, where keeping numbers unboxed matters most.
+*/
+func BenchmarkEvaluationArithmetic(bench *testing.B) {
+
+	expression, _ := NewEvaluableExpression("(a * b + c * d - a / b) * (c - d) > a && !(b == c)")
+	parameters := map[string]interface{}{"a": 3.0, "b": 4.0, "c": 5.0, "d": 6.0}
+
+	bench.ReportAllocs()
+	bench.ResetTimer()
+	for i := 0; i < bench.N; i++ {
+		expression.Evaluate(parameters)
+	}
+}
+
+func BenchmarkCompiledEvaluationArithmetic(bench *testing.B) {
+	benchmarkCompiled(bench, "(a * b + c * d - a / b) * (c - d) > a && !(b == c)", map[string]interface{}{"a": 3.0, "b": 4.0, "c": 5.0, "d": 6.0})
+}
@@ -0,0 +1,561 @@
+package govaluate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollectionParsing(test *testing.T) {
+
+	tokenParsingTests := []TokenParsingTest{
+
+		TokenParsingTest{
+
+			Name:  "Array literal",
+			Input: "[1, 'a']",
+			Expected: []ExpressionToken{
+				ExpressionToken{
+					Kind: ARRAY_OPEN,
+				},
+				ExpressionToken{
+					Kind:  NUMERIC,
+					Value: 1.0,
+				},
+				ExpressionToken{
+					Kind: SEPARATOR,
+				},
+				ExpressionToken{
+					Kind:  STRING,
+					Value: "a",
+				},
+				ExpressionToken{
+					Kind: BRACKET_CLOSE,
+				},
+			},
+		},
+		TokenParsingTest{
+
+			Name:  "Array of parameters",
+			Input: "[a, b]",
+			Expected: []ExpressionToken{
+				ExpressionToken{
+					Kind: ARRAY_OPEN,
+				},
+				ExpressionToken{
+					Kind:  VARIABLE,
+					Value: "a",
+				},
+				ExpressionToken{
+					Kind: SEPARATOR,
+				},
+				ExpressionToken{
+					Kind:  VARIABLE,
+					Value: "b",
+				},
+				ExpressionToken{
+					Kind: BRACKET_CLOSE,
+				},
+			},
+		},
+		TokenParsingTest{
+
+			Name:  "Escaped parameter which looks like an array",
+			Input: "[\\1\\, 2]",
+			Expected: []ExpressionToken{
+				ExpressionToken{
+					Kind:  VARIABLE,
+					Value: "1, 2",
+				},
+			},
+		},
+		TokenParsingTest{
+
+			Name:  "Index",
+			Input: "[foo bar][0]['x']",
+			Expected: []ExpressionToken{
+				ExpressionToken{
+					Kind:  VARIABLE,
+					Value: "foo bar",
+				},
+				ExpressionToken{
+					Kind: INDEX_OPEN,
+				},
+				ExpressionToken{
+					Kind:  NUMERIC,
+					Value: 0.0,
+				},
+				ExpressionToken{
+					Kind: BRACKET_CLOSE,
+				},
+				ExpressionToken{
+					Kind: INDEX_OPEN,
+				},
+				ExpressionToken{
+					Kind:  STRING,
+					Value: "x",
+				},
+				ExpressionToken{
+					Kind: BRACKET_CLOSE,
+				},
+			},
+		},
+		TokenParsingTest{
+
+			Name:  "Members of an index",
+			Input: "peers[0].Endpoint.Port",
+			Expected: []ExpressionToken{
+				ExpressionToken{
+					Kind:  VARIABLE,
+					Value: "peers",
+				},
+				ExpressionToken{
+					Kind: INDEX_OPEN,
+				},
+				ExpressionToken{
+					Kind:  NUMERIC,
+					Value: 0.0,
+				},
+				ExpressionToken{
+					Kind: BRACKET_CLOSE,
+				},
+				ExpressionToken{
+					Kind:  MEMBER,
+					Value: []string{"Endpoint", "Port"},
+				},
+			},
+		},
+		TokenParsingTest{
+
+			Name:  "Map literal",
+			Input: "{'a': 1}",
+			Expected: []ExpressionToken{
+				ExpressionToken{
+					Kind: MAP_OPEN,
+				},
+				ExpressionToken{
+					Kind:  STRING,
+					Value: "a",
+				},
+				ExpressionToken{
+					Kind:  TERNARY,
+					Value: ":",
+				},
+				ExpressionToken{
+					Kind:  NUMERIC,
+					Value: 1.0,
+				},
+				ExpressionToken{
+					Kind: MAP_CLOSE,
+				},
+			},
+		},
+		TokenParsingTest{
+
+			Name:  "Quantifier",
+			Input: "any(peers, p=>p.Port > 1) && count > 1",
+			Expected: []ExpressionToken{
+				ExpressionToken{
+					Kind:  QUANTIFIER,
+					Value: "any",
+				},
+				ExpressionToken{
+					Kind: CLAUSE,
+				},
+				ExpressionToken{
+					Kind:  VARIABLE,
+					Value: "peers",
+				},
+				ExpressionToken{
+					Kind: SEPARATOR,
+				},
+				ExpressionToken{
+					Kind:  LAMBDA,
+					Value: "p",
+				},
+				ExpressionToken{
+					Kind:  ACCESSOR,
+					Value: []string{"p", "Port"},
+				},
+				ExpressionToken{
+					Kind:  COMPARATOR,
+					Value: ">",
+				},
+				ExpressionToken{
+					Kind:  NUMERIC,
+					Value: 1.0,
+				},
+				ExpressionToken{
+					Kind: CLAUSE_CLOSE,
+				},
+				ExpressionToken{
+					Kind:  LOGICALOP,
+					Value: "&&",
+				},
+				ExpressionToken{
+					Kind:  VARIABLE,
+					Value: "count",
+				},
+				ExpressionToken{
+					Kind:  COMPARATOR,
+					Value: ">",
+				},
+				ExpressionToken{
+					Kind:  NUMERIC,
+					Value: 1.0,
+				},
+			},
+		},
+	}
+
+	runTokenParsingTest(tokenParsingTests, test)
+}
+
+func TestCollectionEvaluation(test *testing.T) {
+
+	peers := []interface{}{
+		map[string]interface{}{
+			"PublicKey":  "a",
+			"Port":       51820.0,
+			"AllowedIPs": []interface{}{"10.0.0.0/8"},
+		},
+		map[string]interface{}{
+			"PublicKey":  "b",
+			"Port":       443,
+			"AllowedIPs": []string{"0.0.0.0/0", "::/0"},
+		},
+	}
+
+	parameters := []EvaluationParameter{
+		EvaluationParameter{
+			Name:  "peers",
+			Value: peers,
+		},
+		EvaluationParameter{
+			Name:  "x",
+			Value: 2.0,
+		},
+	}
+
+	evaluationTests := []EvaluationTest{
+
+		EvaluationTest{
+
+			Name:       "Indexed array literal",
+			Input:      "[x, 'a', [1, 2]][2][1] + [(x)][0]",
+			Parameters: parameters,
+			Expected:   4.0,
+		},
+		EvaluationTest{
+
+			Name:       "Indexed map literal",
+			Input:      "{'a': x, 'b': {'c': x > 1 ? 'yes' : 'no'}}['b']['c']",
+			Parameters: parameters,
+			Expected:   "yes",
+		},
+		EvaluationTest{
+
+			Name:       "IN array literal",
+			Input:      "x IN [1, 2, 3] && !(x in [])",
+			Parameters: parameters,
+			Expected:   true,
+		},
+		EvaluationTest{
+
+			Name:       "Indexed parameters",
+			Input:      "-peers[1]['Port'] + len(peers[1]['AllowedIPs'][1])",
+			Functions:  StandardFunctions(),
+			Parameters: parameters,
+			Expected:   -439.0,
+		},
+		EvaluationTest{
+
+			Name:       "Index binds tightly",
+			Input:      "1 - [1, 2][x - 1] - 3",
+			Parameters: parameters,
+			Expected:   -4.0,
+		},
+		EvaluationTest{
+
+			Name:       "Any",
+			Input:      "any(peers, p => any(p.AllowedIPs, ip => ip == '0.0.0.0/0'))",
+			Parameters: parameters,
+			Expected:   true,
+		},
+		EvaluationTest{
+
+			Name:       "All",
+			Input:      "all(peers, p => p.Port > 1024)",
+			Parameters: parameters,
+			Expected:   false,
+		},
+		EvaluationTest{
+
+			Name:       "Count",
+			Input:      "count([1, 2, 3], n => n >= x) + count([], n => true)",
+			Parameters: parameters,
+			Expected:   2.0,
+		},
+		EvaluationTest{
+
+			Name:       "Bindings hide parameters",
+			Input:      "all([3, 4], x => x > 2) && x == 2",
+			Parameters: parameters,
+			Expected:   true,
+		},
+		EvaluationTest{
+
+			Name:  "Quantifier names as parameters",
+			Input: "count + any",
+			Parameters: []EvaluationParameter{
+				EvaluationParameter{
+					Name:  "count",
+					Value: 1.0,
+				},
+				EvaluationParameter{
+					Name:  "any",
+					Value: 2.0,
+				},
+			},
+			Expected: 3.0,
+		},
+	}
+
+	runEvaluationTests(evaluationTests, test)
+}
+
+func TestCollectionMembers(test *testing.T) {
+
+	keepalive := 25
+	peers := []*accessorPeer{
+		&accessorPeer{
+			Endpoint: accessorEndpoint{Host: "demo.wireguard.com", Port: 51820},
+		},
+		&accessorPeer{
+			Endpoint:  accessorEndpoint{Host: "10.0.0.1", Port: 443},
+			Keepalive: &keepalive,
+			Tags:      map[string]string{"site": "office"},
+		},
+	}
+
+	parameters := []EvaluationParameter{
+		EvaluationParameter{
+			Name:  "peers",
+			Value: peers,
+		},
+		EvaluationParameter{
+			Name:  "endpoint",
+			Value: accessorEndpoint{Host: "example.com", Port: 53},
+		},
+	}
+
+	evaluationTests := []EvaluationTest{
+
+		EvaluationTest{
+
+			Name:       "Member of an index",
+			Input:      "peers[1].Endpoint.Port",
+			Parameters: parameters,
+			Expected:   443.0,
+		},
+		EvaluationTest{
+
+			Name:       "String index of a struct",
+			Input:      "peers[0]['Endpoint']['Host']",
+			Parameters: parameters,
+			Expected:   "demo.wireguard.com",
+		},
+		EvaluationTest{
+
+			Name:       "Methods and pointers of an index",
+			Input:      "peers[1].Address == '10.0.0.1' && peers[1].PointerPort == 443 && peers[1].Keepalive == 25",
+			Parameters: parameters,
+			Expected:   true,
+		},
+		EvaluationTest{
+
+			Name:       "Map of an index",
+			Input:      "peers[1].Tags.site + peers[1].Tags['site']",
+			Parameters: parameters,
+			Expected:   "officeoffice",
+		},
+		EvaluationTest{
+
+			Name:       "Member of a clause",
+			Input:      "(endpoint).Port + [endpoint, endpoint][1].Port",
+			Parameters: parameters,
+			Expected:   106.0,
+		},
+		EvaluationTest{
+
+			Name:       "Member of a binding",
+			Input:      "count(peers, p => (p).Endpoint.Port > 1024)",
+			Parameters: parameters,
+			Expected:   1.0,
+		},
+	}
+
+	runEvaluationTests(evaluationTests, test)
+}
+
+func TestCollectionResults(test *testing.T) {
+
+	expression, err := NewEvaluableExpression("{'ports': [x, x + 1], 'empty': [], 'nested': [(x, 1)]}")
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"ports":  []interface{}{1.0, 2.0},
+		"empty":  []interface{}{},
+		"nested": []interface{}{[]interface{}{1.0, 1.0}},
+	}
+
+	for i := 0; i < 2; i++ {
+
+		result, err := expression.Evaluate(map[string]interface{}{"x": 1.0})
+		if err != nil || !reflect.DeepEqual(result, expected) {
+			test.Errorf("Evaluated to %v (%v), expected %v", result, err, expected)
+		}
+	}
+}
+
+func TestCollectionVars(test *testing.T) {
+
+	expression, err := NewEvaluableExpression("any(peers, p => p.Port == port && all(p.AllowedIPs, ip => ip != p.Endpoint)) || p")
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	vars := expression.Vars()
+	if !reflect.DeepEqual(vars, []string{"peers", "port", "p"}) {
+		test.Errorf("Expected the bindings of quantifiers to be left out of Vars(), got %v", vars)
+	}
+}
+
+func TestCollectionParsingFailures(test *testing.T) {
+
+	parsingTests := []ParsingFailureTest{
+
+		ParsingFailureTest{
+
+			Name:     "Unclosed array",
+			Input:    "[1, 2",
+			Expected: "Unbalanced bracket",
+		},
+		ParsingFailureTest{
+
+			Name:     "Mismatched brackets",
+			Input:    "{'a': [1}",
+			Expected: "Unbalanced brace",
+		},
+		ParsingFailureTest{
+
+			Name:     "Map key",
+			Input:    "{'a': 1, b: 2}",
+			Expected: "Map keys must be strings",
+		},
+		ParsingFailureTest{
+
+			Name:     "Duplicate map key",
+			Input:    "{'a': 1, 'a': 2}",
+			Expected: "Duplicate key 'a'",
+		},
+		ParsingFailureTest{
+
+			Name:     "Index of many",
+			Input:    "x[1, 2]",
+			Expected: "Expected ']' to close the index",
+		},
+		ParsingFailureTest{
+
+			Name:     "Empty member",
+			Input:    "peers[0].Endpoint..Port",
+			Expected: "Empty member name",
+		},
+		ParsingFailureTest{
+
+			Name:     "Quantifier without binding",
+			Input:    "any(peers, true)",
+			Expected: "Expected a list and a binding",
+		},
+		ParsingFailureTest{
+
+			Name:     "Binding outside of a quantifier",
+			Input:    "(1, x => x)",
+			Expected: "can only be given to any(), all() or count()",
+		},
+	}
+
+	runParsingFailureTests(parsingTests, test)
+}
+
+func TestCollectionEvaluationFailures(test *testing.T) {
+
+	parameters := map[string]interface{}{
+		"list":   []interface{}{1.0, "a"},
+		"number": 1.0,
+		"peers":  []*accessorPeer{&accessorPeer{}, nil},
+	}
+
+	evaluationTests := []EvaluationFailureTest{
+
+		EvaluationFailureTest{
+
+			Name:       "Index out of range",
+			Input:      "list[2]",
+			Parameters: parameters,
+			Expected:   "Index 2 is out of range of an array of length 2",
+		},
+		EvaluationFailureTest{
+
+			Name:       "Fractional index",
+			Input:      "list[0.5]",
+			Parameters: parameters,
+			Expected:   "it is not a whole number",
+		},
+		EvaluationFailureTest{
+
+			Name:       "Missing key",
+			Input:      "{'a': 1}['b']",
+			Parameters: parameters,
+			Expected:   "No key 'b' found",
+		},
+		EvaluationFailureTest{
+
+			Name:       "Indexed number",
+			Input:      "number[0]",
+			Parameters: parameters,
+			Expected:   "cannot be indexed",
+		},
+		EvaluationFailureTest{
+
+			Name:       "Missing member",
+			Input:      "peers[0].Missing",
+			Parameters: parameters,
+			Expected:   "No member 'Missing' found in value of type *govaluate.accessorPeer",
+		},
+		EvaluationFailureTest{
+
+			Name:       "Member of nil",
+			Input:      "peers[1].Endpoint",
+			Parameters: parameters,
+			Expected:   "cannot be indexed",
+		},
+		EvaluationFailureTest{
+
+			Name:       "Quantified number",
+			Input:      "any(number, n => true)",
+			Parameters: parameters,
+			Expected:   "cannot be used with the quantifier 'any', it is not an array",
+		},
+		EvaluationFailureTest{
+
+			Name:       "Condition which isn't a bool",
+			Input:      "all(list, item => item)",
+			Parameters: parameters,
+			Expected:   "cannot be used as the condition of 'all'",
+		},
+	}
+
+	runEvaluationFailureTests(evaluationTests, test)
+}
@@ -480,5 +480,7 @@ func runEvaluationFailureTests(evaluationTests []EvaluationFailureTest, test *te
 			test.Fail()
 			continue
 		}
+
+		checkCompiledEvaluation(expression, testCase.Parameters, nil, err, testCase.Name, test)
 	}
 }
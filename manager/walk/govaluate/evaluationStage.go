@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net"
 	"regexp"
 	"reflect"
 )
@@ -39,6 +40,13 @@ type evaluationStage struct {
 
 	// regardless of which type check is used, this string format will be used as the error message for type errors
 	typeErrorFormat string
+
+	// index of the token this stage was planned from (its operator, or its value), used to point out static type errors.
+	tokenIndex int
+
+	// the keys of a map literal, in the order of its values, and the name a quantifier binds each item to.
+	keys    []string
+	binding string
 }
 
 var (
@@ -61,6 +69,9 @@ func (this *evaluationStage) setToNonStage(other evaluationStage) {
 	this.rightTypeCheck = other.rightTypeCheck
 	this.typeCheck = other.typeCheck
 	this.typeErrorFormat = other.typeErrorFormat
+	this.tokenIndex = other.tokenIndex
+	this.keys = other.keys
+	this.binding = other.binding
 }
 
 func (this *evaluationStage) isShortCircuitable() bool {
@@ -81,6 +92,10 @@ func (this *evaluationStage) isShortCircuitable() bool {
 	return false
 }
 
+func (this *evaluationStage) isQuantifier() bool {
+	return this.symbol == ANY || this.symbol == ALL || this.symbol == COUNT
+}
+
 func noopStageRight(left interface{}, right interface{}, parameters Parameters) (interface{}, error) {
 	return right, nil
 }
@@ -113,24 +128,36 @@ func gteStage(left interface{}, right interface{}, parameters Parameters) (inter
 	if isString(left) && isString(right) {
 		return boolIface(left.(string) >= right.(string)), nil
 	}
+	if isIP(left) && isIP(right) {
+		return boolIface(compareIPs(left.(net.IP), right.(net.IP)) >= 0), nil
+	}
 	return boolIface(left.(float64) >= right.(float64)), nil
 }
 func gtStage(left interface{}, right interface{}, parameters Parameters) (interface{}, error) {
 	if isString(left) && isString(right) {
 		return boolIface(left.(string) > right.(string)), nil
 	}
+	if isIP(left) && isIP(right) {
+		return boolIface(compareIPs(left.(net.IP), right.(net.IP)) > 0), nil
+	}
 	return boolIface(left.(float64) > right.(float64)), nil
 }
 func lteStage(left interface{}, right interface{}, parameters Parameters) (interface{}, error) {
 	if isString(left) && isString(right) {
 		return boolIface(left.(string) <= right.(string)), nil
 	}
+	if isIP(left) && isIP(right) {
+		return boolIface(compareIPs(left.(net.IP), right.(net.IP)) <= 0), nil
+	}
 	return boolIface(left.(float64) <= right.(float64)), nil
 }
 func ltStage(left interface{}, right interface{}, parameters Parameters) (interface{}, error) {
 	if isString(left) && isString(right) {
 		return boolIface(left.(string) < right.(string)), nil
 	}
+	if isIP(left) && isIP(right) {
+		return boolIface(compareIPs(left.(net.IP), right.(net.IP)) < 0), nil
+	}
 	return boolIface(left.(float64) < right.(float64)), nil
 }
 func equalStage(left interface{}, right interface{}, parameters Parameters) (interface{}, error) {
@@ -229,7 +256,7 @@ func makeLiteralStage(literal interface{}) evaluationOperator {
 	}
 }
 
-func makeFunctionStage(function ExpressionFunction) evaluationOperator {
+func makeFunctionStage(function ExpressionFunction, spread bool) evaluationOperator {
 
 	return func(left interface{}, right interface{}, parameters Parameters) (interface{}, error) {
 
@@ -237,13 +264,10 @@ func makeFunctionStage(function ExpressionFunction) evaluationOperator {
 			return function()
 		}
 
-		switch right.(type) {
-		case []interface{}:
+		if spread {
 			return function(right.([]interface{})...)
-		default:
-			return function(right)
 		}
-
+		return function(right)
 	}
 }
 
@@ -261,8 +285,33 @@ func separatorStage(left interface{}, right interface{}, parameters Parameters)
 	return ret, nil
 }
 
+func startSeparatorStage(left interface{}, right interface{}, parameters Parameters) (interface{}, error) {
+	return []interface{}{left, right}, nil
+}
+
 func inStage(left interface{}, right interface{}, parameters Parameters) (interface{}, error) {
 
+	// containment, as in "ip in 10.0.0.0/8". Either side may also be a string, as in "'10.1.2.3' in '10.0.0.0/8'".
+	if network, ok := toNetwork(right); ok {
+
+		address, ok := toAddress(left)
+		if !ok {
+			errorMsg := fmt.Sprintf("Value '%v' cannot be used with the comparator 'in' on a network, it is not an IP or a network", left)
+			return nil, errors.New(errorMsg)
+		}
+		return boolIface(networkContains(network, address)), nil
+	}
+
+	// IPs are slices, which can't be compared with ==.
+	if ip, ok := left.(net.IP); ok {
+		for _, value := range right.([]interface{}) {
+			if other, ok := value.(net.IP); ok && ip.Equal(other) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
 	for _, value := range right.([]interface{}) {
 		if left == value {
 			return true, nil
@@ -271,6 +320,131 @@ func inStage(left interface{}, right interface{}, parameters Parameters) (interf
 	return false, nil
 }
 
+/*
+	Array literals are given a list of their items, unless they have only one, as in "[(1, 2)]", which is then the only item.
+*/
+func makeArrayStage(spread bool) evaluationOperator {
+
+	return func(left interface{}, right interface{}, parameters Parameters) (interface{}, error) {
+
+		if spread {
+			return right, nil
+		}
+		if right == nil {
+			return []interface{}{}, nil
+		}
+		return []interface{}{right}, nil
+	}
+}
+
+func emptyArrayStage(left interface{}, right interface{}, parameters Parameters) (interface{}, error) {
+	return []interface{}{}, nil
+}
+
+/*
+	Map literals are given a list of their values, in the order of [keys], unless they have only one.
+*/
+func makeMapStage(keys []string) evaluationOperator {
+
+	return func(left interface{}, right interface{}, parameters Parameters) (interface{}, error) {
+
+		ret := make(map[string]interface{}, len(keys))
+
+		switch len(keys) {
+		case 0:
+		case 1:
+			ret[keys[0]] = right
+		default:
+			for i, value := range right.([]interface{}) {
+				ret[keys[i]] = value
+			}
+		}
+		return ret, nil
+	}
+}
+
+func indexStage(left interface{}, right interface{}, parameters Parameters) (interface{}, error) {
+
+	collection := reflect.ValueOf(left)
+
+	// IPs are slices of bytes, but not arrays to expressions.
+	if isIP(left) {
+		collection = reflect.Value{}
+	}
+
+	switch collection.Kind() {
+
+	case reflect.Slice, reflect.Array:
+		index, ok := right.(float64)
+		if !ok || index != math.Trunc(index) {
+			errorMsg := fmt.Sprintf("Value '%v' cannot be used as an index of an array, it is not a whole number", right)
+			return nil, errors.New(errorMsg)
+		}
+		if index < 0 || index >= float64(collection.Len()) {
+			errorMsg := fmt.Sprintf("Index %v is out of range of an array of length %d", index, collection.Len())
+			return nil, errors.New(errorMsg)
+		}
+		return plainValue(collection.Index(int(index)), convertsTimes(parameters)), nil
+
+	case reflect.Map:
+		key, ok := right.(string)
+		if !ok || collection.Type().Key().Kind() != reflect.String {
+			errorMsg := fmt.Sprintf("Value '%v' cannot be used as a key of a map, it is not a string", right)
+			return nil, errors.New(errorMsg)
+		}
+
+		value := collection.MapIndex(reflect.ValueOf(key).Convert(collection.Type().Key()))
+		if !value.IsValid() {
+			errorMsg := fmt.Sprintf("No key '%s' found in map", key)
+			return nil, errors.New(errorMsg)
+		}
+		return plainValue(value, convertsTimes(parameters)), nil
+
+	case reflect.Struct, reflect.Ptr:
+		// members of structs, as in "peers[0]['Port']" or "peers[0].Port", are found the same way as accessors.
+		member, ok := right.(string)
+		if !ok || isNilValue(collection) {
+			break
+		}
+
+		step := findAccessorStep(collection.Type(), member)
+		if step.kind == accessorMissing {
+			errorMsg := fmt.Sprintf("No member '%s' found in value of type %s", member, collection.Type())
+			return nil, errors.New(errorMsg)
+		}
+
+		value, err := accessMember(collection, step, member, collection.Type().String(), parameters)
+		if err != nil {
+			return nil, err
+		}
+		return plainValue(value, convertsTimes(parameters)), nil
+	}
+
+	errorMsg := fmt.Sprintf("Value '%v' cannot be indexed, it is not an array, a map or a struct", left)
+	return nil, errors.New(errorMsg)
+}
+
+/*
+	Returns the items of an array given as any kind of slice, with the same conversions as accessors.
+*/
+func arrayItems(value interface{}, convertTimes bool) ([]interface{}, bool) {
+
+	if items, ok := value.([]interface{}); ok {
+		return items, true
+	}
+
+	array := reflect.ValueOf(value)
+	if (array.Kind() != reflect.Slice && array.Kind() != reflect.Array) || isIP(value) {
+		return nil, false
+	}
+
+	ret := make([]interface{}, array.Len())
+	for i := range ret {
+		ret[i] = plainValue(array.Index(i), convertTimes)
+	}
+	return ret, true
+}
+
 //
 
 func isString(value interface{}) bool {
@@ -325,8 +499,8 @@ func additionTypeCheck(left interface{}, right interface{}) bool {
 }
 
 /*
-	Comparison can either be between numbers, or lexicographic between two strings,
-	but never between the two.
+	Comparison can either be between numbers, lexicographic between two strings,
+	or between two IPs, but never between different ones.
 */
 func comparatorTypeCheck(left interface{}, right interface{}) bool {
 
@@ -336,6 +510,9 @@ func comparatorTypeCheck(left interface{}, right interface{}) bool {
 	if isString(left) && isString(right) {
 		return true
 	}
+	if isIP(left) && isIP(right) {
+		return true
+	}
 	return false
 }
 
@@ -347,6 +524,12 @@ func isArray(value interface{}) bool {
 	return false
 }
 
+func isArrayOrNetwork(value interface{}) bool {
+
+	_, ok := toNetwork(value)
+	return isArray(value) || ok
+}
+
 /*
 	Converting a boolean to an interface{} requires an allocation.
 	We can use interned bools to avoid this cost.
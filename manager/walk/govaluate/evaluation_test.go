@@ -1365,5 +1365,35 @@ func runEvaluationTests(evaluationTests []EvaluationTest, test *testing.T) {
 			test.Logf("Evaluation result '%v' does not match expected: '%v'", result, evaluationTest.Expected)
 			test.Fail()
 		}
+
+		checkCompiledEvaluation(expression, parameters, result, err, evaluationTest.Name, test)
+	}
+}
+
+/*
+	Makes sure that the compiled form of [expression] gives the same result and error as the tree evaluator did.
+*/
+func checkCompiledEvaluation(expression *EvaluableExpression, parameters map[string]interface{}, expected interface{}, expectedErr error, name string, test *testing.T) {
+
+	compiled, err := expression.Compile()
+	if err != nil {
+		test.Logf("Test '%s' failed to compile: '%s'", name, err)
+		test.Fail()
+		return
+	}
+
+	result, err := compiled.Evaluate(parameters)
+
+	if (err == nil) != (expectedErr == nil) || (err != nil && err.Error() != expectedErr.Error()) {
+		test.Logf("Test '%s' failed", name)
+		test.Logf("Compiled evaluation error '%v' does not match tree evaluation error '%v'", err, expectedErr)
+		test.Fail()
+		return
+	}
+
+	if err == nil && fmt.Sprintf("%#v", result) != fmt.Sprintf("%#v", expected) {
+		test.Logf("Test '%s' failed", name)
+		test.Logf("Compiled evaluation result '%#v' does not match tree evaluation result: '%#v'", result, expected)
+		test.Fail()
 	}
 }
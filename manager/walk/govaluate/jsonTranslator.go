@@ -0,0 +1,122 @@
+package govaluate
+
+import (
+	"encoding/json"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+/*
+	A QueryTranslator to a generic syntax tree, for consumers in other languages. Every node is an object with a "type":
+
+		{"type": "literal", "kind": "number", "value": 1}        kinds are number, string, bool, time, duration, ip, cidr and pattern
+		{"type": "variable", "name": "peer.Endpoint.Host"}
+		{"type": "array", "items": [...]}
+		{"type": "unary", "operator": "!", "operand": {...}}
+		{"type": "binary", "operator": "==", "left": {...}, "right": {...}}
+		{"type": "ternary", "condition": {...}, "then": {...}, "else": {...}}    "else" is left out if there is none
+		{"type": "function", "name": "len", "arguments": [...]}
+
+	Operators are written as they are in expressions. Times are formatted with DateFormat.
+*/
+type JSONTranslator struct {
+
+	// Defaults to the complete ISO8601 format, like EvaluableExpression.QueryDateFormat.
+	DateFormat string
+}
+
+/*
+	Returns the syntax tree this expression translates to, as nested maps and slices.
+*/
+func (this JSONTranslator) Translate(expression *EvaluableExpression) (map[string]interface{}, error) {
+
+	result, err := expression.Translate(this)
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[string]interface{}), nil
+}
+
+/*
+	Returns the syntax tree of this expression as JSON. See [JSONTranslator].
+	Times are formatted with this.QueryDateFormat.
+*/
+func (this EvaluableExpression) ToJSONAST() ([]byte, error) {
+
+	tree, err := JSONTranslator{DateFormat: this.QueryDateFormat}.Translate(&this)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(tree)
+}
+
+func (this JSONTranslator) Literal(value interface{}) (interface{}, error) {
+
+	var kind string
+
+	switch typed := value.(type) {
+	case float64:
+		kind = "number"
+	case string:
+		kind = "string"
+	case bool:
+		kind = "bool"
+	case time.Time:
+		kind = "time"
+
+		format := this.DateFormat
+		if format == "" {
+			format = isoDateFormat
+		}
+		value = typed.Format(format)
+	case *regexp.Regexp:
+		kind = "pattern"
+		value = typed.String()
+	case time.Duration:
+		kind = "duration"
+		value = typed.Seconds()
+	case net.IP:
+		kind = "ip"
+		value = typed.String()
+	case *net.IPNet:
+		kind = "cidr"
+		value = typed.String()
+	}
+
+	return map[string]interface{}{"type": "literal", "kind": kind, "value": value}, nil
+}
+
+func (this JSONTranslator) Variable(path []string) (interface{}, error) {
+	return map[string]interface{}{"type": "variable", "name": strings.Join(path, ".")}, nil
+}
+
+func (this JSONTranslator) List(items []interface{}) (interface{}, error) {
+	return map[string]interface{}{"type": "array", "items": items}, nil
+}
+
+func (this JSONTranslator) Unary(symbol OperatorSymbol, operand interface{}) (interface{}, error) {
+	return map[string]interface{}{"type": "unary", "operator": operatorText(symbol), "operand": operand}, nil
+}
+
+func (this JSONTranslator) Binary(symbol OperatorSymbol, left interface{}, right interface{}) (interface{}, error) {
+	return map[string]interface{}{"type": "binary", "operator": operatorText(symbol), "left": left, "right": right}, nil
+}
+
+func (this JSONTranslator) Ternary(condition interface{}, whenTrue interface{}, whenFalse interface{}) (interface{}, error) {
+
+	ret := map[string]interface{}{"type": "ternary", "condition": condition, "then": whenTrue}
+	if whenFalse != nil {
+		ret["else"] = whenFalse
+	}
+	return ret, nil
+}
+
+func (this JSONTranslator) Function(name string, arguments []interface{}) (interface{}, error) {
+
+	if arguments == nil {
+		arguments = []interface{}{}
+	}
+	return map[string]interface{}{"type": "function", "name": name, "arguments": arguments}, nil
+}
@@ -3,6 +3,7 @@ package govaluate
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 type lexerState struct {
@@ -26,11 +27,18 @@ var validLexerStates = []lexerState{
 			NUMERIC,
 			BOOLEAN,
 			VARIABLE,
+			ACCESSOR,
 			PATTERN,
 			FUNCTION,
 			STRING,
 			TIME,
+			DURATION,
+			IP,
+			CIDR,
 			CLAUSE,
+			ARRAY_OPEN,
+			MAP_OPEN,
+			QUANTIFIER,
 		},
 	},
 
@@ -45,12 +53,19 @@ var validLexerStates = []lexerState{
 			NUMERIC,
 			BOOLEAN,
 			VARIABLE,
+			ACCESSOR,
 			PATTERN,
 			FUNCTION,
 			STRING,
 			TIME,
+			DURATION,
+			IP,
+			CIDR,
 			CLAUSE,
 			CLAUSE_CLOSE,
+			ARRAY_OPEN,
+			MAP_OPEN,
+			QUANTIFIER,
 		},
 	},
 
@@ -66,14 +81,22 @@ var validLexerStates = []lexerState{
 			NUMERIC,
 			BOOLEAN,
 			VARIABLE,
+			ACCESSOR,
 			STRING,
 			PATTERN,
 			TIME,
+			DURATION,
+			IP,
+			CIDR,
 			CLAUSE,
 			CLAUSE_CLOSE,
 			LOGICALOP,
 			TERNARY,
 			SEPARATOR,
+			INDEX_OPEN,
+			MEMBER,
+			BRACKET_CLOSE,
+			MAP_CLOSE,
 		},
 	},
 
@@ -90,6 +113,8 @@ var validLexerStates = []lexerState{
 			CLAUSE_CLOSE,
 			TERNARY,
 			SEPARATOR,
+			BRACKET_CLOSE,
+			MAP_CLOSE,
 		},
 	},
 	lexerState{
@@ -105,6 +130,8 @@ var validLexerStates = []lexerState{
 			CLAUSE_CLOSE,
 			TERNARY,
 			SEPARATOR,
+			BRACKET_CLOSE,
+			MAP_CLOSE,
 		},
 	},
 	lexerState{
@@ -120,6 +147,8 @@ var validLexerStates = []lexerState{
 			CLAUSE_CLOSE,
 			TERNARY,
 			SEPARATOR,
+			BRACKET_CLOSE,
+			MAP_CLOSE,
 		},
 	},
 	lexerState{
@@ -134,6 +163,59 @@ var validLexerStates = []lexerState{
 			LOGICALOP,
 			CLAUSE_CLOSE,
 			SEPARATOR,
+			BRACKET_CLOSE,
+			MAP_CLOSE,
+		},
+	},
+	lexerState{
+
+		kind:       DURATION,
+		isEOF:      true,
+		isNullable: false,
+		validNextKinds: []TokenKind{
+
+			MODIFIER,
+			COMPARATOR,
+			LOGICALOP,
+			CLAUSE_CLOSE,
+			TERNARY,
+			SEPARATOR,
+			BRACKET_CLOSE,
+			MAP_CLOSE,
+		},
+	},
+	lexerState{
+
+		kind:       IP,
+		isEOF:      true,
+		isNullable: false,
+		validNextKinds: []TokenKind{
+
+			MODIFIER,
+			COMPARATOR,
+			LOGICALOP,
+			CLAUSE_CLOSE,
+			TERNARY,
+			SEPARATOR,
+			BRACKET_CLOSE,
+			MAP_CLOSE,
+		},
+	},
+	lexerState{
+
+		kind:       CIDR,
+		isEOF:      true,
+		isNullable: false,
+		validNextKinds: []TokenKind{
+
+			MODIFIER,
+			COMPARATOR,
+			LOGICALOP,
+			CLAUSE_CLOSE,
+			TERNARY,
+			SEPARATOR,
+			BRACKET_CLOSE,
+			MAP_CLOSE,
 		},
 	},
 	lexerState{
@@ -148,6 +230,8 @@ var validLexerStates = []lexerState{
 			LOGICALOP,
 			CLAUSE_CLOSE,
 			SEPARATOR,
+			BRACKET_CLOSE,
+			MAP_CLOSE,
 		},
 	},
 	lexerState{
@@ -163,6 +247,45 @@ var validLexerStates = []lexerState{
 			CLAUSE_CLOSE,
 			TERNARY,
 			SEPARATOR,
+			INDEX_OPEN,
+			BRACKET_CLOSE,
+			MAP_CLOSE,
+		},
+	},
+	lexerState{
+
+		kind:       ACCESSOR,
+		isEOF:      true,
+		isNullable: false,
+		validNextKinds: []TokenKind{
+
+			MODIFIER,
+			COMPARATOR,
+			LOGICALOP,
+			CLAUSE_CLOSE,
+			TERNARY,
+			SEPARATOR,
+			INDEX_OPEN,
+			BRACKET_CLOSE,
+			MAP_CLOSE,
+		},
+	},
+	lexerState{
+
+		kind:       MEMBER,
+		isEOF:      true,
+		isNullable: false,
+		validNextKinds: []TokenKind{
+
+			MODIFIER,
+			COMPARATOR,
+			LOGICALOP,
+			CLAUSE_CLOSE,
+			TERNARY,
+			SEPARATOR,
+			INDEX_OPEN,
+			BRACKET_CLOSE,
+			MAP_CLOSE,
 		},
 	},
 	lexerState{
@@ -174,12 +297,17 @@ var validLexerStates = []lexerState{
 
 			PREFIX,
 			NUMERIC,
+			DURATION,
 			VARIABLE,
+			ACCESSOR,
 			FUNCTION,
 			STRING,
 			BOOLEAN,
 			CLAUSE,
 			CLAUSE_CLOSE,
+			ARRAY_OPEN,
+			MAP_OPEN,
+			QUANTIFIER,
 		},
 	},
 	lexerState{
@@ -193,12 +321,19 @@ var validLexerStates = []lexerState{
 			NUMERIC,
 			BOOLEAN,
 			VARIABLE,
+			ACCESSOR,
 			FUNCTION,
 			STRING,
 			TIME,
+			DURATION,
+			IP,
+			CIDR,
 			CLAUSE,
 			CLAUSE_CLOSE,
 			PATTERN,
+			ARRAY_OPEN,
+			MAP_OPEN,
+			QUANTIFIER,
 		},
 	},
 	lexerState{
@@ -212,11 +347,18 @@ var validLexerStates = []lexerState{
 			NUMERIC,
 			BOOLEAN,
 			VARIABLE,
+			ACCESSOR,
 			FUNCTION,
 			STRING,
 			TIME,
+			DURATION,
+			IP,
+			CIDR,
 			CLAUSE,
 			CLAUSE_CLOSE,
+			ARRAY_OPEN,
+			MAP_OPEN,
+			QUANTIFIER,
 		},
 	},
 	lexerState{
@@ -227,11 +369,14 @@ var validLexerStates = []lexerState{
 		validNextKinds: []TokenKind{
 
 			NUMERIC,
+			DURATION,
 			BOOLEAN,
 			VARIABLE,
+			ACCESSOR,
 			FUNCTION,
 			CLAUSE,
 			CLAUSE_CLOSE,
+			QUANTIFIER,
 		},
 	},
 
@@ -247,10 +392,17 @@ var validLexerStates = []lexerState{
 			BOOLEAN,
 			STRING,
 			TIME,
+			DURATION,
+			IP,
+			CIDR,
 			VARIABLE,
+			ACCESSOR,
 			FUNCTION,
 			CLAUSE,
 			SEPARATOR,
+			ARRAY_OPEN,
+			MAP_OPEN,
+			QUANTIFIER,
 		},
 	},
 	lexerState{
@@ -274,9 +426,149 @@ var validLexerStates = []lexerState{
 			BOOLEAN,
 			STRING,
 			TIME,
+			DURATION,
+			IP,
+			CIDR,
+			VARIABLE,
+			ACCESSOR,
+			FUNCTION,
+			CLAUSE,
+			ARRAY_OPEN,
+			MAP_OPEN,
+			QUANTIFIER,
+			LAMBDA,
+		},
+	},
+	lexerState{
+
+		kind:       ARRAY_OPEN,
+		isEOF:      false,
+		isNullable: true,
+		validNextKinds: []TokenKind{
+
+			PREFIX,
+			NUMERIC,
+			BOOLEAN,
+			STRING,
+			TIME,
+			DURATION,
+			IP,
+			CIDR,
 			VARIABLE,
+			ACCESSOR,
 			FUNCTION,
 			CLAUSE,
+			ARRAY_OPEN,
+			MAP_OPEN,
+			QUANTIFIER,
+			BRACKET_CLOSE,
+		},
+	},
+	lexerState{
+
+		kind:       INDEX_OPEN,
+		isEOF:      false,
+		isNullable: true,
+		validNextKinds: []TokenKind{
+
+			PREFIX,
+			NUMERIC,
+			BOOLEAN,
+			STRING,
+			TIME,
+			DURATION,
+			IP,
+			CIDR,
+			VARIABLE,
+			ACCESSOR,
+			FUNCTION,
+			CLAUSE,
+			ARRAY_OPEN,
+			MAP_OPEN,
+			QUANTIFIER,
+		},
+	},
+	lexerState{
+
+		kind:       BRACKET_CLOSE,
+		isEOF:      true,
+		isNullable: true,
+		validNextKinds: []TokenKind{
+
+			COMPARATOR,
+			MODIFIER,
+			LOGICALOP,
+			TERNARY,
+			SEPARATOR,
+			CLAUSE_CLOSE,
+			BRACKET_CLOSE,
+			MAP_CLOSE,
+			INDEX_OPEN,
+			MEMBER,
+		},
+	},
+	lexerState{
+
+		kind:       MAP_OPEN,
+		isEOF:      false,
+		isNullable: true,
+		validNextKinds: []TokenKind{
+
+			STRING,
+			MAP_CLOSE,
+		},
+	},
+	lexerState{
+
+		kind:       MAP_CLOSE,
+		isEOF:      true,
+		isNullable: true,
+		validNextKinds: []TokenKind{
+
+			COMPARATOR,
+			MODIFIER,
+			LOGICALOP,
+			TERNARY,
+			SEPARATOR,
+			CLAUSE_CLOSE,
+			BRACKET_CLOSE,
+			MAP_CLOSE,
+			INDEX_OPEN,
+			MEMBER,
+		},
+	},
+	lexerState{
+
+		kind:       QUANTIFIER,
+		isEOF:      false,
+		isNullable: false,
+		validNextKinds: []TokenKind{
+
+			CLAUSE,
+		},
+	},
+	lexerState{
+
+		kind:       LAMBDA,
+		isEOF:      false,
+		isNullable: false,
+		validNextKinds: []TokenKind{
+
+			PREFIX,
+			NUMERIC,
+			BOOLEAN,
+			STRING,
+			TIME,
+			DURATION,
+			IP,
+			CIDR,
+			VARIABLE,
+			ACCESSOR,
+			FUNCTION,
+			CLAUSE,
+			ARRAY_OPEN,
+			MAP_OPEN,
+			QUANTIFIER,
 		},
 	},
 }
@@ -307,13 +599,16 @@ func checkExpressionSyntax(tokens []ExpressionToken) error {
 
 			// call out a specific error for tokens looking like they want to be functions.
 			if lastToken.Kind == VARIABLE && token.Kind == CLAUSE {
-				return errors.New("Undefined function " + lastToken.Value.(string))
+				return newParseError(lastToken, []TokenKind{FUNCTION}, "Undefined function "+lastToken.Value.(string))
+			}
+			if lastToken.Kind == ACCESSOR && token.Kind == CLAUSE {
+				return newParseError(token, state.validNextKinds, "Accessor "+strings.Join(lastToken.Value.([]string), ".")+" cannot be called; methods are called without parentheses")
 			}
 
 			firstStateName := fmt.Sprintf("%s [%v]", state.kind.String(), lastToken.Value)
 			nextStateName := fmt.Sprintf("%s [%v]", token.Kind.String(), token.Value)
 
-			return errors.New("Cannot transition token types from " + firstStateName + " to " + nextStateName)
+			return newParseError(token, state.validNextKinds, "Cannot transition token types from "+firstStateName+" to "+nextStateName)
 		}
 
 		state, err = getLexerStateForToken(token.Kind)
@@ -324,14 +619,17 @@ func checkExpressionSyntax(tokens []ExpressionToken) error {
 		if !state.isNullable && token.Value == nil {
 
 			errorMsg := fmt.Sprintf("Token kind '%v' cannot have a nil value", token.Kind.String())
-			return errors.New(errorMsg)
+			return newParseError(token, nil, errorMsg)
 		}
 
 		lastToken = token
 	}
 
 	if !state.isEOF {
-		return errors.New("Unexpected end of expression")
+
+		// point just past the last token, where the expression ends.
+		end := ExpressionToken{Offset: lastToken.Offset + lastToken.Length}
+		return newParseError(end, state.validNextKinds, "Unexpected end of expression")
 	}
 	return nil
 }
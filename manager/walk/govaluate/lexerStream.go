@@ -1,9 +1,16 @@
 package govaluate
 
+import (
+	"unicode"
+)
+
 type lexerStream struct {
 	source   []rune
 	position int
 	length   int
+
+	// byte offset of each rune in the source, followed by the length of the source.
+	offsets []int
 }
 
 func newLexerStream(source string) *lexerStream {
@@ -11,13 +18,17 @@ func newLexerStream(source string) *lexerStream {
 	var ret *lexerStream
 	var runes []rune
 
-	for _, character := range source {
+	var offsets []int
+
+	for offset, character := range source {
 		runes = append(runes, character)
+		offsets = append(offsets, offset)
 	}
 
 	ret = new(lexerStream)
 	ret.source = runes
 	ret.length = len(runes)
+	ret.offsets = append(offsets, len(source))
 	return ret
 }
 
@@ -37,3 +48,49 @@ func (this *lexerStream) rewind(amount int) {
 func (this lexerStream) canRead() bool {
 	return this.position < this.length
 }
+
+/*
+	Returns the byte offset of the rune at [position] in the source.
+*/
+func (this lexerStream) byteOffset(position int) int {
+
+	if position < 0 {
+		return 0
+	}
+	if position > this.length {
+		position = this.length
+	}
+	return this.offsets[position]
+}
+
+/*
+	Returns the byte offset and length of the text read since the rune at [start], not counting any trailing whitespace.
+*/
+func (this lexerStream) span(start int) (int, int) {
+
+	end := this.position
+	if end > this.length {
+		end = this.length
+	}
+
+	for end > start+1 && unicode.IsSpace(this.source[end-1]) {
+		end--
+	}
+
+	offset := this.byteOffset(start)
+	return offset, this.byteOffset(end) - offset
+}
+
+/*
+	Returns a ParseError pointing at the text read since the rune at [start].
+*/
+func (this lexerStream) errorFrom(start int, expected []TokenKind, message string) *ParseError {
+
+	offset, length := this.span(start)
+	return &ParseError{
+		Offset:   offset,
+		Length:   length,
+		Expected: expected,
+		Message:  message,
+	}
+}
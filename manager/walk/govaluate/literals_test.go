@@ -0,0 +1,485 @@
+package govaluate
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAddressAndDurationParsing(test *testing.T) {
+
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tokenParsingTests := []TokenParsingTest{
+
+		TokenParsingTest{
+
+			Name:  "Containment in a network",
+			Input: "ip in 10.0.0.0/8",
+			Expected: []ExpressionToken{
+				ExpressionToken{
+					Kind:  VARIABLE,
+					Value: "ip",
+				},
+				ExpressionToken{
+					Kind:  COMPARATOR,
+					Value: "in",
+				},
+				ExpressionToken{
+					Kind:  CIDR,
+					Value: network,
+				},
+			},
+		},
+		TokenParsingTest{
+
+			Name:  "IPs",
+			Input: "fe80::1 != ::1 && 10.0.0.1 > 0.0.0.0",
+			Expected: []ExpressionToken{
+				ExpressionToken{
+					Kind:  IP,
+					Value: net.ParseIP("fe80::1"),
+				},
+				ExpressionToken{
+					Kind:  COMPARATOR,
+					Value: "!=",
+				},
+				ExpressionToken{
+					Kind:  IP,
+					Value: net.ParseIP("::1"),
+				},
+				ExpressionToken{
+					Kind:  LOGICALOP,
+					Value: "&&",
+				},
+				ExpressionToken{
+					Kind:  IP,
+					Value: net.IPv4(10, 0, 0, 1).To4(),
+				},
+				ExpressionToken{
+					Kind:  COMPARATOR,
+					Value: ">",
+				},
+				ExpressionToken{
+					Kind:  IP,
+					Value: net.IPv4zero.To4(),
+				},
+			},
+		},
+		TokenParsingTest{
+
+			Name:  "Durations",
+			Input: "90s + 1h30m - 1.5ms",
+			Expected: []ExpressionToken{
+				ExpressionToken{
+					Kind:  DURATION,
+					Value: 90 * time.Second,
+				},
+				ExpressionToken{
+					Kind:  MODIFIER,
+					Value: "+",
+				},
+				ExpressionToken{
+					Kind:  DURATION,
+					Value: 90 * time.Minute,
+				},
+				ExpressionToken{
+					Kind:  MODIFIER,
+					Value: "-",
+				},
+				ExpressionToken{
+					Kind:  DURATION,
+					Value: 1500 * time.Microsecond,
+				},
+			},
+		},
+		TokenParsingTest{
+
+			Name:  "Ternary which looks like an IPv6 address",
+			Input: "x ? 1:2",
+			Expected: []ExpressionToken{
+				ExpressionToken{
+					Kind:  VARIABLE,
+					Value: "x",
+				},
+				ExpressionToken{
+					Kind:  TERNARY,
+					Value: "?",
+				},
+				ExpressionToken{
+					Kind:  NUMERIC,
+					Value: 1.0,
+				},
+				ExpressionToken{
+					Kind:  TERNARY,
+					Value: ":",
+				},
+				ExpressionToken{
+					Kind:  NUMERIC,
+					Value: 2.0,
+				},
+			},
+		},
+	}
+
+	runTokenParsingTest(tokenParsingTests, test)
+}
+
+func TestAddressAndDurationEvaluation(test *testing.T) {
+
+	_, network, _ := net.ParseCIDR("10.1.2.3/8")
+
+	parameters := []EvaluationParameter{
+		EvaluationParameter{
+			Name:  "ip",
+			Value: net.ParseIP("10.1.2.3"),
+		},
+		EvaluationParameter{
+			Name:  "network",
+			Value: network,
+		},
+		EvaluationParameter{
+			Name:  "sip",
+			Value: "10.1.2.3",
+		},
+		EvaluationParameter{
+			Name:  "snetwork",
+			Value: "10.0.0.0/8",
+		},
+		EvaluationParameter{
+			Name: "peers",
+			Value: []interface{}{
+				map[string]interface{}{"Endpoint": net.ParseIP("192.168.1.1")},
+				map[string]interface{}{"Endpoint": net.ParseIP("fe80::1")},
+			},
+		},
+	}
+
+	evaluationTests := []EvaluationTest{
+
+		EvaluationTest{
+
+			Name:       "IPs in networks",
+			Input:      "10.1.2.3 in 10.0.0.0/8 && ip in 10.0.0.0/8 && !(ip in 10.2.0.0/16) && !(::1 in 0.0.0.0/0)",
+			Parameters: parameters,
+			Expected:   true,
+		},
+		EvaluationTest{
+
+			Name:       "Networks in networks",
+			Input:      "10.1.0.0/16 in network && !(network in 10.1.0.0/16) && fe80::/64 in fe80::/10",
+			Parameters: parameters,
+			Expected:   true,
+		},
+		EvaluationTest{
+
+			Name:       "Equal addresses",
+			Input:      "ip == 10.1.2.3 && ::ffff:10.1.2.3 == ip && network == 10.0.0.0/8 && network != 10.0.0.0/16",
+			Parameters: parameters,
+			Expected:   true,
+		},
+		EvaluationTest{
+
+			Name:       "IN list of IPs",
+			Input:      "ip in [10.0.0.1, 10.1.2.3] && !(ip in (1, 'a'))",
+			Parameters: parameters,
+			Expected:   true,
+		},
+		EvaluationTest{
+
+			Name:       "Ordered IPs",
+			Input:      "10.0.0.2 < 10.0.0.10 && ip >= 10.1.2.3 && 255.255.255.255 < ::",
+			Parameters: parameters,
+			Expected:   true,
+		},
+		EvaluationTest{
+
+			Name:       "Addresses given as strings",
+			Input:      "'10.1.2.3' in 10.0.0.0/8 && sip in network && ip in snetwork && '10.1.0.0/16' in '10.0.0.0/8' && !('fe80::1' in 10.0.0.0/8)",
+			Parameters: parameters,
+			Expected:   true,
+		},
+		EvaluationTest{
+
+			Name:       "IPs within parameters",
+			Input:      "count(peers, p => p.Endpoint in 192.168.0.0/16 || p.Endpoint == fe80::1)",
+			Parameters: parameters,
+			Expected:   2.0,
+		},
+	}
+
+	runEvaluationTests(evaluationTests, test)
+}
+
+/*
+	Durations are always numbers of seconds, and times are used as unix timestamps by arithmetic and comparisons,
+	including those found through accessors, keeping their sub-second precision.
+	Without a schema, times are otherwise left alone, so that functions are still given time.Time.
+*/
+func TestTimeParameters(test *testing.T) {
+
+	schema := TypeSchema{
+		"created": TypeTime,
+		"timeout": TypeDuration,
+		"d":       TypeDuration,
+		"t":       TypeTime,
+		"peer":    TypeAny,
+	}
+
+	created := time.Date(2014, time.January, 2, 0, 0, 0, 0, time.Local)
+	parameters := map[string]interface{}{
+		"created": created.Add(500 * time.Millisecond),
+		"timeout": 45 * time.Second,
+		"d":       90 * time.Second,
+		"t":       created,
+		"peer":    map[string]interface{}{"Handshake": created, "Keepalives": []time.Duration{25 * time.Second}},
+	}
+
+	inputs := map[string]interface{}{
+		"d == 90s && d > 1m && d < 2m":                                  true,
+		"t + 1h - t":                                                    3600.0,
+		"t + 1h > t && t == peer.Handshake":                             true,
+		"created + 1h30m - created":                                     5400.0,
+		"created - peer.Handshake":                                      0.5,
+		"timeout * 2 == 1.5m && timeout > 1s && -timeout < 0s":          true,
+		"peer.Handshake > '2014-01-01' && peer.Keepalives[0] < timeout": true,
+		"all(peer.Keepalives, k => k == 25s)":                           true,
+	}
+
+	for _, schemas := range [][]TypeSchema{nil, []TypeSchema{schema}} {
+		for input, expected := range inputs {
+
+			expression, err := NewEvaluableExpression(input, schemas...)
+			if err != nil {
+				test.Errorf("Failed to parse '%s': %v", input, err)
+				continue
+			}
+
+			compiled, err := expression.Compile()
+			if err != nil {
+				test.Errorf("Failed to compile '%s': %v", input, err)
+				continue
+			}
+
+			for _, evaluate := range []func(map[string]interface{}) (interface{}, error){expression.Evaluate, compiled.Evaluate} {
+
+				result, err := evaluate(parameters)
+				if err != nil || result != expected {
+					test.Errorf("Expected '%s' to be %v with %d schemas, got %v (%v)", input, expected, len(schemas), result, err)
+				}
+			}
+		}
+	}
+
+	var given interface{}
+	functions := map[string]ExpressionFunction{
+		"year": func(arguments ...interface{}) (interface{}, error) {
+			given = arguments[0]
+			return float64(arguments[0].(time.Time).Year()), nil
+		},
+	}
+
+	expression, err := NewEvaluableExpressionWithFunctions("year(peer.Handshake) == year(t)", functions)
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	result, err := expression.Evaluate(parameters)
+	if err != nil || result != true || given != created {
+		test.Errorf("Expected a time to be given to functions as it is without a schema, got %v (%v)", given, err)
+	}
+}
+
+func TestAddressAndDurationFailures(test *testing.T) {
+
+	parsingTests := []ParsingFailureTest{
+
+		ParsingFailureTest{
+
+			Name:     "Unknown unit",
+			Input:    "timeout > 1h30x",
+			Expected: "Unable to parse duration value '1h30x'",
+		},
+		ParsingFailureTest{
+
+			Name:     "Letter which isn't a unit",
+			Input:    "timeout > 1x",
+			Expected: INVALID_TOKEN_TRANSITION,
+		},
+		ParsingFailureTest{
+
+			Name:     "Exponent",
+			Input:    "timeout > 1e5",
+			Expected: INVALID_TOKEN_TRANSITION,
+		},
+		ParsingFailureTest{
+
+			Name:     "Space before unit",
+			Input:    "timeout > 1 s",
+			Expected: INVALID_TOKEN_TRANSITION,
+		},
+		ParsingFailureTest{
+
+			Name:     "Invalid network",
+			Input:    "ip in 10.0.0.0/33",
+			Expected: INVALID_NUMERIC,
+		},
+	}
+
+	runParsingFailureTests(parsingTests, test)
+
+	parameters := map[string]interface{}{
+		"ip": net.ParseIP("10.0.0.1"),
+	}
+
+	evaluationTests := []EvaluationFailureTest{
+
+		EvaluationFailureTest{
+
+			Name:       "Host name in network",
+			Input:      "'vpn.example.com' in 10.0.0.0/8",
+			Parameters: parameters,
+			Expected:   "it is not an IP or a network",
+		},
+		EvaluationFailureTest{
+
+			Name:       "IP compared to number",
+			Input:      "ip > 1",
+			Parameters: parameters,
+			Expected:   INVALID_COMPARATOR_TYPES,
+		},
+		EvaluationFailureTest{
+
+			Name:       "Indexed IP",
+			Input:      "ip[0]",
+			Parameters: parameters,
+			Expected:   "cannot be indexed",
+		},
+	}
+
+	runEvaluationFailureTests(evaluationTests, test)
+}
+
+func TestAddressAndDurationTypes(test *testing.T) {
+
+	schema := TypeSchema{
+		"ip":      TypeIP,
+		"subnet":  TypeCIDR,
+		"created": TypeTime,
+		"timeout": TypeDuration,
+		"port":    TypeNumber,
+		"name":    TypeString,
+	}
+
+	testCases := []StaticTypeTest{
+
+		StaticTypeTest{
+			Name:     "Containment",
+			Input:    "ip in 10.0.0.0/8 || subnet in 10.0.0.0/8 || ip in [10.0.0.1]",
+			Expected: TypeBool,
+		},
+		StaticTypeTest{
+			Name:     "Network literal",
+			Input:    "10.0.0.0/8",
+			Expected: TypeCIDR,
+		},
+		StaticTypeTest{
+			Name:     "Time plus duration",
+			Input:    "created + timeout * 2 - 1h",
+			Expected: TypeTime,
+		},
+		StaticTypeTest{
+			Name:     "Difference of times",
+			Input:    "created - created",
+			Expected: TypeDuration,
+		},
+		StaticTypeTest{
+			Name:     "Ratio of durations",
+			Input:    "timeout / 1s",
+			Expected: TypeNumber,
+		},
+		StaticTypeTest{
+			Name:     "Ordered addresses and durations",
+			Input:    "ip > 10.0.0.1 && -timeout < 1m",
+			Expected: TypeBool,
+		},
+		StaticTypeTest{
+			Name:     "String in network",
+			Input:    "name in subnet && name in 10.0.0.0/8",
+			Expected: TypeBool,
+		},
+		StaticTypeTest{
+			Name:          "Number in network",
+			Input:         "port in subnet",
+			ExpectedError: "comparator 'in' on a network, it is not an IP, a network or a string",
+			Position:      1,
+		},
+		StaticTypeTest{
+			Name:          "Time plus number",
+			Input:         "created + port",
+			ExpectedError: "Value of type time cannot be used with the modifier '+'",
+			Position:      1,
+		},
+		StaticTypeTest{
+			Name:          "Duration compared to number",
+			Input:         "timeout > 5",
+			ExpectedError: "Values of type duration and number cannot be compared",
+			Position:      1,
+		},
+		StaticTypeTest{
+			Name:          "Ordered networks",
+			Input:         "subnet > 10.0.0.0/8",
+			ExpectedError: "Values of type cidr and cidr cannot be compared",
+			Position:      1,
+		},
+	}
+
+	runStaticTypeTests(testCases, schema, test)
+}
+
+func TestAddressAndDurationSyntaxTrees(test *testing.T) {
+
+	syntaxTreeTests := []SyntaxTreeTest{
+
+		SyntaxTreeTest{
+			Name:     "Printing",
+			Input:    "ip in 10.1.2.3/8 && x > 90m && [fe80::0:1, ::ffff:10.0.0.1] != [(::1)]",
+			Expected: "ip in 10.0.0.0/8 && x > 1h30m0s && [fe80::1, 10.0.0.1] != [(::1)]",
+		},
+		SyntaxTreeTest{
+			Name:      "Folding",
+			Input:     "x > 1h + 30m && 1h > 30m && 10.0.0.1 in 10.0.0.0/8",
+			Transform: Fold,
+			Expected:  "x > 1h0m0s + 30m0s && true && true",
+		},
+	}
+
+	for _, syntaxTreeTest := range syntaxTreeTests {
+
+		expression, err := NewEvaluableExpression(syntaxTreeTest.Input)
+		if err != nil {
+			test.Errorf("Test '%s' failed to parse: %s", syntaxTreeTest.Name, err)
+			continue
+		}
+
+		node, err := expression.AST()
+		if err != nil {
+			test.Errorf("Test '%s' failed to build a syntax tree: %s", syntaxTreeTest.Name, err)
+			continue
+		}
+
+		if syntaxTreeTest.Transform != nil {
+			node = syntaxTreeTest.Transform(node)
+		}
+
+		printed := node.String()
+		if printed != syntaxTreeTest.Expected {
+			test.Errorf("Test '%s' printed '%s', expected '%s'", syntaxTreeTest.Name, printed, syntaxTreeTest.Expected)
+			continue
+		}
+
+		if _, err = NewEvaluableExpression(printed); err != nil {
+			test.Errorf("Test '%s' printed '%s', which doesn't parse: %s", syntaxTreeTest.Name, printed, err)
+		}
+	}
+}
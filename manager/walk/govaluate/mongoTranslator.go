@@ -0,0 +1,339 @@
+package govaluate
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*
+	A QueryTranslator to a MongoDB filter document, such as {"port": {"$gt": 1024}}.
+
+	Parameters are taken to be fields, with accessors as dotted paths into embedded documents.
+	Comparisons between a field and a value use query operators; anything else, such as arithmetic, is written as an
+	aggregation expression under $expr. Functions and bitwise operators are unsupported.
+	Date literals are given as time.Time, which drivers store as dates. Durations are given as numbers of seconds,
+	and IPs and networks as strings.
+*/
+type MongoTranslator struct{}
+
+type mongoField string
+
+type mongoValue struct {
+	value interface{}
+}
+
+type mongoList []interface{}
+
+type mongoExpression struct {
+	expression interface{}
+}
+
+type mongoFilter map[string]interface{}
+
+var mongoComparators = map[OperatorSymbol]string{
+	EQ:  "$eq",
+	NEQ: "$ne",
+	GT:  "$gt",
+	GTE: "$gte",
+	LT:  "$lt",
+	LTE: "$lte",
+}
+
+// the comparator to use when the operands are swapped, as in "1 < port" for "port > 1".
+var mongoMirroredComparators = map[OperatorSymbol]OperatorSymbol{
+	EQ:  EQ,
+	NEQ: NEQ,
+	GT:  LT,
+	GTE: LTE,
+	LT:  GT,
+	LTE: GTE,
+}
+
+var mongoArithmetic = map[OperatorSymbol]string{
+	PLUS:     "$add",
+	MINUS:    "$subtract",
+	MULTIPLY: "$multiply",
+	DIVIDE:   "$divide",
+	MODULUS:  "$mod",
+	EXPONENT: "$pow",
+	COALESCE: "$ifNull",
+}
+
+/*
+	Returns the filter document this expression translates to.
+*/
+func (this MongoTranslator) Translate(expression *EvaluableExpression) (map[string]interface{}, error) {
+
+	result, err := expression.Translate(this)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := toMongoFilter(result)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}(filter), nil
+}
+
+/*
+	Returns a MongoDB filter document equivalent to this expression. See [MongoTranslator].
+*/
+func (this EvaluableExpression) ToMongoQuery() (map[string]interface{}, error) {
+	return MongoTranslator{}.Translate(&this)
+}
+
+func (this MongoTranslator) Literal(value interface{}) (interface{}, error) {
+	return mongoValue{portableLiteral(value)}, nil
+}
+
+func (this MongoTranslator) Variable(path []string) (interface{}, error) {
+	return mongoField(strings.Join(path, ".")), nil
+}
+
+func (this MongoTranslator) List(items []interface{}) (interface{}, error) {
+	return mongoList(items), nil
+}
+
+func (this MongoTranslator) Unary(symbol OperatorSymbol, operand interface{}) (interface{}, error) {
+
+	switch symbol {
+	case INVERT:
+		filter, err := toMongoFilter(operand)
+		if err != nil {
+			return nil, err
+		}
+		return mongoFilter{"$nor": []interface{}{map[string]interface{}(filter)}}, nil
+
+	case NEGATE:
+		value, err := toMongoExpression(operand)
+		if err != nil {
+			return nil, err
+		}
+		return mongoExpression{map[string]interface{}{"$multiply": []interface{}{-1.0, value}}}, nil
+	}
+
+	errorMsg := fmt.Sprintf("Operator '%s' is unsupported in MongoDB filters", operatorText(symbol))
+	return nil, errors.New(errorMsg)
+}
+
+func (this MongoTranslator) Binary(symbol OperatorSymbol, left interface{}, right interface{}) (interface{}, error) {
+
+	switch symbol {
+
+	case AND:
+		return joinMongoFilters("$and", left, right)
+	case OR:
+		return joinMongoFilters("$or", left, right)
+
+	case EQ, NEQ, GT, GTE, LT, LTE:
+
+		if field, ok := left.(mongoField); ok {
+			if value, ok := right.(mongoValue); ok {
+				return mongoFilter{string(field): map[string]interface{}{mongoComparators[symbol]: value.value}}, nil
+			}
+		}
+		if field, ok := right.(mongoField); ok {
+			if value, ok := left.(mongoValue); ok {
+				mirrored := mongoMirroredComparators[symbol]
+				return mongoFilter{string(field): map[string]interface{}{mongoComparators[mirrored]: value.value}}, nil
+			}
+		}
+		return mongoExpressionFilter(mongoComparators[symbol], left, right)
+
+	case REQ, NREQ:
+
+		field, isField := left.(mongoField)
+		value, isValue := right.(mongoValue)
+		if !isField || !isValue {
+			return nil, errors.New("Regular expressions in MongoDB filters must match a parameter against a constant pattern")
+		}
+
+		pattern := fmt.Sprintf("%v", value.value)
+		if compiled, ok := value.value.(*regexp.Regexp); ok {
+			pattern = compiled.String()
+		}
+
+		match := map[string]interface{}{"$regex": pattern}
+		if symbol == NREQ {
+			return mongoFilter{string(field): map[string]interface{}{"$not": match}}, nil
+		}
+		return mongoFilter{string(field): match}, nil
+
+	case IN:
+
+		if field, ok := left.(mongoField); ok {
+			if values, ok := mongoListValues(right); ok {
+				return mongoFilter{string(field): map[string]interface{}{"$in": values}}, nil
+			}
+		}
+		return mongoExpressionFilter("$in", left, right)
+	}
+
+	operator, found := mongoArithmetic[symbol]
+	if !found {
+		errorMsg := fmt.Sprintf("Operator '%s' is unsupported in MongoDB filters", operatorText(symbol))
+		return nil, errors.New(errorMsg)
+	}
+
+	leftExpression, err := toMongoExpression(left)
+	if err != nil {
+		return nil, err
+	}
+
+	rightExpression, err := toMongoExpression(right)
+	if err != nil {
+		return nil, err
+	}
+	return mongoExpression{map[string]interface{}{operator: []interface{}{leftExpression, rightExpression}}}, nil
+}
+
+func (this MongoTranslator) Ternary(condition interface{}, whenTrue interface{}, whenFalse interface{}) (interface{}, error) {
+
+	if whenFalse == nil {
+		return nil, errors.New("Ternary operators without an else part are unsupported in MongoDB filters")
+	}
+
+	var err error
+	operands := []interface{}{condition, whenTrue, whenFalse}
+
+	for i, operand := range operands {
+		operands[i], err = toMongoExpression(operand)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return mongoExpression{map[string]interface{}{"$cond": operands}}, nil
+}
+
+func (this MongoTranslator) Function(name string, arguments []interface{}) (interface{}, error) {
+
+	errorMsg := fmt.Sprintf("Function '%s' is unsupported in MongoDB filters", name)
+	return nil, errors.New(errorMsg)
+}
+
+/*
+	Combines two filters with $and or $or, merging filters which already use the same operator,
+	so that "a && b && c" gives a single $and of three.
+*/
+func joinMongoFilters(operator string, left interface{}, right interface{}) (interface{}, error) {
+
+	var filters []interface{}
+
+	for _, operand := range []interface{}{left, right} {
+
+		filter, err := toMongoFilter(operand)
+		if err != nil {
+			return nil, err
+		}
+
+		if nested, ok := filter[operator]; ok && len(filter) == 1 {
+			filters = append(filters, nested.([]interface{})...)
+			continue
+		}
+		filters = append(filters, map[string]interface{}(filter))
+	}
+	return mongoFilter{operator: filters}, nil
+}
+
+func mongoExpressionFilter(operator string, left interface{}, right interface{}) (interface{}, error) {
+
+	leftExpression, err := toMongoExpression(left)
+	if err != nil {
+		return nil, err
+	}
+
+	rightExpression, err := toMongoExpression(right)
+	if err != nil {
+		return nil, err
+	}
+	return mongoFilter{"$expr": map[string]interface{}{operator: []interface{}{leftExpression, rightExpression}}}, nil
+}
+
+/*
+	Returns the [translated] operand as a filter document.
+	Values which aren't comparisons, such as a bool parameter on its own, must be true to match.
+*/
+func toMongoFilter(translated interface{}) (mongoFilter, error) {
+
+	switch operand := translated.(type) {
+	case mongoFilter:
+		return operand, nil
+	case mongoField:
+		return mongoFilter{string(operand): true}, nil
+	}
+
+	expression, err := toMongoExpression(translated)
+	if err != nil {
+		return nil, err
+	}
+	return mongoFilter{"$expr": expression}, nil
+}
+
+/*
+	Returns the [translated] operand as an aggregation expression, where fields are written as "$name".
+*/
+func toMongoExpression(translated interface{}) (interface{}, error) {
+
+	switch operand := translated.(type) {
+
+	case mongoField:
+		return "$" + string(operand), nil
+
+	case mongoValue:
+
+		// strings starting with "$" would otherwise be taken as fields.
+		if str, ok := operand.value.(string); ok && strings.HasPrefix(str, "$") {
+			return map[string]interface{}{"$literal": str}, nil
+		}
+		if pattern, ok := operand.value.(*regexp.Regexp); ok {
+			return pattern.String(), nil
+		}
+		return operand.value, nil
+
+	case mongoList:
+		ret := make([]interface{}, len(operand))
+		for i, item := range operand {
+
+			expression, err := toMongoExpression(item)
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = expression
+		}
+		return ret, nil
+
+	case mongoExpression:
+		return operand.expression, nil
+	}
+
+	return nil, errors.New("Comparisons and logical operators can only be used as conditions in MongoDB filters")
+}
+
+/*
+	Returns the values of the list, if every item of it is a constant. A single constant, as in "x IN (1)", is a list of one.
+*/
+func mongoListValues(translated interface{}) ([]interface{}, bool) {
+
+	if value, ok := translated.(mongoValue); ok {
+		return []interface{}{value.value}, true
+	}
+
+	list, ok := translated.(mongoList)
+	if !ok {
+		return nil, false
+	}
+
+	ret := make([]interface{}, len(list))
+	for i, item := range list {
+
+		value, ok := item.(mongoValue)
+		if !ok {
+			return nil, false
+		}
+		ret[i] = value.value
+	}
+	return ret, true
+}
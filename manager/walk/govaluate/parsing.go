@@ -2,10 +2,11 @@ package govaluate
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
+	"net"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 	"unicode"
 )
@@ -63,11 +64,13 @@ func readToken(stream *lexerStream, state lexerState, functions map[string]Expre
 	var found bool
 	var completed bool
 	var err error
+	var start int
 
-	// numeric is 0-9, or .
+	// numeric is 0-9, or ., and is a duration if a unit follows it
+	// IPs and networks are read whole, as their periods and colons mean something else elsewhere
 	// string starts with '
 	// variable is alphanumeric, always starts with a letter
-	// bracket always means variable
+	// bracket after a value means an index, otherwise an array if it holds one, otherwise a variable
 	// symbols are anything non-alphanumeric
 	// all others read into a buffer until they reach the end of the stream
 	for stream.canRead() {
@@ -79,16 +82,71 @@ func readToken(stream *lexerStream, state lexerState, functions map[string]Expre
 		}
 
 		kind = UNKNOWN
+		start = stream.position - 1
+
+		// members of a value which isn't a parameter, as in "peers[0].Endpoint.Port" or "(a ?? b).Port",
+		// which can only follow a closing paren, bracket or brace.
+		if character == '.' && state.canTransitionTo(MEMBER) && stream.canRead() && unicode.IsLetter(stream.source[stream.position]) {
+
+			var members []string
+			for {
+				member, _ := readUntilFalse(stream, false, true, true, isVariableName)
+				if len(member) == 0 {
+					errorMsg := fmt.Sprintf("Empty member name in '.%s.'", strings.Join(members, "."))
+					return ExpressionToken{}, stream.errorFrom(start, nil, errorMsg), false
+				}
+				members = append(members, member)
+
+				if !isAccessorPeriod(stream) {
+					break
+				}
+				stream.readCharacter()
+			}
+
+			tokenValue = members
+			kind = MEMBER
+			break
+		}
+
+		// IP or network, as in "10.0.0.1", "10.0.0.0/8" or "fe80::/10"
+		if isAddressCharacter(character) {
+
+			tokenValue, found = readAddress(stream, start)
+			if found {
+
+				kind = IP
+				if _, found = tokenValue.(*net.IPNet); found {
+					kind = CIDR
+				}
+				break
+			}
+		}
 
 		// numeric constant
 		if isNumeric(character) {
 
 			tokenString = readTokenUntilFalse(stream, isNumeric)
+
+			// duration, as in "90s" or "1h30m"
+			if isDurationUnit(stream, tokenString) {
+
+				stream.position = start
+				tokenString, _ = readUntilFalse(stream, false, true, false, isDurationCharacter)
+				tokenValue, err = time.ParseDuration(tokenString)
+
+				if err != nil {
+					errorMsg := fmt.Sprintf("Unable to parse duration value '%v'", tokenString)
+					return ExpressionToken{}, stream.errorFrom(start, nil, errorMsg), false
+				}
+				kind = DURATION
+				break
+			}
+
 			tokenValue, err = strconv.ParseFloat(tokenString, 64)
 
 			if err != nil {
-				errorMsg := fmt.Sprintf("Unable to parse numeric value '%v' to float64\n", tokenString)
-				return ExpressionToken{}, errors.New(errorMsg), false
+				errorMsg := fmt.Sprintf("Unable to parse numeric value '%v' to float64", tokenString)
+				return ExpressionToken{}, stream.errorFrom(start, nil, errorMsg), false
 			}
 			kind = NUMERIC
 			break
@@ -102,6 +160,22 @@ func readToken(stream *lexerStream, state lexerState, functions map[string]Expre
 			break
 		}
 
+		// index, as in "peers[0]", which can only follow a value
+		if character == '[' && state.canTransitionTo(INDEX_OPEN) {
+
+			tokenValue = character
+			kind = INDEX_OPEN
+			break
+		}
+
+		// array literal, as in "[1, 2, 3]"
+		if character == '[' && isArrayLiteral(stream) {
+
+			tokenValue = character
+			kind = ARRAY_OPEN
+			break
+		}
+
 		// escaped variable
 		if character == '[' {
 
@@ -109,7 +183,7 @@ func readToken(stream *lexerStream, state lexerState, functions map[string]Expre
 			kind = VARIABLE
 
 			if !completed {
-				return ExpressionToken{}, errors.New("Unclosed parameter bracket"), false
+				return ExpressionToken{}, stream.errorFrom(start, []TokenKind{VARIABLE}, "Unclosed parameter bracket"), false
 			}
 
 			// above method normally rewinds us to the closing bracket, which we want to skip.
@@ -152,6 +226,40 @@ func readToken(stream *lexerStream, state lexerState, functions map[string]Expre
 			if found {
 				kind = FUNCTION
 				tokenValue = function
+				break
+			}
+
+			// quantifier? Only when it's called, so that "count" can still be a parameter.
+			_, found = quantifierSymbols[tokenString]
+			if found && kind == VARIABLE && peekCharacter(stream) == '(' {
+				kind = QUANTIFIER
+				break
+			}
+
+			// binding of a quantifier, as in "p => p.Port > 1024"?
+			if kind == VARIABLE && readLambdaArrow(stream) {
+				kind = LAMBDA
+				break
+			}
+
+			// accessor? e.g. "peer.Endpoint.Port" reads member "Endpoint", then "Port", from parameter "peer".
+			// Escaped periods, as in "peer\.Endpoint", are part of the name instead.
+			if isAccessorPeriod(stream) {
+
+				splits := []string{tokenString}
+				for isAccessorPeriod(stream) {
+
+					stream.readCharacter()
+					member, _ := readUntilFalse(stream, false, true, true, isVariableName)
+					if len(member) == 0 {
+						errorMsg := fmt.Sprintf("Empty member name in accessor '%s.'", strings.Join(splits, "."))
+						return ExpressionToken{}, stream.errorFrom(start, nil, errorMsg), false
+					}
+					splits = append(splits, member)
+				}
+
+				kind = ACCESSOR
+				tokenValue = splits
 			}
 			break
 		}
@@ -160,7 +268,7 @@ func readToken(stream *lexerStream, state lexerState, functions map[string]Expre
 			tokenValue, completed = readUntilFalse(stream, true, false, true, isNotQuote)
 
 			if !completed {
-				return ExpressionToken{}, errors.New("Unclosed string literal"), false
+				return ExpressionToken{}, stream.errorFrom(start, []TokenKind{STRING}, "Unclosed string literal"), false
 			}
 
 			// advance the stream one position, since reading until false assumes the terminator is a real token
@@ -189,6 +297,24 @@ func readToken(stream *lexerStream, state lexerState, functions map[string]Expre
 			break
 		}
 
+		if character == ']' {
+			tokenValue = character
+			kind = BRACKET_CLOSE
+			break
+		}
+
+		if character == '{' {
+			tokenValue = character
+			kind = MAP_OPEN
+			break
+		}
+
+		if character == '}' {
+			tokenValue = character
+			kind = MAP_CLOSE
+			break
+		}
+
 		// must be a known symbol
 		tokenString = readTokenUntilFalse(stream, isNotAlphanumeric)
 		tokenValue = tokenString
@@ -232,11 +358,12 @@ func readToken(stream *lexerStream, state lexerState, functions map[string]Expre
 		}
 
 		errorMessage := fmt.Sprintf("Invalid token: '%s'", tokenString)
-		return ret, errors.New(errorMessage), false
+		return ret, stream.errorFrom(start, state.validNextKinds, errorMessage), false
 	}
 
 	ret.Kind = kind
 	ret.Value = tokenValue
+	ret.Offset, ret.Length = stream.span(start)
 
 	return ret, nil, (kind != UNKNOWN)
 }
@@ -328,7 +455,7 @@ func optimizeTokens(tokens []ExpressionToken) ([]ExpressionToken, error) {
 			token.Value, err = regexp.Compile(token.Value.(string))
 
 			if err != nil {
-				return tokens, err
+				return tokens, newParseError(token, nil, err.Error())
 			}
 
 			tokens[index] = token
@@ -338,12 +465,14 @@ func optimizeTokens(tokens []ExpressionToken) ([]ExpressionToken, error) {
 }
 
 /*
-	Checks the balance of tokens which have multiple parts, such as parenthesis.
+	Checks the balance of tokens which have multiple parts, such as parenthesis, brackets and braces.
 */
 func checkBalance(tokens []ExpressionToken) error {
 
 	var stream *tokenStream
 	var token ExpressionToken
+	var open []ExpressionToken
+	var unopened []ExpressionToken
 	var parens int
 
 	stream = newTokenStream(tokens)
@@ -351,22 +480,77 @@ func checkBalance(tokens []ExpressionToken) error {
 	for stream.hasNext() {
 
 		token = stream.next()
-		if token.Kind == CLAUSE {
+
+		switch token.Kind {
+
+		case CLAUSE:
 			parens++
-			continue
-		}
-		if token.Kind == CLAUSE_CLOSE {
+			open = append(open, token)
+
+		case ARRAY_OPEN, INDEX_OPEN, MAP_OPEN:
+			open = append(open, token)
+
+		case CLAUSE_CLOSE:
 			parens--
-			continue
+			if len(open) > 0 && open[len(open)-1].Kind == CLAUSE {
+				open = open[:len(open)-1]
+			} else if len(open) > 0 {
+				return unbalancedError(open[len(open)-1])
+			} else {
+				unopened = append(unopened, token)
+			}
+
+		case BRACKET_CLOSE, MAP_CLOSE:
+			if len(open) == 0 || closingKind(open[len(open)-1].Kind) != token.Kind {
+				return newParseError(token, nil, "Unbalanced "+closingName(token.Kind))
+			}
+			open = open[:len(open)-1]
 		}
 	}
 
-	if parens != 0 {
-		return errors.New("Unbalanced parenthesis")
+	// point out the outermost parenthesis which isn't matched.
+	if parens > 0 {
+		return unbalancedError(open[0])
+	}
+	for _, token = range open {
+		if token.Kind != CLAUSE {
+			return unbalancedError(token)
+		}
+	}
+	if parens < 0 {
+		return newParseError(unopened[0], nil, "Unbalanced parenthesis")
 	}
 	return nil
 }
 
+func unbalancedError(open ExpressionToken) error {
+
+	closing := closingKind(open.Kind)
+	return newParseError(open, []TokenKind{closing}, "Unbalanced "+closingName(closing))
+}
+
+func closingKind(kind TokenKind) TokenKind {
+
+	switch kind {
+	case ARRAY_OPEN, INDEX_OPEN:
+		return BRACKET_CLOSE
+	case MAP_OPEN:
+		return MAP_CLOSE
+	}
+	return CLAUSE_CLOSE
+}
+
+func closingName(kind TokenKind) string {
+
+	switch kind {
+	case BRACKET_CLOSE:
+		return "bracket"
+	case MAP_CLOSE:
+		return "brace"
+	}
+	return "parenthesis"
+}
+
 func isNumeric(character rune) bool {
 
 	return unicode.IsDigit(character) || character == '.'
@@ -383,6 +567,10 @@ func isNotAlphanumeric(character rune) bool {
 		unicode.IsLetter(character) ||
 		character == '(' ||
 		character == ')' ||
+		character == '[' ||
+		character == ']' ||
+		character == '{' ||
+		character == '}' ||
 		!isNotQuote(character))
 }
 
@@ -393,11 +581,138 @@ func isVariableName(character rune) bool {
 		character == '_'
 }
 
+/*
+	Whether the stream is at a period directly following a variable name, which makes it an accessor.
+	Reading a variable name consumes any whitespace after it, so a preceding space means the period isn't part of it.
+*/
+func isAccessorPeriod(stream *lexerStream) bool {
+
+	return stream.canRead() &&
+		stream.source[stream.position] == '.' &&
+		!unicode.IsSpace(stream.source[stream.position-1])
+}
+
 func isNotClosingBracket(character rune) bool {
 
 	return character != ']'
 }
 
+// the characters which start an array literal, rather than an escaped variable, right after an opening bracket.
+const arrayLiteralStarts = "]'\"0123456789.-!~([{"
+
+/*
+	Whether the bracket just read opens an array literal rather than an escaped variable:
+	it's empty, or starts like a value which isn't a name, as in "[1]" or "['a']", or holds a comma, as in "[a, b]".
+	So "[a]" is still the parameter a; the array of just it is written "[(a)]".
+*/
+func isArrayLiteral(stream *lexerStream) bool {
+
+	position := stream.position
+	for position < stream.length && unicode.IsSpace(stream.source[position]) {
+		position++
+	}
+
+	if position < stream.length && strings.ContainsRune(arrayLiteralStarts, stream.source[position]) {
+		return true
+	}
+
+	for ; position < stream.length; position++ {
+
+		switch stream.source[position] {
+		case '\\':
+			position++
+		case ',':
+			return true
+		case ']':
+			return false
+		}
+	}
+	return false
+}
+
+/*
+	Returns the next character which isn't whitespace, without reading it, or zero at the end of the stream.
+*/
+func peekCharacter(stream *lexerStream) rune {
+
+	for position := stream.position; position < stream.length; position++ {
+		if !unicode.IsSpace(stream.source[position]) {
+			return stream.source[position]
+		}
+	}
+	return 0
+}
+
+/*
+	Reads a "=>" following a variable name, if there is one, which makes the name the binding of a quantifier.
+*/
+func readLambdaArrow(stream *lexerStream) bool {
+
+	position := stream.position
+	for position < stream.length && unicode.IsSpace(stream.source[position]) {
+		position++
+	}
+
+	if position+1 < stream.length && stream.source[position] == '=' && stream.source[position+1] == '>' {
+		stream.position = position + 2
+		return true
+	}
+	return false
+}
+
+/*
+	Whether a unit directly follows the [number] just read, which makes it a duration, as in "90s" or "1h30m".
+	Anything else, such as the period of ".Port" or the exponent of "1e5", is left to be read as it would be otherwise.
+*/
+func isDurationUnit(stream *lexerStream, number string) bool {
+
+	if !strings.ContainsAny(number, "0123456789") || !stream.canRead() || unicode.IsSpace(stream.source[stream.position-1]) {
+		return false
+	}
+
+	end := stream.position
+	for end < stream.length && unicode.IsLetter(stream.source[end]) {
+		end++
+	}
+
+	switch string(stream.source[stream.position:end]) {
+	case "ns", "us", "µs", "ms", "s", "m", "h":
+		return true
+	}
+	return false
+}
+
+func isDurationCharacter(character rune) bool {
+
+	return isVariableName(character) || character == '.'
+}
+
+func isAddressCharacter(character rune) bool {
+
+	return unicode.Is(unicode.ASCII_Hex_Digit, character) ||
+		character == '.' ||
+		character == ':' ||
+		character == '/'
+}
+
+/*
+	Reads an IP or a network starting at [start], if the characters from there are one.
+	Otherwise, the stream is left where it was.
+*/
+func readAddress(stream *lexerStream, start int) (interface{}, bool) {
+
+	end := start
+	for end < stream.length && isAddressCharacter(stream.source[end]) {
+		end++
+	}
+
+	address, found := tryParseAddress(string(stream.source[start:end]))
+	if found {
+		stream.position = end
+	}
+	return address, found
+}
+
 /*
 	Attempts to parse the [candidate] as a Time.
 	Tries a series of standardized date formats, returns the Time if one applies,
@@ -2,6 +2,7 @@ package govaluate
 
 import (
 	"fmt"
+	"reflect"
 	"regexp/syntax"
 	"strings"
 	"testing"
@@ -160,7 +161,7 @@ func TestParsingFailure(test *testing.T) {
 		ParsingFailureTest{
 
 			Name:     "Multiple radix",
-			Input:    "127.0.0.1",
+			Input:    "1.2.3",
 			Expected: INVALID_NUMERIC,
 		},
 		ParsingFailureTest{
@@ -174,6 +175,176 @@ func TestParsingFailure(test *testing.T) {
 	runParsingFailureTests(parsingTests, test)
 }
 
+/*
+	Represents a test for where parsing failures are reported
+*/
+type ParsingFailurePositionTest struct {
+	Name     string
+	Input    string
+	Offset   int
+	Length   int
+	Expected []TokenKind
+	Snippet  string
+}
+
+func TestParsingFailurePositions(test *testing.T) {
+
+	parsingTests := []ParsingFailurePositionTest{
+
+		ParsingFailurePositionTest{
+
+			Name:     "Invalid token",
+			Input:    "foo @ 1",
+			Offset:   4,
+			Length:   1,
+			Expected: []TokenKind{MODIFIER, COMPARATOR, LOGICALOP, CLAUSE_CLOSE, TERNARY, SEPARATOR, INDEX_OPEN, BRACKET_CLOSE, MAP_CLOSE},
+			Snippet:  "foo @ 1\n    ^",
+		},
+		ParsingFailurePositionTest{
+
+			Name:     "Invalid transition",
+			Input:    "foo > >= 10",
+			Offset:   6,
+			Length:   2,
+			Expected: nextKindsAfter(COMPARATOR),
+			Snippet:  "foo > >= 10\n      ^~",
+		},
+		ParsingFailurePositionTest{
+
+			Name:     "Premature end",
+			Input:    "10 > 5 +  ",
+			Offset:   8,
+			Length:   0,
+			Expected: nextKindsAfter(MODIFIER),
+			Snippet:  "10 > 5 +  \n        ^",
+		},
+		ParsingFailurePositionTest{
+
+			Name:     "Unclosed parenthesis",
+			Input:    "(1 + (2 * 3)",
+			Offset:   0,
+			Length:   1,
+			Expected: []TokenKind{CLAUSE_CLOSE},
+			Snippet:  "(1 + (2 * 3)\n^",
+		},
+		ParsingFailurePositionTest{
+
+			Name:    "Unopened parenthesis",
+			Input:   "1 + 2) * 3)",
+			Offset:  5,
+			Length:  1,
+			Snippet: "1 + 2) * 3)\n     ^",
+		},
+		ParsingFailurePositionTest{
+
+			Name:     "Unclosed quote",
+			Input:    "foo == 'bär",
+			Offset:   7,
+			Length:   5,
+			Expected: []TokenKind{STRING},
+			Snippet:  "foo == 'bär\n       ^~~~",
+		},
+		ParsingFailurePositionTest{
+
+			Name:    "Constant regex pattern",
+			Input:   "foo =~ '[abc'",
+			Offset:  7,
+			Length:  6,
+			Snippet: "foo =~ '[abc'\n       ^~~~~~",
+		},
+		ParsingFailurePositionTest{
+
+			Name:     "Undefined function",
+			Input:    "1 > 0 &&\n\tfoobar()",
+			Offset:   10,
+			Length:   6,
+			Expected: []TokenKind{FUNCTION},
+			Snippet:  "\tfoobar()\n\t^~~~~~",
+		},
+		ParsingFailurePositionTest{
+
+			Name:     "Empty index",
+			Input:    "x[] > 1",
+			Offset:   2,
+			Length:   1,
+			Expected: nextKindsAfter(INDEX_OPEN),
+			Snippet:  "x[] > 1\n  ^",
+		},
+		ParsingFailurePositionTest{
+
+			Name:     "Unclosed index",
+			Input:    "x[0 > 1",
+			Offset:   1,
+			Length:   1,
+			Expected: []TokenKind{BRACKET_CLOSE},
+			Snippet:  "x[0 > 1\n ^",
+		},
+		ParsingFailurePositionTest{
+
+			Name:    "Unopened bracket",
+			Input:   "[1, 2]] == x",
+			Offset:  6,
+			Length:  1,
+			Snippet: "[1, 2]] == x\n      ^",
+		},
+		ParsingFailurePositionTest{
+
+			Name:     "Map key without colon",
+			Input:    "{'a' 1}",
+			Offset:   5,
+			Length:   1,
+			Expected: nextKindsAfter(STRING),
+			Snippet:  "{'a' 1}\n     ^",
+		},
+		ParsingFailurePositionTest{
+
+			Name:     "Map without key",
+			Input:    "{: 1}",
+			Offset:   1,
+			Length:   1,
+			Expected: nextKindsAfter(MAP_OPEN),
+			Snippet:  "{: 1}\n ^",
+		},
+	}
+
+	for _, testCase := range parsingTests {
+
+		_, err := NewEvaluableExpression(testCase.Input)
+
+		parseError, ok := err.(*ParseError)
+		if !ok {
+			test.Logf("Test '%s' failed", testCase.Name)
+			test.Logf("Expected a parse error, got '%v'", err)
+			test.Fail()
+			continue
+		}
+
+		if parseError.Offset != testCase.Offset || parseError.Length != testCase.Length {
+			test.Logf("Test '%s' failed", testCase.Name)
+			test.Logf("Expected error at %d+%d, found %d+%d", testCase.Offset, testCase.Length, parseError.Offset, parseError.Length)
+			test.Fail()
+		}
+
+		if !reflect.DeepEqual(parseError.Expected, testCase.Expected) {
+			test.Logf("Test '%s' failed", testCase.Name)
+			test.Logf("Expected token kinds %v, found %v", testCase.Expected, parseError.Expected)
+			test.Fail()
+		}
+
+		if parseError.Snippet() != testCase.Snippet {
+			test.Logf("Test '%s' failed", testCase.Name)
+			test.Logf("Expected snippet:\n%s\nFound:\n%s", testCase.Snippet, parseError.Snippet())
+			test.Fail()
+		}
+	}
+}
+
+func nextKindsAfter(kind TokenKind) []TokenKind {
+
+	state, _ := getLexerStateForToken(kind)
+	return state.validNextKinds
+}
+
 func runParsingFailureTests(parsingTests []ParsingFailureTest, test *testing.T) {
 
 	var err error
@@ -1467,6 +1467,30 @@ func stripUnquotedWhitespace(expression string) string {
 	return expressionBuffer.String()
 }
 
+func TestTokenPositions(test *testing.T) {
+
+	input := "ƒoo == 'bär' && [a b] > 1 &&\n\tx.Y <= (-2)"
+	expected := []string{"ƒoo", "==", "'bär'", "&&", "[a b]", ">", "1", "&&", "x.Y", "<=", "(", "-", "2", ")"}
+
+	expression, err := NewEvaluableExpression(input)
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	tokens := expression.Tokens()
+	if len(tokens) != len(expected) {
+		test.Fatalf("Expected %d tokens, found %d", len(expected), len(tokens))
+	}
+
+	for i, token := range tokens {
+
+		actual := input[token.Offset : token.Offset+token.Length]
+		if actual != expected[i] {
+			test.Errorf("Token %d (%v) is at '%s', expected '%s'", i, token.Kind, actual, expected[i])
+		}
+	}
+}
+
 func runTokenParsingTest(tokenParsingTests []TokenParsingTest, test *testing.T) {
 
 	var expression *EvaluableExpression
@@ -1525,7 +1549,7 @@ func runTokenParsingTest(tokenParsingTests []TokenParsingTest, test *testing.T)
 			if expectedToken.Value == nil || reflect.TypeOf(expectedToken.Value).Kind() == reflect.Func {
 				continue
 			}
-			if actualToken.Value != expectedToken.Value {
+			if !reflect.DeepEqual(actualToken.Value, expectedToken.Value) {
 
 				test.Logf("Test '%s' failed:", parsingTest.Name)
 				test.Logf("Expected token value '%v' does not match '%v'", expectedToken.Value, actualToken.Value)
@@ -1533,6 +1557,8 @@ func runTokenParsingTest(tokenParsingTests []TokenParsingTest, test *testing.T)
 				continue
 			}
 		}
+
+		checkSyntaxTreeRoundTrip(expression, parsingTest.Name, parsingTest.Functions, test)
 	}
 }
 
@@ -0,0 +1,87 @@
+package govaluate
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+/*
+	The parameters of the condition of a quantifier, where [name] is the item being tested,
+	and every other parameter is looked up as usual.
+*/
+type boundParameters struct {
+	name   string
+	value  interface{}
+	parent Parameters
+}
+
+func (this boundParameters) Get(name string) (interface{}, error) {
+
+	if name == this.name {
+		return this.value, nil
+	}
+
+	if this.parent == nil {
+		return nil, errors.New("No parameter '" + name + "' found.")
+	}
+	return this.parent.Get(name)
+}
+
+func (this boundParameters) convertsTimes() bool {
+	return convertsTimes(this.parent)
+}
+
+/*
+	Evaluates the condition of an ANY, ALL or COUNT [stage] for every item of [list]. ANY and ALL stop at the first item which decides them.
+	Conditions are evaluated by the tree evaluator, even for compiled expressions.
+*/
+func (this EvaluableExpression) evaluateQuantifier(stage *evaluationStage, list interface{}, parameters Parameters, sandbox *sandbox) (interface{}, error) {
+
+	items, ok := arrayItems(list, convertsTimes(parameters))
+	if !ok {
+		errorMsg := fmt.Sprintf(stage.typeErrorFormat, list, stage.symbol.String())
+		return nil, errors.New(errorMsg)
+	}
+
+	if sandbox != nil {
+		if err := sandbox.step(); err != nil {
+			return nil, err
+		}
+	}
+
+	count := 0.0
+
+	for _, item := range items {
+
+		bound := boundParameters{name: stage.binding, value: plainValue(reflect.ValueOf(item), convertsTimes(parameters)), parent: parameters}
+
+		result, err := this.evaluateStage(stage.rightStage, bound, sandbox)
+		if err != nil {
+			return nil, err
+		}
+
+		matched, ok := result.(bool)
+		if !ok {
+			errorMsg := fmt.Sprintf("Value '%v' cannot be used as the condition of '%v', it is not a bool", result, stage.symbol.String())
+			return nil, errors.New(errorMsg)
+		}
+
+		switch {
+		case stage.symbol == ANY && matched:
+			return true, nil
+		case stage.symbol == ALL && !matched:
+			return false, nil
+		case matched:
+			count++
+		}
+	}
+
+	switch stage.symbol {
+	case ANY:
+		return false, nil
+	case ALL:
+		return true, nil
+	}
+	return count, nil
+}
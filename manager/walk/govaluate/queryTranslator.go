@@ -0,0 +1,196 @@
+package govaluate
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+/*
+	Translates an expression into another language, such as a query for a database.
+
+	An expression is translated from the bottom up: every method is given the translations of the operands,
+	in the order they appear in the expression, and returns its own translation.
+	[EvaluableExpression.Translate] returns the translation of the whole expression.
+*/
+type QueryTranslator interface {
+
+	// a number, string, bool, time.Time (for date literals), time.Duration (for duration literals),
+	// net.IP or *net.IPNet (for IP and network literals), or *regexp.Regexp (for constant patterns).
+	Literal(value interface{}) (interface{}, error)
+
+	// a parameter, by the names in its path: one for a parameter, and more for accessors, as in ["peer", "Endpoint", "Host"].
+	// Names are given as written, so an escaped parameter such as "[a\.b]" is the single name "a.b".
+	Variable(path []string) (interface{}, error)
+
+	// a list of values, as in "foo IN (1, 2, 3)".
+	List(items []interface{}) (interface{}, error)
+
+	// a prefix: NEGATE, INVERT or BITWISE_NOT.
+	Unary(symbol OperatorSymbol, operand interface{}) (interface{}, error)
+
+	// any operator with two operands, including COALESCE.
+	Binary(symbol OperatorSymbol, left interface{}, right interface{}) (interface{}, error)
+
+	// "condition ? whenTrue : whenFalse". [whenFalse] is nil if there is no else part.
+	Ternary(condition interface{}, whenTrue interface{}, whenFalse interface{}) (interface{}, error)
+
+	Function(name string, arguments []interface{}) (interface{}, error)
+}
+
+/*
+	Translates this expression with the given [translator], returning the translation of the whole expression.
+	Constants are folded first, as they are for evaluation.
+*/
+func (this EvaluableExpression) Translate(translator QueryTranslator) (interface{}, error) {
+
+	if this.evaluationStages == nil {
+		return nil, errors.New("Cannot translate an empty expression")
+	}
+
+	root, err := this.buildNode(this.evaluationStages)
+	if err != nil {
+		return nil, err
+	}
+	return translateNode(root, translator)
+}
+
+func translateNode(node Node, translator QueryTranslator) (interface{}, error) {
+
+	switch typed := node.(type) {
+
+	case *LiteralNode:
+		return translator.Literal(typed.Value)
+
+	case *VariableNode:
+		return translator.Variable(typed.Path)
+
+	case *ArrayNode:
+		items, err := translateNodes(typed.Items, translator)
+		if err != nil {
+			return nil, err
+		}
+		return translator.List(items)
+
+	case *UnaryNode:
+		operand, err := translateNode(typed.Operand, translator)
+		if err != nil {
+			return nil, err
+		}
+		return translator.Unary(typed.Operator, operand)
+
+	case *BinaryNode:
+		// containment in a network has no equivalent in the languages translated to.
+		if literal, ok := typed.Right.(*LiteralNode); ok && typed.Operator == IN && isNetwork(literal.Value) {
+			errorMsg := fmt.Sprintf("Cannot translate containment in the network '%v'", literal.Value)
+			return nil, errors.New(errorMsg)
+		}
+
+		left, err := translateNode(typed.Left, translator)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := translateNode(typed.Right, translator)
+		if err != nil {
+			return nil, err
+		}
+		return translator.Binary(typed.Operator, left, right)
+
+	case *TernaryNode:
+		operands, err := translateNodes(children(typed), translator)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(operands) < 3 {
+			return translator.Ternary(operands[0], operands[1], nil)
+		}
+		return translator.Ternary(operands[0], operands[1], operands[2])
+
+	case *FunctionNode:
+		arguments, err := translateNodes(typed.Arguments, translator)
+		if err != nil {
+			return nil, err
+		}
+		return translator.Function(typed.Name, arguments)
+	}
+
+	errorMsg := fmt.Sprintf("Cannot translate node '%v'", node)
+	return nil, errors.New(errorMsg)
+}
+
+/*
+	Returns durations as numbers of seconds, as they're evaluated, and IPs and networks as text,
+	for languages which have no types of their own for them.
+*/
+func portableLiteral(value interface{}) interface{} {
+
+	switch typed := value.(type) {
+	case time.Duration:
+		return typed.Seconds()
+	case net.IP, *net.IPNet:
+		return fmt.Sprintf("%v", typed)
+	}
+	return value
+}
+
+func translateNodes(nodes []Node, translator QueryTranslator) ([]interface{}, error) {
+
+	var ret []interface{}
+
+	for _, node := range nodes {
+
+		translated, err := translateNode(node, translator)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, translated)
+	}
+	return ret, nil
+}
+
+/*
+	Returns how loosely the operator binds, as it's parsed by the stage planner, from 0 for values up to 11 for separators.
+	Unlike operatorPrecedence, which only groups operators of the same level, this orders the levels too.
+*/
+func grammarPrecedence(symbol OperatorSymbol) int {
+
+	switch symbol {
+	case NEGATE, INVERT, BITWISE_NOT:
+		return 1
+	case EXPONENT:
+		return 2
+	case MULTIPLY, DIVIDE, MODULUS:
+		return 3
+	case PLUS, MINUS:
+		return 4
+	case BITWISE_LSHIFT, BITWISE_RSHIFT:
+		return 5
+	case BITWISE_AND, BITWISE_OR, BITWISE_XOR:
+		return 6
+	case EQ, NEQ, GT, LT, GTE, LTE, REQ, NREQ, IN:
+		return 7
+	case AND:
+		return 8
+	case OR:
+		return 9
+	case TERNARY_TRUE, TERNARY_FALSE, COALESCE:
+		return 10
+	case SEPARATE:
+		return 11
+	}
+	return 0
+}
+
+/*
+	Returns the operator as it is written in expressions, such as "==".
+*/
+func operatorText(symbol OperatorSymbol) string {
+
+	if symbol == EQ {
+		return "=="
+	}
+	return symbol.String()
+}
@@ -0,0 +1,349 @@
+package govaluate
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+	Represents a test of translating an expression with a QueryTranslator.
+	If [ExpectedError] is set, translation is expected to fail with an error containing it.
+*/
+type TranslationTest struct {
+	Name          string
+	Input         string
+	Expected      interface{}
+	ExpectedArgs  []interface{}
+	ExpectedError string
+}
+
+func TestParameterizedSQLTranslation(test *testing.T) {
+
+	created := time.Date(2014, time.January, 2, 0, 0, 0, 0, time.Local)
+
+	testCases := []TranslationTest{
+
+		TranslationTest{
+			Name:         "Literals as arguments",
+			Input:        "port > 1024 && name == 'wg0'",
+			Expected:     `"port" > ? AND "name" = ?`,
+			ExpectedArgs: []interface{}{1024.0, "wg0"},
+		},
+		TranslationTest{
+			Name:         "Injected strings",
+			Input:        "name == '\\' OR 1 = 1 --'",
+			Expected:     `"name" = ?`,
+			ExpectedArgs: []interface{}{"' OR 1 = 1 --"},
+		},
+		TranslationTest{
+			Name:         "Escaped periods",
+			Input:        "[a\\.b] > 1 && peer.Endpoint.Port > 1",
+			Expected:     `"a.b" > ? AND "peer"."Endpoint"."Port" > ?`,
+			ExpectedArgs: []interface{}{1.0, 1.0},
+		},
+		TranslationTest{
+			Name:         "Minimal parenthesis",
+			Input:        "a > 1 && (b == 'x' || !c)",
+			Expected:     `"a" > ? AND ("b" = ? OR NOT "c")`,
+			ExpectedArgs: []interface{}{1.0, "x"},
+		},
+		TranslationTest{
+			Name:         "Right associativity",
+			Input:        "1 - (2 - x)",
+			Expected:     `? - (? - "x")`,
+			ExpectedArgs: []interface{}{1.0, 2.0},
+		},
+		TranslationTest{
+			Name:     "Grammar precedence",
+			Input:    "(a + b) * c > d * e + f && (g | h) == i",
+			Expected: `("a" + "b") * "c" > "d" * "e" + "f" AND "g" | "h" = "i"`,
+		},
+		TranslationTest{
+			Name:         "Functional operators",
+			Input:        "-(a + b) * c ** 2 % 3 > (d ?? 0)",
+			Expected:     `MOD(-("a" + "b") * POW("c", ?), ?) > COALESCE("d", ?)`,
+			ExpectedArgs: []interface{}{2.0, 3.0, 0.0},
+		},
+		TranslationTest{
+			Name:         "IN list",
+			Input:        "x IN (1, y, 'z')",
+			Expected:     `"x" IN (?, "y", ?)`,
+			ExpectedArgs: []interface{}{1.0, "z"},
+		},
+		TranslationTest{
+			Name:         "Ternary",
+			Input:        "c ? 1 : 2",
+			Expected:     `CASE WHEN "c" THEN ? ELSE ? END`,
+			ExpectedArgs: []interface{}{1.0, 2.0},
+		},
+		TranslationTest{
+			Name:         "Regex and dates",
+			Input:        "host =~ '^vpn' && created > '2014-01-02'",
+			Expected:     `"host" RLIKE ? AND "created" > ?`,
+			ExpectedArgs: []interface{}{"^vpn", created},
+		},
+		TranslationTest{
+			Name:         "Accessors",
+			Input:        "peer.Endpoint.Port >= 1024",
+			Expected:     `"peer"."Endpoint"."Port" >= ?`,
+			ExpectedArgs: []interface{}{1024.0},
+		},
+		TranslationTest{
+			Name:         "Addresses and durations",
+			Input:        "ip IN (10.0.0.1, fe80::1) && subnet != 10.0.0.0/8 && timeout > 1m30s",
+			Expected:     `"ip" IN (?, ?) AND "subnet" <> ? AND "timeout" > ?`,
+			ExpectedArgs: []interface{}{"10.0.0.1", "fe80::1", "10.0.0.0/8", 90.0},
+		},
+	}
+
+	for _, testCase := range testCases {
+
+		expression, err := NewEvaluableExpression(testCase.Input)
+		if err != nil {
+			test.Errorf("Test '%s' failed to parse: %s", testCase.Name, err)
+			continue
+		}
+
+		query, args, err := expression.ToParameterizedSQLQuery()
+		if err != nil {
+			test.Errorf("Test '%s' failed to translate: %s", testCase.Name, err)
+			continue
+		}
+
+		if query != testCase.Expected || !reflect.DeepEqual(args, testCase.ExpectedArgs) {
+			test.Errorf("Test '%s' translated to '%s' %v, expected '%s' %v", testCase.Name, query, args, testCase.Expected, testCase.ExpectedArgs)
+		}
+	}
+}
+
+func TestSQLTranslatorOptions(test *testing.T) {
+
+	translator := &SQLTranslator{
+		Placeholder: func(index int) string {
+			return fmt.Sprintf("$%d", index)
+		},
+		QuoteIdentifier: func(name string) string {
+			return "`" + name + "`"
+		},
+	}
+
+	// the translator is reused, and shouldn't carry arguments over from the last query.
+	expression, err := NewEvaluableExpressionWithFunctions("peer.Port == 1 || len(name) > 2", StandardFunctions())
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+
+		query, args, err := translator.Translate(expression)
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		expected := "`peer`.`Port` = $1 OR len(`name`) > $2"
+		if query != expected || len(args) != 2 {
+			test.Errorf("Translated to '%s' %v, expected '%s' with two arguments", query, args, expected)
+		}
+	}
+}
+
+func TestMongoTranslation(test *testing.T) {
+
+	testCases := []TranslationTest{
+
+		TranslationTest{
+			Name:     "Comparison",
+			Input:    "port > 1024",
+			Expected: map[string]interface{}{"port": map[string]interface{}{"$gt": 1024.0}},
+		},
+		TranslationTest{
+			Name:     "Mirrored comparison",
+			Input:    "1024 <= port",
+			Expected: map[string]interface{}{"port": map[string]interface{}{"$gte": 1024.0}},
+		},
+		TranslationTest{
+			Name:  "Flattened logic",
+			Input: "a == 1 && b != 'x' && !c",
+			Expected: map[string]interface{}{"$and": []interface{}{
+				map[string]interface{}{"a": map[string]interface{}{"$eq": 1.0}},
+				map[string]interface{}{"b": map[string]interface{}{"$ne": "x"}},
+				map[string]interface{}{"$nor": []interface{}{map[string]interface{}{"c": true}}},
+			}},
+		},
+		TranslationTest{
+			Name:  "Nested logic",
+			Input: "a || (b && c)",
+			Expected: map[string]interface{}{"$or": []interface{}{
+				map[string]interface{}{"a": true},
+				map[string]interface{}{"$and": []interface{}{
+					map[string]interface{}{"b": true},
+					map[string]interface{}{"c": true},
+				}},
+			}},
+		},
+		TranslationTest{
+			Name:     "IN",
+			Input:    "peer.Endpoint.Port IN (51820, 51821)",
+			Expected: map[string]interface{}{"peer.Endpoint.Port": map[string]interface{}{"$in": []interface{}{51820.0, 51821.0}}},
+		},
+		TranslationTest{
+			Name:  "Regex",
+			Input: "host =~ '^vpn' && name !~ 'test'",
+			Expected: map[string]interface{}{"$and": []interface{}{
+				map[string]interface{}{"host": map[string]interface{}{"$regex": "^vpn"}},
+				map[string]interface{}{"name": map[string]interface{}{"$not": map[string]interface{}{"$regex": "test"}}},
+			}},
+		},
+		TranslationTest{
+			Name:  "Expression",
+			Input: "a + b > '$c'",
+			Expected: map[string]interface{}{"$expr": map[string]interface{}{"$gt": []interface{}{
+				map[string]interface{}{"$add": []interface{}{"$a", "$b"}},
+				map[string]interface{}{"$literal": "$c"},
+			}}},
+		},
+		TranslationTest{
+			Name:  "Ternary",
+			Input: "(c ? a : b) == 1",
+			Expected: map[string]interface{}{"$expr": map[string]interface{}{"$eq": []interface{}{
+				map[string]interface{}{"$cond": []interface{}{"$c", "$a", "$b"}},
+				1.0,
+			}}},
+		},
+		TranslationTest{
+			Name:          "Function",
+			Input:         "len(name) > 2",
+			ExpectedError: "Function 'len' is unsupported in MongoDB filters",
+		},
+		TranslationTest{
+			Name:          "Bitwise",
+			Input:         "(a & 1) == 1",
+			ExpectedError: "Operator '&' is unsupported in MongoDB filters",
+		},
+		TranslationTest{
+			Name:          "Dynamic regex",
+			Input:         "host =~ pattern",
+			ExpectedError: "must match a parameter against a constant pattern",
+		},
+		TranslationTest{
+			Name:          "Ternary without else",
+			Input:         "c ? a",
+			ExpectedError: "without an else part",
+		},
+		TranslationTest{
+			Name:          "Logic as a value",
+			Input:         "(a && b) + 1",
+			ExpectedError: "can only be used as conditions",
+		},
+		TranslationTest{
+			Name:          "Containment in a network",
+			Input:         "ip in 10.0.0.0/8",
+			ExpectedError: "Cannot translate containment in the network '10.0.0.0/8'",
+		},
+	}
+
+	for _, testCase := range testCases {
+
+		expression, err := NewEvaluableExpressionWithFunctions(testCase.Input, StandardFunctions())
+		if err != nil {
+			test.Errorf("Test '%s' failed to parse: %s", testCase.Name, err)
+			continue
+		}
+
+		filter, err := expression.ToMongoQuery()
+		checkTranslation(test, testCase, filter, err)
+	}
+}
+
+func TestJSONTranslation(test *testing.T) {
+
+	variable := func(name string) map[string]interface{} {
+		return map[string]interface{}{"type": "variable", "name": name}
+	}
+	literal := func(kind string, value interface{}) map[string]interface{} {
+		return map[string]interface{}{"type": "literal", "kind": kind, "value": value}
+	}
+
+	testCases := []TranslationTest{
+
+		TranslationTest{
+			Name:  "Binary and unary",
+			Input: "!(a == 1)",
+			Expected: map[string]interface{}{"type": "unary", "operator": "!", "operand": map[string]interface{}{
+				"type": "binary", "operator": "==", "left": variable("a"), "right": literal("number", 1.0),
+			}},
+		},
+		TranslationTest{
+			Name:  "Literal kinds",
+			Input: "x IN ('a', true, '2014-01-02T00:00:00Z', 1h30m, ::1, 10.1.2.3/8) && y =~ '^z'",
+			Expected: map[string]interface{}{"type": "binary", "operator": "&&",
+				"left": map[string]interface{}{"type": "binary", "operator": "in", "left": variable("x"),
+					"right": map[string]interface{}{"type": "array", "items": []interface{}{
+						literal("string", "a"),
+						literal("bool", true),
+						literal("time", "2014-01-02T00:00:00Z"),
+						literal("duration", 5400.0),
+						literal("ip", "::1"),
+						literal("cidr", "10.0.0.0/8"),
+					}},
+				},
+				"right": map[string]interface{}{"type": "binary", "operator": "=~", "left": variable("y"), "right": literal("pattern", "^z")},
+			},
+		},
+		TranslationTest{
+			Name:  "Ternary and functions",
+			Input: "c ? len(peer.Name) : now()",
+			Expected: map[string]interface{}{"type": "ternary",
+				"condition": variable("c"),
+				"then":      map[string]interface{}{"type": "function", "name": "len", "arguments": []interface{}{variable("peer.Name")}},
+				"else":      map[string]interface{}{"type": "function", "name": "now", "arguments": []interface{}{}},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+
+		expression, err := NewEvaluableExpressionWithFunctions(testCase.Input, StandardFunctions())
+		if err != nil {
+			test.Errorf("Test '%s' failed to parse: %s", testCase.Name, err)
+			continue
+		}
+
+		tree, err := JSONTranslator{}.Translate(expression)
+		checkTranslation(test, testCase, tree, err)
+	}
+
+	expression, _ := NewEvaluableExpression("a > 1")
+	encoded, err := expression.ToJSONAST()
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	var decoded map[string]interface{}
+	if err = json.Unmarshal(encoded, &decoded); err != nil || decoded["operator"] != ">" {
+		test.Errorf("Expected ToJSONAST to give the tree as JSON, got '%s'", encoded)
+	}
+}
+
+func checkTranslation(test *testing.T, testCase TranslationTest, actual interface{}, err error) {
+
+	if testCase.ExpectedError != "" {
+		if err == nil || !strings.Contains(err.Error(), testCase.ExpectedError) {
+			test.Errorf("Test '%s' expected an error containing '%s', got '%v'", testCase.Name, testCase.ExpectedError, err)
+		}
+		return
+	}
+
+	if err != nil {
+		test.Errorf("Test '%s' failed to translate: %s", testCase.Name, err)
+		return
+	}
+
+	if !reflect.DeepEqual(actual, testCase.Expected) {
+		test.Errorf("Test '%s' translated to %v, expected %v", testCase.Name, actual, testCase.Expected)
+	}
+}
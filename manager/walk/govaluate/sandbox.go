@@ -0,0 +1,373 @@
+package govaluate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+/*
+	Limits for evaluating expressions which come from untrusted sources, such as configuration files written by other people.
+	The zero value has no limits. Exceeding any of them stops evaluation with a *LimitError.
+*/
+type EvaluationOptions struct {
+
+	// Stops evaluation once done. Functions which are already running are not interrupted.
+	Context context.Context
+
+	// The most operators, functions and parameters that may be evaluated. Literals don't count.
+	MaxSteps int
+
+	// The longest string, array or map which an operator or function may produce.
+	// Parameters may be longer, as long as what's made of them isn't.
+	MaxSize int
+
+	// How long a pattern may take to compile, and how long its source may be, when it's given by a parameter to =~ or !~.
+	// Constant patterns are compiled when the expression is parsed.
+	RegexTimeout time.Duration
+	MaxRegexSize int
+
+	// The names of the only functions which may be called. If nil, any function may be called.
+	// Expressions which mention any other function fail to evaluate at all, even if it wouldn't be called.
+	// Methods of parameters called through accessors count as functions of the same name, but only fail once they would be called,
+	// since which methods there are depends on the parameters. An empty list allows neither.
+	AllowedFunctions []string
+}
+
+/*
+	Which limit of EvaluationOptions was exceeded.
+*/
+type Limit int
+
+const (
+	LimitContext Limit = iota
+	LimitSteps
+	LimitSize
+	LimitRegexTimeout
+	LimitRegexSize
+	LimitFunction
+)
+
+func (this Limit) String() string {
+
+	switch this {
+	case LimitContext:
+		return "Context"
+	case LimitSteps:
+		return "MaxSteps"
+	case LimitSize:
+		return "MaxSize"
+	case LimitRegexTimeout:
+		return "RegexTimeout"
+	case LimitRegexSize:
+		return "MaxRegexSize"
+	case LimitFunction:
+		return "AllowedFunctions"
+	}
+	return "Unknown"
+}
+
+/*
+	The error returned when evaluation exceeds one of the limits of its EvaluationOptions.
+	When it was stopped by its context, it wraps the error of the context, such as context.DeadlineExceeded.
+*/
+type LimitError struct {
+	Limit   Limit
+	Message string
+
+	cause error
+}
+
+func (this *LimitError) Error() string {
+	return this.Message
+}
+
+func (this *LimitError) Unwrap() error {
+	return this.cause
+}
+
+/*
+	The state of one sandboxed evaluation.
+*/
+type sandbox struct {
+	options EvaluationOptions
+	steps   int
+
+	// the functions and methods which may be called, or nil if any may be.
+	allowed map[string]bool
+}
+
+func newSandbox(options EvaluationOptions, functionNames []string) (*sandbox, error) {
+
+	ret := &sandbox{options: options}
+
+	if options.AllowedFunctions != nil {
+
+		ret.allowed = make(map[string]bool)
+		for _, name := range options.AllowedFunctions {
+			ret.allowed[name] = true
+		}
+
+		for _, name := range functionNames {
+			if !ret.allowed[name] {
+				errorMsg := fmt.Sprintf("Function '%s' is not allowed", name)
+				return nil, &LimitError{Limit: LimitFunction, Message: errorMsg}
+			}
+		}
+	}
+
+	return ret, ret.checkContext()
+}
+
+/*
+	Checks whether the method [name] of a parameter may be called by an accessor.
+*/
+func (this *sandbox) checkMethod(name string) error {
+
+	if this.allowed != nil && !this.allowed[name] {
+		errorMsg := fmt.Sprintf("Method '%s' is not allowed", name)
+		return &LimitError{Limit: LimitFunction, Message: errorMsg}
+	}
+	return nil
+}
+
+/*
+	The parameters given to operators evaluated within limits, through which accessors find out which methods they may call.
+*/
+type sandboxedParameters struct {
+	parameters Parameters
+	sandbox    *sandbox
+}
+
+func (this sandboxedParameters) Get(name string) (interface{}, error) {
+	return this.parameters.Get(name)
+}
+
+func (this sandboxedParameters) convertsTimes() bool {
+	return convertsTimes(this.parameters)
+}
+
+/*
+	Checks whether an accessor may call the method [name] of a parameter, given the [parameters] of its stage.
+*/
+func checkMethod(parameters Parameters, name string) error {
+
+	sandboxed, ok := parameters.(sandboxedParameters)
+	if !ok {
+		return nil
+	}
+	return sandboxed.sandbox.checkMethod(name)
+}
+
+/*
+	Counts one step of evaluation, and checks whether evaluation has been cancelled.
+*/
+func (this *sandbox) step() error {
+
+	this.steps++
+	if this.options.MaxSteps > 0 && this.steps > this.options.MaxSteps {
+		errorMsg := fmt.Sprintf("Evaluation took more than %d steps", this.options.MaxSteps)
+		return &LimitError{Limit: LimitSteps, Message: errorMsg}
+	}
+	return this.checkContext()
+}
+
+func (this *sandbox) checkContext() error {
+
+	if this.options.Context == nil {
+		return nil
+	}
+
+	select {
+	case <-this.options.Context.Done():
+		err := this.options.Context.Err()
+		return &LimitError{Limit: LimitContext, Message: fmt.Sprintf("Evaluation was stopped: %v", err), cause: err}
+	default:
+		return nil
+	}
+}
+
+/*
+	Runs the operator of [stage] within the limits; its operands have already been evaluated, and type checked.
+*/
+func (this *sandbox) apply(stage *evaluationStage, left interface{}, right interface{}, parameters Parameters) (interface{}, error) {
+
+	var err error
+
+	if err = this.step(); err != nil {
+		return nil, err
+	}
+
+	if pattern, isString := right.(string); isString && (stage.symbol == REQ || stage.symbol == NREQ) {
+		right, err = this.compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if this.allowed != nil && parameters != nil {
+		parameters = sandboxedParameters{parameters, this}
+	}
+
+	value, err := stage.operator(left, right, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	if stage.symbol == VALUE {
+		return value, nil
+	}
+	return value, this.checkSize(value)
+}
+
+func (this *sandbox) checkSize(value interface{}) error {
+
+	var kind string
+	var size int
+
+	switch typed := value.(type) {
+	case string:
+		kind, size = "string", len(typed)
+	case []interface{}:
+		kind, size = "array", len(typed)
+	case map[string]interface{}:
+		kind, size = "map", len(typed)
+	default:
+		return nil
+	}
+
+	if this.options.MaxSize > 0 && size > this.options.MaxSize {
+		errorMsg := fmt.Sprintf("Evaluation produced a %s of length %d, more than the limit of %d", kind, size, this.options.MaxSize)
+		return &LimitError{Limit: LimitSize, Message: errorMsg}
+	}
+	return nil
+}
+
+/*
+	Compiles a pattern given by a parameter. Compiling which takes too long is left to finish on its own,
+	since it can't be interrupted, but evaluation doesn't wait for it.
+*/
+func (this *sandbox) compile(pattern string) (*regexp.Regexp, error) {
+
+	type compiled struct {
+		pattern *regexp.Regexp
+		err     error
+	}
+
+	if this.options.MaxRegexSize > 0 && len(pattern) > this.options.MaxRegexSize {
+		errorMsg := fmt.Sprintf("Pattern is %d bytes long, more than the limit of %d", len(pattern), this.options.MaxRegexSize)
+		return nil, &LimitError{Limit: LimitRegexSize, Message: errorMsg}
+	}
+
+	if this.options.RegexTimeout <= 0 {
+		return compileDynamicPattern(pattern)
+	}
+
+	result := make(chan compiled, 1)
+	go func() {
+		ret, err := compileDynamicPattern(pattern)
+		result <- compiled{ret, err}
+	}()
+
+	timer := time.NewTimer(this.options.RegexTimeout)
+	defer timer.Stop()
+
+	var done <-chan struct{}
+	if this.options.Context != nil {
+		done = this.options.Context.Done()
+	}
+
+	select {
+	case ret := <-result:
+		return ret.pattern, ret.err
+	case <-timer.C:
+		errorMsg := fmt.Sprintf("Pattern took longer than %v to compile", this.options.RegexTimeout)
+		return nil, &LimitError{Limit: LimitRegexTimeout, Message: errorMsg}
+	case <-done:
+		return nil, this.checkContext()
+	}
+}
+
+func compileDynamicPattern(pattern string) (*regexp.Regexp, error) {
+
+	ret, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Unable to compile regexp pattern '%v': %v", pattern, err))
+	}
+	return ret, nil
+}
+
+/*
+	Returns the names of the functions this expression calls, or an error if they can't be known,
+	as for expressions made from tokens.
+*/
+func (this EvaluableExpression) functionNames() ([]string, error) {
+
+	var ret []string
+	var err error
+
+	var find func(stage *evaluationStage)
+	find = func(stage *evaluationStage) {
+
+		if stage == nil || err != nil {
+			return
+		}
+
+		if stage.symbol == FUNCTIONAL {
+
+			var name string
+			name, err = this.functionName(stage)
+			ret = append(ret, name)
+		}
+
+		find(stage.leftStage)
+		find(stage.rightStage)
+	}
+
+	find(this.evaluationStages)
+	return ret, err
+}
+
+/*
+	Same as `EvalWithOptions`, but automatically wraps a map of parameters into a `govalute.Parameters` structure.
+*/
+func (this EvaluableExpression) EvaluateWithOptions(parameters map[string]interface{}, options EvaluationOptions) (interface{}, error) {
+
+	if parameters == nil {
+		return this.EvalWithOptions(nil, options)
+	}
+	return this.EvalWithOptions(MapParameters(parameters), options)
+}
+
+/*
+	Same as `Eval`, within the limits of [options].
+*/
+func (this EvaluableExpression) EvalWithOptions(parameters Parameters, options EvaluationOptions) (interface{}, error) {
+
+	var names []string
+	var err error
+
+	if options.AllowedFunctions != nil {
+
+		names, err = this.functionNames()
+		if err != nil {
+			return nil, &LimitError{Limit: LimitFunction, Message: err.Error()}
+		}
+	}
+
+	sandbox, err := newSandbox(options, names)
+	if err != nil {
+		return nil, err
+	}
+
+	if this.evaluationStages == nil {
+		return nil, nil
+	}
+
+	if parameters != nil {
+		parameters = &sanitizedParameters{parameters, this.typed}
+	}
+	return this.evaluateStage(this.evaluationStages, parameters, sandbox)
+}
@@ -0,0 +1,351 @@
+package govaluate
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+	Represents a test of evaluating an expression with EvaluationOptions, with both evaluators.
+	If [ExpectedLimit] is set, evaluation is expected to fail with a *LimitError for it.
+*/
+type SandboxTest struct {
+	Name          string
+	Input         string
+	Functions     map[string]ExpressionFunction
+	Parameters    map[string]interface{}
+	Options       EvaluationOptions
+	Expected      interface{}
+	ExpectedLimit *Limit
+}
+
+func limit(limit Limit) *Limit {
+	return &limit
+}
+
+func TestSandboxLimits(test *testing.T) {
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	functions := StandardFunctions()
+
+	sandboxTests := []SandboxTest{
+
+		SandboxTest{
+			Name:     "No limits",
+			Input:    "a + 1 > 2 && name =~ pattern",
+			Options:  EvaluationOptions{},
+			Expected: true,
+			Parameters: map[string]interface{}{
+				"a":       2.0,
+				"name":    "wg0",
+				"pattern": "^wg",
+			},
+		},
+		SandboxTest{
+			Name:     "Within steps",
+			Input:    "a + 1 > 2 && 1 + 2 == 3",
+			Options:  EvaluationOptions{MaxSteps: 4},
+			Expected: true,
+			Parameters: map[string]interface{}{
+				"a": 2.0,
+			},
+		},
+		SandboxTest{
+			Name:          "Too many steps",
+			Input:         "a + 1 > 2 && a < 10",
+			Options:       EvaluationOptions{MaxSteps: 4},
+			ExpectedLimit: limit(LimitSteps),
+			Parameters: map[string]interface{}{
+				"a": 2.0,
+			},
+		},
+		SandboxTest{
+			Name:     "Short circuits aren't counted",
+			Input:    "a > 2 && a + a + a + a > 10",
+			Options:  EvaluationOptions{MaxSteps: 2},
+			Expected: false,
+			Parameters: map[string]interface{}{
+				"a": 2.0,
+			},
+		},
+		SandboxTest{
+			Name:          "Cancelled",
+			Input:         "1 + 1",
+			Options:       EvaluationOptions{Context: cancelled},
+			ExpectedLimit: limit(LimitContext),
+		},
+		SandboxTest{
+			Name:      "Long parameters",
+			Input:     "len(s) + len(dns)",
+			Options:   EvaluationOptions{MaxSize: 2},
+			Expected:  6.0,
+			Functions: functions,
+			Parameters: map[string]interface{}{
+				"s":   "four",
+				"dns": []interface{}{"1.1.1.1", "8.8.8.8"},
+			},
+		},
+		SandboxTest{
+			Name:          "Long string",
+			Input:         "s + s + s",
+			Options:       EvaluationOptions{MaxSize: 10},
+			ExpectedLimit: limit(LimitSize),
+			Parameters: map[string]interface{}{
+				"s": "four",
+			},
+		},
+		SandboxTest{
+			Name:          "Long array",
+			Input:         "x IN (1, 2, 3)",
+			Options:       EvaluationOptions{MaxSize: 2},
+			ExpectedLimit: limit(LimitSize),
+			Parameters: map[string]interface{}{
+				"x": 1.0,
+			},
+		},
+		SandboxTest{
+			Name:          "Long map",
+			Input:         "{'a': x, 'b': x, 'c': x}['a']",
+			Options:       EvaluationOptions{MaxSize: 2},
+			ExpectedLimit: limit(LimitSize),
+			Parameters: map[string]interface{}{
+				"x": 1.0,
+			},
+		},
+		SandboxTest{
+			Name:          "Steps in quantifiers",
+			Input:         "count(list, n => n > 1) > 0",
+			Options:       EvaluationOptions{MaxSteps: 8},
+			ExpectedLimit: limit(LimitSteps),
+			Parameters: map[string]interface{}{
+				"list": []interface{}{1.0, 2.0, 3.0, 4.0, 5.0},
+			},
+		},
+		SandboxTest{
+			Name:          "Long function result",
+			Input:         "split(s, ',')",
+			Functions:     functions,
+			Options:       EvaluationOptions{MaxSize: 2},
+			ExpectedLimit: limit(LimitSize),
+			Parameters: map[string]interface{}{
+				"s": "a,b,c",
+			},
+		},
+		SandboxTest{
+			Name:          "Long pattern",
+			Input:         "name =~ pattern",
+			Options:       EvaluationOptions{MaxRegexSize: 8},
+			ExpectedLimit: limit(LimitRegexSize),
+			Parameters: map[string]interface{}{
+				"name":    "wg0",
+				"pattern": strings.Repeat("a", 9),
+			},
+		},
+		SandboxTest{
+			Name:     "Constant patterns aren't limited",
+			Input:    "name !~ 'aaaaaaaaa'",
+			Options:  EvaluationOptions{MaxRegexSize: 8, RegexTimeout: time.Nanosecond},
+			Expected: true,
+			Parameters: map[string]interface{}{
+				"name": "wg0",
+			},
+		},
+		SandboxTest{
+			Name:     "Pattern within timeout",
+			Input:    "name =~ pattern",
+			Options:  EvaluationOptions{RegexTimeout: time.Minute},
+			Expected: true,
+			Parameters: map[string]interface{}{
+				"name":    "wg0",
+				"pattern": "^wg[0-9]+$",
+			},
+		},
+		SandboxTest{
+			Name:          "Pattern timeout",
+			Input:         "name =~ pattern",
+			Options:       EvaluationOptions{RegexTimeout: time.Nanosecond},
+			ExpectedLimit: limit(LimitRegexTimeout),
+			Parameters: map[string]interface{}{
+				"name":    "wg0",
+				"pattern": strings.Repeat("(a|b|c)?", 900),
+			},
+		},
+		SandboxTest{
+			Name:      "Allowed function",
+			Input:     "len(s) > 1",
+			Functions: functions,
+			Options:   EvaluationOptions{AllowedFunctions: []string{"len"}},
+			Expected:  true,
+			Parameters: map[string]interface{}{
+				"s": "four",
+			},
+		},
+		SandboxTest{
+			Name:          "Forbidden function",
+			Input:         "false && now() > 0",
+			Functions:     functions,
+			Options:       EvaluationOptions{AllowedFunctions: []string{"len"}},
+			ExpectedLimit: limit(LimitFunction),
+		},
+	}
+
+	for _, sandboxTest := range sandboxTests {
+
+		expression, err := NewEvaluableExpressionWithFunctions(sandboxTest.Input, sandboxTest.Functions)
+		if err != nil {
+			test.Errorf("Test '%s' failed to parse: %s", sandboxTest.Name, err)
+			continue
+		}
+
+		compiled, err := expression.Compile()
+		if err != nil {
+			test.Errorf("Test '%s' failed to compile: %s", sandboxTest.Name, err)
+			continue
+		}
+
+		treeResult, treeErr := expression.EvaluateWithOptions(sandboxTest.Parameters, sandboxTest.Options)
+		compiledResult, compiledErr := compiled.EvaluateWithOptions(sandboxTest.Parameters, sandboxTest.Options)
+
+		checkSandboxResult(sandboxTest, "tree", treeResult, treeErr, test)
+		checkSandboxResult(sandboxTest, "compiled", compiledResult, compiledErr, test)
+	}
+}
+
+func TestSandboxCancellation(test *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	functions := map[string]ExpressionFunction{
+		"cancel": func(arguments ...interface{}) (interface{}, error) {
+			cancel()
+			return 1.0, nil
+		},
+	}
+
+	expression, _ := NewEvaluableExpressionWithFunctions("cancel() + 1", functions)
+
+	_, err := expression.EvalWithOptions(nil, EvaluationOptions{Context: ctx})
+
+	var limitError *LimitError
+	if !errors.As(err, &limitError) || limitError.Limit != LimitContext || !errors.Is(err, context.Canceled) {
+		test.Errorf("Expected evaluation to stop once cancelled, with an error wrapping context.Canceled, got '%v'", err)
+	}
+}
+
+func TestSandboxUnknownFunctionNames(test *testing.T) {
+
+	tokens := []ExpressionToken{
+		ExpressionToken{
+			Kind:  FUNCTION,
+			Value: ExpressionFunction(noop),
+		},
+		ExpressionToken{
+			Kind: CLAUSE,
+		},
+		ExpressionToken{
+			Kind: CLAUSE_CLOSE,
+		},
+	}
+
+	expression, err := NewEvaluableExpressionFromTokens(tokens)
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	_, err = expression.EvalWithOptions(nil, EvaluationOptions{})
+	if err != nil {
+		test.Errorf("Expected functions to be allowed without an allow-list, got '%v'", err)
+	}
+
+	_, err = expression.EvalWithOptions(nil, EvaluationOptions{AllowedFunctions: []string{"noop"}})
+	if limitError, ok := err.(*LimitError); !ok || limitError.Limit != LimitFunction {
+		test.Errorf("Expected functions without names to be forbidden by an allow-list, got '%v'", err)
+	}
+}
+
+func checkSandboxResult(sandboxTest SandboxTest, evaluator string, result interface{}, err error, test *testing.T) {
+
+	if sandboxTest.ExpectedLimit != nil {
+
+		limitError, ok := err.(*LimitError)
+		if !ok || limitError.Limit != *sandboxTest.ExpectedLimit {
+			test.Errorf("Test '%s' (%s) expected to exceed %v, got '%v'", sandboxTest.Name, evaluator, *sandboxTest.ExpectedLimit, err)
+		}
+		return
+	}
+
+	if err != nil {
+		test.Errorf("Test '%s' (%s) failed: %s", sandboxTest.Name, evaluator, err)
+		return
+	}
+
+	if result != sandboxTest.Expected {
+		test.Errorf("Test '%s' (%s) gave '%v', expected '%v'", sandboxTest.Name, evaluator, result, sandboxTest.Expected)
+	}
+}
+
+type sandboxParameter struct {
+	Name   string
+	called *bool
+}
+
+func (this sandboxParameter) Danger() bool {
+	*this.called = true
+	return true
+}
+
+/*
+	Methods called through accessors are limited by the allow-list as if they were functions.
+*/
+func TestSandboxMethods(test *testing.T) {
+
+	called := false
+	parameters := map[string]interface{}{
+		"s":    sandboxParameter{"wg0", &called},
+		"list": []sandboxParameter{sandboxParameter{"wg1", &called}},
+	}
+
+	for _, input := range []string{"s.Danger", "s.Name == 'wg0' && s.Danger", "any(list, p => p.Danger)"} {
+
+		expression, err := NewEvaluableExpression(input)
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		compiled, err := expression.Compile()
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		evaluators := map[string]func(map[string]interface{}, EvaluationOptions) (interface{}, error){
+			"tree":     expression.EvaluateWithOptions,
+			"compiled": compiled.EvaluateWithOptions,
+		}
+
+		for evaluator, evaluate := range evaluators {
+
+			called = false
+			_, err = evaluate(parameters, EvaluationOptions{AllowedFunctions: []string{}})
+			if limitError, ok := err.(*LimitError); !ok || limitError.Limit != LimitFunction || called {
+				test.Errorf("Expected %s evaluation of '%s' to be forbidden from calling a method, got '%v' (called: %v)", evaluator, input, err, called)
+			}
+
+			called = false
+			result, err := evaluate(parameters, EvaluationOptions{AllowedFunctions: []string{"Danger"}})
+			if result != true || err != nil || !called {
+				test.Errorf("Expected %s evaluation of '%s' to call an allowed method, got %v, '%v'", evaluator, input, result, err)
+			}
+		}
+	}
+
+	expression, _ := NewEvaluableExpression("s.Name")
+	result, err := expression.EvaluateWithOptions(parameters, EvaluationOptions{AllowedFunctions: []string{}})
+	if result != "wg0" || err != nil {
+		test.Errorf("Expected fields to be accessible without any allowed functions, got %v, '%v'", result, err)
+	}
+}
@@ -1,9 +1,16 @@
 package govaluate
 
+import (
+	"time"
+)
+
 // sanitizedParameters is a wrapper for Parameters that does sanitization as
 // parameters are accessed.
 type sanitizedParameters struct {
 	orig Parameters
+
+	// whether times are turned into unix timestamps, as they are for expressions parsed with a schema.
+	convertTimes bool
 }
 
 func (p sanitizedParameters) Get(key string) (interface{}, error) {
@@ -17,9 +24,62 @@ func (p sanitizedParameters) Get(key string) (interface{}, error) {
 		return castFixedPoint(value), nil
 	}
 
+	// durations are numbers of seconds, just like duration literals.
+	if d, ok := value.(time.Duration); ok {
+		return d.Seconds(), nil
+	}
+
+	if t, ok := value.(time.Time); ok && p.convertTimes {
+		return unixSeconds(t), nil
+	}
+
+	// IPs and networks take the form of their literals, so that they're equal to them.
+	if address, ok := normalizedAddress(value); ok {
+		return address, nil
+	}
+
 	return value, nil
 }
 
+func (p sanitizedParameters) convertsTimes() bool {
+	return p.convertTimes
+}
+
+/*
+	Returns whether [parameters] turn times into unix timestamps, as their values are used.
+	Values taken from within them, by accessors, indexes and quantifiers, are converted the same way.
+*/
+func convertsTimes(parameters Parameters) bool {
+
+	converting, ok := parameters.(interface{ convertsTimes() bool })
+	return ok && converting.convertsTimes()
+}
+
+/*
+	Returns [t] as a unix timestamp, like date literals, but keeping any fraction of a second.
+*/
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+/*
+	Returns the operands of [stage] as it uses them. Without a schema, times are left as time.Time,
+	so that functions are still given them as they were, but arithmetic and comparisons use them as unix timestamps.
+*/
+func timeOperands(stage *evaluationStage, left interface{}, right interface{}) (interface{}, interface{}) {
+
+	switch stage.symbol {
+	case EQ, NEQ, GT, LT, GTE, LTE, PLUS, MINUS:
+		if t, ok := left.(time.Time); ok {
+			left = unixSeconds(t)
+		}
+		if t, ok := right.(time.Time); ok {
+			right = unixSeconds(t)
+		}
+	}
+	return left, right
+}
+
 func isFixedPoint(value interface{}) bool {
 
 	switch value.(type) {
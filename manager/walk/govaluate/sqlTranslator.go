@@ -0,0 +1,199 @@
+package govaluate
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*
+	A QueryTranslator to SQL, which binds every literal as an argument rather than writing it into the query,
+	so that values from expressions can't inject SQL of their own.
+
+	Parameters are taken to be columns, as with [EvaluableExpression.ToSQLQuery].
+	The ternary operator is translated to CASE, and functions are called by the same name.
+*/
+type SQLTranslator struct {
+
+	// Returns the placeholder for the argument at [index], counting from 1, such as "$1" for PostgreSQL.
+	// Defaults to "?".
+	Placeholder func(index int) string
+
+	// Quotes a parameter name to be used as a column. Accessors are quoted one name at a time, as in "peer"."Endpoint".
+	// Defaults to double quotes, as in standard SQL.
+	QuoteIdentifier func(name string) string
+
+	arguments []interface{}
+}
+
+/*
+	A translated part of a query, along with how tightly it binds, so that it can be parenthesized where needed.
+*/
+type sqlFragment struct {
+	text       string
+	precedence int
+	isList     bool
+}
+
+/*
+	Returns the query this expression translates to, and the arguments to bind to its placeholders, in order.
+*/
+func (this *SQLTranslator) Translate(expression *EvaluableExpression) (string, []interface{}, error) {
+
+	this.arguments = nil
+
+	result, err := expression.Translate(this)
+	if err != nil {
+		return "", nil, err
+	}
+	return result.(sqlFragment).text, this.arguments, nil
+}
+
+/*
+	Same as [ToSQLQuery], except that literals are bound as arguments with "?" placeholders, and parameters are quoted as columns.
+*/
+func (this EvaluableExpression) ToParameterizedSQLQuery() (string, []interface{}, error) {
+	return new(SQLTranslator).Translate(&this)
+}
+
+func (this *SQLTranslator) Literal(value interface{}) (interface{}, error) {
+
+	// constant patterns are matched with RLIKE, which takes the pattern as a string.
+	if pattern, ok := value.(*regexp.Regexp); ok {
+		value = pattern.String()
+	}
+	value = portableLiteral(value)
+
+	this.arguments = append(this.arguments, value)
+
+	placeholder := "?"
+	if this.Placeholder != nil {
+		placeholder = this.Placeholder(len(this.arguments))
+	}
+	return sqlFragment{text: placeholder}, nil
+}
+
+func (this *SQLTranslator) Variable(path []string) (interface{}, error) {
+
+	quote := this.QuoteIdentifier
+	if quote == nil {
+		quote = quoteSQLIdentifier
+	}
+
+	parts := make([]string, len(path))
+	for i, name := range path {
+		parts[i] = quote(name)
+	}
+	return sqlFragment{text: strings.Join(parts, ".")}, nil
+}
+
+func (this *SQLTranslator) List(items []interface{}) (interface{}, error) {
+	return sqlFragment{text: "(" + joinSQLFragments(items) + ")", isList: true}, nil
+}
+
+func (this *SQLTranslator) Unary(symbol OperatorSymbol, operand interface{}) (interface{}, error) {
+
+	precedence := grammarPrecedence(symbol)
+	right := parenthesizeSQL(operand.(sqlFragment), precedence, false)
+
+	switch symbol {
+	case INVERT:
+		return sqlFragment{text: "NOT " + right, precedence: precedence}, nil
+	case NEGATE, BITWISE_NOT:
+		return sqlFragment{text: symbol.String() + right, precedence: precedence}, nil
+	}
+
+	errorMsg := fmt.Sprintf("Operator '%s' is unsupported in SQL output", operatorText(symbol))
+	return nil, errors.New(errorMsg)
+}
+
+func (this *SQLTranslator) Binary(symbol OperatorSymbol, left interface{}, right interface{}) (interface{}, error) {
+
+	leftFragment := left.(sqlFragment)
+	rightFragment := right.(sqlFragment)
+
+	// these are written as functions, and need no parenthesis around their operands.
+	switch symbol {
+	case MODULUS:
+		return sqlFunctionFragment("MOD", leftFragment, rightFragment), nil
+	case EXPONENT:
+		return sqlFunctionFragment("POW", leftFragment, rightFragment), nil
+	case COALESCE:
+		return sqlFunctionFragment("COALESCE", leftFragment, rightFragment), nil
+	}
+
+	var operator string
+
+	switch symbol {
+	case EQ:
+		operator = "="
+	case NEQ:
+		operator = "<>"
+	case REQ:
+		operator = "RLIKE"
+	case NREQ:
+		operator = "NOT RLIKE"
+	case AND:
+		operator = "AND"
+	case OR:
+		operator = "OR"
+	case IN:
+		operator = "IN"
+		if !rightFragment.isList {
+			rightFragment = sqlFragment{text: "(" + rightFragment.text + ")"}
+		}
+	case GT, LT, GTE, LTE, PLUS, MINUS, MULTIPLY, DIVIDE,
+		BITWISE_AND, BITWISE_OR, BITWISE_XOR, BITWISE_LSHIFT, BITWISE_RSHIFT:
+		operator = symbol.String()
+	default:
+		errorMsg := fmt.Sprintf("Operator '%s' is unsupported in SQL output", operatorText(symbol))
+		return nil, errors.New(errorMsg)
+	}
+
+	precedence := grammarPrecedence(symbol)
+	text := parenthesizeSQL(leftFragment, precedence, false) + " " + operator + " " + parenthesizeSQL(rightFragment, precedence, true)
+	return sqlFragment{text: text, precedence: precedence}, nil
+}
+
+func (this *SQLTranslator) Ternary(condition interface{}, whenTrue interface{}, whenFalse interface{}) (interface{}, error) {
+
+	text := "CASE WHEN " + condition.(sqlFragment).text + " THEN " + whenTrue.(sqlFragment).text
+	if whenFalse != nil {
+		text += " ELSE " + whenFalse.(sqlFragment).text
+	}
+	return sqlFragment{text: text + " END"}, nil
+}
+
+func (this *SQLTranslator) Function(name string, arguments []interface{}) (interface{}, error) {
+	return sqlFragment{text: name + "(" + joinSQLFragments(arguments) + ")"}, nil
+}
+
+func sqlFunctionFragment(name string, left sqlFragment, right sqlFragment) sqlFragment {
+	return sqlFragment{text: fmt.Sprintf("%s(%s, %s)", name, left.text, right.text)}
+}
+
+func joinSQLFragments(fragments []interface{}) string {
+
+	texts := make([]string, len(fragments))
+	for i, fragment := range fragments {
+		texts[i] = fragment.(sqlFragment).text
+	}
+	return strings.Join(texts, ", ")
+}
+
+/*
+	Returns the text of the [fragment], parenthesized if it binds more loosely than the operator it's an operand of.
+	Operators are left-associative, so right operands of the same precedence are parenthesized too.
+*/
+func parenthesizeSQL(fragment sqlFragment, precedence int, isRight bool) string {
+
+	if fragment.precedence > precedence || (isRight && fragment.precedence == precedence) {
+		return "(" + fragment.text + ")"
+	}
+	return fragment.text
+}
+
+func quoteSQLIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
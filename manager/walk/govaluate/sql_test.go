@@ -1,6 +1,7 @@
 package govaluate
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -200,11 +201,30 @@ func TestSQLSerialization(test *testing.T) {
 			Input:    "'foo' !~ '[fF][oO]+'",
 			Expected: "'foo' NOT RLIKE '[fF][oO]+'",
 		},
+		QueryTest{
+
+			Name:     "Addresses and durations",
+			Input:    "[ip] == 10.0.0.1 && [timeout] > 1m30s",
+			Expected: "[ip] = '10.0.0.1' AND [timeout] > 90",
+		},
 	}
 
 	runQueryTests(testCases, test)
 }
 
+func TestSQLNetworks(test *testing.T) {
+
+	expression, err := NewEvaluableExpression("[ip] == 10.0.0.1 && [ip] in 10.0.0.0/8")
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	_, err = expression.ToSQLQuery()
+	if err == nil || !strings.Contains(err.Error(), "Containment in a network is unsupported") {
+		test.Errorf("Expected containment in a network to be refused, got %v", err)
+	}
+}
+
 func runQueryTests(testCases []QueryTest, test *testing.T) {
 
 	var expression *EvaluableExpression
@@ -81,13 +81,13 @@ func init() {
 		validSymbols:    prefixSymbols,
 		validKinds:      []TokenKind{PREFIX},
 		typeErrorFormat: prefixErrorFormat,
-		nextRight:       planFunction,
+		nextRight:       planIndex,
 	})
 	planExponential = makePrecedentFromPlanner(&precedencePlanner{
 		validSymbols:    exponentialSymbolsS,
 		validKinds:      []TokenKind{MODIFIER},
 		typeErrorFormat: modifierErrorFormat,
-		next:            planFunction,
+		next:            planIndex,
 	})
 	planMultiplicative = makePrecedentFromPlanner(&precedencePlanner{
 		validSymbols:    multiplicativeSymbols,
@@ -180,6 +180,20 @@ func makePrecedentFromPlanner(planner *precedencePlanner) precedent {
 */
 func planStages(tokens []ExpressionToken) (*evaluationStage, error) {
 
+	stage, err := planStructure(tokens)
+	if err != nil || stage == nil {
+		return stage, err
+	}
+
+	stage = elideLiterals(stage)
+	return stage, nil
+}
+
+/*
+	Plans the stages of the given [tokens] as they were written, without precalculating any literals.
+*/
+func planStructure(tokens []ExpressionToken) (*evaluationStage, error) {
+
 	stream := newTokenStream(tokens)
 
 	stage, err := planTokens(stream)
@@ -190,8 +204,7 @@ func planStages(tokens []ExpressionToken) (*evaluationStage, error) {
 	// while we're now fully-planned, we now need to re-order same-precedence operators.
 	// this could probably be avoided with a different planning method
 	reorderStages(stage)
-
-	stage = elideLiterals(stage)
+	planSeparators(stage)
 	return stage, nil
 }
 
@@ -262,6 +275,8 @@ func planPrecedenceLevel(
 			}
 		}
 
+		tokenIndex := stream.index - 1
+
 		if rightPrecedent != nil {
 			rightStage, err = rightPrecedent(stream)
 			if err != nil {
@@ -282,6 +297,7 @@ func planPrecedenceLevel(
 			rightTypeCheck:  checks.right,
 			typeCheck:       checks.combined,
 			typeErrorFormat: typeErrorFormat,
+			tokenIndex:      tokenIndex,
 		}, nil
 	}
 
@@ -289,6 +305,68 @@ func planPrecedenceLevel(
 	return leftStage, nil
 }
 
+/*
+	Indexes follow a value, and bind more tightly than any operator, so that "-peers[0]" negates the first peer.
+	So do members, as in "peers[0].Port", which are planned as indexes by their name, as in "peers[0]['Port']".
+*/
+func planIndex(stream *tokenStream) (*evaluationStage, error) {
+
+	ret, err := planFunction(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	for stream.hasNext() {
+
+		token := stream.next()
+		tokenIndex := stream.index - 1
+
+		if token.Kind == MEMBER {
+			for _, member := range token.Value.([]string) {
+
+				ret = &evaluationStage{
+
+					symbol:    INDEX,
+					leftStage: ret,
+					rightStage: &evaluationStage{
+						symbol:     LITERAL,
+						operator:   makeLiteralStage(member),
+						tokenIndex: tokenIndex,
+					},
+					operator:   indexStage,
+					tokenIndex: tokenIndex,
+				}
+			}
+			continue
+		}
+
+		if token.Kind != INDEX_OPEN {
+			stream.rewind()
+			break
+		}
+
+		index, err := planTernary(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = expectToken(stream, BRACKET_CLOSE, "Expected ']' to close the index")
+		if err != nil {
+			return nil, err
+		}
+
+		ret = &evaluationStage{
+
+			symbol:     INDEX,
+			leftStage:  ret,
+			rightStage: index,
+			operator:   indexStage,
+			tokenIndex: tokenIndex,
+		}
+	}
+	return ret, nil
+}
+
 /*
 	A special case where functions need to be of higher precedence than values, and need a special wrapped execution stage operator.
 */
@@ -305,17 +383,23 @@ func planFunction(stream *tokenStream) (*evaluationStage, error) {
 		return planValue(stream)
 	}
 
+	tokenIndex := stream.index - 1
+
 	rightStage, err = planValue(stream)
 	if err != nil {
 		return nil, err
 	}
 
+	// only a list of arguments is spread into them; a single array is passed as one argument.
+	spread := rightStage != nil && rightStage.rightStage != nil && rightStage.rightStage.symbol == SEPARATE
+
 	return &evaluationStage{
 
 		symbol:          FUNCTIONAL,
 		rightStage:      rightStage,
-		operator:        makeFunctionStage(token.Value.(ExpressionFunction)),
+		operator:        makeFunctionStage(token.Value.(ExpressionFunction), spread),
 		typeErrorFormat: "Unable to run function '%v': %v",
+		tokenIndex:      tokenIndex,
 	}, nil
 }
 
@@ -332,6 +416,7 @@ func planValue(stream *tokenStream) (*evaluationStage, error) {
 	var err error
 
 	token = stream.next()
+	tokenIndex := stream.index - 1
 
 	switch token.Kind {
 
@@ -352,6 +437,7 @@ func planValue(stream *tokenStream) (*evaluationStage, error) {
 			rightStage: ret,
 			operator: noopStageRight,
 			symbol: NOOP,
+			tokenIndex: tokenIndex,
 		}
 
 		return ret, nil
@@ -366,6 +452,9 @@ func planValue(stream *tokenStream) (*evaluationStage, error) {
 	case VARIABLE:
 		operator = makeParameterStage(token.Value.(string))
 
+	case ACCESSOR:
+		operator = makeAccessorStage(token.Value.([]string))
+
 	case NUMERIC:
 		fallthrough
 	case STRING:
@@ -378,10 +467,31 @@ func planValue(stream *tokenStream) (*evaluationStage, error) {
 	case TIME:
 		symbol = LITERAL
 		operator = makeLiteralStage(float64(token.Value.(time.Time).Unix()))
+	case DURATION:
+		symbol = LITERAL
+		operator = makeLiteralStage(token.Value.(time.Duration).Seconds())
+	case IP:
+		fallthrough
+	case CIDR:
+		symbol = LITERAL
+		operator = makeLiteralStage(token.Value)
 
 	case PREFIX:
 		stream.rewind()
 		return planPrefix(stream)
+
+	case ARRAY_OPEN:
+		return planArray(stream, tokenIndex)
+
+	case MAP_OPEN:
+		return planMap(stream, tokenIndex)
+
+	case QUANTIFIER:
+		return planQuantifier(stream, token, tokenIndex)
+
+	case LAMBDA:
+		errorMsg := fmt.Sprintf("The binding '%v =>' can only be given to any(), all() or count()", token.Value)
+		return nil, newParseError(token, nil, errorMsg)
 	}
 
 	if operator == nil {
@@ -392,9 +502,192 @@ func planValue(stream *tokenStream) (*evaluationStage, error) {
 	return &evaluationStage{
 		symbol: symbol,
 		operator: operator,
+		tokenIndex: tokenIndex,
+	}, nil
+}
+
+/*
+	Plans "[1, 2, 3]". Like the arguments of functions, a list of items is the array, while a single item,
+	even a list itself as in "[(1, 2)]", is its only item.
+*/
+func planArray(stream *tokenStream, tokenIndex int) (*evaluationStage, error) {
+
+	var items *evaluationStage
+	var err error
+
+	if !nextTokenIs(stream, BRACKET_CLOSE) {
+
+		items, err = planSeparator(stream)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = expectToken(stream, BRACKET_CLOSE, "Expected ']' to close the array")
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &evaluationStage{
+		symbol:     ARRAY,
+		rightStage: items,
+		operator:   makeArrayStage(items != nil && items.symbol == SEPARATE),
+		tokenIndex: tokenIndex,
+	}
+
+	if items == nil {
+		ret.operator = emptyArrayStage
+	}
+	return ret, nil
+}
+
+/*
+	Plans "{'a': 1, 'b': 2}". Keys are strings, and the values are planned as one list, in the order of the keys.
+*/
+func planMap(stream *tokenStream, tokenIndex int) (*evaluationStage, error) {
+
+	var keys []string
+	var values *evaluationStage
+
+	found := make(map[string]bool)
+
+	for !nextTokenIs(stream, MAP_CLOSE) {
+
+		if len(keys) > 0 {
+			_, err := expectToken(stream, SEPARATOR, "Expected ',' or '}' after a value of the map")
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		key, err := expectToken(stream, STRING, "Map keys must be strings")
+		if err != nil {
+			return nil, err
+		}
+
+		name := key.Value.(string)
+		if found[name] {
+			errorMsg := fmt.Sprintf("Duplicate key '%s' in map", name)
+			return nil, newParseError(key, nil, errorMsg)
+		}
+		found[name] = true
+
+		colon, err := expectToken(stream, TERNARY, "Expected ':' after a key of the map")
+		if err != nil {
+			return nil, err
+		}
+		if colon.Value != ":" {
+			return nil, newParseError(colon, []TokenKind{TERNARY}, "Expected ':' after a key of the map")
+		}
+
+		value, err := planTernary(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, name)
+		if values == nil {
+			values = value
+			continue
+		}
+
+		values = &evaluationStage{
+			symbol:     SEPARATE,
+			leftStage:  values,
+			rightStage: value,
+			operator:   separatorStage,
+			tokenIndex: tokenIndex,
+		}
+	}
+
+	// the closing brace
+	stream.next()
+
+	return &evaluationStage{
+		symbol:     MAP,
+		rightStage: values,
+		operator:   makeMapStage(keys),
+		tokenIndex: tokenIndex,
+		keys:       keys,
+	}, nil
+}
+
+/*
+	Plans "any(list, x => condition)", and likewise all() and count(). The condition is evaluated once for every item of the list,
+	with the item as the parameter x; see EvaluableExpression.evaluateQuantifier.
+*/
+func planQuantifier(stream *tokenStream, token ExpressionToken, tokenIndex int) (*evaluationStage, error) {
+
+	name := token.Value.(string)
+	usage := fmt.Sprintf("Expected a list and a binding, as in '%s(list, x => condition)'", name)
+
+	_, err := expectToken(stream, CLAUSE, usage)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := planTernary(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = expectToken(stream, SEPARATOR, usage)
+	if err != nil {
+		return nil, err
+	}
+
+	binding, err := expectToken(stream, LAMBDA, usage)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := planTernary(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = expectToken(stream, CLAUSE_CLOSE, usage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &evaluationStage{
+
+		symbol:          quantifierSymbols[name],
+		leftStage:       list,
+		rightStage:      body,
+		typeErrorFormat: "Value '%v' cannot be used with the quantifier '%v', it is not an array",
+		tokenIndex:      tokenIndex,
+		binding:         binding.Value.(string),
 	}, nil
 }
 
+func nextTokenIs(stream *tokenStream, kind TokenKind) bool {
+	return stream.hasNext() && stream.tokens[stream.index].Kind == kind
+}
+
+/*
+	Reads the next token, which must be of the given [kind], returning a ParseError with [message] otherwise.
+*/
+func expectToken(stream *tokenStream, kind TokenKind, message string) (ExpressionToken, error) {
+
+	if !stream.hasNext() {
+
+		var end ExpressionToken
+		if stream.index > 0 {
+			last := stream.tokens[stream.index-1]
+			end.Offset = last.Offset + last.Length
+		}
+		return end, newParseError(end, []TokenKind{kind}, message)
+	}
+
+	token := stream.next()
+	if token.Kind != kind {
+		return token, newParseError(token, []TokenKind{kind}, message)
+	}
+	return token, nil
+}
+
 /*
 	Convenience function to pass a triplet of typechecks between `findTypeChecks` and `planPrecedenceLevel`.
 	Each of these members may be nil, which indicates that type does not matter for that value.
@@ -437,7 +730,7 @@ func findTypeChecks(symbol OperatorSymbol) typeChecks {
 		}
 	case IN:
 		return typeChecks{
-			right: isArray,
+			right: isArrayOrNetwork,
 		}
 	case BITWISE_LSHIFT:
 		fallthrough
@@ -526,7 +819,8 @@ func reorderStages(rootStage *evaluationStage) {
 
 		currentPrecedence = findOperatorPrecedenceForSymbol(currentStage.symbol)
 
-		if currentPrecedence == precedence {
+		// parenthesis, and other bracketed stages, break chains of their own, even when nested directly inside one another.
+		if currentPrecedence == precedence && currentPrecedence != noopPrecedence {
 			identicalPrecedences = append(identicalPrecedences, currentStage)
 			continue
 		}
@@ -593,6 +887,24 @@ func mirrorStageSubtree(stages []*evaluationStage) {
 	}
 }
 
+/*
+	Separators append to the list built by the separator on their left, so that "1, 2, 3" is one list.
+	The first separator of a list starts a new one instead, so that arrays given as values (as in "join(names, ',')") are kept whole.
+*/
+func planSeparators(root *evaluationStage) {
+
+	if root == nil {
+		return
+	}
+
+	planSeparators(root.leftStage)
+	planSeparators(root.rightStage)
+
+	if root.symbol == SEPARATE && (root.leftStage == nil || root.leftStage.symbol != SEPARATE) {
+		root.operator = startSeparatorStage
+	}
+}
+
 /*
 	Recurses through all operators in the entire tree, eliding operators where both sides are literals.
 */
@@ -632,6 +944,8 @@ func elideStage(root *evaluationStage) *evaluationStage {
 	case SEPARATE:
 		fallthrough
 	case IN:
+		fallthrough
+	case ANY, ALL, COUNT:
 		return root
 	}
 
@@ -671,5 +985,6 @@ func elideStage(root *evaluationStage) *evaluationStage {
 	return &evaluationStage {
 		symbol: LITERAL,
 		operator: makeLiteralStage(result),
+		tokenIndex: root.tokenIndex,
 	}
 }
@@ -0,0 +1,323 @@
+package govaluate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+	A set of functions to parse expressions with, by name. It can be given anywhere a map of functions can.
+*/
+type FunctionRegistry map[string]ExpressionFunction
+
+/*
+	Returns a registry with the standard library of functions, which are only available to expressions parsed with it:
+
+		string:     len, lower, upper, contains, startsWith, split, join, format
+		math:       abs, min, max, round, floor, ceil
+		time:       now, parseTime, duration, addDuration
+		networking: cidrContains, isIPv4, isIPv6
+
+	Times are numbers of seconds since the Unix epoch, like date literals, and durations are numbers of seconds.
+	IPs and networks may be given as their literals or parameters, or as strings.
+	Every function checks the number and types of its arguments, and returns an error naming itself if they're wrong.
+	The returned registry is a copy, and can be changed freely.
+*/
+func StandardFunctions() FunctionRegistry {
+
+	ret := make(FunctionRegistry, len(standardFunctions))
+	for name, function := range standardFunctions {
+		ret[name] = function
+	}
+	return ret
+}
+
+/*
+	Returns a copy of this registry, with the given [overrides] added, or replacing functions of the same name.
+	A nil override removes the function of that name.
+*/
+func (this FunctionRegistry) With(overrides map[string]ExpressionFunction) FunctionRegistry {
+
+	ret := make(FunctionRegistry, len(this)+len(overrides))
+	for name, function := range this {
+		ret[name] = function
+	}
+
+	for name, function := range overrides {
+		if function == nil {
+			delete(ret, name)
+			continue
+		}
+		ret[name] = function
+	}
+	return ret
+}
+
+/*
+	Describes the arguments of a standard function. The last type in [arguments] repeats if [variadic],
+	and only the first [required] arguments must be given.
+*/
+type functionSignature struct {
+	name      string
+	arguments []Type
+	required  int
+	variadic  bool
+}
+
+func makeCheckedFunction(signature functionSignature, body ExpressionFunction) ExpressionFunction {
+
+	return func(arguments ...interface{}) (interface{}, error) {
+
+		err := signature.check(arguments)
+		if err != nil {
+			return nil, err
+		}
+		return body(arguments...)
+	}
+}
+
+func (this functionSignature) check(arguments []interface{}) error {
+
+	count := len(arguments)
+	if count < this.required || (!this.variadic && count > len(this.arguments)) {
+
+		var expected string
+		switch {
+		case this.variadic:
+			expected = fmt.Sprintf("at least %d", this.required)
+		case this.required == len(this.arguments):
+			expected = fmt.Sprintf("%d", this.required)
+		default:
+			expected = fmt.Sprintf("%d to %d", this.required, len(this.arguments))
+		}
+
+		errorMsg := fmt.Sprintf("Function '%s' takes %s argument(s), got %d", this.name, expected, count)
+		return errors.New(errorMsg)
+	}
+
+	for i, argument := range arguments {
+
+		expected := this.arguments[len(this.arguments)-1]
+		if i < len(this.arguments) {
+			expected = this.arguments[i]
+		}
+
+		if !isValueOfType(argument, expected) {
+			errorMsg := fmt.Sprintf("Argument %d of function '%s' must be of type %s, got '%v'", i+1, this.name, expected, argument)
+			return errors.New(errorMsg)
+		}
+	}
+	return nil
+}
+
+func isValueOfType(value interface{}, typ Type) bool {
+
+	switch typ {
+	case TypeAny:
+		return true
+	case TypeNumber, TypeTime:
+		return isFloat64(value)
+	case TypeString:
+		return isString(value)
+	case TypeBool:
+		return isBool(value)
+	case TypeArray:
+		return isArray(value)
+	case TypeIP:
+		return isString(value) || isIP(value)
+	case TypeCIDR:
+		return isString(value) || isNetwork(value)
+	}
+	return false
+}
+
+var standardFunctions = map[string]ExpressionFunction{
+
+	// strings
+
+	"len": makeCheckedFunction(functionSignature{name: "len", arguments: []Type{TypeAny}, required: 1},
+		func(arguments ...interface{}) (interface{}, error) {
+
+			switch value := arguments[0].(type) {
+			case string:
+				return float64(utf8.RuneCountInString(value)), nil
+			case []interface{}:
+				return float64(len(value)), nil
+			}
+			return nil, errors.New(fmt.Sprintf("Argument 1 of function 'len' must be a string or an array, got '%v'", arguments[0]))
+		}),
+	"lower": makeCheckedFunction(functionSignature{name: "lower", arguments: []Type{TypeString}, required: 1},
+		func(arguments ...interface{}) (interface{}, error) {
+			return strings.ToLower(arguments[0].(string)), nil
+		}),
+	"upper": makeCheckedFunction(functionSignature{name: "upper", arguments: []Type{TypeString}, required: 1},
+		func(arguments ...interface{}) (interface{}, error) {
+			return strings.ToUpper(arguments[0].(string)), nil
+		}),
+	"contains": makeCheckedFunction(functionSignature{name: "contains", arguments: []Type{TypeAny, TypeAny}, required: 2},
+		func(arguments ...interface{}) (interface{}, error) {
+
+			switch value := arguments[0].(type) {
+			case string:
+				if !isString(arguments[1]) {
+					return nil, errors.New(fmt.Sprintf("Argument 2 of function 'contains' must be a string, got '%v'", arguments[1]))
+				}
+				return strings.Contains(value, arguments[1].(string)), nil
+			case []interface{}:
+				for _, element := range value {
+					if element == arguments[1] {
+						return true, nil
+					}
+				}
+				return false, nil
+			}
+			return nil, errors.New(fmt.Sprintf("Argument 1 of function 'contains' must be a string or an array, got '%v'", arguments[0]))
+		}),
+	"startsWith": makeCheckedFunction(functionSignature{name: "startsWith", arguments: []Type{TypeString, TypeString}, required: 2},
+		func(arguments ...interface{}) (interface{}, error) {
+			return strings.HasPrefix(arguments[0].(string), arguments[1].(string)), nil
+		}),
+	"split": makeCheckedFunction(functionSignature{name: "split", arguments: []Type{TypeString, TypeString}, required: 2},
+		func(arguments ...interface{}) (interface{}, error) {
+
+			splits := strings.Split(arguments[0].(string), arguments[1].(string))
+			ret := make([]interface{}, len(splits))
+			for i, split := range splits {
+				ret[i] = split
+			}
+			return ret, nil
+		}),
+	"join": makeCheckedFunction(functionSignature{name: "join", arguments: []Type{TypeArray, TypeString}, required: 2},
+		func(arguments ...interface{}) (interface{}, error) {
+
+			elements := arguments[0].([]interface{})
+			strs := make([]string, len(elements))
+			for i, element := range elements {
+				strs[i] = fmt.Sprintf("%v", element)
+			}
+			return strings.Join(strs, arguments[1].(string)), nil
+		}),
+	"format": makeCheckedFunction(functionSignature{name: "format", arguments: []Type{TypeString, TypeAny}, required: 1, variadic: true},
+		func(arguments ...interface{}) (interface{}, error) {
+			return fmt.Sprintf(arguments[0].(string), arguments[1:]...), nil
+		}),
+
+	// math
+
+	"abs": makeCheckedFunction(functionSignature{name: "abs", arguments: []Type{TypeNumber}, required: 1},
+		func(arguments ...interface{}) (interface{}, error) {
+			return math.Abs(arguments[0].(float64)), nil
+		}),
+	"min": makeCheckedFunction(functionSignature{name: "min", arguments: []Type{TypeNumber}, required: 1, variadic: true},
+		func(arguments ...interface{}) (interface{}, error) {
+
+			ret := arguments[0].(float64)
+			for _, argument := range arguments[1:] {
+				ret = math.Min(ret, argument.(float64))
+			}
+			return ret, nil
+		}),
+	"max": makeCheckedFunction(functionSignature{name: "max", arguments: []Type{TypeNumber}, required: 1, variadic: true},
+		func(arguments ...interface{}) (interface{}, error) {
+
+			ret := arguments[0].(float64)
+			for _, argument := range arguments[1:] {
+				ret = math.Max(ret, argument.(float64))
+			}
+			return ret, nil
+		}),
+	"round": makeCheckedFunction(functionSignature{name: "round", arguments: []Type{TypeNumber}, required: 1},
+		func(arguments ...interface{}) (interface{}, error) {
+			return math.Round(arguments[0].(float64)), nil
+		}),
+	"floor": makeCheckedFunction(functionSignature{name: "floor", arguments: []Type{TypeNumber}, required: 1},
+		func(arguments ...interface{}) (interface{}, error) {
+			return math.Floor(arguments[0].(float64)), nil
+		}),
+	"ceil": makeCheckedFunction(functionSignature{name: "ceil", arguments: []Type{TypeNumber}, required: 1},
+		func(arguments ...interface{}) (interface{}, error) {
+			return math.Ceil(arguments[0].(float64)), nil
+		}),
+
+	// time
+
+	"now": makeCheckedFunction(functionSignature{name: "now"},
+		func(arguments ...interface{}) (interface{}, error) {
+			return float64(time.Now().Unix()), nil
+		}),
+	"parseTime": makeCheckedFunction(functionSignature{name: "parseTime", arguments: []Type{TypeAny, TypeString}, required: 1},
+		func(arguments ...interface{}) (interface{}, error) {
+
+			// string literals which look like dates are already times by the time they get here.
+			if isFloat64(arguments[0]) && len(arguments) == 1 {
+				return arguments[0], nil
+			}
+
+			candidate, ok := arguments[0].(string)
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("Argument 1 of function 'parseTime' must be of type string, got '%v'", arguments[0]))
+			}
+
+			// without a layout, the same formats as date literals are accepted.
+			if len(arguments) == 1 {
+				ret, found := tryParseTime(candidate)
+				if !found {
+					return nil, errors.New(fmt.Sprintf("Function 'parseTime' cannot parse '%s' as a time", candidate))
+				}
+				return float64(ret.Unix()), nil
+			}
+
+			ret, err := time.ParseInLocation(arguments[1].(string), candidate, time.Local)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Function 'parseTime' cannot parse '%s' as a time: %v", candidate, err))
+			}
+			return float64(ret.Unix()), nil
+		}),
+	"duration": makeCheckedFunction(functionSignature{name: "duration", arguments: []Type{TypeString}, required: 1},
+		func(arguments ...interface{}) (interface{}, error) {
+
+			ret, err := time.ParseDuration(arguments[0].(string))
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Function 'duration' cannot parse '%s' as a duration: %v", arguments[0], err))
+			}
+			return ret.Seconds(), nil
+		}),
+	"addDuration": makeCheckedFunction(functionSignature{name: "addDuration", arguments: []Type{TypeTime, TypeNumber}, required: 2},
+		func(arguments ...interface{}) (interface{}, error) {
+			return arguments[0].(float64) + arguments[1].(float64), nil
+		}),
+
+	// networking
+
+	"cidrContains": makeCheckedFunction(functionSignature{name: "cidrContains", arguments: []Type{TypeCIDR, TypeIP}, required: 2},
+		func(arguments ...interface{}) (interface{}, error) {
+
+			network, ok := toNetwork(arguments[0])
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("Function 'cidrContains' cannot parse '%v' as a CIDR", arguments[0]))
+			}
+
+			ip, ok := toIP(arguments[1])
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("Function 'cidrContains' cannot parse '%v' as an IP address", arguments[1]))
+			}
+			return networkContains(network, ip), nil
+		}),
+	"isIPv4": makeCheckedFunction(functionSignature{name: "isIPv4", arguments: []Type{TypeIP}, required: 1},
+		func(arguments ...interface{}) (interface{}, error) {
+
+			ip, ok := toIP(arguments[0])
+			return ok && len(ip) == net.IPv4len, nil
+		}),
+	"isIPv6": makeCheckedFunction(functionSignature{name: "isIPv6", arguments: []Type{TypeIP}, required: 1},
+		func(arguments ...interface{}) (interface{}, error) {
+
+			ip, ok := toIP(arguments[0])
+			return ok && len(ip) == net.IPv6len, nil
+		}),
+}
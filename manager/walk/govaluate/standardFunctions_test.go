@@ -0,0 +1,261 @@
+package govaluate
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStandardFunctions(test *testing.T) {
+
+	functions := StandardFunctions()
+	created := float64(time.Date(2014, time.January, 2, 0, 0, 0, 0, time.Local).Unix())
+
+	evaluationTests := []EvaluationTest{
+
+		EvaluationTest{
+			Name:      "String length",
+			Input:     "len('bär')",
+			Functions: functions,
+			Expected:  3.0,
+		},
+		EvaluationTest{
+			Name:      "Array length",
+			Input:     "len(dns)",
+			Functions: functions,
+			Parameters: []EvaluationParameter{
+				EvaluationParameter{
+					Name:  "dns",
+					Value: []interface{}{"1.1.1.1", "8.8.8.8"},
+				},
+			},
+			Expected: 2.0,
+		},
+		EvaluationTest{
+			Name:      "Case",
+			Input:     "lower('WireGuard') + upper('wg')",
+			Functions: functions,
+			Expected:  "wireguardWG",
+		},
+		EvaluationTest{
+			Name:      "String contains",
+			Input:     "contains('wireguard', 'guard') && startsWith('wireguard', 'wire')",
+			Functions: functions,
+			Expected:  true,
+		},
+		EvaluationTest{
+			Name:      "Array contains",
+			Input:     "contains(split('a,b,c', ','), 'd')",
+			Functions: functions,
+			Expected:  false,
+		},
+		EvaluationTest{
+			Name:      "Split and join",
+			Input:     "join(split('a,b,c', ','), '-')",
+			Functions: functions,
+			Expected:  "a-b-c",
+		},
+		EvaluationTest{
+			Name:      "Format",
+			Input:     "format('%v:%v', 'host', 51820)",
+			Functions: functions,
+			Expected:  "host:51820",
+		},
+		EvaluationTest{
+			Name:      "Absolute and rounding",
+			Input:     "abs(-2) + round(1.5) + floor(1.7) + ceil(1.2)",
+			Functions: functions,
+			Expected:  7.0,
+		},
+		EvaluationTest{
+			Name:      "Minimum and maximum",
+			Input:     "min(3, 1, 2) + max(3, 10, 2) + max(5)",
+			Functions: functions,
+			Expected:  16.0,
+		},
+		EvaluationTest{
+			Name:      "Now",
+			Input:     "now() > '2014-01-02'",
+			Functions: functions,
+			Expected:  true,
+		},
+		EvaluationTest{
+			Name:      "Parse time",
+			Input:     "parseTime('2014-01-02') == '2014-01-02' && parseTime(stamp) == '2014-01-02' && parseTime('2014.01.02', '2006.01.02') == '2014-01-02'",
+			Functions: functions,
+			Parameters: []EvaluationParameter{
+				EvaluationParameter{
+					Name:  "stamp",
+					Value: "2014-01-02",
+				},
+			},
+			Expected: true,
+		},
+		EvaluationTest{
+			Name:      "Durations",
+			Input:     "addDuration(created, duration('1h30m'))",
+			Functions: functions,
+			Parameters: []EvaluationParameter{
+				EvaluationParameter{
+					Name:  "created",
+					Value: created,
+				},
+			},
+			Expected: created + 5400,
+		},
+		EvaluationTest{
+			Name:      "CIDR containment",
+			Input:     "cidrContains('10.0.0.0/8', '10.1.2.3') && !cidrContains('fd00::/8', '::1')",
+			Functions: functions,
+			Expected:  true,
+		},
+		EvaluationTest{
+			Name:      "CIDR containment of addresses",
+			Input:     "cidrContains(10.0.0.0/8, 10.1.2.3) && cidrContains(network, ip) && cidrContains('10.0.0.0/8', ip) && !cidrContains(network, '::1')",
+			Functions: functions,
+			Parameters: []EvaluationParameter{
+				EvaluationParameter{
+					Name:  "ip",
+					Value: net.ParseIP("10.1.2.3"),
+				},
+				EvaluationParameter{
+					Name:  "network",
+					Value: net.IPNet{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+				},
+			},
+			Expected: true,
+		},
+		EvaluationTest{
+			Name:      "IP versions of addresses",
+			Input:     "isIPv4(ip) && !isIPv6(ip) && isIPv6(fe80::1) && !isIPv4(fe80::1) && isIPv4(::ffff:10.0.0.1)",
+			Functions: functions,
+			Parameters: []EvaluationParameter{
+				EvaluationParameter{
+					Name:  "ip",
+					Value: net.ParseIP("10.1.2.3"),
+				},
+			},
+			Expected: true,
+		},
+		EvaluationTest{
+			Name:      "IP versions",
+			Input:     "isIPv4('10.0.0.1') && !isIPv4('fd00::1') && isIPv6('fd00::1') && !isIPv6('10.0.0.1') && !isIPv6('host')",
+			Functions: functions,
+			Expected:  true,
+		},
+		EvaluationTest{
+			Name:  "Overridden function",
+			Input: "lower('A') + upper('a')",
+			Functions: functions.With(map[string]ExpressionFunction{
+				"upper": func(arguments ...interface{}) (interface{}, error) {
+					return "overridden", nil
+				},
+			}),
+			Expected: "aoverridden",
+		},
+	}
+
+	runEvaluationTests(evaluationTests, test)
+}
+
+func TestStandardFunctionFailures(test *testing.T) {
+
+	functions := StandardFunctions()
+
+	evaluationTests := []EvaluationFailureTest{
+
+		EvaluationFailureTest{
+			Name:      "Too few arguments",
+			Input:     "startsWith('a')",
+			Functions: functions,
+			Expected:  "Function 'startsWith' takes 2 argument(s), got 1",
+		},
+		EvaluationFailureTest{
+			Name:      "Too many arguments",
+			Input:     "parseTime('a', 'b', 'c')",
+			Functions: functions,
+			Expected:  "Function 'parseTime' takes 1 to 2 argument(s), got 3",
+		},
+		EvaluationFailureTest{
+			Name:      "No arguments to variadic",
+			Input:     "min()",
+			Functions: functions,
+			Expected:  "Function 'min' takes at least 1 argument(s), got 0",
+		},
+		EvaluationFailureTest{
+			Name:      "Arguments to nullary",
+			Input:     "now(1)",
+			Functions: functions,
+			Expected:  "Function 'now' takes 0 argument(s), got 1",
+		},
+		EvaluationFailureTest{
+			Name:      "Wrong argument type",
+			Input:     "lower(1)",
+			Functions: functions,
+			Expected:  "Argument 1 of function 'lower' must be of type string, got '1'",
+		},
+		EvaluationFailureTest{
+			Name:      "Wrong variadic argument type",
+			Input:     "max(1, 2, 'three')",
+			Functions: functions,
+			Expected:  "Argument 3 of function 'max' must be of type number",
+		},
+		EvaluationFailureTest{
+			Name:      "Length of a number",
+			Input:     "len(1)",
+			Functions: functions,
+			Expected:  "must be a string or an array",
+		},
+		EvaluationFailureTest{
+			Name:      "Invalid duration",
+			Input:     "duration('soon')",
+			Functions: functions,
+			Expected:  "Function 'duration' cannot parse 'soon'",
+		},
+		EvaluationFailureTest{
+			Name:      "Invalid time",
+			Input:     "parseTime('soon')",
+			Functions: functions,
+			Expected:  "Function 'parseTime' cannot parse 'soon' as a time",
+		},
+		EvaluationFailureTest{
+			Name:      "Invalid CIDR",
+			Input:     "cidrContains('10.0.0.0', '10.0.0.1')",
+			Functions: functions,
+			Expected:  "cannot parse '10.0.0.0' as a CIDR",
+		},
+		EvaluationFailureTest{
+			Name:      "IP as a CIDR",
+			Input:     "cidrContains(10.0.0.1, 10.0.0.1)",
+			Functions: functions,
+			Expected:  "Argument 1 of function 'cidrContains' must be of type cidr",
+		},
+		EvaluationFailureTest{
+			Name:      "Number as an IP",
+			Input:     "isIPv4(1)",
+			Functions: functions,
+			Expected:  "Argument 1 of function 'isIPv4' must be of type ip",
+		},
+	}
+
+	runEvaluationFailureTests(evaluationTests, test)
+}
+
+func TestStandardFunctionRegistry(test *testing.T) {
+
+	functions := StandardFunctions()
+	delete(functions, "len")
+
+	if _, found := StandardFunctions()["len"]; !found {
+		test.Errorf("Expected changes to a registry not to affect the standard library")
+	}
+
+	withoutNow := StandardFunctions().With(map[string]ExpressionFunction{"now": nil})
+	if _, err := NewEvaluableExpressionWithFunctions("now()", withoutNow); err == nil {
+		test.Errorf("Expected a nil override to remove the function")
+	}
+
+	if _, err := NewEvaluableExpression("lower('A')"); err == nil {
+		test.Errorf("Expected the standard library to be opt-in")
+	}
+}
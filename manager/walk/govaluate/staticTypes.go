@@ -0,0 +1,441 @@
+package govaluate
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+/*
+	Represents the static type of a parameter or of an expression, as inferred at parse time.
+	TypeAny is used wherever a type can't be known before evaluation, such as for function results.
+*/
+type Type int
+
+const (
+	TypeAny Type = iota
+	TypeNumber
+	TypeString
+	TypeBool
+	TypeTime
+	TypeArray
+	TypeMap
+	TypeDuration
+	TypeIP
+	TypeCIDR
+)
+
+func (this Type) String() string {
+
+	switch this {
+	case TypeAny:
+		return "any"
+	case TypeNumber:
+		return "number"
+	case TypeString:
+		return "string"
+	case TypeBool:
+		return "bool"
+	case TypeTime:
+		return "time"
+	case TypeArray:
+		return "array"
+	case TypeMap:
+		return "map"
+	case TypeDuration:
+		return "duration"
+	case TypeIP:
+		return "ip"
+	case TypeCIDR:
+		return "cidr"
+	}
+	return "unknown"
+}
+
+/*
+	Parses a type from its name, as returned by [Type.String].
+*/
+func ParseType(name string) (Type, error) {
+
+	switch strings.ToLower(name) {
+	case "any":
+		return TypeAny, nil
+	case "number":
+		return TypeNumber, nil
+	case "string":
+		return TypeString, nil
+	case "bool":
+		return TypeBool, nil
+	case "time":
+		return TypeTime, nil
+	case "array":
+		return TypeArray, nil
+	case "map":
+		return TypeMap, nil
+	case "duration":
+		return TypeDuration, nil
+	case "ip":
+		return TypeIP, nil
+	case "cidr":
+		return TypeCIDR, nil
+	}
+
+	errorMsg := fmt.Sprintf("Unknown type '%s'", name)
+	return TypeAny, errors.New(errorMsg)
+}
+
+/*
+	Maps parameter names to their types. Accessors are looked up by their full dotted name ("peer.Endpoint.Host"),
+	or, failing that, by any parent declared as TypeAny, which permits every member below it.
+*/
+type TypeSchema map[string]Type
+
+/*
+	Returned when an expression is ill-typed according to the schema it was parsed with.
+	Position is the index of the offending token, and Offset and Length where it is in [Expression], in bytes.
+*/
+type TypeError struct {
+	Token    ExpressionToken
+	Position int
+
+	Expression string
+	Offset     int
+	Length     int
+
+	Message string
+}
+
+func (this *TypeError) Error() string {
+	return fmt.Sprintf("%s (at offset %d)", this.Message, this.Offset)
+}
+
+/*
+	Returns the line of the expression containing the offending token, with carets underneath it.
+*/
+func (this *TypeError) Snippet() string {
+	return annotateSnippet(this.Expression, this.Offset, this.Length)
+}
+
+func (this TypeSchema) lookup(name string) (Type, bool) {
+
+	typ, found := this[name]
+	if found {
+		return typ, true
+	}
+
+	for i := strings.LastIndexByte(name, '.'); i > 0; i = strings.LastIndexByte(name[:i], '.') {
+		typ, found = this[name[:i]]
+		if found && typ == TypeAny {
+			return TypeAny, true
+		}
+	}
+	return TypeAny, false
+}
+
+/*
+	Returns a copy of the schema where [name], the binding of a quantifier, is declared as TypeAny, hiding any parameter of that name.
+*/
+func (this TypeSchema) bind(name string) TypeSchema {
+
+	ret := TypeSchema{name: TypeAny}
+	for key, typ := range this {
+		if key != name && !strings.HasPrefix(key, name+".") {
+			ret[key] = typ
+		}
+	}
+	return ret
+}
+
+/*
+	Infers the type of the given [stage], returning a TypeError if any operator in it can't be used with the types of its operands.
+*/
+func checkStageTypes(stage *evaluationStage, tokens []ExpressionToken, schema TypeSchema) (Type, error) {
+
+	var left, right Type
+	var err error
+
+	if stage == nil {
+		return TypeAny, nil
+	}
+
+	if stage.leftStage != nil {
+		left, err = checkStageTypes(stage.leftStage, tokens, schema)
+		if err != nil {
+			return TypeAny, err
+		}
+	}
+
+	// the condition of a quantifier is checked below, with its binding.
+	if stage.rightStage != nil && !stage.isQuantifier() {
+		right, err = checkStageTypes(stage.rightStage, tokens, schema)
+		if err != nil {
+			return TypeAny, err
+		}
+	}
+
+	fail := func(format string, args ...interface{}) (Type, error) {
+
+		ret := &TypeError{
+			Position: stage.tokenIndex,
+			Message:  fmt.Sprintf(format, args...),
+		}
+		if stage.tokenIndex >= 0 && stage.tokenIndex < len(tokens) {
+			ret.Token = tokens[stage.tokenIndex]
+			ret.Offset = ret.Token.Offset
+			ret.Length = ret.Token.Length
+		}
+		return TypeAny, ret
+	}
+
+	switch stage.symbol {
+
+	case VALUE:
+		token := tokens[stage.tokenIndex]
+		name := ""
+
+		switch token.Kind {
+		case VARIABLE:
+			name = token.Value.(string)
+		case ACCESSOR:
+			name = strings.Join(token.Value.([]string), ".")
+		}
+
+		typ, found := schema.lookup(name)
+		if !found {
+			return fail("Parameter '%s' is not declared in the schema", name)
+		}
+		return typ, nil
+
+	case LITERAL:
+		switch tokens[stage.tokenIndex].Kind {
+		case TIME:
+			return TypeTime, nil
+		case DURATION:
+			return TypeDuration, nil
+		}
+
+		value, err := stage.operator(nil, nil, nil)
+		if err != nil {
+			return TypeAny, nil
+		}
+		return typeOfValue(value), nil
+
+	case NOOP:
+		return right, nil
+
+	case FUNCTIONAL:
+		return TypeAny, nil
+
+	case SEPARATE, ARRAY:
+		return TypeArray, nil
+
+	case MAP:
+		return TypeMap, nil
+
+	case INDEX:
+		switch {
+		case left == TypeArray && !isTypeOrAny(right, TypeNumber):
+			return fail("Value of type %s cannot be used as an index of an array, it is not a number", right)
+		case left == TypeMap && !isTypeOrAny(right, TypeString):
+			return fail("Value of type %s cannot be used as a key of a map, it is not a string", right)
+		case !isTypeOrAny(left, TypeArray, TypeMap):
+			return fail("Value of type %s cannot be indexed, it is not an array, a map or a struct", left)
+		}
+		return TypeAny, nil
+
+	case ANY, ALL, COUNT:
+		if !isTypeOrAny(left, TypeArray) {
+			return fail("Value of type %s cannot be used with the quantifier '%s', it is not an array", left, operatorString(stage, tokens))
+		}
+
+		condition, err := checkStageTypes(stage.rightStage, tokens, schema.bind(stage.binding))
+		if err != nil {
+			return TypeAny, err
+		}
+		if !isTypeOrAny(condition, TypeBool) {
+			return fail("Value of type %s cannot be used as the condition of '%s', it is not a bool", condition, operatorString(stage, tokens))
+		}
+
+		if stage.symbol == COUNT {
+			return TypeNumber, nil
+		}
+		return TypeBool, nil
+
+	case PLUS:
+		if left == TypeString || right == TypeString {
+			return TypeString, nil
+		}
+		fallthrough
+
+	case MINUS, MULTIPLY, DIVIDE, MODULUS, EXPONENT,
+		BITWISE_AND, BITWISE_OR, BITWISE_XOR, BITWISE_LSHIFT, BITWISE_RSHIFT:
+		if typ, found := durationArithmetic(stage.symbol, left, right); found {
+			return typ, nil
+		}
+		if !isTypeOrAny(left, TypeNumber) {
+			return fail("Value of type %s cannot be used with the modifier '%s', it is not a number", left, operatorString(stage, tokens))
+		}
+		if !isTypeOrAny(right, TypeNumber) {
+			return fail("Value of type %s cannot be used with the modifier '%s', it is not a number", right, operatorString(stage, tokens))
+		}
+		return TypeNumber, nil
+
+	case NEGATE, BITWISE_NOT:
+		if stage.symbol == NEGATE && right == TypeDuration {
+			return TypeDuration, nil
+		}
+		if !isTypeOrAny(right, TypeNumber) {
+			return fail("Value of type %s cannot be used with the prefix '%s'", right, operatorString(stage, tokens))
+		}
+		return TypeNumber, nil
+
+	case INVERT:
+		if !isTypeOrAny(right, TypeBool) {
+			return fail("Value of type %s cannot be used with the prefix '%s'", right, operatorString(stage, tokens))
+		}
+		return TypeBool, nil
+
+	case GT, LT, GTE, LTE:
+		if !isTypeOrAny(left, orderedTypes...) || !isTypeOrAny(right, orderedTypes...) ||
+			!compatibleTypes(left, right) {
+			return fail("Values of type %s and %s cannot be compared with '%s'", left, right, operatorString(stage, tokens))
+		}
+		return TypeBool, nil
+
+	case EQ, NEQ:
+		if !compatibleTypes(left, right) {
+			return fail("Values of type %s and %s cannot be compared with '%s', they are never equal", left, right, operatorString(stage, tokens))
+		}
+		return TypeBool, nil
+
+	case REQ, NREQ:
+		if !isTypeOrAny(left, TypeString) {
+			return fail("Value of type %s cannot be matched with '%s', it is not a string", left, operatorString(stage, tokens))
+		}
+		if !isTypeOrAny(right, TypeString) {
+			return fail("Value of type %s cannot be used as a pattern with '%s', it is not a string", right, operatorString(stage, tokens))
+		}
+		return TypeBool, nil
+
+	case AND, OR:
+		if !isTypeOrAny(left, TypeBool) {
+			return fail("Value of type %s cannot be used with the logical operator '%s', it is not a bool", left, operatorString(stage, tokens))
+		}
+		if !isTypeOrAny(right, TypeBool) {
+			return fail("Value of type %s cannot be used with the logical operator '%s', it is not a bool", right, operatorString(stage, tokens))
+		}
+		return TypeBool, nil
+
+	case IN:
+		if right == TypeCIDR && !isTypeOrAny(left, TypeIP, TypeCIDR, TypeString) {
+			return fail("Value of type %s cannot be used with the comparator '%s' on a network, it is not an IP, a network or a string", left, operatorString(stage, tokens))
+		}
+		if !isTypeOrAny(right, TypeArray, TypeCIDR) {
+			return fail("Value of type %s cannot be used with the comparator '%s', it is not an array or a network", right, operatorString(stage, tokens))
+		}
+		return TypeBool, nil
+
+	case TERNARY_TRUE:
+		if !isTypeOrAny(left, TypeBool) {
+			return fail("Value of type %s cannot be used with the ternary operator '%s', it is not a bool", left, operatorString(stage, tokens))
+		}
+		return right, nil
+
+	case TERNARY_FALSE, COALESCE:
+		if left == right {
+			return left, nil
+		}
+		return TypeAny, nil
+	}
+
+	return TypeAny, nil
+}
+
+func typeOfValue(value interface{}) Type {
+
+	switch value.(type) {
+	case float64:
+		return TypeNumber
+	case string, *regexp.Regexp:
+		return TypeString
+	case bool:
+		return TypeBool
+	case []interface{}:
+		return TypeArray
+	case map[string]interface{}:
+		return TypeMap
+	case net.IP:
+		return TypeIP
+	case *net.IPNet:
+		return TypeCIDR
+	}
+	return TypeAny
+}
+
+// the types which can be ordered with '>' and the like, against the same type.
+var orderedTypes = []Type{TypeNumber, TypeString, TypeTime, TypeDuration, TypeIP}
+
+/*
+	Durations are added to and subtracted from times, the difference of two times is a duration,
+	and durations can be scaled by numbers. Returns false if neither side is a time or a duration.
+*/
+func durationArithmetic(symbol OperatorSymbol, left Type, right Type) (Type, bool) {
+
+	if left != TypeTime && left != TypeDuration && right != TypeTime && right != TypeDuration {
+		return TypeAny, false
+	}
+
+	// the other side might be anything, so the result might be too.
+	if left == TypeAny || right == TypeAny {
+		return TypeAny, true
+	}
+
+	switch {
+	case symbol == PLUS && left == TypeTime && right == TypeDuration,
+		symbol == PLUS && left == TypeDuration && right == TypeTime,
+		symbol == MINUS && left == TypeTime && right == TypeDuration:
+		return TypeTime, true
+
+	case symbol == MINUS && left == TypeTime && right == TypeTime:
+		return TypeDuration, true
+
+	case (symbol == PLUS || symbol == MINUS || symbol == MODULUS) && left == TypeDuration && right == TypeDuration,
+		(symbol == MULTIPLY || symbol == DIVIDE) && left == TypeDuration && right == TypeNumber,
+		symbol == MULTIPLY && left == TypeNumber && right == TypeDuration:
+		return TypeDuration, true
+
+	case symbol == DIVIDE && left == TypeDuration && right == TypeDuration:
+		return TypeNumber, true
+	}
+
+	// left to fail as numbers would.
+	return TypeAny, false
+}
+
+func isTypeOrAny(typ Type, allowed ...Type) bool {
+
+	if typ == TypeAny {
+		return true
+	}
+
+	for _, candidate := range allowed {
+		if typ == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+func compatibleTypes(left Type, right Type) bool {
+	return left == TypeAny || right == TypeAny || left == right
+}
+
+func operatorString(stage *evaluationStage, tokens []ExpressionToken) string {
+
+	if stage.tokenIndex >= 0 && stage.tokenIndex < len(tokens) {
+		return fmt.Sprintf("%v", tokens[stage.tokenIndex].Value)
+	}
+	return stage.symbol.String()
+}
@@ -0,0 +1,306 @@
+package govaluate
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+	Represents a test of static type inference, which either infers [Expected] or fails with [ExpectedError] at token [Position].
+*/
+type StaticTypeTest struct {
+	Name          string
+	Input         string
+	Expected      Type
+	ExpectedError string
+	Position      int
+}
+
+var staticTypeSchema = TypeSchema{
+	"port":    TypeNumber,
+	"name":    TypeString,
+	"enabled": TypeBool,
+	"created": TypeTime,
+	"dns":     TypeArray,
+	"extra":   TypeAny,
+	"foo.Bar": TypeString,
+}
+
+func TestStaticTypes(test *testing.T) {
+
+	testCases := []StaticTypeTest{
+
+		StaticTypeTest{
+			Name:     "Arithmetic",
+			Input:    "port * 2 + 1",
+			Expected: TypeNumber,
+		},
+		StaticTypeTest{
+			Name:     "String concat",
+			Input:    "name + port",
+			Expected: TypeString,
+		},
+		StaticTypeTest{
+			Name:     "Logical",
+			Input:    "enabled && port > 1024 || name =~ '^wg'",
+			Expected: TypeBool,
+		},
+		StaticTypeTest{
+			Name:     "Time comparison",
+			Input:    "created > '2014-01-02'",
+			Expected: TypeBool,
+		},
+		StaticTypeTest{
+			Name:     "Membership",
+			Input:    "'1.1.1.1' IN dns",
+			Expected: TypeBool,
+		},
+		StaticTypeTest{
+			Name:     "Separator",
+			Input:    "port, name",
+			Expected: TypeArray,
+		},
+		StaticTypeTest{
+			Name:     "Ternary",
+			Input:    "enabled ? port : 0",
+			Expected: TypeNumber,
+		},
+		StaticTypeTest{
+			Name:     "Ternary of mixed types",
+			Input:    "enabled ? port : name",
+			Expected: TypeAny,
+		},
+		StaticTypeTest{
+			Name:     "Parenthesis and prefixes",
+			Input:    "-(port - 1) > 0 && !(enabled)",
+			Expected: TypeBool,
+		},
+		StaticTypeTest{
+			Name:     "Declared accessor",
+			Input:    "foo.Bar + '!'",
+			Expected: TypeString,
+		},
+		StaticTypeTest{
+			Name:     "Members of untyped parameters",
+			Input:    "extra.Anything.Goes - 1",
+			Expected: TypeNumber,
+		},
+		StaticTypeTest{
+			Name:     "Elided literals",
+			Input:    "1 + 2 > 2",
+			Expected: TypeBool,
+		},
+		StaticTypeTest{
+			Name:          "Undeclared parameter",
+			Input:         "port > missing",
+			ExpectedError: "Parameter 'missing' is not declared",
+			Position:      2,
+		},
+		StaticTypeTest{
+			Name:          "Undeclared accessor",
+			Input:         "foo.Baz == ''",
+			ExpectedError: "Parameter 'foo.Baz' is not declared",
+			Position:      0,
+		},
+		StaticTypeTest{
+			Name:          "Modifier on string",
+			Input:         "port > 0 && name - 1 > 0",
+			ExpectedError: "Value of type string cannot be used with the modifier '-'",
+			Position:      5,
+		},
+		StaticTypeTest{
+			Name:          "Comparing number to string",
+			Input:         "port > name",
+			ExpectedError: "Values of type number and string cannot be compared with '>'",
+			Position:      1,
+		},
+		StaticTypeTest{
+			Name:          "Comparing time to number",
+			Input:         "created < 10",
+			ExpectedError: "Values of type time and number cannot be compared",
+			Position:      1,
+		},
+		StaticTypeTest{
+			Name:          "Equality that never holds",
+			Input:         "enabled == 'true'",
+			ExpectedError: "they are never equal",
+			Position:      1,
+		},
+		StaticTypeTest{
+			Name:          "Logical on number",
+			Input:         "enabled || port",
+			ExpectedError: "logical operator '||', it is not a bool",
+			Position:      1,
+		},
+		StaticTypeTest{
+			Name:          "Regex on number",
+			Input:         "port =~ '[0-9]+'",
+			ExpectedError: "cannot be matched with '=~'",
+			Position:      1,
+		},
+		StaticTypeTest{
+			Name:          "Membership of a string",
+			Input:         "'a' IN name",
+			ExpectedError: "it is not an array",
+			Position:      1,
+		},
+		StaticTypeTest{
+			Name:          "Ternary on number",
+			Input:         "port ? 1 : 2",
+			ExpectedError: "ternary operator '?'",
+			Position:      1,
+		},
+		StaticTypeTest{
+			Name:          "Inverting a string",
+			Input:         "!name",
+			ExpectedError: "cannot be used with the prefix '!'",
+			Position:      0,
+		},
+		StaticTypeTest{
+			Name:     "Map literal",
+			Input:    "{'port': port, 'dns': [name]}",
+			Expected: TypeMap,
+		},
+		StaticTypeTest{
+			Name:     "Index",
+			Input:    "dns[port] == {'a': 1}[name]",
+			Expected: TypeBool,
+		},
+		StaticTypeTest{
+			Name:     "Quantifiers",
+			Input:    "count(dns, d => d == name) > 1 && all(dns, port => port > 1)",
+			Expected: TypeBool,
+		},
+		StaticTypeTest{
+			Name:          "Index of a number",
+			Input:         "port[0]",
+			ExpectedError: "cannot be indexed",
+			Position:      1,
+		},
+		StaticTypeTest{
+			Name:          "Array index which isn't a number",
+			Input:         "[1, 2][name]",
+			ExpectedError: "cannot be used as an index of an array",
+			Position:      5,
+		},
+		StaticTypeTest{
+			Name:          "Quantified string",
+			Input:         "any(name, n => true)",
+			ExpectedError: "quantifier 'any', it is not an array",
+			Position:      0,
+		},
+		StaticTypeTest{
+			Name:          "Condition which isn't a bool",
+			Input:         "any(dns, d => port)",
+			ExpectedError: "condition of 'any', it is not a bool",
+			Position:      0,
+		},
+	}
+
+	runStaticTypeTests(testCases, staticTypeSchema, test)
+}
+
+func TestStaticTypesWithoutSchema(test *testing.T) {
+
+	expression, err := NewEvaluableExpression("foo - 'bar'")
+	if err != nil {
+		test.Fatalf("Expected no error without a schema, got '%v'", err)
+	}
+
+	if expression.ResultType() != TypeAny {
+		test.Errorf("Expected no type to be inferred without a schema, got %v", expression.ResultType())
+	}
+}
+
+func TestStaticTimeParameters(test *testing.T) {
+
+	expression, err := NewEvaluableExpression("created > '2014-01-02'", staticTypeSchema)
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	result, err := expression.Evaluate(map[string]interface{}{
+		"created": time.Date(2015, time.January, 1, 0, 0, 0, 0, time.Local),
+	})
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	if result != true {
+		test.Errorf("Expected a time parameter to compare against a time literal, got %v", result)
+	}
+}
+
+func TestStaticTypeErrorSnippet(test *testing.T) {
+
+	_, err := NewEvaluableExpression("enabled &&\n  name - 1 > 0", staticTypeSchema)
+
+	typeError, ok := err.(*TypeError)
+	if !ok {
+		test.Fatalf("Expected a type error, got '%v'", err)
+	}
+
+	if typeError.Offset != 18 || typeError.Length != 1 {
+		test.Errorf("Expected the error at offset 18, found %d+%d", typeError.Offset, typeError.Length)
+	}
+
+	expected := "  name - 1 > 0\n       ^"
+	if typeError.Snippet() != expected {
+		test.Errorf("Expected snippet:\n%s\nFound:\n%s", expected, typeError.Snippet())
+	}
+}
+
+func TestParseType(test *testing.T) {
+
+	for _, typ := range []Type{TypeAny, TypeNumber, TypeString, TypeBool, TypeTime, TypeArray, TypeMap} {
+
+		parsed, err := ParseType(typ.String())
+		if err != nil || parsed != typ {
+			test.Errorf("Expected '%s' to parse as itself, got %v (%v)", typ, parsed, err)
+		}
+	}
+
+	_, err := ParseType("complex")
+	if err == nil {
+		test.Errorf("Expected an unknown type name to fail")
+	}
+}
+
+func runStaticTypeTests(testCases []StaticTypeTest, schema TypeSchema, test *testing.T) {
+
+	for _, testCase := range testCases {
+
+		expression, err := NewEvaluableExpression(testCase.Input, schema)
+
+		if testCase.ExpectedError == "" {
+
+			if err != nil {
+				test.Logf("Test '%s' failed to parse: %v", testCase.Name, err)
+				test.Fail()
+				continue
+			}
+
+			if expression.ResultType() != testCase.Expected {
+				test.Logf("Test '%s' failed", testCase.Name)
+				test.Logf("Expected type %v, inferred %v", testCase.Expected, expression.ResultType())
+				test.Fail()
+			}
+			continue
+		}
+
+		typeError, ok := err.(*TypeError)
+		if !ok {
+			test.Logf("Test '%s' failed", testCase.Name)
+			test.Logf("Expected a type error, got '%v'", err)
+			test.Fail()
+			continue
+		}
+
+		if !strings.Contains(typeError.Message, testCase.ExpectedError) || typeError.Position != testCase.Position {
+			test.Logf("Test '%s' failed", testCase.Name)
+			test.Logf("Got error '%v', expected '%s' at token %d", typeError, testCase.ExpectedError, testCase.Position)
+			test.Fail()
+		}
+	}
+}
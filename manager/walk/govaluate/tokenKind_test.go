@@ -22,12 +22,23 @@ func TestTokenKindStrings(test *testing.T) {
 		PATTERN,
 		TIME,
 		VARIABLE,
+		ACCESSOR,
 		COMPARATOR,
 		LOGICALOP,
 		MODIFIER,
 		CLAUSE,
 		CLAUSE_CLOSE,
 		TERNARY,
+		ARRAY_OPEN,
+		INDEX_OPEN,
+		BRACKET_CLOSE,
+		MAP_OPEN,
+		MAP_CLOSE,
+		QUANTIFIER,
+		LAMBDA,
+		DURATION,
+		IP,
+		CIDR,
 	}
 
 	for _, kind := range kinds {
@@ -0,0 +1,324 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+	"git.zx2c4.com/wireguard-windows/manager/policy"
+)
+
+// parseFile parses a configuration file outside of the tunnel store, naming
+// it after the file, or "stdin" when it was read from standard input.
+func parseFile(filename string, contents []byte) (*conf.Config, error) {
+	name := conf.TunnelName("stdin")
+	if filename != "-" {
+		var err error
+		name, err = conf.NameFromPath(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return conf.FromWgQuick(string(contents), string(name))
+}
+
+// checkPolicy refuses configurations that the administrator's policy does
+// not allow to be imported or activated.
+func checkPolicy(config *conf.Config) error {
+	p, err := policy.LoadDefault()
+	if err != nil {
+		return err
+	}
+	return p.Check(config)
+}
+
+type lintResult struct {
+	File     string   `json:"file"`
+	Errors   []string `json:"errors"`
+	Warnings []string `json:"warnings"`
+}
+
+func lintConfig(config *conf.Config) (warnings []string) {
+	if len(config.Interface.Addresses) == 0 {
+		warnings = append(warnings, "Interface has no addresses")
+	}
+	if len(config.Peers) == 0 {
+		warnings = append(warnings, "Interface has no peers")
+	}
+	publicKey := *config.Interface.PrivateKey.Public()
+	seen := make(map[conf.Key]int)
+	for i, peer := range config.Peers {
+		if peer.PublicKey == publicKey {
+			warnings = append(warnings, fmt.Sprintf("Peer %d has the public key of this interface", i+1))
+		}
+		if j, ok := seen[peer.PublicKey]; ok {
+			warnings = append(warnings, fmt.Sprintf("Peer %d has the same public key as peer %d", i+1, j+1))
+		}
+		seen[peer.PublicKey] = i
+		if len(peer.AllowedIPs) == 0 {
+			warnings = append(warnings, fmt.Sprintf("Peer %d has no allowed IPs, so no traffic is sent to it", i+1))
+		}
+	}
+	for _, conflict := range config.RouteConflicts() {
+		warnings = append(warnings, conflict.Explain(config))
+	}
+	return
+}
+
+func lint(flags *flag.FlagSet, jsonOutput bool) error {
+	if flags.NArg() == 0 {
+		return usageError("No files given")
+	}
+	results := make([]lintResult, 0, flags.NArg())
+	failed := false
+	for _, filename := range flags.Args() {
+		result := lintResult{File: filename, Errors: []string{}, Warnings: []string{}}
+		contents, err := readFileOrStdin(filename)
+		var config *conf.Config
+		if err == nil {
+			config, err = parseFile(filename, contents)
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			failed = true
+		} else {
+			result.Warnings = append(result.Warnings, lintConfig(config)...)
+			if err = checkPolicy(config); err != nil {
+				if violations, ok := err.(*policy.Violations); ok {
+					for i := range violations.Violations {
+						result.Errors = append(result.Errors, "Not allowed by policy: "+violations.Violations[i].Error())
+					}
+				} else {
+					result.Errors = append(result.Errors, err.Error())
+				}
+				failed = true
+			}
+		}
+		results = append(results, result)
+	}
+	if jsonOutput {
+		if err := writeJSON(results); err != nil {
+			return err
+		}
+	} else {
+		for _, result := range results {
+			for _, e := range result.Errors {
+				fmt.Fprintf(os.Stderr, "%s: error: %s\n", result.File, e)
+			}
+			for _, w := range result.Warnings {
+				fmt.Fprintf(os.Stderr, "%s: warning: %s\n", result.File, w)
+			}
+		}
+	}
+	if failed {
+		return errAlreadyReported
+	}
+	return nil
+}
+
+var formatInPlace *bool
+
+func formatFlags(flags *flag.FlagSet) {
+	formatInPlace = flags.Bool("w", false, "write the result back to the files instead of to stdout")
+}
+
+type formatResult struct {
+	File    string `json:"file"`
+	Config  string `json:"config,omitempty"`
+	Changed bool   `json:"changed"`
+}
+
+// format drops comments and unknown formatting; it is meant for generated
+// or machine-edited files rather than hand-annotated ones.
+func format(flags *flag.FlagSet, jsonOutput bool) error {
+	if flags.NArg() == 0 {
+		return usageError("No files given")
+	}
+	var results []formatResult
+	for _, filename := range flags.Args() {
+		if *formatInPlace && filename == "-" {
+			return usageError("Cannot write stdin in place")
+		}
+		original, err := readFileOrStdin(filename)
+		if err != nil {
+			return err
+		}
+		config, err := parseFile(filename, original)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		formatted := config.ToWgQuick()
+		result := formatResult{File: filename, Changed: formatted != string(original)}
+		if *formatInPlace {
+			if result.Changed {
+				if err = ioutil.WriteFile(filename, []byte(formatted), 0600); err != nil {
+					return err
+				}
+			}
+		} else if jsonOutput {
+			result.Config = formatted
+		} else {
+			os.Stdout.WriteString(formatted)
+		}
+		results = append(results, result)
+	}
+	if jsonOutput {
+		return writeJSON(results)
+	}
+	return nil
+}
+
+var importPolicy *string
+
+func importFlags(flags *flag.FlagSet) {
+	importPolicy = flags.String("on-collision", "skip", "what to do when a tunnel of the same name exists: skip, rename or overwrite")
+}
+
+type importResult struct {
+	File    string `json:"file"`
+	Name    string `json:"name,omitempty"`
+	Skipped bool   `json:"skipped"`
+	Error   string `json:"error,omitempty"`
+}
+
+func importConfigs(flags *flag.FlagSet, jsonOutput bool) error {
+	if flags.NArg() == 0 {
+		return usageError("No files given")
+	}
+	var onCollision conf.CollisionPolicy
+	switch *importPolicy {
+	case "skip":
+		onCollision = conf.CollisionSkip
+	case "rename":
+		onCollision = conf.CollisionRename
+	case "overwrite":
+		onCollision = conf.CollisionOverwrite
+	default:
+		return usageError(fmt.Sprintf("Invalid collision policy ‘%s’", *importPolicy))
+	}
+
+	p, err := policy.LoadDefault()
+	if err != nil {
+		return err
+	}
+
+	var results []conf.ImportResult
+	for _, filename := range flags.Args() {
+		if strings.EqualFold(filepath.Ext(filename), ".zip") {
+			zipResults, err := conf.ImportZipFile(filename, onCollision, p.Check)
+			if err != nil {
+				results = append(results, conf.ImportResult{FileName: filename, Err: err})
+			}
+			results = append(results, zipResults...)
+		} else {
+			results = append(results, conf.ImportFile(filename, onCollision, p.Check))
+		}
+	}
+
+	failed := false
+	output := make([]importResult, 0, len(results))
+	for _, r := range results {
+		result := importResult{File: r.FileName, Name: string(r.Name), Skipped: r.Skipped}
+		if r.Err != nil {
+			result.Error = r.Err.Error()
+			failed = true
+		}
+		output = append(output, result)
+		if jsonOutput {
+			continue
+		}
+		switch {
+		case r.Err != nil:
+			fmt.Fprintf(os.Stderr, "%s: %v\n", r.FileName, r.Err)
+		case r.Skipped:
+			fmt.Printf("%s: skipped, a tunnel of that name already exists\n", r.FileName)
+		default:
+			fmt.Printf("%s: imported as %s\n", r.FileName, r.Name)
+		}
+	}
+	if jsonOutput {
+		if err := writeJSON(output); err != nil {
+			return err
+		}
+	}
+	if failed {
+		return errAlreadyReported
+	}
+	return nil
+}
+
+func exportConfigs(flags *flag.FlagSet, jsonOutput bool) error {
+	if err := requireArgs(flags, 1); err != nil {
+		return err
+	}
+	filename := flags.Arg(0)
+	if filename == "-" {
+		return conf.ExportZip(os.Stdout)
+	}
+	if err := conf.ExportZipFile(filename); err != nil {
+		return err
+	}
+	if jsonOutput {
+		return writeJSON(map[string]string{"file": filename})
+	}
+	return nil
+}
+
+type routeCandidate struct {
+	PublicKey string `json:"public_key"`
+	Prefix    string `json:"prefix"`
+	Shadowed  bool   `json:"shadowed"`
+}
+
+type routeResult struct {
+	Address    string           `json:"address"`
+	PublicKey  string           `json:"public_key,omitempty"`
+	Prefix     string           `json:"prefix,omitempty"`
+	Candidates []routeCandidate `json:"candidates"`
+}
+
+func route(flags *flag.FlagSet, jsonOutput bool) error {
+	if err := requireArgs(flags, 2); err != nil {
+		return err
+	}
+	name, err := conf.NewTunnelName(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(flags.Arg(1))
+	if ip == nil {
+		return usageError(fmt.Sprintf("Invalid IP address ‘%s’", flags.Arg(1)))
+	}
+	config, err := conf.LoadFromName(name)
+	if err != nil {
+		return err
+	}
+	decision := config.Route(ip)
+	if !jsonOutput {
+		fmt.Print(decision.Explain(config))
+		return nil
+	}
+	result := routeResult{Address: ip.String(), Candidates: []routeCandidate{}}
+	if peer := decision.Peer(config); peer != nil {
+		result.PublicKey = peer.PublicKey.String()
+		result.Prefix = decision.Prefix.String()
+	}
+	for _, c := range decision.Candidates {
+		result.Candidates = append(result.Candidates, routeCandidate{
+			PublicKey: config.Peers[c.PeerIndex].PublicKey.String(),
+			Prefix:    c.Prefix.String(),
+			Shadowed:  c.Shadowed,
+		})
+	}
+	return writeJSON(result)
+}
@@ -0,0 +1,60 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+func printKey(jsonOutput bool, field string, key *conf.Key) error {
+	if jsonOutput {
+		return writeJSON(map[string]string{field: key.String()})
+	}
+	_, err := fmt.Println(key.String())
+	return err
+}
+
+func genkey(flags *flag.FlagSet, jsonOutput bool) error {
+	if err := requireArgs(flags, 0); err != nil {
+		return err
+	}
+	key, err := conf.NewPrivateKey()
+	if err != nil {
+		return err
+	}
+	return printKey(jsonOutput, "private_key", key)
+}
+
+func genpsk(flags *flag.FlagSet, jsonOutput bool) error {
+	if err := requireArgs(flags, 0); err != nil {
+		return err
+	}
+	key, err := conf.NewPresharedKey()
+	if err != nil {
+		return err
+	}
+	return printKey(jsonOutput, "preshared_key", key)
+}
+
+func pubkey(flags *flag.FlagSet, jsonOutput bool) error {
+	if err := requireArgs(flags, 0); err != nil {
+		return err
+	}
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	key, err := conf.ParseKey(string(input))
+	if err != nil {
+		return err
+	}
+	return printKey(jsonOutput, "public_key", key.Public())
+}
@@ -0,0 +1,126 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+type command struct {
+	name        string
+	arguments   string
+	description string
+	run         func(flags *flag.FlagSet, jsonOutput bool) error
+	setup       func(flags *flag.FlagSet)
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "genkey", description: "Generates a new private key and writes it to stdout", run: genkey},
+		{name: "genpsk", description: "Generates a new preshared key and writes it to stdout", run: genpsk},
+		{name: "pubkey", description: "Reads a private key from stdin and writes its public key to stdout", run: pubkey},
+		{name: "show", arguments: "[<tunnel>]", description: "Shows the current state of running tunnels", run: show},
+		{name: "showconf", arguments: "<tunnel>", description: "Shows the current configuration of a running tunnel, completed from its stored configuration", run: showconf},
+		{name: "setconf", arguments: "<tunnel> <file>", description: "Replaces the current configuration of a running tunnel with a file", run: setconf},
+		{name: "save", arguments: "<tunnel>", description: "Saves the endpoints learned by a running tunnel into its stored configuration", run: save},
+		{name: "set", arguments: "<tunnel> [listen-port <port>] [private-key <file>] [peer <key> [remove] [preshared-key <file>] [endpoint <host:port>] [persistent-keepalive <seconds>] [allowed-ips <prefix>[,...]]]...", description: "Changes the current configuration of a running tunnel", run: set},
+		{name: "stats", arguments: "<tunnel>", description: "Shows the recent transfer history of a running tunnel", run: transferHistory, setup: statsFlags},
+		{name: "lint", arguments: "<file>...", description: "Checks configuration files for errors and likely mistakes", run: lint},
+		{name: "fmt", arguments: "<file>...", description: "Rewrites configuration files in canonical form", run: format, setup: formatFlags},
+		{name: "import", arguments: "<file.conf|file.zip>...", description: "Adds configuration files to the tunnel store", run: importConfigs, setup: importFlags},
+		{name: "export", arguments: "<file.zip|->", description: "Writes all stored tunnels into a zip archive", run: exportConfigs},
+		{name: "route", arguments: "<tunnel> <address>", description: "Explains which peer of a stored tunnel receives traffic for an address", run: route},
+		{name: "up", arguments: "<tunnel>", description: "Starts a stored tunnel", run: up, setup: upFlags},
+		{name: "down", arguments: "<tunnel>", description: "Stops a running tunnel", run: down},
+	}
+}
+
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <cmd> [-json] [<args>]\n\nAvailable subcommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", c.name, c.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nYou may pass -help to any of these subcommands to view usage.\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+	name := os.Args[1]
+	if name == "help" || name == "-help" || name == "--help" || name == "-h" {
+		usage()
+		return
+	}
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		flags := flag.NewFlagSet(c.name, flag.ExitOnError)
+		jsonOutput := flags.Bool("json", false, "write machine-readable output")
+		if c.setup != nil {
+			c.setup(flags)
+		}
+		flags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: %s %s [flags] %s\n", os.Args[0], c.name, c.arguments)
+			flags.PrintDefaults()
+		}
+		flags.Parse(os.Args[2:])
+		err := c.run(flags, *jsonOutput)
+		if err == nil {
+			return
+		}
+		if _, ok := err.(usageError); ok {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", c.name, err)
+			flags.Usage()
+			os.Exit(1)
+		}
+		if err != errAlreadyReported {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", c.name, err)
+		}
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Invalid subcommand: ‘%s’\n", name)
+	usage()
+	os.Exit(1)
+}
+
+// errAlreadyReported makes a subcommand fail without printing anything more.
+var errAlreadyReported = errors.New("")
+
+func writeJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(v)
+}
+
+func readFileOrStdin(filename string) ([]byte, error) {
+	if filename == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(filename)
+}
+
+func requireArgs(flags *flag.FlagSet, n int) error {
+	if flags.NArg() != n {
+		return usageError(fmt.Sprintf("Expected %d arguments, got %d", n, flags.NArg()))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+	"git.zx2c4.com/wireguard-windows/manager/humanize"
+	"git.zx2c4.com/wireguard-windows/manager/stats"
+)
+
+var (
+	statsSince *time.Duration
+	statsCSV   *bool
+)
+
+func statsFlags(flags *flag.FlagSet) {
+	statsSince = flags.Duration("since", time.Hour, "how far back to go")
+	statsCSV = flags.Bool("csv", false, "write comma-separated values")
+}
+
+func transferHistory(flags *flag.FlagSet, jsonOutput bool) error {
+	if err := requireArgs(flags, 1); err != nil {
+		return err
+	}
+	name, err := conf.NewTunnelName(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	connection, err := connectPipe(stats.ListenerName(name))
+	if err != nil {
+		return fmt.Errorf("Unable to access tunnel %s: %v", name, err)
+	}
+	defer connection.Close()
+	series, err := stats.Fetch(connection, time.Now().Add(-*statsSince))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case jsonOutput:
+		return stats.WriteJSON(os.Stdout, series)
+	case *statsCSV:
+		return stats.WriteCSV(os.Stdout, series)
+	}
+	for _, s := range series {
+		var rx, tx uint64
+		var duration time.Duration
+		for _, p := range s.Points {
+			rx += p.RxBytes
+			tx += p.TxBytes
+			duration += p.Duration
+		}
+		latest := s.Points[len(s.Points)-1]
+		fmt.Printf("peer: %s\n", s.PublicKey.String())
+		fmt.Printf("  transfer: %s received, %s sent in %s\n", humanize.Bytes(rx), humanize.Bytes(tx), humanize.Duration(duration))
+		fmt.Printf("  current rate: %s received, %s sent\n", humanize.Rate(latest.RxRate()), humanize.Rate(latest.TxRate()))
+	}
+	return nil
+}
@@ -0,0 +1,574 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+	"git.zx2c4.com/wireguard-windows/manager/humanize"
+)
+
+type peerStatus struct {
+	PublicKey           string   `json:"public_key"`
+	HasPresharedKey     bool     `json:"has_preshared_key"`
+	Endpoint            string   `json:"endpoint,omitempty"`
+	AllowedIPs          []string `json:"allowed_ips"`
+	LatestHandshake     int64    `json:"latest_handshake"` // Unix time, or 0 for never.
+	RxBytes             uint64   `json:"rx_bytes"`
+	TxBytes             uint64   `json:"tx_bytes"`
+	PersistentKeepalive uint16   `json:"persistent_keepalive"`
+}
+
+type tunnelStatus struct {
+	Name       string       `json:"name"`
+	PublicKey  string       `json:"public_key"`
+	ListenPort uint16       `json:"listen_port"`
+	Peers      []peerStatus `json:"peers"`
+}
+
+func statusOf(config *conf.Config) tunnelStatus {
+	status := tunnelStatus{
+		Name:       string(config.Name),
+		PublicKey:  config.Interface.PrivateKey.Public().String(),
+		ListenPort: config.Interface.ListenPort,
+		Peers:      []peerStatus{},
+	}
+	for _, peer := range config.Peers {
+		p := peerStatus{
+			PublicKey:           peer.PublicKey.String(),
+			HasPresharedKey:     !peer.PresharedKey.IsZero(),
+			AllowedIPs:          []string{},
+			RxBytes:             peer.RxBytes,
+			TxBytes:             peer.TxBytes,
+			PersistentKeepalive: peer.PersistentKeepalive,
+		}
+		if !peer.Endpoint.IsEmpty() {
+			p.Endpoint = peer.Endpoint.String()
+		}
+		for _, allowedIP := range peer.AllowedIPs {
+			p.AllowedIPs = append(p.AllowedIPs, allowedIP.String())
+		}
+		if peer.LastHandshakeTime != 0 {
+			p.LatestHandshake = int64(time.Duration(peer.LastHandshakeTime) / time.Second)
+		}
+		status.Peers = append(status.Peers, p)
+	}
+	return status
+}
+
+func printStatus(config *conf.Config) {
+	fmt.Printf("interface: %s\n", config.Name)
+	fmt.Printf("  public key: %s\n", config.Interface.PrivateKey.Public().String())
+	fmt.Printf("  private key: (hidden)\n")
+	if config.Interface.ListenPort > 0 {
+		fmt.Printf("  listening port: %d\n", config.Interface.ListenPort)
+	}
+	for _, peer := range config.Peers {
+		fmt.Printf("\npeer: %s\n", peer.PublicKey.String())
+		if !peer.PresharedKey.IsZero() {
+			fmt.Printf("  preshared key: (hidden)\n")
+		}
+		if !peer.Endpoint.IsEmpty() {
+			fmt.Printf("  endpoint: %s\n", peer.Endpoint.String())
+		}
+		allowedIPs := make([]string, len(peer.AllowedIPs))
+		for i := range peer.AllowedIPs {
+			allowedIPs[i] = peer.AllowedIPs[i].String()
+		}
+		if len(allowedIPs) == 0 {
+			allowedIPs = append(allowedIPs, "(none)")
+		}
+		fmt.Printf("  allowed ips: %s\n", strings.Join(allowedIPs, ", "))
+		if peer.LastHandshakeTime != 0 {
+			fmt.Printf("  latest handshake: %s\n", peer.LastHandshakeTime.String())
+		}
+		if peer.RxBytes > 0 || peer.TxBytes > 0 {
+			fmt.Printf("  transfer: %s received, %s sent\n", humanize.Bytes(peer.RxBytes), humanize.Bytes(peer.TxBytes))
+		}
+		if peer.PersistentKeepalive > 0 {
+			fmt.Printf("  persistent keepalive: every %d seconds\n", peer.PersistentKeepalive)
+		}
+	}
+}
+
+func show(flags *flag.FlagSet, jsonOutput bool) error {
+	if flags.NArg() > 1 {
+		return usageError("Too many arguments")
+	}
+	var configs []*conf.Config
+	if flags.NArg() == 1 {
+		name, err := conf.NewTunnelName(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		config, err := uapiGet(name, &conf.Config{Name: name})
+		if err != nil {
+			return fmt.Errorf("Unable to access tunnel %s: %v", name, err)
+		}
+		configs = append(configs, config)
+	} else {
+		// There is no registry of running tunnels, so try every stored one.
+		names, err := conf.ListConfigNames()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			config, err := uapiGet(name, &conf.Config{Name: name})
+			if err == nil {
+				configs = append(configs, config)
+			}
+		}
+	}
+
+	if jsonOutput {
+		if flags.NArg() == 1 {
+			return writeJSON(statusOf(configs[0]))
+		}
+		statuses := make([]tunnelStatus, 0, len(configs))
+		for _, config := range configs {
+			statuses = append(statuses, statusOf(config))
+		}
+		return writeJSON(statuses)
+	}
+	for i, config := range configs {
+		if i > 0 {
+			fmt.Println()
+		}
+		printStatus(config)
+	}
+	return nil
+}
+
+// runningConfig returns the configuration of a running tunnel, completed with
+// the addresses, DNS servers and MTU of the stored tunnel of the same name.
+func runningConfig(name conf.TunnelName) (running *conf.Config, stored *conf.Config, err error) {
+	stored, err = conf.LoadFromName(name)
+	if err != nil {
+		stored = nil
+	}
+	base := stored
+	if base == nil {
+		base = &conf.Config{Name: name}
+	}
+	running, err = uapiGet(name, base)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to access tunnel %s: %v", name, err)
+	}
+	return running, stored, nil
+}
+
+func showconf(flags *flag.FlagSet, jsonOutput bool) error {
+	if err := requireArgs(flags, 1); err != nil {
+		return err
+	}
+	name, err := conf.NewTunnelName(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	config, _, err := runningConfig(name)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return writeJSON(map[string]string{"name": string(name), "config": config.ToWgQuick()})
+	}
+	fmt.Print(config.ToWgQuick())
+	return nil
+}
+
+func setconf(flags *flag.FlagSet, jsonOutput bool) error {
+	if err := requireArgs(flags, 2); err != nil {
+		return err
+	}
+	name, err := conf.NewTunnelName(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	contents, err := readFileOrStdin(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	config, err := conf.FromWgQuick(string(contents), string(name))
+	if err != nil {
+		return err
+	}
+	if err = checkPolicy(config); err != nil {
+		return err
+	}
+	operation, err := config.ToUAPI()
+	if err != nil {
+		return err
+	}
+	err = uapiSet(name, operation)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return writeJSON(map[string]string{"name": string(name)})
+	}
+	return nil
+}
+
+type saveResult struct {
+	Name    string   `json:"name"`
+	Updated []string `json:"updated"`
+}
+
+// save is the equivalent of wg-quick's SaveConfig, but limited to what the
+// tunnel learns while running: the endpoints its peers roam to.
+func save(flags *flag.FlagSet, jsonOutput bool) error {
+	if err := requireArgs(flags, 1); err != nil {
+		return err
+	}
+	name, err := conf.NewTunnelName(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	running, stored, err := runningConfig(name)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return fmt.Errorf("Tunnel %s is not stored", name)
+	}
+	result := saveResult{Name: string(stored.Name), Updated: []string{}}
+	for _, key := range stored.LearnEndpoints(running) {
+		result.Updated = append(result.Updated, key.String())
+	}
+	if len(result.Updated) > 0 {
+		err = stored.Save()
+		if err != nil {
+			return err
+		}
+	}
+	if jsonOutput {
+		return writeJSON(result)
+	}
+	for _, key := range result.Updated {
+		fmt.Printf("peer %s: endpoint updated\n", key)
+	}
+	return nil
+}
+
+func readKeyFile(filename string) (*conf.Key, error) {
+	contents, err := readFileOrStdin(filename)
+	if err != nil {
+		return nil, err
+	}
+	return conf.ParseKey(string(contents))
+}
+
+// setOperation translates wg(8)-style set arguments into a UAPI set operation.
+func setOperation(args []string) (string, error) {
+	var output strings.Builder
+	inPeer := false
+	for i := 0; i < len(args); i++ {
+		option := args[i]
+		if option == "remove" {
+			if !inPeer {
+				return "", usageError("‘remove’ must follow a peer")
+			}
+			output.WriteString("remove=true\n")
+			continue
+		}
+		if i+1 >= len(args) {
+			return "", usageError(fmt.Sprintf("‘%s’ needs a value", option))
+		}
+		i++
+		value := args[i]
+		switch option {
+		case "listen-port", "private-key":
+			if inPeer {
+				return "", usageError(fmt.Sprintf("‘%s’ must come before any peer", option))
+			}
+		case "peer":
+		default:
+			if !inPeer {
+				return "", usageError(fmt.Sprintf("‘%s’ must follow a peer", option))
+			}
+		}
+		switch option {
+		case "listen-port":
+			port, err := strconv.ParseUint(value, 10, 16)
+			if err != nil {
+				return "", fmt.Errorf("Invalid port ‘%s’", value)
+			}
+			output.WriteString(fmt.Sprintf("listen_port=%d\n", port))
+		case "private-key":
+			key, err := readKeyFile(value)
+			if err != nil {
+				return "", err
+			}
+			output.WriteString(fmt.Sprintf("private_key=%s\n", key.HexString()))
+		case "peer":
+			key, err := conf.ParseKey(value)
+			if err != nil {
+				return "", err
+			}
+			output.WriteString(fmt.Sprintf("public_key=%s\n", key.HexString()))
+			inPeer = true
+		case "preshared-key":
+			key, err := readKeyFile(value)
+			if err != nil {
+				return "", err
+			}
+			output.WriteString(fmt.Sprintf("preshared_key=%s\n", key.HexString()))
+		case "endpoint":
+			endpoint, err := conf.ParseEndpoint(value)
+			if err != nil {
+				return "", err
+			}
+			endpoint, err = endpoint.Resolve()
+			if err != nil {
+				return "", err
+			}
+			output.WriteString(fmt.Sprintf("endpoint=%s\n", endpoint.String()))
+		case "persistent-keepalive":
+			interval := uint64(0)
+			if value != "off" {
+				var err error
+				interval, err = strconv.ParseUint(value, 10, 16)
+				if err != nil {
+					return "", fmt.Errorf("Invalid persistent keepalive ‘%s’", value)
+				}
+			}
+			output.WriteString(fmt.Sprintf("persistent_keepalive_interval=%d\n", interval))
+		case "allowed-ips":
+			output.WriteString("replace_allowed_ips=true\n")
+			for _, s := range strings.Split(value, ",") {
+				s = strings.TrimSpace(s)
+				if len(s) == 0 {
+					continue
+				}
+				allowedIP, err := conf.ParseIPCidr(s)
+				if err != nil {
+					return "", err
+				}
+				output.WriteString(fmt.Sprintf("allowed_ip=%s\n", allowedIP.String()))
+			}
+		default:
+			return "", usageError(fmt.Sprintf("Invalid option ‘%s’", option))
+		}
+	}
+	return output.String(), nil
+}
+
+func set(flags *flag.FlagSet, jsonOutput bool) error {
+	if flags.NArg() < 2 {
+		return usageError("Expected a tunnel and at least one option")
+	}
+	name, err := conf.NewTunnelName(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	operation, err := setOperation(flags.Args()[1:])
+	if err != nil {
+		return err
+	}
+	// The policy applies to the configuration that the change results in.
+	config, _, err := runningConfig(name)
+	if err != nil {
+		return err
+	}
+	if err = config.ApplyUAPI(operation); err != nil {
+		return err
+	}
+	if err = checkPolicy(config); err != nil {
+		return err
+	}
+	err = uapiSet(name, operation)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return writeJSON(map[string]string{"name": string(name)})
+	}
+	return nil
+}
+
+func pidFilePath(name conf.TunnelName) (string, error) {
+	root, err := conf.RootDirectory()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "Running")
+	err = os.MkdirAll(dir, os.ModeDir|0700)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, string(name)+".pid"), nil
+}
+
+func readPidFile(filename string) (*os.Process, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid process ID file %s", filename)
+	}
+	return os.FindProcess(pid)
+}
+
+var (
+	upServicePath       *string
+	upMetricsAddress    *string
+	upHistoryResolution *time.Duration
+	upHistoryRetention  *time.Duration
+)
+
+func upFlags(flags *flag.FlagSet) {
+	upServicePath = flags.String("service", "", "path of the tunnel service executable (default: next to this program)")
+	upMetricsAddress = flags.String("metrics", "", "loopback address, such as 127.0.0.1:9586, on which to serve Prometheus metrics")
+	upHistoryResolution = flags.Duration("history-resolution", 0, "interval at which the transfer history is sampled (default: 5s)")
+	upHistoryRetention = flags.Duration("history-retention", 0, "period for which the transfer history is kept (default: 24h)")
+}
+
+// The service takes a moment to create its adapter and UAPI listener.
+const serviceStartTimeout = 10 * time.Second
+
+func up(flags *flag.FlagSet, jsonOutput bool) error {
+	if err := requireArgs(flags, 1); err != nil {
+		return err
+	}
+	name, err := conf.NewTunnelName(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	config, err := conf.LoadFromName(name)
+	if err != nil {
+		return err
+	}
+	if err = checkPolicy(config); err != nil {
+		return err
+	}
+	operation, err := config.ToUAPI()
+	if err != nil {
+		return err
+	}
+	pidFile, err := pidFilePath(config.Name)
+	if err != nil {
+		return err
+	}
+	if connection, err := uapiConnect(config.Name); err == nil {
+		connection.Close()
+		return fmt.Errorf("Tunnel %s is already running", config.Name)
+	}
+
+	servicePath := *upServicePath
+	if len(servicePath) == 0 {
+		self, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		servicePath = filepath.Join(filepath.Dir(self), serviceExecutable)
+	}
+	cmd := exec.Command(servicePath, string(config.Name))
+	cmd.Env = os.Environ()
+	if len(*upMetricsAddress) > 0 {
+		cmd.Env = append(cmd.Env, "WIREGUARD_METRICS_ADDRESS="+*upMetricsAddress)
+	}
+	if *upHistoryResolution > 0 {
+		cmd.Env = append(cmd.Env, "WIREGUARD_HISTORY_RESOLUTION="+upHistoryResolution.String())
+	}
+	if *upHistoryRetention > 0 {
+		cmd.Env = append(cmd.Env, "WIREGUARD_HISTORY_RETENTION="+upHistoryRetention.String())
+	}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	fail := func(err error) error {
+		stopProcess(cmd.Process)
+		os.Remove(pidFile)
+		return err
+	}
+	err = ioutil.WriteFile(pidFile, []byte(strconv.Itoa(cmd.Process.Pid)+"\n"), 0600)
+	if err != nil {
+		return fail(err)
+	}
+
+	deadline := time.Now().Add(serviceStartTimeout)
+	for {
+		connection, err := uapiConnect(config.Name)
+		if err == nil {
+			connection.Close()
+			break
+		}
+		select {
+		case err := <-exited:
+			os.Remove(pidFile)
+			if err == nil {
+				err = errors.New("exited")
+			}
+			return fmt.Errorf("Tunnel service failed to start: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			return fail(errors.New("Timed out waiting for the tunnel service to start"))
+		}
+	}
+	err = uapiSet(config.Name, operation)
+	if err != nil {
+		return fail(err)
+	}
+
+	if jsonOutput {
+		return writeJSON(map[string]interface{}{"name": string(config.Name), "pid": cmd.Process.Pid})
+	}
+	return nil
+}
+
+func down(flags *flag.FlagSet, jsonOutput bool) error {
+	if err := requireArgs(flags, 1); err != nil {
+		return err
+	}
+	name, err := conf.NewTunnelName(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	pidFile, err := pidFilePath(name)
+	if err != nil {
+		return err
+	}
+	process, err := readPidFile(pidFile)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("Tunnel %s is not running", name)
+	} else if err != nil {
+		return err
+	}
+	// Killing the service would skip its Down hooks, so it is only a last
+	// resort for services that do not accept requests to stop.
+	control, err := connectPipe(name.ControlName())
+	if err == nil {
+		err = stopService(control)
+	} else {
+		err = stopProcess(process)
+	}
+	os.Remove(pidFile)
+	if err != nil {
+		return fmt.Errorf("Unable to stop tunnel %s: %v", name, err)
+	}
+	if jsonOutput {
+		return writeJSON(map[string]string{"name": string(name)})
+	}
+	return nil
+}
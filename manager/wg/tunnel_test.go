@@ -0,0 +1,76 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+func TestSetOperation(test *testing.T) {
+	dir, err := ioutil.TempDir("", "wireguard-wg-test")
+	if err != nil {
+		test.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	psk, _ := conf.NewPresharedKey()
+	pskFile := filepath.Join(dir, "psk")
+	ioutil.WriteFile(pskFile, []byte(psk.String()+"\n"), 0600)
+	privateKey, _ := conf.NewPrivateKey()
+	peer := privateKey.Public()
+
+	operation, err := setOperation([]string{"listen-port", "51820", "peer", peer.String(), "preshared-key", pskFile, "endpoint", "192.0.2.1:51820", "persistent-keepalive", "off", "allowed-ips", "10.0.0.0/24, fd00::/64"})
+	if err != nil {
+		test.Fatal(err)
+	}
+	expected := "listen_port=51820\npublic_key=" + peer.HexString() + "\npreshared_key=" + psk.HexString() + "\nendpoint=192.0.2.1:51820\npersistent_keepalive_interval=0\nreplace_allowed_ips=true\nallowed_ip=10.0.0.0/24\nallowed_ip=fd00::/64\n"
+	if operation != expected {
+		test.Errorf("Unexpected operation:\n%s\nexpected\n%s", operation, expected)
+	}
+
+	operation, err = setOperation([]string{"peer", peer.String(), "remove"})
+	if err != nil || operation != "public_key="+peer.HexString()+"\nremove=true\n" {
+		test.Errorf("Unexpected operation for remove: %q, %v", operation, err)
+	}
+
+	for _, args := range [][]string{
+		{"remove"},
+		{"endpoint", "192.0.2.1:1"},
+		{"peer", peer.String(), "listen-port", "1"},
+		{"listen-port"},
+		{"listen-port", "65536"},
+		{"peer", "nope"},
+		{"bogus", "value"},
+	} {
+		if _, err := setOperation(args); err == nil {
+			test.Errorf("Expected error for %q", args)
+		}
+	}
+}
+
+func TestStopService(test *testing.T) {
+	for _, errno := range []string{"0", "114"} {
+		client, service := net.Pipe()
+		go func() {
+			request, _ := readUAPIResponse(bufio.NewReader(service))
+			if request != "stop=1\n" {
+				test.Errorf("Unexpected request %q", request)
+			}
+			service.Write([]byte("errno=" + errno + "\n\n"))
+			service.Close()
+		}()
+		err := stopService(client)
+		if (errno == "0") != (err == nil) {
+			test.Errorf("Unexpected result for errno=%s: %v", errno, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+func uapiConnect(name conf.TunnelName) (io.ReadWriteCloser, error) {
+	return connectPipe(name.AdapterName())
+}
+
+// uapiGet returns the running configuration of a tunnel, with the parts
+// unknown to the UAPI, such as addresses and DNS, taken from base.
+func uapiGet(name conf.TunnelName, base *conf.Config) (*conf.Config, error) {
+	connection, err := uapiConnect(name)
+	if err != nil {
+		return nil, err
+	}
+	defer connection.Close()
+	_, err = connection.Write([]byte("get=1\n\n"))
+	if err != nil {
+		return nil, err
+	}
+	response, err := readUAPIResponse(bufio.NewReader(connection))
+	if err != nil {
+		return nil, err
+	}
+	return conf.FromUAPI(response, base)
+}
+
+// uapiSet applies a set operation, given as newline-terminated key=value
+// lines, to a running tunnel.
+func uapiSet(name conf.TunnelName, operation string) error {
+	connection, err := uapiConnect(name)
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
+	_, err = connection.Write([]byte("set=1\n" + operation + "\n"))
+	if err != nil {
+		return err
+	}
+	response, err := readUAPIResponse(bufio.NewReader(connection))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(response) != "errno=0" {
+		return fmt.Errorf("Unable to configure tunnel: %s", strings.TrimSpace(response))
+	}
+	return nil
+}
+
+// stopService asks the service of a tunnel, through its control connection,
+// to shut down, which it confirms once it has run the PreDown and PostDown
+// hooks.
+func stopService(connection io.ReadWriteCloser) error {
+	defer connection.Close()
+	_, err := connection.Write([]byte("stop=1\n\n"))
+	if err != nil {
+		return err
+	}
+	response, err := readUAPIResponse(bufio.NewReader(connection))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(response) != "errno=0" {
+		return fmt.Errorf("Unable to stop tunnel: %s", strings.TrimSpace(response))
+	}
+	return nil
+}
+
+// readUAPIResponse reads lines up to the blank line that ends every response.
+func readUAPIResponse(reader *bufio.Reader) (string, error) {
+	var response strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == "\n" {
+			return response.String(), nil
+		}
+		response.WriteString(line)
+	}
+}
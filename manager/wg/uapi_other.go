@@ -0,0 +1,26 @@
+// +build !windows
+
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"io"
+	"net"
+	"os"
+	"syscall"
+)
+
+const serviceExecutable = "wireguard-service"
+
+// connectPipe opens one of the sockets that the tunnel service listens on.
+func connectPipe(name string) (io.ReadWriteCloser, error) {
+	return net.Dial("unix", "/var/run/wireguard/"+name+".sock")
+}
+
+func stopProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
+}
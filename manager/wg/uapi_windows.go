@@ -0,0 +1,22 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"io"
+	"os"
+)
+
+const serviceExecutable = "wireguard-service.exe"
+
+// connectPipe opens one of the named pipes that the tunnel service listens on.
+func connectPipe(name string) (io.ReadWriteCloser, error) {
+	return os.OpenFile(`\\.\pipe\WireGuard\`+name, os.O_RDWR, 0)
+}
+
+func stopProcess(process *os.Process) error {
+	return process.Kill()
+}
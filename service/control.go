@@ -0,0 +1,83 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+)
+
+// The protocol follows the UAPI: a request is a block of key=value lines
+// ended by a blank line, currently always "stop=1". The response, an errno
+// line and a blank line, is only sent once the service has shut down and run
+// its Down hooks, so that the client knows they are done.
+
+// startControl accepts requests to stop the service, handing the connection
+// of the first one to the returned channel, until the returned function is
+// called. The caller answers it with finishStop.
+func startControl(interfaceName string, logger *Logger) (<-chan net.Conn, func(), error) {
+	listener, err := UAPIListen(conf.TunnelName(interfaceName).ControlName())
+	if err != nil {
+		return nil, nil, err
+	}
+	stop := make(chan net.Conn, 1)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				if !readStopRequest(bufio.NewReader(conn)) {
+					io.WriteString(conn, "errno=22\n\n")
+					conn.Close()
+					return
+				}
+				select {
+				case stop <- conn:
+					logger.Info.Println("Stop requested")
+				default:
+					// Already stopping.
+					io.WriteString(conn, "errno=114\n\n")
+					conn.Close()
+				}
+			}()
+		}
+	}()
+
+	return stop, func() {
+		listener.Close()
+	}, nil
+}
+
+func readStopRequest(reader *bufio.Reader) bool {
+	valid := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return false
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if len(line) == 0 {
+			return valid
+		}
+		valid = line == "stop=1"
+	}
+}
+
+// finishStop tells the client that requested the stop, if any, that the service has shut down.
+func finishStop(conn net.Conn) {
+	if conn == nil {
+		return
+	}
+	io.WriteString(conn, "errno=0\n\n")
+	conn.Close()
+}
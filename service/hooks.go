@@ -0,0 +1,39 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+	"git.zx2c4.com/wireguard-windows/manager/hooks"
+)
+
+type tunnelHooks struct {
+	runner *hooks.Runner
+	config *conf.Config
+}
+
+func loadHooks(tunnelName conf.TunnelName, interfaceName string, logger *Logger) *tunnelHooks {
+	h := &tunnelHooks{runner: &hooks.Runner{Logger: logger.Info, Interface: interfaceName}}
+	config, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		logger.Debug.Println("No stored configuration, so no hooks:", err)
+		return h
+	}
+	h.config = config
+	h.runner.Policy, err = hooks.LoadDefaultPolicy()
+	if err != nil {
+		logger.Error.Println("Failed to load hook policy, so hooks are disabled:", err)
+		h.runner.Policy = &hooks.Policy{}
+	}
+	return h
+}
+
+func (h *tunnelHooks) run(event hooks.Event) error {
+	if h.config == nil {
+		return nil
+	}
+	return h.runner.Run(event, h.config)
+}
@@ -8,12 +8,15 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 
 	"git.zx2c4.com/wireguard-go/tun"
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+	"git.zx2c4.com/wireguard-windows/manager/hooks"
 )
 
 const (
@@ -21,13 +24,43 @@ const (
 	ExitSetupFailed  = 1
 )
 
+// TODO: read from encrypted wg-quick(8) file instead
+const phonyConfig = `private_key=e8aa5c6cd14ae2d2817222814f6463e99fec1c1ab1755fa9fa7b8d0390255862
+listen_port=0
+fwmark=0
+replace_peers=true
+public_key=25123c5dcd3328ff645e4f2a3fce0d754400d3887a0cb7c56f0267e20fbf3c5b
+endpoint=163.172.161.0:12912
+replace_allowed_ips=true
+allowed_ip=0.0.0.0/0`
+
+// initialConfig returns the stored configuration of the tunnel as a UAPI set
+// operation, or the phony configuration if there is none.
+func initialConfig(tunnelName conf.TunnelName, logger *Logger) string {
+	config, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		logger.Debug.Println("No stored configuration, so starting with the phony one:", err)
+		return phonyConfig
+	}
+	operation, err := config.ToUAPI()
+	if err != nil {
+		logger.Error.Println("Failed to convert the stored configuration, so starting with the phony one:", err)
+		return phonyConfig
+	}
+	return operation
+}
+
 func main() {
 	//TODO: ensure we're running as a service
 
 	if len(os.Args) != 2 {
 		os.Exit(ExitSetupFailed)
 	}
-	interfaceName := os.Args[1] //TODO: infer from file instead
+	tunnelName, err := conf.NewTunnelName(os.Args[1]) //TODO: infer from file instead
+	if err != nil {
+		os.Exit(ExitSetupFailed)
+	}
+	interfaceName := tunnelName.AdapterName()
 
 	logger := NewLogger(
 		LogLevelDebug,
@@ -36,11 +69,22 @@ func main() {
 	logger.Info.Println("Starting wireguard-go version", WireGuardGoVersion)
 	logger.Debug.Println("Debug log enabled")
 
+	if err := checkPolicy(tunnelName); err != nil {
+		logger.Error.Println(err)
+		os.Exit(ExitSetupFailed)
+	}
+
+	tunnelHooks := loadHooks(tunnelName, interfaceName, logger)
+	if err := tunnelHooks.run(hooks.PreUp); err != nil {
+		os.Exit(ExitSetupFailed)
+	}
+
 	tun, err := tun.CreateTUN(interfaceName)
 	if err == nil {
 		realInterfaceName, err2 := tun.Name()
 		if err2 == nil {
 			interfaceName = realInterfaceName
+			tunnelHooks.runner.Interface = interfaceName
 		}
 	} else {
 		logger.Error.Println("Failed to create TUN device:", err)
@@ -51,6 +95,11 @@ func main() {
 	device.Up()
 	logger.Info.Println("Device started")
 
+	// The initial configuration must be in place before the UAPI listener
+	// starts, or it would replace one that a client sets as soon as it can
+	// connect.
+	ipcSetOperation(device, bufio.NewReader(strings.NewReader(initialConfig(tunnelName, logger))))
+
 	uapi, err := UAPIListen(interfaceName)
 	if err != nil {
 		logger.Error.Println("Failed to listen on uapi socket:", err)
@@ -72,35 +121,56 @@ func main() {
 	}()
 	logger.Info.Println("UAPI listener started")
 
-	//TODO: read from encrypted wg-quick(8) file instead
-	phonyConfig := `private_key=e8aa5c6cd14ae2d2817222814f6463e99fec1c1ab1755fa9fa7b8d0390255862
-listen_port=0
-fwmark=0
-replace_peers=true
-public_key=25123c5dcd3328ff645e4f2a3fce0d754400d3887a0cb7c56f0267e20fbf3c5b
-endpoint=163.172.161.0:12912
-replace_allowed_ips=true
-allowed_ip=0.0.0.0/0`
-	ipcSetOperation(device, bufio.NewReader(strings.NewReader(phonyConfig)))
+	stopTransferHistory, err := startTransferHistory(device, interfaceName, logger)
+	if err != nil {
+		logger.Error.Println("Failed to start transfer history:", err)
+		os.Exit(ExitSetupFailed)
+	}
+
+	stopMetrics, err := startMetrics(device, interfaceName, logger)
+	if err != nil {
+		logger.Error.Println("Failed to start metrics listener:", err)
+		os.Exit(ExitSetupFailed)
+	}
 
 	//TODO: set address,routes,dns with winipcfg module
 
+	if err := tunnelHooks.run(hooks.PostUp); err != nil {
+		device.Close()
+		os.Exit(ExitSetupFailed)
+	}
+
+	stop, stopControl, err := startControl(interfaceName, logger)
+	if err != nil {
+		logger.Error.Println("Failed to start control listener:", err)
+		device.Close()
+		os.Exit(ExitSetupFailed)
+	}
+
 	// wait for program to terminate
 
 	signal.Notify(term, os.Interrupt)
 	signal.Notify(term, os.Kill)
 	signal.Notify(term, syscall.SIGTERM)
 
+	var stopper net.Conn
 	select {
 	case <-term:
 	case <-errs:
 	case <-device.Wait():
+	case stopper = <-stop:
 	}
 
 	// clean up
 
+	tunnelHooks.run(hooks.PreDown)
+	stopControl()
+	stopMetrics()
+	stopTransferHistory()
 	uapi.Close()
 	device.Close()
+	tunnelHooks.run(hooks.PostDown)
+	finishStop(stopper)
 
 	logger.Info.Println("Shutting down")
 }
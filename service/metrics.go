@@ -0,0 +1,43 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"net/http"
+	"os"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+	"git.zx2c4.com/wireguard-windows/manager/metrics"
+)
+
+// metricsAddressVariable names the environment variable holding the loopback
+// address to serve Prometheus metrics on. Metrics are off when it is unset.
+const metricsAddressVariable = "WIREGUARD_METRICS_ADDRESS"
+
+func startMetrics(device *Device, interfaceName string, logger *Logger) (func(), error) {
+	address := os.Getenv(metricsAddressVariable)
+	if len(address) == 0 {
+		return func() {}, nil
+	}
+	listener, err := metrics.ListenLocal(address)
+	if err != nil {
+		return nil, err
+	}
+	base := &conf.Config{Name: conf.TunnelName(interfaceName)}
+	server := &http.Server{Handler: metrics.Handler(nil, func() (*conf.Config, error) {
+		return deviceConfiguration(device, base)
+	})}
+	go func() {
+		err := server.Serve(listener)
+		if err != http.ErrServerClosed {
+			logger.Error.Println("Metrics listener failed:", err)
+		}
+	}()
+	logger.Info.Println("Serving metrics on", listener.Addr())
+	return func() {
+		server.Close()
+	}, nil
+}
@@ -0,0 +1,33 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"os"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+	"git.zx2c4.com/wireguard-windows/manager/policy"
+)
+
+// checkPolicy refuses to activate a stored configuration that the
+// administrator's policy does not allow. A policy that cannot be loaded
+// refuses everything, so that a broken file does not lift the restrictions,
+// and so does a stored configuration that cannot be loaded. Only a tunnel
+// without any stored configuration is let through unchecked.
+func checkPolicy(tunnelName conf.TunnelName) error {
+	config, err := conf.LoadFromName(tunnelName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	p, err := policy.LoadDefault()
+	if err != nil {
+		return err
+	}
+	return p.Check(config)
+}
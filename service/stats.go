@@ -0,0 +1,106 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"time"
+
+	"git.zx2c4.com/wireguard-windows/manager/conf"
+	"git.zx2c4.com/wireguard-windows/manager/stats"
+)
+
+// These environment variables hold durations, such as 10s or 72h, that
+// override how often the transfer history is sampled and how long it is kept.
+const (
+	historyResolutionVariable = "WIREGUARD_HISTORY_RESOLUTION"
+	historyRetentionVariable  = "WIREGUARD_HISTORY_RETENTION"
+)
+
+const (
+	defaultHistoryResolution = 5 * time.Second
+	defaultHistoryRetention  = 24 * time.Hour
+)
+
+func durationFromEnvironment(variable string, fallback time.Duration, logger *Logger) time.Duration {
+	value := os.Getenv(variable)
+	if len(value) == 0 {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		logger.Error.Printf("Invalid %s ‘%s’, so using %s", variable, value, fallback)
+		return fallback
+	}
+	return duration
+}
+
+// deviceConfiguration reads the state of device through the UAPI get
+// operation, completed with base as in conf.FromUAPI.
+func deviceConfiguration(device *Device, base *conf.Config) (*conf.Config, error) {
+	var buffer bytes.Buffer
+	writer := bufio.NewWriter(&buffer)
+	if err := ipcGetOperation(device, writer); err != nil {
+		return nil, err
+	}
+	writer.Flush()
+	return conf.FromUAPI(buffer.String(), base)
+}
+
+// startTransferHistory samples the peer counters of device into a history
+// and serves it to the manager, until the returned function is called.
+func startTransferHistory(device *Device, interfaceName string, logger *Logger) (func(), error) {
+	history := stats.NewHistory(
+		durationFromEnvironment(historyResolutionVariable, defaultHistoryResolution, logger),
+		durationFromEnvironment(historyRetentionVariable, defaultHistoryRetention, logger),
+	)
+	listener, err := UAPIListen(stats.ListenerName(conf.TunnelName(interfaceName)))
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(history.Resolution())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				config, err := deviceConfiguration(device, nil)
+				if err != nil {
+					logger.Error.Println("Unable to read transfer statistics:", err)
+					continue
+				}
+				history.Record(now, config.Peers)
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				err := history.ServeQuery(conn)
+				if err != nil {
+					logger.Debug.Println("Transfer history query failed:", err)
+				}
+			}()
+		}
+	}()
+
+	return func() {
+		close(done)
+		listener.Close()
+	}, nil
+}